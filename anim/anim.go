@@ -12,7 +12,9 @@ type AnimationSystem struct {
 
 	// tables
 	st *gfx.SpriteTable
+	tm *gfx.TileMapTable
 	xf *gfx.TransformTable
+	et *gfx.ColorEffectTable
 }
 
 func NewAnimationSystem() *AnimationSystem {
@@ -29,8 +31,12 @@ func (as *AnimationSystem) RequireTable(tables []interface{}) {
 		switch table := t.(type) {
 		case *gfx.SpriteTable:
 			as.st = table
+		case *gfx.TileMapTable:
+			as.tm = table
 		case *gfx.TransformTable:
 			as.xf = table
+		case *gfx.ColorEffectTable:
+			as.et = table
 		}
 	}
 }
@@ -38,6 +44,11 @@ func (as *AnimationSystem) RequireTable(tables []interface{}) {
 func (as *AnimationSystem) Update(dt float32) {
 	as.SpriteEngine.Update(dt)
 	as.TweenEngine.Update(dt)
+
+	// animated tiles
+	if as.tm != nil {
+		as.tm.Animate(dt)
+	}
 }
 
 // set shortcut
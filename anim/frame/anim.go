@@ -1,6 +1,7 @@
 package frame
 
 import (
+	"sckorok/engi"
 	"sckorok/gfx"
 )
 
@@ -11,12 +12,33 @@ type Animation struct {
 	Name       string
 	Start, Len int
 	Loop       bool
+
+	// events fired when the frames are entered
+	Events []FrameEvent
+}
+
+// FrameEvent is fired when the animation enters the frame, the frame is the
+// index in the animation. Events of the same frame are fired in the order of
+// definition.
+type FrameEvent struct {
+	Frame int
+	Name  string
+}
+
+// a callback waiting for dispatch
+type dispatch struct {
+	entity engi.Entity
+	name   string
+	event  EventCallback
+	end    AnimCallback
 }
 
 // Sprite Animation System
 type SpriteEngine struct {
 	// raw frames
 	frames []gfx.Tex2D
+	// duration of raw frames in seconds, zero uses the rate of FlipbookComp
+	durations []float32
 	// raw animation
 	data []Animation
 	// mapping from name to index
@@ -25,6 +47,9 @@ type SpriteEngine struct {
 	// sprite and animate table
 	st *gfx.SpriteTable
 	at *FlipbookTable
+
+	// callbacks of current update
+	queue []dispatch
 }
 
 func NewEngine() *SpriteEngine {
@@ -53,12 +78,52 @@ func (eng *SpriteEngine) NewAnimation(name string, frames []gfx.Tex2D, loop bool
 	// copy frames
 	start, size := len(eng.frames), len(frames)
 	eng.frames = append(eng.frames, frames...)
+	eng.durations = append(eng.durations, make([]float32, size)...)
 	// new animation
-	eng.data = append(eng.data, Animation{name, start, size, loop})
+	eng.data = append(eng.data, Animation{Name: name, Start: start, Len: size, Loop: loop})
 	// keep mapping
 	eng.names[name] = len(eng.data) - 1
 }
 
+// Clip is a named frame sequence, usually loaded from a sprite sheet.
+// Durations are the time of each frame in seconds, nil or zero uses the rate
+// of FlipbookComp.
+type Clip struct {
+	Name      string
+	Frames    []gfx.Tex2D
+	Durations []float32
+	Loop      bool
+	Events    []FrameEvent
+}
+
+// NewClips creates an animation for each clip.
+func (eng *SpriteEngine) NewClips(clips []Clip) {
+	for _, c := range clips {
+		start := len(eng.frames)
+		eng.NewAnimation(c.Name, c.Frames, c.Loop)
+		copy(eng.durations[start:], c.Durations)
+		for _, e := range c.Events {
+			eng.AddEvent(c.Name, e.Frame, e.Name)
+		}
+	}
+}
+
+// AddEvent adds an event on the frame of animation, it's fired to the
+// FlipbookComp.OnEvent callback when the frame is entered. Returns false if
+// the animation or frame doesn't exist.
+func (eng *SpriteEngine) AddEvent(anim string, frame int, event string) bool {
+	ii, ok := eng.names[anim]
+	if !ok {
+		return false
+	}
+	data := &eng.data[ii]
+	if frame < 0 || frame >= data.Len {
+		return false
+	}
+	data.Events = append(data.Events, FrameEvent{frame, event})
+	return true
+}
+
 // 返回动画定义 - 好像并没有太大的意义
 func (eng *SpriteEngine) Animation(name string) (anim *Animation, seq []gfx.Tex2D) {
 	if ii, ok := eng.names[name]; ok {
@@ -68,6 +133,10 @@ func (eng *SpriteEngine) Animation(name string) (anim *Animation, seq []gfx.Tex2
 	return
 }
 
+// Update advances the FlipbookComps. The callbacks are dispatched after all
+// the comps are updated, in the order of the table. Callbacks of a comp are
+// dispatched in the order they happen: the loop, the events of the entered
+// frame, and the completion.
 func (eng *SpriteEngine) Update(dt float32) {
 	var (
 		at, st = eng.at, eng.st
@@ -81,8 +150,16 @@ func (eng *SpriteEngine) Update(dt float32) {
 				id   = eng.names[am.define]
 				data = eng.data[id]
 			)
+			if am.enter {
+				am.enter = false
+				eng.enterFrame(am, &data)
+			}
 			am.gfi = data.Start + int(am.frameIndex)
-			if am.dt += dt; am.dt > am.rate {
+			rate := am.rate
+			if d := eng.durations[am.gfi]; d > 0 {
+				rate = d
+			}
+			if am.dt += dt; am.dt > rate {
 				am.ii = am.ii + 1
 				am.dt = 0
 				frame := am.ii % data.Len
@@ -96,8 +173,10 @@ func (eng *SpriteEngine) Update(dt float32) {
 					}
 					if !am.loop {
 						am.running = false
-						break
+						eng.post(am.Entity, am.define, nil, am.onComplete)
+						continue
 					}
+					eng.post(am.Entity, am.define, nil, am.onLoop)
 				}
 
 				if am.reverse {
@@ -107,6 +186,7 @@ func (eng *SpriteEngine) Update(dt float32) {
 				am.lastFrameIndex = am.frameIndex
 				am.frameIndex = uint16(frame)
 				am.gfi = data.Start + frame
+				eng.enterFrame(am, &data)
 			}
 
 			// update sprite-component
@@ -115,4 +195,34 @@ func (eng *SpriteEngine) Update(dt float32) {
 			comp.SetSprite(frame)
 		}
 	}
+
+	// dispatch callbacks, they may change the tables
+	queue := eng.queue
+	eng.queue = eng.queue[:0]
+	for i, d := range queue {
+		if d.event != nil {
+			d.event(d.entity, d.name)
+		} else {
+			d.end(d.entity, d.name)
+		}
+		queue[i] = dispatch{}
+	}
+}
+
+// enterFrame posts the events of current frame.
+func (eng *SpriteEngine) enterFrame(am *FlipbookComp, data *Animation) {
+	if am.onEvent == nil {
+		return
+	}
+	for _, e := range data.Events {
+		if e.Frame == int(am.frameIndex) {
+			eng.post(am.Entity, e.Name, am.onEvent, nil)
+		}
+	}
+}
+
+func (eng *SpriteEngine) post(entity engi.Entity, name string, event EventCallback, end AnimCallback) {
+	if event != nil || end != nil {
+		eng.queue = append(eng.queue, dispatch{entity, name, event, end})
+	}
 }
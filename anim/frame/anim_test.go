@@ -0,0 +1,70 @@
+package frame
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"sckorok/engi"
+	"sckorok/gfx"
+)
+
+type testTex struct{}
+
+func (testTex) Tex() uint16        { return 1 }
+func (testTex) Region() gfx.Region { return gfx.Region{X2: 1, Y2: 1} }
+func (testTex) Size() gfx.Size     { return gfx.Size{Width: 1, Height: 1} }
+
+func TestFlipbookEvents(t *testing.T) {
+	em := engi.NewEntityManager()
+	st, at := gfx.NewSpriteTable(4), NewFlipbookTable(4)
+	eng := NewEngine()
+	eng.RequireTable([]interface{}{st, at})
+
+	frames := make([]gfx.Tex2D, 3)
+	for i := range frames {
+		frames[i] = testTex{}
+	}
+	eng.NewClips([]Clip{{Name: "walk", Frames: frames, Loop: true, Events: []FrameEvent{{0, "start"}, {2, "step"}}}})
+	eng.NewAnimation("die", frames, false)
+	if !eng.AddEvent("die", 1, "fall") || eng.AddEvent("die", 3, "none") || eng.AddEvent("jump", 0, "none") {
+		t.Fatal("event should be added to the existing frame")
+	}
+
+	var log []string
+	event := func(e engi.Entity, name string) { log = append(log, fmt.Sprint(e, ":", name)) }
+	end := func(kind string) AnimCallback {
+		return func(e engi.Entity, anim string) { log = append(log, fmt.Sprint(e, ":", kind, ":", anim)) }
+	}
+
+	walker, dier := em.New(), em.New()
+	for _, e := range []engi.Entity{walker, dier} {
+		st.NewComp(e)
+		fb := at.NewComp(e)
+		fb.SetRate(.1)
+		fb.OnEvent(event)
+		fb.OnLoop(end("loop"))
+		fb.OnComplete(end("complete"))
+	}
+	at.Comp(walker).SetLoop(true, Restart)
+	at.Comp(walker).Play("walk")
+	at.Comp(dier).Play("die")
+
+	for i := 0; i < 4; i++ {
+		eng.Update(.11)
+	}
+	expect := []string{
+		fmt.Sprint(walker, ":start"),
+		fmt.Sprint(dier, ":fall"),
+		fmt.Sprint(walker, ":step"),
+		fmt.Sprint(walker, ":loop:walk"),
+		fmt.Sprint(walker, ":start"),
+		fmt.Sprint(dier, ":complete:die"),
+	}
+	if !reflect.DeepEqual(log, expect) {
+		t.Error("callbacks should be dispatched in order:", log)
+	}
+	if at.Comp(dier).Running() || !at.Comp(walker).Running() {
+		t.Error("only the non-looping animation should complete")
+	}
+}
@@ -26,8 +26,22 @@ type FlipbookComp struct {
 	gfi                        int
 	typ                        LoopType
 	reverse                    bool
+
+	// the first frame is entered but not dispatched
+	enter bool
+
+	// callbacks, dispatched by SpriteEngine after the update
+	onEvent    EventCallback
+	onComplete AnimCallback
+	onLoop     AnimCallback
 }
 
+// EventCallback is called when the animation enters a frame with an event.
+type EventCallback func(e engi.Entity, event string)
+
+// AnimCallback is called when the animation completes or loops.
+type AnimCallback func(e engi.Entity, anim string)
+
 func (fb *FlipbookComp) Play(name string) {
 	fb.define = name
 	fb.running = true
@@ -35,6 +49,7 @@ func (fb *FlipbookComp) Play(name string) {
 	fb.frameIndex = 0
 	fb.lastFrameIndex = 0
 	fb.dt, fb.ii = 0, 0
+	fb.enter = true
 }
 
 func (fb *FlipbookComp) Resume() {
@@ -77,6 +92,23 @@ func (fb *FlipbookComp) FrameIndex() (frame, lastFrame uint16) {
 	return fb.frameIndex, fb.lastFrameIndex
 }
 
+// OnEvent sets the callback of frame events, see Animation.Events.
+func (fb *FlipbookComp) OnEvent(fn EventCallback) {
+	fb.onEvent = fn
+}
+
+// OnComplete sets the callback called when a non-looping animation reaches
+// the end, Stop doesn't trigger it.
+func (fb *FlipbookComp) OnComplete(fn AnimCallback) {
+	fb.onComplete = fn
+}
+
+// OnLoop sets the callback called each time a looping animation restarts or
+// turns around.
+func (fb *FlipbookComp) OnLoop(fn AnimCallback) {
+	fb.onLoop = fn
+}
+
 // Sprite Animation Table
 type FlipbookTable struct {
 	comps      []FlipbookComp
@@ -132,9 +164,9 @@ func (t *FlipbookTable) Delete(entity engi.Entity) {
 			tComp := &t.comps[tail]
 			ei := tComp.Entity.Index()
 			t._map[ei] = v
-			tComp.Entity = 0
+			*tComp = FlipbookComp{}
 		} else {
-			t.comps[tail].Entity = 0
+			t.comps[tail] = FlipbookComp{}
 		}
 
 		t.index -= 1
@@ -42,6 +42,15 @@ func ColorLerp(from, to gfx.Color, f float32) gfx.Color {
 	return c
 }
 
+func ColorMatrixLerp(from, to gfx.ColorMatrix, f float32) (m gfx.ColorMatrix) {
+	for i := range m {
+		for j := range m[i] {
+			m[i][j] = F32Lerp(from[i][j], to[i][j], f)
+		}
+	}
+	return
+}
+
 // A float32 linear interpolation between a beginning and ending value.
 // It use the Animator as the input.
 type F32Tween struct {
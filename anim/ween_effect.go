@@ -0,0 +1,66 @@
+package anim
+
+import (
+	"sckorok/anim/ween"
+	"sckorok/engi"
+	"sckorok/gfx"
+)
+
+// Convenient methods that uses to animate the ColorEffect Component.
+
+// Flash the Entity with the additive color, the alpha of color is the strength.
+func Flash(e engi.Entity, from, to gfx.Color) *proxyAnimator {
+	return effectAnimator(e, func(ec *gfx.ColorEffectComp, f float32) {
+		ec.SetAdd(ween.ColorLerp(from, to, f))
+	})
+}
+
+// Grayscale the Entity by the amount in [0, 1].
+func Grayscale(e engi.Entity, from, to float32) *proxyAnimator {
+	return effectAnimator(e, func(ec *gfx.ColorEffectComp, f float32) {
+		ec.SetGrayscale(ween.F32Lerp(from, to, f))
+	})
+}
+
+// HueShift rotates the hue of Entity by the angle in radians.
+func HueShift(e engi.Entity, from, to float32) *proxyAnimator {
+	return effectAnimator(e, func(ec *gfx.ColorEffectComp, f float32) {
+		ec.SetHueShift(ween.F32Lerp(from, to, f))
+	})
+}
+
+// ColorMatrix animates the color matrix of Entity.
+func ColorMatrix(e engi.Entity, from, to gfx.ColorMatrix) *proxyAnimator {
+	return effectAnimator(e, func(ec *gfx.ColorEffectComp, f float32) {
+		ec.SetMatrix(ween.ColorMatrixLerp(from, to, f))
+	})
+}
+
+// Outline animates the outline width of Entity with the color.
+func Outline(e engi.Entity, c gfx.Color, from, to float32) *proxyAnimator {
+	return effectAnimator(e, func(ec *gfx.ColorEffectComp, f float32) {
+		ec.SetOutline(c, ween.F32Lerp(from, to, f))
+	})
+}
+
+// effectAnimator applies fn to the ColorEffectComp of Entity on update.
+func effectAnimator(e engi.Entity, fn func(ec *gfx.ColorEffectComp, f float32)) *proxyAnimator {
+	proxy := &proxyAnimator{Animator: tweenEngine.NewAnimator()}
+	proxy.Animator.OnUpdate(func(reverse bool, f float32) {
+		if et := animationSystem.et; et != nil {
+			if ec := et.Comp(e); ec != nil {
+				fn(ec, f)
+			}
+		}
+		if fn := proxy.update; fn != nil {
+			fn(reverse, f)
+		}
+	})
+	proxy.Animator.OnComplete(func(reverse bool) {
+		proxy.Dispose()
+		if fn := proxy.complete; fn != nil {
+			fn(reverse)
+		}
+	})
+	return proxy
+}
@@ -4,6 +4,8 @@ import (
 	"sckorok/anim/ween"
 	"sckorok/engi"
 	"sckorok/gfx"
+	"sckorok/math"
+	"sckorok/math/f32"
 )
 
 // Convenient methods that uses to animate the Sprite Component.
@@ -50,3 +52,40 @@ func Tint(e engi.Entity, from, to gfx.Color) *proxyAnimator {
 //	})
 //	return proxy
 //}
+
+// Skew the Entity by the angles in radians.
+func Skew(e engi.Entity, from, to f32.Vec2) *proxyAnimator {
+	return spriteAnimator(e, func(spr *gfx.SpriteComp, f float32) {
+		v := ween.Vec2Lerp(from, to, f)
+		spr.SetSkew(v[0], v[1])
+	})
+}
+
+// Wobble the Entity like a jelly, the skew swings the cycles and fades out
+// from the amplitude.
+func Wobble(e engi.Entity, amplitude f32.Vec2, cycles float32) *proxyAnimator {
+	return spriteAnimator(e, func(spr *gfx.SpriteComp, f float32) {
+		k := math.Sin(2*math.Pi*cycles*f) * (1 - f)
+		spr.SetSkew(amplitude[0]*k, amplitude[1]*k)
+	})
+}
+
+// spriteAnimator applies fn to the SpriteComp of Entity on update.
+func spriteAnimator(e engi.Entity, fn func(spr *gfx.SpriteComp, f float32)) *proxyAnimator {
+	proxy := &proxyAnimator{Animator: tweenEngine.NewAnimator()}
+	proxy.Animator.OnUpdate(func(reverse bool, f float32) {
+		if spr := animationSystem.st.Comp(e); spr != nil {
+			fn(spr, f)
+		}
+		if fn := proxy.update; fn != nil {
+			fn(reverse, f)
+		}
+	})
+	proxy.Animator.OnComplete(func(reverse bool) {
+		proxy.Dispose()
+		if fn := proxy.complete; fn != nil {
+			fn(reverse)
+		}
+	})
+	return proxy
+}
@@ -31,6 +31,7 @@ var Texture *TextureManager
 var Font *FontManager
 var PSConfig *ParticleConfigManager
 var Audio *AudioManager
+var TileMap *TileMapManager
 
 func init() {
 	Shader = &ShaderManager{}
@@ -38,4 +39,5 @@ func init() {
 	Texture = NewTextureManager()
 	Font = NewFontManager()
 	PSConfig = NewParticleConfigManager()
+	TileMap = NewTileMapManager()
 }
\ No newline at end of file
@@ -70,3 +70,348 @@ void main() {
     outputColor = texture(tex, outTexCoord) * outColor;
 }
 ` + "\x00"
+
+// distance field text shader
+
+var sdfVertex = bVertex
+
+var sdfColor = `
+#version 330
+
+uniform sampler2D tex;
+
+// msdf > 0.5 if the distance is stored in RGB
+uniform float msdf;
+// widths are in distance units, the edge of glyph is at 0.5
+uniform float outline;
+uniform vec4 outlineColor;
+uniform vec4 shadowOffset;
+uniform float shadowSoftness;
+uniform vec4 shadowColor;
+uniform float glow;
+uniform vec4 glowColor;
+
+in vec2 outTexCoord;
+in vec4 outColor;
+
+out vec4 outputColor;
+
+float field(vec2 uv) {
+    vec4 s = texture(tex, uv);
+    if (msdf > 0.5) {
+        return max(min(s.r, s.g), min(max(s.r, s.g), s.b));
+    }
+    return s.a;
+}
+
+void main() {
+    float d = field(outTexCoord);
+    float w = max(fwidth(d) * 0.5, 0.001);
+    float edge = 0.5 - outline;
+
+    vec4 fill = outColor * smoothstep(0.5 - w, 0.5 + w, d);
+    vec4 border = outlineColor * smoothstep(edge - w, edge + w, d);
+    vec4 text = fill + border * (1.0 - fill.a);
+
+    vec4 under = vec4(0.0);
+    if (glow > 0.0) {
+        under = glowColor * smoothstep(edge - glow, edge, d);
+    }
+    if (shadowColor.a > 0.0) {
+        float s = field(outTexCoord - shadowOffset.xy);
+        float soft = shadowSoftness + w;
+        under += shadowColor * smoothstep(edge - soft, edge + soft, s) * (1.0 - under.a);
+    }
+    outputColor = text + under * (1.0 - text.a);
+}
+` + "\x00"
+
+// stencil mask shader, transparent pixels are discarded
+
+var maskVertex = bVertex
+
+var maskColor = `
+#version 330
+
+uniform sampler2D tex;
+uniform float cutoff;
+
+in vec2 outTexCoord;
+in vec4 outColor;
+
+out vec4 outputColor;
+void main() {
+    if (texture(tex, outTexCoord).a < cutoff) {
+        discard;
+    }
+    outputColor = vec4(0.0);
+}
+` + "\x00"
+
+// lit batch shader, composites the light map, see gfx.LightRenderFeature
+
+var litVertex = `
+#version 330
+
+uniform mat4 proj;
+
+in vec4 xyuv;
+in vec4 rgba;
+
+out vec4 outColor;
+out vec2 outTexCoord;
+out vec2 outScreen;
+
+void main() {
+    outColor = rgba;
+	outTexCoord = xyuv.zw;
+    gl_Position = proj * vec4(xyuv.xy, 1, 1);
+    outScreen = gl_Position.xy * 0.5 + 0.5;
+}
+` + "\x00"
+
+var litColor = `
+#version 330
+
+uniform sampler2D tex;
+// half of the light, the lights brighten up to 2 times
+uniform sampler2D lightMap;
+
+in vec2 outTexCoord;
+in vec4 outColor;
+in vec2 outScreen;
+
+out vec4 outputColor;
+
+void main() {
+    vec4 c = texture(tex, outTexCoord) * outColor;
+    outputColor = vec4(c.rgb * texture(lightMap, outScreen).rgb * 2.0, c.a);
+}
+` + "\x00"
+
+// light shader, draws a light into the light map
+
+var lightVertex = `
+#version 330
+
+uniform mat4 proj;
+
+in vec4 xyuv;
+in vec4 rgba;
+
+out vec4 outColor;
+out vec2 outPosition;
+out vec2 outScreen;
+
+void main() {
+    outColor = rgba;
+	outPosition = xyuv.xy;
+    gl_Position = proj * vec4(xyuv.xy, 1, 1);
+    outScreen = gl_Position.xy * 0.5 + 0.5;
+}
+` + "\x00"
+
+var lightColor = `
+#version 330
+
+#define MAX_EDGES 64
+
+// normal buffer
+uniform sampler2D tex;
+// occluder edges, a row per light
+uniform sampler2D edges;
+
+uniform vec4 light[4];
+// origin, scale and number of the edges
+uniform vec4 occluders;
+
+in vec4 outColor;
+in vec2 outPosition;
+in vec2 outScreen;
+
+out vec4 outputColor;
+
+// decodes a point of 16 bits fixed point
+vec2 decode(vec4 c) {
+    return (c.rb * 65280.0 + c.ga * 255.0) / 65535.0 * 2.0 - 1.0;
+}
+
+// 0 if the segment p-q crosses an edge
+float visible(vec2 p, vec2 q) {
+    for (int i = 0; i < MAX_EDGES; i++) {
+        if (float(i) >= occluders.w) {
+            break;
+        }
+        float u = (float(i) * 2.0 + 0.5) / float(MAX_EDGES * 2);
+        vec2 a = decode(texture(edges, vec2(u, light[3].w)));
+        vec2 s = decode(texture(edges, vec2(u + 0.5 / float(MAX_EDGES), light[3].w))) - a;
+        vec2 r = q - p;
+        float d = r.x * s.y - r.y * s.x;
+        if (abs(d) < 1e-6) {
+            continue;
+        }
+        vec2 ap = a - p;
+        float t = (ap.x * s.y - ap.y * s.x) / d;
+        float v = (ap.x * r.y - ap.y * r.x) / d;
+        if (t > 0.0 && t < 1.0 && v >= 0.0 && v <= 1.0) {
+            return 0.0;
+        }
+    }
+    return 1.0;
+}
+
+// soft shadow, the light is a disc of radius <size>
+float shadow(vec2 src, vec2 p, float size) {
+    // to the space of edges
+    src = (src - occluders.xy) / occluders.z;
+    p = (p - occluders.xy) / occluders.z;
+    size = size / occluders.z;
+    if (size <= 0.0) {
+        return visible(src, p);
+    }
+    vec2 dir = normalize(p - src + vec2(1e-4, 0.0));
+    vec2 side = vec2(-dir.y, dir.x) * size;
+    return (visible(src, p) +
+        visible(src + side * 0.5, p) + visible(src - side * 0.5, p) +
+        visible(src + side, p) + visible(src - side, p)) / 5.0;
+}
+
+void main() {
+    vec4 pos = light[0];
+    vec4 color = light[1];
+    vec4 dir = light[2];
+    vec4 opt = light[3];
+    if (pos.w > 2.5) {
+        // ambient
+        outputColor = vec4(color.rgb * 0.5, 1.0) * outColor;
+        return;
+    }
+    vec3 n = normalize(texture(tex, outScreen).rgb * 2.0 - 1.0);
+    vec2 p = outPosition;
+
+    vec3 l;
+    float att = 1.0;
+    if (pos.w > 1.5) {
+        // directional
+        l = normalize(vec3(-dir.xy, pos.z));
+        if (opt.x > 0.5) {
+            att *= shadow(p - dir.xy * color.w, p, dir.w);
+        }
+    } else {
+        vec2 d = pos.xy - p;
+        att = pow(clamp(1.0 - length(d) / color.w, 0.0, 1.0), opt.y);
+        if (pos.w > 0.5) {
+            // spot
+            float c = dot(normalize(-d + vec2(1e-4, 0.0)), dir.xy);
+            att *= smoothstep(dir.z, dir.z + opt.z, c);
+        }
+        l = normalize(vec3(d, pos.z));
+        if (opt.x > 0.5 && att > 0.0) {
+            att *= shadow(pos.xy, p, dir.w);
+        }
+    }
+    outputColor = vec4(color.rgb * att * max(dot(n, l), 0.0) * 0.5, 1.0) * outColor;
+}
+` + "\x00"
+
+// normal shader, draws the normal maps of lit sprites into the normal buffer
+
+var litNormalVertex = bVertex
+
+var litNormalColor = `
+#version 330
+
+uniform sampler2D tex;
+uniform sampler2D normalMap;
+
+in vec2 outTexCoord;
+in vec4 outColor;
+
+out vec4 outputColor;
+
+void main() {
+    float a = texture(tex, outTexCoord).a * outColor.a;
+    outputColor = vec4(texture(normalMap, outTexCoord).rgb * a, a);
+}
+` + "\x00"
+
+// color effect batch shader, see gfx.ColorEffectRenderFeature
+
+var effectVertex = `
+#version 330
+
+uniform mat4 proj;
+
+in vec4 xyuv;
+in vec4 rgba;
+in vec4 cmr;
+in vec4 cmg;
+in vec4 cmb;
+in vec4 add;
+in vec4 outline;
+in vec2 offset;
+
+out vec4 outColor;
+out vec2 outTexCoord;
+out vec4 outR;
+out vec4 outG;
+out vec4 outB;
+out vec4 outAdd;
+out vec4 outOutline;
+out vec2 outOffset;
+
+void main() {
+    outColor = rgba;
+	outTexCoord = xyuv.zw;
+	outR = cmr;
+	outG = cmg;
+	outB = cmb;
+	outAdd = add;
+	outOutline = outline;
+	outOffset = offset;
+    gl_Position = proj * vec4(xyuv.xy, 1, 1);
+}
+` + "\x00"
+
+var effectColor = `
+#version 330
+
+uniform sampler2D tex;
+
+in vec2 outTexCoord;
+in vec4 outColor;
+in vec4 outR;
+in vec4 outG;
+in vec4 outB;
+in vec4 outAdd;
+in vec4 outOutline;
+in vec2 outOffset;
+
+out vec4 outputColor;
+
+// max alpha of the pixels around at the offset
+float around(vec2 uv, vec2 d) {
+    vec2 e = d * 0.7071;
+    float a = texture(tex, uv + vec2(d.x, 0.0)).a;
+    a = max(a, texture(tex, uv - vec2(d.x, 0.0)).a);
+    a = max(a, texture(tex, uv + vec2(0.0, d.y)).a);
+    a = max(a, texture(tex, uv - vec2(0.0, d.y)).a);
+    a = max(a, texture(tex, uv + e).a);
+    a = max(a, texture(tex, uv - e).a);
+    a = max(a, texture(tex, uv + vec2(e.x, -e.y)).a);
+    a = max(a, texture(tex, uv + vec2(-e.x, e.y)).a);
+    return a;
+}
+
+void main() {
+    vec4 c = texture(tex, outTexCoord) * outColor;
+    // the color is premultiplied, so the offsets are scaled by alpha
+    c.rgb = vec3(dot(outR, c), dot(outG, c), dot(outB, c));
+    c.rgb = clamp(c.rgb + outAdd.rgb * c.a, 0.0, c.a);
+    if (outOutline.a > 0.0) {
+        float a = around(outTexCoord, outOffset) * outColor.a;
+        c += vec4(outOutline.rgb * outOutline.a, outOutline.a) * a * (1.0 - c.a);
+    }
+    outputColor = c;
+}
+` + "\x00"
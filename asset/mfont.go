@@ -4,7 +4,9 @@ import (
 	"sckorok/gfx/font"
 
 	"fmt"
+	"io"
 	"log"
+	"path"
 
 	"sckorok/asset/res"
 )
@@ -74,6 +76,127 @@ func (fm *FontManager) LoadTrueType(name string, file string, lc font.TTFConfig)
 	fmt.Println("load true-type font sucess...", name)
 }
 
+// LoadDynamic loads a TrueType font which rasterizes glyphs on demand, so any
+// rune in the font can be rendered.
+func (fm *FontManager) LoadDynamic(name string, file string, dc font.DynamicConfig) {
+	var cnt int32 = 0
+	var fnt interface{}
+
+	if v, ok := fm.repo[name]; ok {
+		cnt = v.cnt
+		fnt = v.ref
+	} else {
+		fcr, err := res.Open(file)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		f, err := font.LoadDynamic(fcr, dc)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fnt = f
+	}
+
+	fm.repo[name] = refCount{fnt, cnt + 1}
+	fmt.Println("load dynamic font sucess...", name)
+}
+
+// LoadSDF loads a TrueType font and generates signed distance field of the
+// glyphs, the font is rendered crisp at any scale.
+func (fm *FontManager) LoadSDF(name string, file string, sc font.SDFConfig) {
+	var cnt int32 = 0
+	var fnt interface{}
+
+	if v, ok := fm.repo[name]; ok {
+		cnt = v.cnt
+		fnt = v.ref
+	} else {
+		fcr, err := res.Open(file)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		f, err := font.LoadSDF(fcr, sc)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fnt = f
+	}
+
+	fm.repo[name] = refCount{fnt, cnt + 1}
+	fmt.Println("load sdf font sucess...", name)
+}
+
+// LoadSDFBitmap loads a pre-baked (M)SDF atlas, spread is the distance range in
+// pixels used to bake the atlas.
+func (fm *FontManager) LoadSDFBitmap(name string, img, fc string, spread float32, msdf bool) {
+	var cnt int32 = 0
+	var fnt interface{}
+
+	if v, ok := fm.repo[name]; ok {
+		cnt = v.cnt
+		fnt = v.ref
+	} else {
+		ir, err := res.Open(img)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fcr, err := res.Open(fc)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		f, err := font.LoadSDFBitmap(ir, fcr, spread, msdf)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fnt = f
+	}
+
+	fm.repo[name] = refCount{fnt, cnt + 1}
+	fmt.Println("load sdf bitmap font sucess...", name)
+}
+
+// LoadBMFont loads an AngelCode BMFont (text, XML or binary .fnt file), the
+// page images are loaded from the directory of the .fnt file.
+func (fm *FontManager) LoadBMFont(name string, file string) {
+	var cnt int32 = 0
+	var fnt interface{}
+
+	if v, ok := fm.repo[name]; ok {
+		cnt = v.cnt
+		fnt = v.ref
+	} else {
+		fcr, err := res.Open(file)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer fcr.Close()
+
+		dir := path.Dir(file)
+		f, err := font.LoadBMFont(fcr, func(page string) (io.ReadCloser, error) {
+			return res.Open(path.Join(dir, page))
+		})
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fnt = f
+	}
+
+	fm.repo[name] = refCount{fnt, cnt + 1}
+	fmt.Println("load bmfont sucess...", name)
+}
+
 func (fm *FontManager) Unload(name string) {
 	if v, ok := fm.repo[name]; ok {
 		if v.cnt > 1 {
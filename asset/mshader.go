@@ -9,6 +9,18 @@ func (sm *ShaderManager) GetShaderStr(key string) (string, string) {
 		return vertex, color
 	case "batch":
 		return bVertex, bColor
+	case "sdf":
+		return sdfVertex, sdfColor
+	case "mask":
+		return maskVertex, maskColor
+	case "lit":
+		return litVertex, litColor
+	case "light":
+		return lightVertex, lightColor
+	case "litNormal":
+		return litNormalVertex, litNormalColor
+	case "effect":
+		return effectVertex, effectColor
 	}
 	return "", ""
 }
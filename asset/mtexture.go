@@ -1,11 +1,11 @@
 package asset
 
 import (
+	"sckorok/anim/frame"
 	"sckorok/asset/res"
 	"sckorok/gfx"
 	"sckorok/gfx/bk"
 
-	"encoding/json"
 	"errors"
 	"fmt"
 	"image"
@@ -16,24 +16,33 @@ import (
 type TextureManager struct {
 	repo  map[string]idCount
 	names map[string]uint32
+
+	// animations of sprite sheets
+	clips map[string][]frame.Clip
 }
 
 func NewTextureManager() *TextureManager {
 	return &TextureManager{
 		make(map[string]idCount),
 		make(map[string]uint32),
+		make(map[string][]frame.Clip),
 	}
 }
 
-// Load loads a single Texture file.
-func (tm *TextureManager) Load(file string) {
+// Load loads a single Texture file, the optional TextureOptions sets the
+// filter, wrap mode and mipmaps. PNG, JPEG, BMP and WebP are supported.
+func (tm *TextureManager) Load(file string, opt ...bk.TextureOptions) {
 	var rid, cnt uint16
 	if v, ok := tm.repo[file]; ok {
 		cnt = v.cnt
 		rid = v.rid
 	} else {
+		var options bk.TextureOptions
+		if len(opt) > 0 {
+			options = opt[0]
+		}
 		// create bk.Texture2D
-		id, err := tm.loadTexture(file)
+		id, err := tm.loadTexture(file, options)
 		if err != nil {
 			log.Println(err)
 		}
@@ -49,6 +58,7 @@ func (tm *TextureManager) Unload(file string) {
 			tm.repo[file] = idCount{v.rid, v.cnt - 1}
 		} else {
 			delete(tm.repo, file)
+			delete(tm.clips, file)
 			bk.R.Free(v.rid)
 			// maybe it's a atlas, try to delete
 			gfx.R.Delete(file)
@@ -60,6 +70,11 @@ func (tm *TextureManager) Unload(file string) {
 
 // LoadAtlas loads the atlas with a description file.
 // The SubTexture can be found by SubTexture's name.
+//
+// The format of description is detected from the content: TexturePacker
+// JSON-array or JSON-hash, Starling/Sparrow XML, Aseprite JSON or libGDX atlas.
+// The trims and pivots are kept in the SubTextures, the frame tags of Aseprite
+// can be found by Clips.
 func (tm *TextureManager) LoadAtlas(file, desc string) {
 	var rid, cnt uint16
 	if v, ok := tm.repo[file]; ok {
@@ -71,14 +86,23 @@ func (tm *TextureManager) LoadAtlas(file, desc string) {
 			log.Println(err)
 			return
 		}
-		size := len(data.Frames)
+		size := len(data.frames)
 
 		// new atlas
 		at := gfx.R.NewAtlas(id, size, file)
 
 		// fill
-		for _, f := range data.Frames {
-			at.AddItem(float32(f.Frame.X), float32(f.Frame.Y), float32(f.Frame.W), float32(f.Frame.H), f.Filename, f.Rotated)
+		for _, f := range data.frames {
+			at.AddItem(f.x, f.y, f.w, f.h, f.name, f.rotated)
+			if f.trimmed {
+				at.SetTrim(f.trim)
+			}
+			if f.hasPivot {
+				at.SetPivot(f.pivot[0], f.pivot[1])
+			}
+		}
+		if clips := data.clips(at); len(clips) > 0 {
+			tm.clips[file] = clips
 		}
 		rid = id
 	}
@@ -92,7 +116,7 @@ func (tm *TextureManager) LoadAtlasIndexed(file string, width, height float32, r
 		cnt = v.cnt
 		rid = v.rid
 	} else {
-		id, err := tm.loadTexture(file)
+		id, err := tm.loadTexture(file, bk.TextureOptions{})
 		if err != nil {
 			log.Println(err)
 		}
@@ -111,6 +135,31 @@ func (tm *TextureManager) LoadAtlasIndexed(file string, width, height float32, r
 	tm.repo[file] = idCount{rid, cnt + 1}
 }
 
+// LoadPacked loads the images and packs them into the shared pages of the
+// packer, the SubTexture can be found by Packer.Get with the file name. Use it
+// for many small images, they are drawn in one batch and use few textures.
+func (tm *TextureManager) LoadPacked(p *gfx.Packer, files ...string) {
+	for _, file := range files {
+		if _, ok := p.Get(file); ok {
+			continue
+		}
+		img, err := tm.decode(file)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if _, err := p.Add(file, img); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// Clips returns the animations of the atlas, they are the frame tags of an
+// Aseprite sheet. Use SpriteEngine.NewClips to play them with FlipbookComp.
+func (tm *TextureManager) Clips(file string) []frame.Clip {
+	return tm.clips[file]
+}
+
 // Get returns the low-level Texture.
 func (tm *TextureManager) Get(file string) gfx.Tex2D {
 	rid := tm.repo[file]
@@ -136,28 +185,33 @@ func (tm *TextureManager) Atlas(file string) (at *gfx.Atlas, ok bool) {
 	return
 }
 
-func (tm *TextureManager) loadTexture(file string) (uint16, error) {
+func (tm *TextureManager) loadTexture(file string, opt bk.TextureOptions) (uint16, error) {
 	log.Println("load file:" + file)
-	// 1. load file
-	imgFile, err := res.Open(file)
-	if err != nil {
-		return bk.InvalidId, fmt.Errorf("texture %q not found: %v", file, err)
-	}
-	// 2. decode image
-	img, _, err := image.Decode(imgFile)
+	// 1. load file & decode image
+	img, err := tm.decode(file)
 	if err != nil {
 		return bk.InvalidId, err
 	}
-	// 3. create raw texture
-	if id, _ := bk.R.AllocTexture(img); id != bk.InvalidId {
+	// 2. create raw texture
+	if id, _ := bk.R.AllocTextureOptions(img, opt); id != bk.InvalidId {
 		return id, nil
 	}
 	return bk.InvalidId, errors.New("fail to load texture")
 }
 
+func (tm *TextureManager) decode(file string) (image.Image, error) {
+	imgFile, err := res.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("texture %q not found: %v", file, err)
+	}
+	defer imgFile.Close()
+	img, _, err := image.Decode(imgFile)
+	return img, err
+}
+
 // 加载纹理图集
-func (tm *TextureManager) loadAtlas(img, desc string) (id uint16, at *atlas, e error) {
-	id, err := tm.loadTexture(img)
+func (tm *TextureManager) loadAtlas(img, desc string) (id uint16, at *sheet, e error) {
+	id, err := tm.loadTexture(img, bk.TextureOptions{})
 	if err != nil {
 		e = err
 		return
@@ -174,31 +228,6 @@ func (tm *TextureManager) loadAtlas(img, desc string) (id uint16, at *atlas, e e
 		e = err
 		return
 	}
-	at = &atlas{}
-	e = json.Unmarshal(d, at)
+	at, e = parseSheet(d, img)
 	return
 }
-
-// Field int `json:"myName"`
-// The file format is TexturePacker's generic json-array format.
-// TexturePacker: https://www.codeandweb.com/texturepacker
-type atlas struct {
-	Meta struct {
-		App     string `json:"app"`
-		Version string `json:"version"`
-		Image   string `json:"image"`
-		Format  string `json:"format"`
-		Size    struct {
-			W, H int
-		} `json:"size"`
-		Scale float32 `json:"scale,string"`
-	} `json:"meta"`
-
-	Frames []struct {
-		Filename string                   `json:"filename"`
-		Frame    struct{ X, Y, W, H int } `json:"frame"`
-		Rotated  bool                     `json:"rotated"`
-		Trimmed  bool                     `json:"trimmed"`
-		Pivot    struct{ X, Y float32 }   `json:"pivot"`
-	} `json:"frames"`
-}
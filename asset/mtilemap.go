@@ -0,0 +1,99 @@
+package asset
+
+import (
+	"sckorok/asset/res"
+	"sckorok/gfx"
+	"sckorok/gfx/tiled"
+
+	"io"
+	"log"
+	"path"
+	"strings"
+)
+
+// TileMapManager manages the map files created by Tiled, both
+// TMX(.tmx) and JSON(.json/.tmj) format are supported.
+// Images of tilesets are loaded by the TextureManager.
+type TileMapManager struct {
+	repo map[string]refCount
+}
+
+func NewTileMapManager() *TileMapManager {
+	return &TileMapManager{
+		repo: make(map[string]refCount),
+	}
+}
+
+func (tmm *TileMapManager) Load(file string) {
+	if rc, ok := tmm.repo[file]; ok {
+		tmm.repo[file] = refCount{rc.ref, rc.cnt + 1}
+	} else {
+		ref, err := tmm.load(file)
+		if err != nil {
+			log.Println(err)
+		} else {
+			tmm.repo[file] = refCount{ref, 1}
+		}
+	}
+}
+
+// Unload deletes the map and the textures of it's tilesets.
+func (tmm *TileMapManager) Unload(file string) {
+	if rc, ok := tmm.repo[file]; ok {
+		if rc.cnt > 1 {
+			tmm.repo[file] = refCount{rc.ref, rc.cnt - 1}
+		} else {
+			delete(tmm.repo, file)
+			tm := rc.ref.(*gfx.TileMap)
+			for _, ts := range tm.TileSets {
+				if ts.Image != "" {
+					Texture.Unload(path.Join(path.Dir(file), ts.Image))
+				}
+			}
+		}
+	}
+}
+
+func (tmm *TileMapManager) Get(file string) (tm *gfx.TileMap, exist bool) {
+	if rc, ok := tmm.repo[file]; ok {
+		tm, exist = rc.ref.(*gfx.TileMap), ok
+	}
+	return
+}
+
+func (tmm *TileMapManager) load(file string) (tm *gfx.TileMap, err error) {
+	reader, err := res.Open(file)
+	if err != nil {
+		return
+	}
+	defer reader.Close()
+
+	// external files are related to the map file
+	dir := path.Dir(file)
+	open := func(name string) (io.ReadCloser, error) {
+		return res.Open(path.Join(dir, name))
+	}
+
+	var m *tiled.Map
+	if strings.HasSuffix(file, ".tmx") {
+		m, err = tiled.LoadTMX(reader, open)
+	} else {
+		m, err = tiled.LoadJSON(reader, open)
+	}
+	if err != nil {
+		return
+	}
+
+	// load tileset images
+	tm = &gfx.TileMap{Map: m, Textures: make([]gfx.Tex2D, len(m.TileSets))}
+	for i, ts := range m.TileSets {
+		if ts.Image == "" {
+			log.Printf("tilemap: tileset %q has no image", ts.Name)
+			continue
+		}
+		img := path.Join(dir, ts.Image)
+		Texture.Load(img)
+		tm.Textures[i] = Texture.Get(img)
+	}
+	return
+}
@@ -0,0 +1,333 @@
+package asset
+
+import (
+	"sckorok/anim/frame"
+	"sckorok/gfx"
+
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// sheet is the description of a sprite sheet, parsed from one of the formats:
+//
+//	TexturePacker JSON-array and JSON-hash
+//	Starling/Sparrow XML
+//	Aseprite JSON, the frame tags are animations
+//	libGDX atlas, both the legacy and the new format
+type sheet struct {
+	frames []sheetFrame
+	tags   []sheetTag
+}
+
+// sheetFrame is a sub-texture, X, Y, W, H is the rectangle in the texture
+// before rotated. The pivot uses the gravity of SpriteComp.
+type sheetFrame struct {
+	name       string
+	x, y, w, h float32
+	rotated    bool
+
+	trimmed bool
+	trim    gfx.Trim
+
+	pivot    [2]float32
+	hasPivot bool
+
+	// in seconds
+	duration float32
+}
+
+// sheetTag is a frame tag of Aseprite, frames are in [from, to].
+type sheetTag struct {
+	name      string
+	from, to  int
+	direction string
+}
+
+// clips turns the frame tags into animations of the atlas.
+func (sh *sheet) clips(at *gfx.Atlas) (clips []frame.Clip) {
+	for _, t := range sh.tags {
+		if t.from < 0 || t.to >= len(sh.frames) || t.from > t.to {
+			continue
+		}
+		var seq []int
+		for i := t.from; i <= t.to; i++ {
+			seq = append(seq, i)
+		}
+		switch t.direction {
+		case "reverse":
+			for i, j := 0, len(seq)-1; i < j; i, j = i+1, j-1 {
+				seq[i], seq[j] = seq[j], seq[i]
+			}
+		case "pingpong":
+			for i := t.to - 1; i > t.from; i-- {
+				seq = append(seq, i)
+			}
+		}
+		c := frame.Clip{Name: t.name, Loop: true}
+		for _, i := range seq {
+			tex, _ := at.GetByIndex(i)
+			c.Frames = append(c.Frames, tex)
+			c.Durations = append(c.Durations, sh.frames[i].duration)
+		}
+		clips = append(clips, c)
+	}
+	return
+}
+
+// parseSheet detects the format and parses the description. image is the
+// name of texture, it selects the page of a multi-page libGDX atlas.
+func parseSheet(data []byte, image string) (*sheet, error) {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return parseSheetJSON(data)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return parseSheetXML(data)
+	default:
+		return parseSheetGDX(data, image)
+	}
+}
+
+// TexturePacker and Aseprite share the JSON format, Aseprite adds duration
+// and frame tags.
+type jsonFrame struct {
+	Filename         string
+	Frame            struct{ X, Y, W, H float32 }
+	Rotated          bool
+	Trimmed          bool
+	SpriteSourceSize struct{ X, Y, W, H float32 }
+	SourceSize       struct{ W, H float32 }
+	Pivot            *struct{ X, Y float32 }
+	Duration         float32
+}
+
+func parseSheetJSON(data []byte) (*sheet, error) {
+	var doc struct {
+		Frames json.RawMessage
+		Meta   struct {
+			FrameTags []struct {
+				Name      string
+				From, To  int
+				Direction string
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var frames []jsonFrame
+	if raw := bytes.TrimSpace(doc.Frames); bytes.HasPrefix(raw, []byte("[")) {
+		if err := json.Unmarshal(raw, &frames); err != nil {
+			return nil, err
+		}
+	} else if err := decodeHash(raw, &frames); err != nil {
+		return nil, err
+	}
+
+	sh := &sheet{frames: make([]sheetFrame, len(frames))}
+	for i, f := range frames {
+		sf := &sh.frames[i]
+		sf.name = f.Filename
+		sf.x, sf.y, sf.w, sf.h = f.Frame.X, f.Frame.Y, f.Frame.W, f.Frame.H
+		sf.rotated = f.Rotated
+		if f.Trimmed {
+			sf.trimmed = true
+			sf.trim = gfx.Trim{
+				X: f.SpriteSourceSize.X, Y: f.SpriteSourceSize.Y,
+				Source: gfx.Size{Width: f.SourceSize.W, Height: f.SourceSize.H},
+			}
+		}
+		if p := f.Pivot; p != nil {
+			// TexturePacker's pivot is from top-left
+			sf.pivot, sf.hasPivot = [2]float32{p.X, 1 - p.Y}, true
+		}
+		sf.duration = f.Duration / 1000
+	}
+	for _, t := range doc.Meta.FrameTags {
+		sh.tags = append(sh.tags, sheetTag{t.Name, t.From, t.To, t.Direction})
+	}
+	return sh, nil
+}
+
+// decodeHash decodes the JSON-hash frames in the order of the file, the key
+// is the name of frame.
+func decodeHash(raw []byte, frames *[]jsonFrame) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return errors.New("atlas: frames is neither array nor hash")
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var f jsonFrame
+		if err := dec.Decode(&f); err != nil {
+			return err
+		}
+		f.Filename = t.(string)
+		*frames = append(*frames, f)
+	}
+	return nil
+}
+
+// Starling/Sparrow XML, frameX and frameY are the negative trim offsets,
+// pivotX and pivotY are in pixels from top-left. The width and height of a
+// rotated SubTexture are the rectangle in the texture, they're swapped to the
+// size before rotated.
+func parseSheetXML(data []byte) (*sheet, error) {
+	var doc struct {
+		SubTextures []struct {
+			Name        string   `xml:"name,attr"`
+			X           float32  `xml:"x,attr"`
+			Y           float32  `xml:"y,attr"`
+			Width       float32  `xml:"width,attr"`
+			Height      float32  `xml:"height,attr"`
+			FrameX      float32  `xml:"frameX,attr"`
+			FrameY      float32  `xml:"frameY,attr"`
+			FrameWidth  float32  `xml:"frameWidth,attr"`
+			FrameHeight float32  `xml:"frameHeight,attr"`
+			Rotated     bool     `xml:"rotated,attr"`
+			PivotX      *float32 `xml:"pivotX,attr"`
+			PivotY      *float32 `xml:"pivotY,attr"`
+		} `xml:"SubTexture"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	sh := &sheet{frames: make([]sheetFrame, len(doc.SubTextures))}
+	for i, st := range doc.SubTextures {
+		sf := &sh.frames[i]
+		sf.name = st.Name
+		w, h := st.Width, st.Height
+		if st.Rotated {
+			w, h = h, w
+		}
+		sf.x, sf.y, sf.w, sf.h = st.X, st.Y, w, h
+		sf.rotated = st.Rotated
+		fw, fh := w, h
+		if st.FrameWidth > 0 && st.FrameHeight > 0 {
+			fw, fh = st.FrameWidth, st.FrameHeight
+			sf.trimmed = true
+			sf.trim = gfx.Trim{X: -st.FrameX, Y: -st.FrameY, Source: gfx.Size{Width: fw, Height: fh}}
+		}
+		if st.PivotX != nil && st.PivotY != nil && fw > 0 && fh > 0 {
+			sf.pivot, sf.hasPivot = [2]float32{*st.PivotX / fw, 1 - *st.PivotY/fh}, true
+		}
+	}
+	return sh, nil
+}
+
+// libGDX atlas, pages are separated by empty lines. A page starts with the
+// image name and a region starts with the region name, the properties are
+// "key: value" lines. Regions with index >= 0 are named "name_index". Only the
+// page matches image is parsed, the first page is used if none matches.
+func parseSheetGDX(data []byte, image string) (*sheet, error) {
+	type page struct {
+		name   string
+		frames []sheetFrame
+	}
+	var (
+		pages   []*page
+		pg      *page
+		region  *sheetFrame
+		index   int
+		scanner = bufio.NewScanner(bytes.NewReader(data))
+	)
+	endRegion := func() {
+		if region != nil {
+			if index >= 0 {
+				region.name = fmt.Sprintf("%s_%d", region.name, index)
+			}
+			pg.frames = append(pg.frames, *region)
+			region = nil
+		}
+	}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			endRegion()
+			pg = nil
+			continue
+		}
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			if pg == nil {
+				pg = &page{name: line}
+				pages = append(pages, pg)
+			} else {
+				endRegion()
+				region, index = &sheetFrame{name: line}, -1
+			}
+			continue
+		}
+		if region == nil {
+			continue // page properties
+		}
+		key := strings.TrimSpace(line[:colon])
+		values := strings.Split(line[colon+1:], ",")
+		nums := make([]float32, len(values))
+		for i, v := range values {
+			values[i] = strings.TrimSpace(v)
+			n, _ := strconv.ParseFloat(values[i], 32)
+			nums[i] = float32(n)
+		}
+		switch {
+		case key == "rotate":
+			region.rotated = values[0] == "true" || values[0] == "90"
+		case key == "xy" && len(nums) == 2:
+			region.x, region.y = nums[0], nums[1]
+		case key == "size" && len(nums) == 2:
+			region.w, region.h = nums[0], nums[1]
+		case key == "bounds" && len(nums) == 4:
+			region.x, region.y, region.w, region.h = nums[0], nums[1], nums[2], nums[3]
+		case key == "orig" && len(nums) == 2:
+			region.trim.Source = gfx.Size{Width: nums[0], Height: nums[1]}
+		case key == "offset" && len(nums) == 2:
+			region.trim.X, region.trim.Y = nums[0], nums[1]
+		case key == "offsets" && len(nums) == 4:
+			region.trim.X, region.trim.Y = nums[0], nums[1]
+			region.trim.Source = gfx.Size{Width: nums[2], Height: nums[3]}
+		case key == "index":
+			index = int(nums[0])
+		}
+	}
+	if pg != nil {
+		endRegion()
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("atlas: unknown format")
+	}
+
+	selected := pages[0]
+	for _, p := range pages {
+		if p.name == path.Base(image) {
+			selected = p
+			break
+		}
+	}
+	sh := &sheet{frames: selected.frames}
+	for i := range sh.frames {
+		f := &sh.frames[i]
+		src := f.trim.Source
+		if src.Width == 0 || src.Height == 0 || (src.Width == f.w && src.Height == f.h) {
+			f.trim = gfx.Trim{}
+			continue
+		}
+		// libGDX's offset is from bottom-left
+		f.trimmed = true
+		f.trim.Y = src.Height - f.trim.Y - f.h
+	}
+	return sh, nil
+}
@@ -0,0 +1,172 @@
+package asset
+
+import (
+	"image"
+	"reflect"
+	"testing"
+
+	"sckorok/gfx"
+	"sckorok/gfx/bk"
+)
+
+const sheetArray = `{"frames": [
+ {"filename": "a", "frame": {"x": 2, "y": 4, "w": 10, "h": 20}, "rotated": false, "trimmed": true,
+  "spriteSourceSize": {"x": 1, "y": 3, "w": 10, "h": 20}, "sourceSize": {"w": 12, "h": 24}, "pivot": {"x": 0.25, "y": 0.75}},
+ {"filename": "b", "frame": {"x": 12, "y": 4, "w": 16, "h": 8}, "rotated": true, "trimmed": false}
+], "meta": {"image": "sheet.png"}}`
+
+const sheetHash = `{"frames": {
+ "b": {"frame": {"x": 12, "y": 4, "w": 16, "h": 8}, "rotated": true, "trimmed": false},
+ "a": {"frame": {"x": 2, "y": 4, "w": 10, "h": 20}, "rotated": false, "trimmed": true,
+  "spriteSourceSize": {"x": 1, "y": 3, "w": 10, "h": 20}, "sourceSize": {"w": 12, "h": 24}, "pivot": {"x": 0.25, "y": 0.75}}
+}, "meta": {"image": "sheet.png"}}`
+
+// the rotated SubTexture stores the rectangle in the texture
+const sheetStarling = `<?xml version="1.0" encoding="UTF-8"?>
+<TextureAtlas imagePath="sheet.png">
+ <SubTexture name="a" x="2" y="4" width="10" height="20" frameX="-1" frameY="-3" frameWidth="12" frameHeight="24" pivotX="3" pivotY="18"/>
+ <SubTexture name="b" x="12" y="4" width="8" height="16" rotated="true" pivotX="4" pivotY="4"/>
+</TextureAtlas>`
+
+const sheetAseprite = `{"frames": [
+ {"filename": "run 0", "frame": {"x": 0, "y": 0, "w": 8, "h": 8}, "duration": 100},
+ {"filename": "run 1", "frame": {"x": 8, "y": 0, "w": 8, "h": 8}, "duration": 200},
+ {"filename": "run 2", "frame": {"x": 16, "y": 0, "w": 8, "h": 8}, "duration": 300}
+], "meta": {"frameTags": [
+ {"name": "forward", "from": 0, "to": 2, "direction": "forward"},
+ {"name": "reverse", "from": 0, "to": 2, "direction": "reverse"},
+ {"name": "pingpong", "from": 0, "to": 2, "direction": "pingpong"},
+ {"name": "invalid", "from": 1, "to": 3, "direction": "forward"}
+]}}`
+
+// the offset is from bottom-left, the second page is selected by image
+const sheetGDXLegacy = `
+sheet.png
+size: 64,64
+format: RGBA8888
+filter: Linear,Linear
+repeat: none
+a
+  rotate: false
+  xy: 2, 4
+  size: 10, 20
+  orig: 12, 24
+  offset: 1, 1
+  index: -1
+b
+  rotate: true
+  xy: 12, 4
+  size: 16, 8
+  orig: 16, 8
+  offset: 0, 0
+  index: 3
+
+other.png
+size: 32,32
+c
+  xy: 0, 0
+  size: 4, 4
+  orig: 4, 4
+  offset: 0, 0
+  index: -1
+`
+
+const sheetGDX = `sheet.png
+size:64,64
+filter:Linear,Linear
+a
+bounds:2,4,10,20
+offsets:1,1,12,24
+b
+bounds:12,4,16,8
+rotate:90
+index:3
+`
+
+var (
+	frameA = sheetFrame{
+		name: "a", x: 2, y: 4, w: 10, h: 20,
+		trimmed: true, trim: gfx.Trim{X: 1, Y: 3, Source: gfx.Size{Width: 12, Height: 24}},
+		pivot: [2]float32{.25, .25}, hasPivot: true,
+	}
+	frameB = sheetFrame{name: "b", x: 12, y: 4, w: 16, h: 8, rotated: true}
+)
+
+func TestParseSheet(t *testing.T) {
+	gdxA, gdxB := frameA, frameB
+	gdxA.pivot, gdxA.hasPivot = [2]float32{}, false
+	gdxB.name = "b_3"
+	starB := frameB
+	starB.pivot, starB.hasPivot = [2]float32{.25, .5}, true
+
+	cases := []struct {
+		name   string
+		data   string
+		image  string
+		frames []sheetFrame
+	}{
+		{"array", sheetArray, "sheet.png", []sheetFrame{frameA, frameB}},
+		{"hash", sheetHash, "sheet.png", []sheetFrame{frameB, frameA}},
+		{"starling", sheetStarling, "sheet.png", []sheetFrame{frameA, starB}},
+		{"gdx legacy", sheetGDXLegacy, "sheet.png", []sheetFrame{gdxA, gdxB}},
+		{"gdx legacy page", sheetGDXLegacy, "res/other.png", []sheetFrame{{name: "c", w: 4, h: 4}}},
+		{"gdx", sheetGDX, "sheet.png", []sheetFrame{gdxA, gdxB}},
+	}
+	for _, c := range cases {
+		sh, err := parseSheet([]byte(c.data), c.image)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(sh.frames, c.frames) {
+			t.Errorf("%s: frames error\n got: %+v\nwant: %+v", c.name, sh.frames, c.frames)
+		}
+	}
+
+	if _, err := parseSheet([]byte(" \n"), "sheet.png"); err == nil {
+		t.Error("empty description should fail")
+	}
+}
+
+func TestSheetClips(t *testing.T) {
+	sh, err := parseSheet([]byte(sheetAseprite), "run.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := sh.frames[1].duration; d != .2 {
+		t.Error("duration should be in seconds:", d)
+	}
+
+	bk.UseSoftRenderer(bk.NewSoftRenderer(4, 4))
+	defer bk.UseSoftRenderer(nil)
+	bk.R.Init()
+	id, _ := bk.R.AllocTexture(image.NewRGBA(image.Rect(0, 0, 24, 8)))
+	defer bk.R.Free(id)
+	at := gfx.R.NewAtlas(id, len(sh.frames), "run.json")
+	defer gfx.R.Delete("run.json")
+	for _, f := range sh.frames {
+		at.AddItem(f.x, f.y, f.w, f.h, f.name, f.rotated)
+	}
+	clips := sh.clips(at)
+	expect := map[string][]int{
+		"forward":  {0, 1, 2},
+		"reverse":  {2, 1, 0},
+		"pingpong": {0, 1, 2, 1},
+	}
+	if len(clips) != len(expect) {
+		t.Fatal("invalid tag should be skipped:", len(clips))
+	}
+	for _, c := range clips {
+		seq := expect[c.Name]
+		if len(c.Frames) != len(seq) || !c.Loop {
+			t.Errorf("%s: frame size error: %d", c.Name, len(c.Frames))
+			continue
+		}
+		for i, index := range seq {
+			tex, _ := at.GetByIndex(index)
+			if c.Frames[i] != tex || c.Durations[i] != sh.frames[index].duration {
+				t.Errorf("%s: frame %d should be %d", c.Name, i, index)
+			}
+		}
+	}
+}
@@ -15,9 +15,14 @@ import (
 type ParticleComp struct {
 	init bool
 	engi.Entity
-	sim     Simulator
-	zOrder  int16
-	visible int16
+	sim      Simulator
+	zOrder   int16
+	visible  int16
+	material uint16
+
+	// sorting layer and custom sort key
+	layer   uint8
+	sortKey int32
 
 	tex  gfx.Tex2D
 	size f32.Vec2
@@ -66,6 +71,33 @@ func (pc *ParticleComp) Z() int16 {
 	return pc.zOrder
 }
 
+// SetLayer moves the particle system to the sorting layer, see gfx.SortingLayers.
+func (pc *ParticleComp) SetLayer(id uint8) {
+	pc.layer = id
+}
+
+func (pc *ParticleComp) Layer() uint8 {
+	return pc.layer
+}
+
+// SetSortKey sets the key used in a gfx.SortCustom layer.
+func (pc *ParticleComp) SetSortKey(key int32) {
+	pc.sortKey = key
+}
+
+func (pc *ParticleComp) SortKey() int32 {
+	return pc.sortKey
+}
+
+// SetMaterial sets the Material, zero means the default material.
+func (pc *ParticleComp) SetMaterial(id uint16) {
+	pc.material = id
+}
+
+func (pc *ParticleComp) Material() uint16 {
+	return pc.material
+}
+
 func (pc *ParticleComp) Visible() bool {
 	if pc.visible == 0 {
 		return false
@@ -168,6 +200,7 @@ type ParticleRenderFeature struct {
 
 	et *ParticleSystemTable
 	xt *gfx.TransformTable
+	mt *gfx.MaskTable
 
 	stats struct {
 		lives int
@@ -191,6 +224,8 @@ func (f *ParticleRenderFeature) Register(rs *gfx.RenderSystem) {
 			f.et = table
 		case *gfx.TransformTable:
 			f.xt = table
+		case *gfx.MaskTable:
+			f.mt = table
 		}
 	}
 	// add new feature
@@ -205,7 +240,7 @@ func (f *ParticleRenderFeature) Extract(v *gfx.View) {
 	)
 	for i, pc := range f.et.comps[:f.et.index] {
 		if xf := xt.Comp(pc.Entity); pc.visible != 0 && camera.InView(xf, pc.size, f32.Vec2{.5, .5}) {
-			sid := gfx.PackSortId(pc.zOrder, 0)
+			sid := gfx.Layers.Pack(pc.layer, pc.zOrder, xf.World().Position[1], pc.sortKey, pc.material, 0)
 			val := fi + uint32(i)
 			v.RenderNodes = append(v.RenderNodes, gfx.SortObject{SortId: sid, Value: val})
 		}
@@ -253,10 +288,12 @@ func (f *ParticleRenderFeature) Draw(nodes gfx.RenderNodes) {
 		mesh.FirstIndex = 0
 		mesh.NumIndex = uint16(isz)
 		mesh.SetTexture(ps.tex.Tex())
+		mesh.SetMaterial(ps.material)
 
 		p := xf.Position()
 		mat4.Set(0, 3, p[0])
 		mat4.Set(1, 3, p[1])
+		f.MeshRender.SetStencil(f.mt.Stencil(ps.Entity))
 		f.MeshRender.Draw(mesh, mat4, int32(z))
 
 		f.stats.lives += live
@@ -8,6 +8,7 @@ import (
 	"sckorok/effect"
 	"sckorok/engi"
 	"sckorok/gfx"
+	"sckorok/gfx/bk"
 	"sckorok/gfx/dbg"
 	"sckorok/gui"
 	"sckorok/hid/input"
@@ -25,7 +26,19 @@ const (
 	MaxTextSize      = 64 << 10
 	MaxMeshSize      = 64 << 10
 
+	MaxTileMapSize = 64
+
 	MaxParticleSize = 1024
+
+	MaxMaskSize = 256
+
+	MaxLightSize    = 256
+	MaxOccluderSize = 1024
+
+	MaxTrailSize    = 256
+	MaxParallaxSize = 64
+
+	MaxColorEffectSize = 1024
 )
 
 type Options struct {
@@ -186,6 +199,24 @@ func (g *Game) Create(w, h float32, ratio float32) {
 	mrf.Register(rs)
 	trf := &gfx.TextRenderFeature{}
 	trf.Register(rs)
+	trf.SetDistanceFieldShader(asset.Shader.GetShaderStr("sdf"))
+	tmf := &gfx.TileMapRenderFeature{}
+	tmf.Register(rs)
+	mkf := &gfx.MaskRenderFeature{}
+	mkf.Register(rs)
+	mkf.SetAlphaShader(asset.Shader.GetShaderStr("mask"))
+	lrf := &gfx.LightRenderFeature{}
+	lrf.Register(rs)
+	lrf.SetShader(asset.Shader.GetShaderStr("lit"))
+	lrf.SetLightShader(asset.Shader.GetShaderStr("light"))
+	lrf.SetNormalShader(asset.Shader.GetShaderStr("litNormal"))
+	tlf := &gfx.TrailRenderFeature{}
+	tlf.Register(rs)
+	plf := &gfx.ParallaxRenderFeature{}
+	plf.Register(rs)
+	cef := &gfx.ColorEffectRenderFeature{}
+	cef.Register(rs)
+	cef.SetShader(asset.Shader.GetShaderStr("effect"))
 
 	// gui system
 	ui := &gui.UIRenderFeature{}
@@ -274,7 +305,16 @@ func (g *Game) loadTables() {
 	xfTable := gfx.NewTransformTable(MaxTransformSize)
 	textTable := gfx.NewTextTable(MaxTextSize)
 
-	g.DB.Tables = append(g.DB.Tables, spriteTable, meshTable, xfTable, textTable)
+	tileMapTable := gfx.NewTileMapTable(MaxTileMapSize)
+	maskTable := gfx.NewMaskTable(MaxMaskSize)
+	lightTable := gfx.NewLightTable(MaxLightSize)
+	occluderTable := gfx.NewOccluderTable(MaxOccluderSize)
+	trailTable := gfx.NewTrailTable(MaxTrailSize)
+	parallaxTable := gfx.NewParallaxTable(MaxParallaxSize)
+	colorEffectTable := gfx.NewColorEffectTable(MaxColorEffectSize)
+
+	g.DB.Tables = append(g.DB.Tables, spriteTable, meshTable, xfTable, textTable, tileMapTable, maskTable)
+	g.DB.Tables = append(g.DB.Tables, lightTable, occluderTable, trailTable, parallaxTable, colorEffectTable)
 
 	psTable := effect.NewParticleSystemTable(MaxParticleSize)
 	g.DB.Tables = append(g.DB.Tables, psTable)
@@ -291,6 +331,11 @@ func (g *Game) Update() {
 	// update
 	dt := g.FPS.Smooth()
 
+	// fixed timestep while recording
+	if r := gfx.Recording(); r != nil {
+		dt = r.Step()
+	}
+
 	// ease cpu usage TODO
 	if g.now.paused || (g.now.lostFocus && dt < 0.016) {
 		time.Sleep(time.Duration((0.016-dt)*1000) * time.Millisecond)
@@ -330,11 +375,10 @@ func (g *Game) Update() {
 	audio.AdvanceFrame()
 
 	// flush drawCall
-	num := gfx.Flush()
+	gfx.Flush()
 
-	// drawCall = all-drawCall - camera-drawCall
-	dc := num - len(g.RenderSystem.RenderList)
-	dbg.LogFPS(int(g.fps), dc)
+	// uniform-only submissions are not counted
+	dbg.LogFPS(int(g.fps), bk.Stats().DrawCalls)
 }
 
 func (g *Game) DrawProfile() {
@@ -2,6 +2,7 @@ package gfx
 
 import (
 	"sckorok/gfx/bk"
+	"sckorok/math/f32"
 	"unsafe"
 )
 
@@ -59,6 +60,22 @@ func NewTex(id uint16) Tex2D {
 	return bkTex{id: id}
 }
 
+// Trim is the transparent border removed by the texture packer. X, Y is the
+// offset of the packed image in the original image from top-left, Source is
+// the size of the original image.
+type Trim struct {
+	X, Y   float32
+	Source Size
+}
+
+// Packed is implemented by the sub-textures which carry the trim and pivot of
+// the sprite sheet. The pivot is normalized with the origin at bottom-left,
+// the same as the gravity of SpriteComp.
+type Packed interface {
+	Trim() (trim Trim, ok bool)
+	Pivot() (x, y float32, ok bool)
+}
+
 // SubTexture = (atlas-id << 16) + SubTexture-id
 type SubTex struct {
 	id uint32
@@ -76,6 +93,14 @@ func (tex SubTex) Size() Size {
 	return R.size(tex.id)
 }
 
+func (tex SubTex) Trim() (trim Trim, ok bool) {
+	return R.trim(tex.id)
+}
+
+func (tex SubTex) Pivot() (x, y float32, ok bool) {
+	return R.pivot(tex.id)
+}
+
 func (tex SubTex) Id() (atlas, index int) {
 	atlas = int(tex.id >> 16)
 	index = int(tex.id & 0xFFFF)
@@ -97,10 +122,20 @@ type Atlas struct {
 	// name of this atlas
 	names map[string]int
 
+	// trim and pivot of sub-texture, allocated on demand
+	packs []packInfo
+
 	// start index and size
 	index, size uint16
 }
 
+type packInfo struct {
+	trim     Trim
+	pivot    f32.Vec2
+	hasTrim  bool
+	hasPivot bool
+}
+
 func (at *Atlas) initialize(size int) {
 	var (
 		szRegion = size * int(sizeOfRegion)
@@ -108,20 +143,45 @@ func (at *Atlas) initialize(size int) {
 	)
 
 	buffer := make([]byte, szRegion+szSize)
-	at.regions = (*[1 << 16]Region)(unsafe.Pointer(&buffer[0]))[:size]
-	at.sizes = (*[1 << 16]Size)(unsafe.Pointer(&buffer[szRegion]))[:size]
+	at.regions = unsafe.Slice((*Region)(unsafe.Pointer(&buffer[0])), size)
+	at.sizes = unsafe.Slice((*Size)(unsafe.Pointer(&buffer[szRegion])), size)
 	at.names = make(map[string]int, size)
+	at.packs = nil
 	at.index = 0
 	at.size = uint16(size)
 }
 
+func (at *Atlas) grow(size int) {
+	if size < 16 {
+		size = 16
+	}
+	if size > 0xFFFF {
+		size = 0xFFFF
+	}
+	regions, sizes, packs := at.regions, at.sizes, at.packs
+	names, index := at.names, at.index
+	at.initialize(size)
+	copy(at.regions, regions)
+	copy(at.sizes, sizes)
+	if packs != nil {
+		at.packs = make([]packInfo, size)
+		copy(at.packs, packs)
+	}
+	at.names, at.index = names, index
+}
+
 func (at *Atlas) release() {
 	at.regions = nil
 	at.sizes = nil
 	at.names = nil
+	at.packs = nil
 }
 
+// AddItem adds a sub-texture, the atlas grows if it's full.
 func (at *Atlas) AddItem(x, y, w, h float32, name string, rotated bool) {
+	if at.index >= at.size {
+		at.grow(int(at.size) * 2)
+	}
 	ii := at.index
 	at.index++
 
@@ -142,6 +202,30 @@ func (at *Atlas) AddItem(x, y, w, h float32, name string, rotated bool) {
 	}
 }
 
+// SetTrim sets the trim of the last added item.
+func (at *Atlas) SetTrim(trim Trim) {
+	if p := at.last(); p != nil {
+		p.trim, p.hasTrim = trim, true
+	}
+}
+
+// SetPivot sets the pivot of the last added item, see Packed.
+func (at *Atlas) SetPivot(x, y float32) {
+	if p := at.last(); p != nil {
+		p.pivot, p.hasPivot = f32.Vec2{x, y}, true
+	}
+}
+
+func (at *Atlas) last() *packInfo {
+	if at.index == 0 {
+		return nil
+	}
+	if at.packs == nil {
+		at.packs = make([]packInfo, at.size)
+	}
+	return &at.packs[at.index-1]
+}
+
 func (at *Atlas) GetByName(name string) (tex SubTex, ok bool) {
 	if v, ook := at.names[name]; ook {
 		ok = true
@@ -151,7 +235,7 @@ func (at *Atlas) GetByName(name string) (tex SubTex, ok bool) {
 }
 
 func (at *Atlas) GetByIndex(index int) (tex SubTex, ok bool) {
-	if index < int(at.size) {
+	if index < int(at.index) {
 		ok = true
 		tex = SubTex{uint32(at.aid)<<16 + uint32(index)}
 	}
@@ -178,8 +262,10 @@ type TexManager struct {
 // 纹理图集的管理是以纹理为单位.
 func (tm *TexManager) NewAtlas(id uint16, size int, name string) (at *Atlas) {
 	if n := len(tm.frees); n > 0 {
-		at = &tm.atlases[tm.frees[size-1]]
-		tm.frees = tm.frees[:size-1]
+		ii := tm.frees[n-1]
+		tm.frees = tm.frees[:n-1]
+		at = &tm.atlases[ii]
+		tm.names[name] = int(ii)
 	} else {
 		ii := len(tm.atlases)
 		tm.atlases = append(tm.atlases, Atlas{aid: uint16(ii)})
@@ -232,6 +318,23 @@ func (tm *TexManager) size(id uint32) (sz Size) {
 	return
 }
 
+func (tm *TexManager) trim(id uint32) (trim Trim, ok bool) {
+	at := &tm.atlases[id>>16]
+	if ii := id & 0xFFFF; at.packs != nil {
+		trim, ok = at.packs[ii].trim, at.packs[ii].hasTrim
+	}
+	return
+}
+
+func (tm *TexManager) pivot(id uint32) (x, y float32, ok bool) {
+	at := &tm.atlases[id>>16]
+	if ii := id & 0xFFFF; at.packs != nil {
+		p := &at.packs[ii]
+		x, y, ok = p.pivot[0], p.pivot[1], p.hasPivot
+	}
+	return
+}
+
 func (tm *TexManager) texId(id uint32) uint16 {
 	return tm.atlases[id>>16].id
 }
@@ -25,9 +25,15 @@ Batch 系统设计
 type Batch struct {
 	TextureId uint16
 	depth     int16
+	material  uint16
+	stencil   uint32
+	normal    uint16
+	target    uint16 // render target, InvalidId is the screen
 
 	VertexId  uint16
 	IndexId   uint16
+	// vertex stream 1 of the color effects, InvalidId if not used
+	EffectId  uint16
 
 	firstVertex uint16
 	numVertex   uint16
@@ -41,7 +47,12 @@ type BatchObject interface {
 	Size() int
 }
 
+// effectObject is a BatchObject with the vertex data of color effect.
+type effectObject interface {
+	FillEffect(vertex []effectVertex)
+}
+
 type SortObject struct {
-	SortId uint32
+	SortId uint64
 	Value  uint32
 }
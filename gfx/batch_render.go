@@ -23,6 +23,9 @@ type BatchRender struct {
 	umhProjection uint16 // Projection
 	umhSampler0   uint16 // Sampler0
 
+	// projection matrix, shared by materials
+	projection f32.Mat4
+
 	// batch context
 	BatchContext
 }
@@ -61,10 +64,10 @@ func NewBatchRender(vsh, fsh string) *BatchRender {
 
 func (br *BatchRender) SetCamera(camera *Camera) {
 	left, right, bottom, top := camera.P()
-	p := f32.Ortho2D(left, right, bottom, top)
+	br.projection = f32.Ortho2D(left, right, bottom, top)
 
 	// setup uniform
-	bk.SetUniform(br.umhProjection, unsafe.Pointer(&p[0]))
+	bk.SetUniform(br.umhProjection, unsafe.Pointer(&br.projection[0]))
 	bk.Submit(0, br.program, 0)
 }
 
@@ -72,22 +75,68 @@ func (br *BatchRender) SetCamera(camera *Camera) {
 func (br *BatchRender) submit(bList []Batch) {
 	for i := range bList {
 		b := &bList[i]
+		program, state, sampler := br.program, br.stateFlags, br.umhSampler0
+
+		// material
+		if m := M.Get(b.material); m != nil {
+			if m.state != 0 {
+				state = m.state
+			}
+			if m.program != bk.InvalidId {
+				program, sampler = m.program, m.umhSampler0
+				bk.SetUniform(m.umhProjection, unsafe.Pointer(&br.projection[0]))
+			}
+			m.apply()
+
+			// normal map replaces the texture at stage 1
+			if b.normal != bk.InvalidId && m.texture.sampler != bk.InvalidId {
+				bk.SetTexture(1, m.texture.sampler, b.normal, 0)
+			}
+		}
 
 		// state
-		bk.SetState(br.stateFlags, br.rgba)
-		bk.SetTexture(0, br.umhSampler0, b.TextureId, 0)
+		bk.SetState(state, br.rgba)
+		bk.SetStencil(b.stencil)
+		bk.SetRenderTarget(b.target)
+		bk.SetTexture(0, sampler, b.TextureId, 0)
 
 		// set vertex
 		bk.SetVertexBuffer(0, b.VertexId, uint32(b.firstVertex), uint32(b.numVertex))
+		if b.EffectId != bk.InvalidId {
+			bk.SetVertexBuffer(1, b.EffectId, uint32(b.firstVertex), uint32(b.numVertex))
+		}
 		bk.SetIndexBuffer(b.IndexId, uint32(b.firstIndex), uint32(b.numIndex))
 
 		// submit draw-call
-		bk.Submit(0, br.program, int32(b.depth))
+		bk.Submit(0, program, int32(b.depth))
 	}
 }
 
 func (br *BatchRender) Begin(tex uint16, depth int16) {
-	br.BatchContext.begin(tex, depth)
+	br.BatchContext.begin(0, tex, depth)
+}
+
+// BeginMaterial starts a new batch, which will be drawn with the Material.
+func (br *BatchRender) BeginMaterial(mat, tex uint16, depth int16) {
+	br.BatchContext.begin(mat, tex, depth)
+}
+
+// SetStencil sets the stencil state of the batches begin after it, it's
+// reset by Flush.
+func (br *BatchRender) SetStencil(stencil uint32) {
+	br.BatchContext.stencil = stencil
+}
+
+// SetNormalMap sets the normal map of the batches begin after it, it's
+// bound to the stage 1 of material. It's reset by Flush.
+func (br *BatchRender) SetNormalMap(tex uint16) {
+	br.BatchContext.normal = tex
+}
+
+// SetRenderTarget sets the texture the batches begin after it are drawn into,
+// see bk.R.AllocRenderTarget. It's reset by Flush.
+func (br *BatchRender) SetRenderTarget(tex uint16) {
+	br.BatchContext.target = tex
 }
 
 func (br *BatchRender) Draw(b BatchObject) {
@@ -131,13 +180,24 @@ type BatchContext struct {
 	vertexPos   uint32
 	firstVertex uint32
 
+	// color effects in parallel with vertex, allocated by the first effect
+	effect     []effectVertex
+	effectUsed bool
+
 	// state
 	batchUsed int
 	texId     uint16
 	depth     int16
+	material  uint16
+	stencil   uint32
+	normal    uint16
+	target    uint16
 
 	// batch-list
 	BatchList [128]Batch
+
+	// batch breaks by cause, collected by RenderSystem
+	breaks [BreakCauses]int
 }
 
 func (bc *BatchContext) init() {
@@ -146,7 +206,12 @@ func (bc *BatchContext) init() {
 	bc.batchUsed = 0
 }
 
-func (bc *BatchContext) begin(tex uint16, depth int16) {
+func (bc *BatchContext) begin(mat, tex uint16, depth int16) {
+	if bc.batchUsed > 0 {
+		prev := &bc.BatchList[bc.batchUsed-1]
+		bc.breaks[breakCause(prev, mat, tex, depth, bc.stencil, bc.normal)]++
+	}
+	bc.material = mat
 	bc.texId = tex
 	bc.depth = depth
 	bc.firstVertex = bc.vertexPos
@@ -165,12 +230,20 @@ func (bc *BatchContext) drawComp(b BatchObject) {
 	if bc.vertexPos+step > MAX_BATCH_VERTEX_SIZE {
 		bc.flushBuffer()
 		bc.end()
+		bc.breaks[BreakBuffer]++
 
 		bc.vertexPos = 0
 		bc.firstVertex = 0
 	}
 
 	buf := bc.vertex[bc.vertexPos : bc.vertexPos+step]
+	if eo, ok := b.(effectObject); ok {
+		if bc.effect == nil {
+			bc.effect = make([]effectVertex, MAX_BATCH_VERTEX_SIZE)
+		}
+		bc.effectUsed = true
+		eo.FillEffect(bc.effect[bc.vertexPos : bc.vertexPos+step])
+	}
 	bc.vertexPos = bc.vertexPos + step
 	b.Fill(buf)
 }
@@ -184,8 +257,13 @@ func (bc *BatchContext) end() {
 	batch := &bc.BatchList[bc.batchUsed]
 	batch.TextureId = bc.texId
 	batch.depth = bc.depth
+	batch.material = bc.material
+	batch.stencil = bc.stencil
+	batch.normal = bc.normal
+	batch.target = bc.target
 
 	batch.VertexId = bk.InvalidId
+	batch.EffectId = bk.InvalidId
 	batch.firstVertex = 0 //uint16(bc.firstVertex)
 	batch.numVertex = uint16(bc.vertexPos - bc.firstVertex)
 	batch.firstIndex = uint16(bc.firstVertex / 4 * 6)
@@ -197,6 +275,10 @@ func (bc *BatchContext) end() {
 // upload buffer
 func (bc *BatchContext) reset() {
 	bc.texId = 0
+	bc.material = 0
+	bc.stencil = 0
+	bc.normal = 0
+	bc.target = 0
 	bc.firstVertex = 0
 	bc.vertexPos = 0
 	bc.batchUsed = 0
@@ -216,11 +298,22 @@ func (bc *BatchContext) flushBuffer() {
 	// flush vertex-buffer
 	vb.Update(0, bc.vertexPos*uint32(stride), unsafe.Pointer(&bc.vertex[0]), false)
 
+	// flush the effect stream, the vertices without effect are never read
+	eid := bk.InvalidId
+	if bc.effectUsed {
+		size := int(unsafe.Sizeof(effectVertex{}))
+		id, _, evb := Context.TempVertexBuffer(reqSize, size)
+		evb.Update(0, bc.vertexPos*uint32(size), unsafe.Pointer(&bc.effect[0]), false)
+		eid = id
+		bc.effectUsed = false
+	}
+
 	// backward rewrite vertex-buffer id
 	for i := bc.batchUsed; i >= 0; i-- {
 		if b := &bc.BatchList[i]; b.VertexId == bk.InvalidId {
 			b.VertexId = vid
 			b.IndexId = iid
+			b.EffectId = eid
 		}
 	}
 }
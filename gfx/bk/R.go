@@ -39,14 +39,6 @@ const (
 	IdTypeShader
 )
 
-const (
-	MaxIndex   = 2 << 10
-	MaxVertex  = 2 << 10
-	MaxTexture = 1 << 10
-	MaxUniform = 32 * 8
-	MaxShader  = 32
-)
-
 type FreeList struct {
 	slots []uint16
 }
@@ -64,13 +56,16 @@ func (fl *FreeList) Push(slot uint16) {
 	fl.slots = append(fl.slots, slot)
 }
 
+// ResManager keeps the resources in pools, a pool grows by blocks so the
+// resources never move and the pointers returned by Alloc* stay valid.
 type ResManager struct {
-	indexBuffers  [MaxIndex]IndexBuffer
-	vertexBuffers [MaxVertex]VertexBuffer
-	textures      [MaxTexture]Texture2D
+	indexBuffers  []*IndexBuffer
+	vertexBuffers []*VertexBuffer
+	textures      []*Texture2D
 
-	uniforms [MaxUniform]Uniform
-	shaders  [MaxShader]Shader
+	layouts  []*VertexLayout
+	uniforms []*Uniform
+	shaders  []*Shader
 
 	ibIndex uint16
 	vbIndex uint16
@@ -83,12 +78,15 @@ type ResManager struct {
 	ibFrees FreeList
 	vbFrees FreeList
 	ttFrees FreeList
+	vlFrees FreeList
 	umFrees FreeList
 	shFrees FreeList
+
+	limits Limits
 }
 
 func NewResManager() *ResManager {
-	return &ResManager{}
+	return &ResManager{limits: DefaultLimits()}
 }
 
 // skip first index - 0
@@ -101,6 +99,25 @@ func (rm *ResManager) Init() {
 		rm.umIndex++
 		rm.shIndex++
 	}
+	// the first block, the renderer may look up the unused slot 0
+	if len(rm.indexBuffers) == 0 {
+		rm.indexBuffers = growIndexBuffers(rm.indexBuffers, rm.limits.IndexBuffers)
+	}
+	if len(rm.vertexBuffers) == 0 {
+		rm.vertexBuffers = growVertexBuffers(rm.vertexBuffers, rm.limits.VertexBuffers)
+	}
+	if len(rm.textures) == 0 {
+		rm.textures = growTextures(rm.textures, rm.limits.Textures)
+	}
+	if len(rm.layouts) == 0 {
+		rm.layouts = growLayouts(rm.layouts, rm.limits.Layouts)
+	}
+	if len(rm.uniforms) == 0 {
+		rm.uniforms = growUniforms(rm.uniforms, rm.limits.Uniforms)
+	}
+	if len(rm.shaders) == 0 {
+		rm.shaders = growShaders(rm.shaders, rm.limits.Shaders)
+	}
 }
 
 func (rm *ResManager) Destroy() {
@@ -111,9 +128,15 @@ func (rm *ResManager) Destroy() {
 func (rm *ResManager) AllocIndexBuffer(mem Memory) (id uint16, ib *IndexBuffer) {
 	if index, ok := rm.ibFrees.Pop(); ok {
 		id = index
-		ib = &rm.indexBuffers[index]
+		ib = rm.indexBuffers[index]
+	} else if limit := rm.limits.IndexBuffers; int(rm.ibIndex) > limit {
+		fail("index-buffer", limit)
+		return InvalidId, nil
 	} else {
-		id, ib = rm.ibIndex, &rm.indexBuffers[rm.ibIndex]
+		if int(rm.ibIndex) >= len(rm.indexBuffers) {
+			rm.indexBuffers = growIndexBuffers(rm.indexBuffers, limit)
+		}
+		id, ib = rm.ibIndex, rm.indexBuffers[rm.ibIndex]
 		rm.ibIndex++
 	}
 	id = id | (IdTypeIndex << IdTypeShift)
@@ -132,12 +155,19 @@ func (rm *ResManager) AllocIndexBuffer(mem Memory) (id uint16, ib *IndexBuffer)
 func (rm *ResManager) AllocVertexBuffer(mem Memory, stride uint16) (id uint16, vb *VertexBuffer) {
 	if index, ok := rm.vbFrees.Pop(); ok {
 		id = index
-		vb = &rm.vertexBuffers[index]
+		vb = rm.vertexBuffers[index]
+	} else if limit := rm.limits.VertexBuffers; int(rm.vbIndex) > limit {
+		fail("vertex-buffer", limit)
+		return InvalidId, nil
 	} else {
-		id, vb = rm.vbIndex, &rm.vertexBuffers[rm.vbIndex]
+		if int(rm.vbIndex) >= len(rm.vertexBuffers) {
+			rm.vertexBuffers = growVertexBuffers(rm.vertexBuffers, limit)
+		}
+		id, vb = rm.vbIndex, rm.vertexBuffers[rm.vbIndex]
 		rm.vbIndex++
 	}
 	id = id | (IdTypeVertex << IdTypeShift)
+	vb.format = InvalidId
 	if err := vb.Create(mem.Size, mem.Data, stride, 0); err != nil {
 		log.Println("fail to alloc vertex-buffer, ", err)
 	} else {
@@ -148,13 +178,57 @@ func (rm *ResManager) AllocVertexBuffer(mem Memory, stride uint16) (id uint16, v
 	return
 }
 
+// AllocVertexBufferLayout alloc a new Vertex-Buffer with the vertex layout,
+// Return the resource handler.
+func (rm *ResManager) AllocVertexBufferLayout(mem Memory, layout uint16) (id uint16, vb *VertexBuffer) {
+	ok, l := rm.Layout(layout)
+	if !ok {
+		log.Printf("fail to alloc vertex-buffer, invalid layout: %d", layout&IdMask)
+		return InvalidId, nil
+	}
+	if id, vb = rm.AllocVertexBuffer(mem, l.Stride); vb != nil {
+		vb.format = layout & IdMask
+	}
+	return
+}
+
+// AllocLayout registers the vertex layout, Return the resource handler.
+func (rm *ResManager) AllocLayout(layout VertexLayout) (id uint16, l *VertexLayout) {
+	if index, ok := rm.vlFrees.Pop(); ok {
+		id = index
+		l = rm.layouts[index]
+	} else if limit := rm.limits.Layouts; int(rm.vlIndex) > limit {
+		fail("layout", limit)
+		return InvalidId, nil
+	} else {
+		if int(rm.vlIndex) >= len(rm.layouts) {
+			rm.layouts = growLayouts(rm.layouts, limit)
+		}
+		id, l = rm.vlIndex, rm.layouts[rm.vlIndex]
+		rm.vlIndex++
+	}
+	id = id | (IdTypeLayout << IdTypeShift)
+	l.Attrs = append([]VertexAttr(nil), layout.Attrs...)
+	l.Stride = layout.Stride
+	if (gDebug & DebugResMan) != 0 {
+		log.Printf("alloc layout: (%d, %d)", id&IdMask, l.Stride)
+	}
+	return
+}
+
 // AllocUniform get the uniform slot in a shader program, Return the resource handler.
 func (rm *ResManager) AllocUniform(shId uint16, name string, xType UniformType, num uint32) (id uint16, um *Uniform) {
 	if index, ok := rm.umFrees.Pop(); ok {
 		id = index
-		um = &rm.uniforms[index]
+		um = rm.uniforms[index]
+	} else if limit := rm.limits.Uniforms; int(rm.umIndex) > limit {
+		fail("uniform", limit)
+		return InvalidId, nil
 	} else {
-		id, um = rm.umIndex, &rm.uniforms[rm.umIndex]
+		if int(rm.umIndex) >= len(rm.uniforms) {
+			rm.uniforms = growUniforms(rm.uniforms, limit)
+		}
+		id, um = rm.umIndex, rm.uniforms[rm.umIndex]
 		rm.umIndex++
 	}
 	id = id | (IdTypeUniform << IdTypeShift)
@@ -172,15 +246,27 @@ func (rm *ResManager) AllocUniform(shId uint16, name string, xType UniformType,
 
 // AllocTexture upload image to GPU, Return the resource handler.
 func (rm *ResManager) AllocTexture(img image.Image) (id uint16, tex *Texture2D) {
+	return rm.AllocTextureOptions(img, TextureOptions{})
+}
+
+// AllocTextureOptions upload image to GPU with the sampling options, Return
+// the resource handler.
+func (rm *ResManager) AllocTextureOptions(img image.Image, opt TextureOptions) (id uint16, tex *Texture2D) {
 	if index, ok := rm.ttFrees.Pop(); ok {
 		id = index
-		tex = &rm.textures[index]
+		tex = rm.textures[index]
+	} else if limit := rm.limits.Textures; int(rm.ttIndex) > limit {
+		fail("texture", limit)
+		return InvalidId, nil
 	} else {
-		id, tex = rm.ttIndex, &rm.textures[rm.ttIndex]
+		if int(rm.ttIndex) >= len(rm.textures) {
+			rm.textures = growTextures(rm.textures, limit)
+		}
+		id, tex = rm.ttIndex, rm.textures[rm.ttIndex]
 		rm.ttIndex++
 	}
 	id = id | (IdTypeTexture << IdTypeShift)
-	if err := tex.Create(img); err != nil {
+	if err := tex.CreateOptions(img, opt); err != nil {
 		log.Printf("fail to alloc texture, %s", err)
 	} else {
 		if (gDebug & DebugResMan) != 0 {
@@ -190,13 +276,38 @@ func (rm *ResManager) AllocTexture(img image.Image) (id uint16, tex *Texture2D)
 	return
 }
 
+// AllocRenderTarget allocates a width x height texture that can be drawn into,
+// see SetRenderTarget. It's sampled like other textures, Return the resource
+// handler.
+func (rm *ResManager) AllocRenderTarget(width, height int) (id uint16, tex *Texture2D) {
+	id, tex = rm.AllocTexture(image.NewRGBA(image.Rect(0, 0, width, height)))
+	if tex == nil {
+		return
+	}
+	if err := tex.createFrameBuffer(); err != nil {
+		log.Printf("fail to alloc render target, %s", err)
+		rm.Free(id)
+		return InvalidId, nil
+	}
+	if (gDebug & DebugResMan) != 0 {
+		log.Printf("alloc render target: (%d, %d)", id&IdMask, tex.fbo)
+	}
+	return
+}
+
 // AllocShader compile and link the Shader source code, Return the resource handler.
 func (rm *ResManager) AllocShader(vsh, fsh string) (id uint16, sh *Shader) {
 	if index, ok := rm.shFrees.Pop(); ok {
 		id = index
-		sh = &rm.shaders[index]
+		sh = rm.shaders[index]
+	} else if limit := rm.limits.Shaders; int(rm.shIndex) > limit {
+		fail("shader", limit)
+		return InvalidId, nil
 	} else {
-		id, sh = rm.shIndex, &rm.shaders[rm.shIndex]
+		if int(rm.shIndex) >= len(rm.shaders) {
+			rm.shaders = growShaders(rm.shaders, limit)
+		}
+		id, sh = rm.shIndex, rm.shaders[rm.shIndex]
 		rm.shIndex++
 	}
 	id = id | (IdTypeShader << IdTypeShift)
@@ -216,6 +327,9 @@ func (rm *ResManager) AllocShader(vsh, fsh string) (id uint16, sh *Shader) {
 func (rm *ResManager) Free(id uint16) {
 	t := (id >> IdTypeShift) & 0x000F
 	v := id & IdMask
+	if v == InvalidId {
+		return
+	}
 
 	switch t {
 	case IdTypeIndex:
@@ -228,7 +342,8 @@ func (rm *ResManager) Free(id uint16) {
 		rm.textures[v].Destroy()
 		rm.ttFrees.Push(v)
 	case IdTypeLayout:
-		// todo
+		*rm.layouts[v] = VertexLayout{}
+		rm.vlFrees.Push(v)
 	case IdTypeUniform:
 		rm.umFrees.Push(v)
 	case IdTypeShader:
@@ -237,52 +352,110 @@ func (rm *ResManager) Free(id uint16) {
 	}
 }
 
+// grow the pools by a block of resources
+func growIndexBuffers(pool []*IndexBuffer, limit int) []*IndexBuffer {
+	block := make([]IndexBuffer, growSize(len(pool), limit))
+	for i := range block {
+		pool = append(pool, &block[i])
+	}
+	return pool
+}
+
+func growVertexBuffers(pool []*VertexBuffer, limit int) []*VertexBuffer {
+	block := make([]VertexBuffer, growSize(len(pool), limit))
+	for i := range block {
+		pool = append(pool, &block[i])
+	}
+	return pool
+}
+
+func growTextures(pool []*Texture2D, limit int) []*Texture2D {
+	block := make([]Texture2D, growSize(len(pool), limit))
+	for i := range block {
+		pool = append(pool, &block[i])
+	}
+	return pool
+}
+
+func growLayouts(pool []*VertexLayout, limit int) []*VertexLayout {
+	block := make([]VertexLayout, growSize(len(pool), limit))
+	for i := range block {
+		pool = append(pool, &block[i])
+	}
+	return pool
+}
+
+func growUniforms(pool []*Uniform, limit int) []*Uniform {
+	block := make([]Uniform, growSize(len(pool), limit))
+	for i := range block {
+		pool = append(pool, &block[i])
+	}
+	return pool
+}
+
+func growShaders(pool []*Shader, limit int) []*Shader {
+	block := make([]Shader, growSize(len(pool), limit))
+	for i := range block {
+		pool = append(pool, &block[i])
+	}
+	return pool
+}
+
 // IndexBuffer returns the low-level IndexBuffer struct.
 func (rm *ResManager) IndexBuffer(id uint16) (ok bool, ib *IndexBuffer) {
 	t, v := id>>IdTypeShift, id&IdMask
-	if t != IdTypeIndex || v >= MaxIndex {
+	if t != IdTypeIndex || int(v) >= len(rm.indexBuffers) {
 		return false, nil
 	}
-	return true, &rm.indexBuffers[v]
+	return true, rm.indexBuffers[v]
 }
 
 // VertexBuffer returns the low-level VertexBuffer struct.
 func (rm *ResManager) VertexBuffer(id uint16) (ok bool, vb *VertexBuffer) {
 	t, v := id>>IdTypeShift, id&IdMask
-	if t != IdTypeVertex || v >= MaxVertex {
+	if t != IdTypeVertex || int(v) >= len(rm.vertexBuffers) {
 		return false, nil
 	}
-	return true, &rm.vertexBuffers[v]
+	return true, rm.vertexBuffers[v]
 }
 
 // Texture returns the low-level Texture struct.
 func (rm *ResManager) Texture(id uint16) (ok bool, tex *Texture2D) {
 	t, v := id>>IdTypeShift, id&IdMask
-	if t != IdTypeTexture || v >= MaxTexture {
+	if t != IdTypeTexture || int(v) >= len(rm.textures) {
 		return false, nil
 	}
-	return true, &rm.textures[v]
+	return true, rm.textures[v]
+}
+
+// Layout returns the VertexLayout struct.
+func (rm *ResManager) Layout(id uint16) (ok bool, l *VertexLayout) {
+	t, v := id>>IdTypeShift, id&IdMask
+	if t != IdTypeLayout || v == InvalidId || int(v) >= len(rm.layouts) {
+		return false, nil
+	}
+	return true, rm.layouts[v]
 }
 
 // Uniform returns the low-level Uniform struct.
 func (rm *ResManager) Uniform(id uint16) (ok bool, um *Uniform) {
 	t, v := id>>IdTypeShift, id&IdMask
-	if t != IdTypeUniform || v >= MaxUniform {
+	if t != IdTypeUniform || int(v) >= len(rm.uniforms) {
 		return false, nil
 	}
-	return true, &rm.uniforms[v]
+	return true, rm.uniforms[v]
 }
 
 // Shader returns the low-level Shader struct.
 func (rm *ResManager) Shader(id uint16) (ok bool, sh *Shader) {
 	t, v := id>>IdTypeShift, id&IdMask
-	if t != IdTypeShader || v >= MaxShader {
+	if t != IdTypeShader || int(v) >= len(rm.shaders) {
 		if (gDebug & DebugResMan) != 0 {
 			log.Printf("Invalid shader id:(%d, %d, %d)", id, t, v)
 		}
 		return false, nil
 	}
-	return true, &rm.shaders[v]
+	return true, rm.shaders[v]
 }
 
 ////// MAX SIZE
@@ -376,6 +549,104 @@ var g_Blend = []struct {
 	{gl.SRC_ALPHA, gl.ONE},
 }
 
+// STENCIL ENCODE FORMAT
+// 32bit:
+//
+//	pass zfail fail test  read-mask   ref
+//	0000 0000  0000 0000  0000-0000 0000-0000
+//
+// test is the compare function(1 - 8, same as depth-test), zero means no stencil-test,
+// fail, zfail and pass are the stencil operations.
+var ST_STENCIL = struct {
+	REF_MASK    uint32
+	READ_MASK   uint32
+	READ_SHIFT  uint32
+	TEST_MASK   uint32
+	TEST_SHIFT  uint32
+	FAIL_SHIFT  uint32
+	ZFAIL_SHIFT uint32
+	PASS_SHIFT  uint32
+	OP_MASK     uint32
+
+	TEST_LESS     uint32
+	TEST_LEQUAL   uint32
+	TEST_EQUAL    uint32
+	TEST_GEQUAL   uint32
+	TEST_GREATER  uint32
+	TEST_NOTEQUAL uint32
+	TEST_NEVER    uint32
+	TEST_ALWAYS   uint32
+
+	OP_KEEP      uint32
+	OP_ZERO      uint32
+	OP_REPLACE   uint32
+	OP_INCR      uint32
+	OP_DECR      uint32
+	OP_INVERT    uint32
+	OP_INCR_WRAP uint32
+	OP_DECR_WRAP uint32
+}{
+	REF_MASK:    0x000000FF,
+	READ_MASK:   0x0000FF00,
+	READ_SHIFT:  8,
+	TEST_MASK:   0x000F0000,
+	TEST_SHIFT:  16,
+	FAIL_SHIFT:  20,
+	ZFAIL_SHIFT: 24,
+	PASS_SHIFT:  28,
+	OP_MASK:     0xF,
+
+	TEST_LESS:     1,
+	TEST_LEQUAL:   2,
+	TEST_EQUAL:    3,
+	TEST_GEQUAL:   4,
+	TEST_GREATER:  5,
+	TEST_NOTEQUAL: 6,
+	TEST_NEVER:    7,
+	TEST_ALWAYS:   8,
+
+	OP_KEEP:      0,
+	OP_ZERO:      1,
+	OP_REPLACE:   2,
+	OP_INCR:      3,
+	OP_DECR:      4,
+	OP_INVERT:    5,
+	OP_INCR_WRAP: 6,
+	OP_DECR_WRAP: 7,
+}
+
+// Stencil encodes the stencil state, test is one of ST_STENCIL.TEST_XXX and
+// fail, zfail, pass are ST_STENCIL.OP_XXX.
+func Stencil(test uint32, ref, mask uint8, fail, zfail, pass uint32) uint32 {
+	return uint32(ref) |
+		uint32(mask)<<ST_STENCIL.READ_SHIFT |
+		test<<ST_STENCIL.TEST_SHIFT |
+		fail<<ST_STENCIL.FAIL_SHIFT |
+		zfail<<ST_STENCIL.ZFAIL_SHIFT |
+		pass<<ST_STENCIL.PASS_SHIFT
+}
+
+func stencilDecode(stencil uint32) (test uint32, ref, mask uint8, fail, zfail, pass uint32) {
+	ref = uint8(stencil & ST_STENCIL.REF_MASK)
+	mask = uint8((stencil & ST_STENCIL.READ_MASK) >> ST_STENCIL.READ_SHIFT)
+	test = (stencil & ST_STENCIL.TEST_MASK) >> ST_STENCIL.TEST_SHIFT
+	fail = (stencil >> ST_STENCIL.FAIL_SHIFT) & ST_STENCIL.OP_MASK
+	zfail = (stencil >> ST_STENCIL.ZFAIL_SHIFT) & ST_STENCIL.OP_MASK
+	pass = (stencil >> ST_STENCIL.PASS_SHIFT) & ST_STENCIL.OP_MASK
+	return
+}
+
+var g_StencilOp = []uint32{
+	gl.KEEP,
+	gl.ZERO,
+	gl.REPLACE,
+	gl.INCR,
+	gl.DECR,
+	gl.INVERT,
+	gl.INCR_WRAP,
+	gl.DECR_WRAP,
+}
+
 var ST_PT = struct {
 	TRIANGLES      uint64
 	TRIANGLE_STRIP uint64
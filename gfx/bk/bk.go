@@ -2,6 +2,7 @@
 package bk
 
 import (
+	"image"
 	"log"
 	"sckorok/math/f32"
 	"unsafe"
@@ -13,7 +14,8 @@ func SetDebug(debug uint32) {
 	gDebug = debug
 }
 
-// Init init the bk-api.
+// Init init the bk-api. The resource pools grow on demand, call SetLimits
+// before Init to limit them.
 func Init() {
 	R.Init()
 	gRenderQ.Init()
@@ -66,6 +68,13 @@ func SetStencil(stencil uint32) {
 	gRenderQ.SetStencil(stencil)
 }
 
+// SetRenderTarget sets the texture drawCall primitive is drawn into, it should
+// be allocated by R.AllocRenderTarget. InvalidId is the back-buffer, which is
+// the default.
+func SetRenderTarget(texture uint16) {
+	gRenderQ.SetRenderTarget(texture)
+}
+
 // SetScissor set scissor for drawCall primitive. Return a cached ScissorRect handler.
 func SetScissor(x, y, width, height uint16) uint16 {
 	return gRenderQ.SetScissor(x, y, width, height)
@@ -121,6 +130,12 @@ func Flush() int {
 	return gRenderQ.Flush()
 }
 
+// ReadFrame reads the pixels of the frame-buffer, it should be called after Flush
+// and before the buffers are swapped. The origin of the image is top-left.
+func ReadFrame() *image.RGBA {
+	return gRenderQ.ctx.ReadFrame()
+}
+
 func Dump() {
 	size := gRenderQ.drawCallNum
 	drawCall := gRenderQ.drawCallList[:size]
@@ -18,6 +18,11 @@ func (ib *IndexBuffer) Create(size uint32, data unsafe.Pointer, flags uint16) er
 	ib.size = size
 	ib.flags = flags
 
+	if gSoft != nil {
+		ib.Id = gSoft.newBuffer(size, data)
+		return nil
+	}
+
 	gl.GenBuffers(1, &ib.Id)
 
 	if 0 == ib.Id {
@@ -47,12 +52,21 @@ func (ib *IndexBuffer) Update(offset uint32, size uint32, data unsafe.Pointer, d
 		ib.Create(ib.size, nil, ib.flags)
 	}
 
+	if gSoft != nil {
+		gSoft.updateBuffer(ib.Id, offset, size, data)
+		return
+	}
+
 	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, ib.Id)
 	gl.BufferSubData(gl.ELEMENT_ARRAY_BUFFER, int(offset), int(size), data)
 	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, 0)
 }
 
 func (ib *IndexBuffer) Destroy() {
+	if gSoft != nil {
+		gSoft.deleteBuffer(ib.Id)
+		return
+	}
 	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, 0)
 	gl.DeleteBuffers(1, &ib.Id)
 }
@@ -62,6 +76,7 @@ type VertexBuffer struct {
 	target uint32
 	size   uint32
 	layout uint16 // Stride | Offset
+	format uint16 // vertex layout, InvalidId if it's created with a stride
 }
 
 /// draw indirect >= es 3.0 or gl 4.0
@@ -70,6 +85,11 @@ func (vb *VertexBuffer) Create(size uint32, data unsafe.Pointer, layout uint16,
 	vb.layout = layout
 	vb.target = gl.ARRAY_BUFFER
 
+	if gSoft != nil {
+		vb.Id = gSoft.newBuffer(size, data)
+		return nil
+	}
+
 	gl.GenBuffers(1, &vb.Id)
 	if vb.Id == 0 {
 		return errors.New("failed to generate buffer id")
@@ -95,12 +115,21 @@ func (vb *VertexBuffer) Update(offset uint32, size uint32, data unsafe.Pointer,
 		vb.Create(vb.size, nil, vb.layout, 0)
 	}
 
+	if gSoft != nil {
+		gSoft.updateBuffer(vb.Id, offset, size, data)
+		return
+	}
+
 	gl.BindBuffer(vb.target, vb.Id)
 	gl.BufferSubData(vb.target, int(offset), int(size), data)
 	gl.BindBuffer(vb.target, 0)
 }
 
 func (vb *VertexBuffer) Destroy() {
+	if gSoft != nil {
+		gSoft.deleteBuffer(vb.Id)
+		return
+	}
 	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
 	gl.DeleteBuffers(1, &vb.Id)
 }
@@ -0,0 +1,115 @@
+package bk
+
+import (
+	"fmt"
+	"log"
+)
+
+// Limits is the max size of the resource pools and render queue. The pools
+// start small and grow on demand, the limits only guard the memory and catch
+// the leaked resources. The ids of resource have 12 bits, so a resource pool
+// can't hold more than IdMask resources, and a frame can't submit more than
+// UInt16Max draw calls.
+type Limits struct {
+	IndexBuffers  int
+	VertexBuffers int
+	Textures      int
+	Layouts       int
+	Uniforms      int
+	Shaders       int
+
+	// draw calls submitted in a frame
+	DrawCalls int
+}
+
+// DefaultLimits returns the largest limits the ids can address.
+func DefaultLimits() Limits {
+	return Limits{
+		IndexBuffers:  int(IdMask),
+		VertexBuffers: int(IdMask),
+		Textures:      int(IdMask),
+		Layouts:       int(IdMask),
+		Uniforms:      int(IdMask),
+		Shaders:       int(IdMask),
+		DrawCalls:     int(UInt16Max),
+	}
+}
+
+// SetLimits sets the limits of the resource pools and render queue, it should
+// be called before Init. A zero or out of range field uses the default value.
+// Lowering a limit doesn't free the resources in use.
+func SetLimits(l Limits) {
+	def := DefaultLimits()
+	clamp := func(v *int, max int) {
+		if *v <= 0 || *v > max {
+			*v = max
+		}
+	}
+	clamp(&l.IndexBuffers, def.IndexBuffers)
+	clamp(&l.VertexBuffers, def.VertexBuffers)
+	clamp(&l.Textures, def.Textures)
+	clamp(&l.Layouts, def.Layouts)
+	clamp(&l.Uniforms, def.Uniforms)
+	clamp(&l.Shaders, def.Shaders)
+	clamp(&l.DrawCalls, def.DrawCalls)
+	R.limits = l
+	gRenderQ.limit = l.DrawCalls
+}
+
+// GetLimits returns the limits in use.
+func GetLimits() Limits {
+	l := R.limits
+	l.DrawCalls = gRenderQ.limit
+	return l
+}
+
+// PoolError is reported when a pool is exhausted. The allocation returns
+// InvalidId, a submitted draw call or uniform is dropped.
+type PoolError struct {
+	Pool  string
+	Limit int
+}
+
+func (e *PoolError) Error() string {
+	return fmt.Sprintf("bk: %s pool is exhausted, limit %d", e.Pool, e.Limit)
+}
+
+// the last error and the exhausted pools that have been logged
+var (
+	gErr    error
+	gFailed = map[string]bool{}
+)
+
+// Err returns the last error of bk-api, nil if no pool is exhausted since
+// the last ClearErr.
+func Err() error {
+	return gErr
+}
+
+// ClearErr clears the error returned by Err.
+func ClearErr() {
+	gErr = nil
+	gFailed = map[string]bool{}
+}
+
+// fail reports an exhausted pool, it's logged once until the error is cleared.
+func fail(pool string, limit int) {
+	if !gFailed[pool] {
+		gFailed[pool] = true
+		log.Printf("bk: %s pool is exhausted, limit %d", pool, limit)
+	}
+	gErr = &PoolError{pool, limit}
+}
+
+// growSize returns the number of slots to add to a pool of n slots, the pool
+// doubles and holds limit+1 slots at most (slot 0 is not used).
+func growSize(n, limit int) int {
+	grow := n
+	if grow < 32 {
+		grow = 32
+	}
+	if n+grow > limit+1 {
+		grow = limit + 1 - n
+	}
+	return grow
+}
@@ -0,0 +1,74 @@
+package bk
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+func TestPoolGrowth(t *testing.T) {
+	UseSoftRenderer(NewSoftRenderer(4, 4))
+	t.Cleanup(func() {
+		UseSoftRenderer(nil)
+		ClearErr()
+	})
+
+	rm := NewResManager()
+	rm.limits.Textures = 40
+	rm.Init()
+
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	id, first := rm.AllocTexture(img)
+	for i := 1; i < 40; i++ {
+		if id, _ := rm.AllocTexture(img); id == InvalidId {
+			t.Fatal("pool should grow to the limit:", i)
+		}
+	}
+	if ok, tex := rm.Texture(id); !ok || tex != first {
+		t.Error("resource should not move when the pool grows")
+	}
+
+	// exhausted
+	if id, tex := rm.AllocTexture(img); id != InvalidId || tex != nil {
+		t.Error("exhausted pool should return InvalidId:", id)
+	}
+	var pe *PoolError
+	if !errors.As(Err(), &pe) || pe.Pool != "texture" || pe.Limit != 40 {
+		t.Error("exhausted pool should be reported:", Err())
+	}
+
+	// freed slot is reused
+	rm.Free(id)
+	if id2, _ := rm.AllocTexture(img); id2 != id {
+		t.Error("freed slot should be reused:", id2, id)
+	}
+}
+
+func TestQueueGrowth(t *testing.T) {
+	newSoftScene(t)
+	t.Cleanup(func() {
+		gRenderQ.limit = int(UInt16Max)
+		ClearErr()
+	})
+
+	for i := 0; i < MAX_QUEUE_SIZE+10; i++ {
+		Touch(0)
+	}
+	if n := Flush(); n != MAX_QUEUE_SIZE+10 {
+		t.Error("render queue should grow:", n)
+	}
+	if Err() != nil {
+		t.Error("unexpected error:", Err())
+	}
+
+	gRenderQ.limit = len(gRenderQ.drawCallList)
+	for i := 0; i < gRenderQ.limit+1; i++ {
+		Touch(0)
+	}
+	if n := Flush(); n != gRenderQ.limit {
+		t.Error("draw calls over the limit should be dropped:", n)
+	}
+	if pe, ok := Err().(*PoolError); !ok || pe.Pool != "draw-call" {
+		t.Error("exhausted queue should be reported:", Err())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"sckorok/math/f32"
 	"sort"
+	"time"
 	"unsafe"
 )
 
@@ -32,7 +33,7 @@ func (r *Rect) isZero() bool {
 
 type Stream struct {
 	vertexBuffer uint16
-	vertexFormat uint16 // Offset | Stride， not used now!!
+	vertexFormat uint16 // vertex layout of the buffer, InvalidId if it has no layout
 
 	firstVertex uint16
 	numVertex   uint16
@@ -54,6 +55,9 @@ type RenderDraw struct {
 	stencil uint32
 	scissor uint16
 
+	// render target texture, InvalidId is the back-buffer
+	target uint16
+
 	// required renderer state
 	state uint64
 }
@@ -61,21 +65,25 @@ type RenderDraw struct {
 func (rd *RenderDraw) reset() {
 	rd.indexBuffer = 0
 	rd.firstIndex, rd.num = 0, 0
-	rd.scissor = 0
+	rd.stencil, rd.scissor = 0, 0
+	rd.target = InvalidId
 }
 
-// ~ 8000 draw call
-const MAX_QUEUE_SIZE = 8 << 10
+// initial size of render list, it grows to Limits.DrawCalls
+const MAX_QUEUE_SIZE = 1 << 10
 
 type RenderQueue struct {
 	SortMode
 	// render list
-	sortKey    [MAX_QUEUE_SIZE]uint64
-	sortValues [MAX_QUEUE_SIZE]uint16
+	sortKey    []uint64
+	sortValues []uint16
 
-	drawCallList [MAX_QUEUE_SIZE]RenderDraw
+	drawCallList []RenderDraw
 	drawCallNum  uint16
 
+	// max draw calls in a frame
+	limit int
+
 	sk SortKey
 
 	// per-drawCall state cache
@@ -101,15 +109,19 @@ type RenderQueue struct {
 
 	// render context
 	ctx *RenderContext
+
+	// statistics of current and last frame
+	frame, last FrameStats
 }
 
 func NewRenderQueue(m *ResManager) *RenderQueue {
 	ub := NewUniformBuffer()
 	rc := NewRenderContext(m, ub)
 	return &RenderQueue{
-		ctx: rc,
-		rm:  m,
-		ub:  ub,
+		ctx:   rc,
+		rm:    m,
+		ub:    ub,
+		limit: int(UInt16Max),
 	}
 }
 
@@ -147,6 +159,9 @@ func (rq *RenderQueue) SetVertexBuffer(stream uint8, id uint16, firstVertex, num
 	vbStream := &rq.drawCall.vertexBuffers[stream]
 	vbStream.vertexBuffer = id & IdMask
 	vbStream.vertexFormat = InvalidId
+	if ok, vb := rq.rm.VertexBuffer(id); ok {
+		vbStream.vertexFormat = vb.format
+	}
 	vbStream.firstVertex = firstVertex
 	vbStream.numVertex = numVertex
 }
@@ -163,6 +178,10 @@ func (rq *RenderQueue) SetTexture(stage uint8, samplerId uint16, texId uint16, f
 // 复制简单数据的时候（比如：Sampler），采用赋值的方式可能更快 TODO
 func (rq *RenderQueue) SetUniform(id uint16, ptr unsafe.Pointer) {
 	if ok, um := rq.rm.Uniform(id); ok {
+		if !rq.ub.reserve(4 + uint32(um.Size)*uint32(um.Count)) {
+			fail("uniform-buffer", UNIFORM_BUFFER_LIMIT)
+			return
+		}
 		opCode := Uniform_encode(um.Type, um.Slot, um.Size, um.Count)
 		rq.ub.WriteUInt32(opCode)
 		rq.ub.Copy(ptr, uint32(um.Size)*uint32(um.Count))
@@ -178,6 +197,10 @@ func (rq *RenderQueue) SetStencil(stencil uint32) {
 	rq.drawCall.stencil = stencil
 }
 
+func (rq *RenderQueue) SetRenderTarget(texId uint16) {
+	rq.drawCall.target = texId & IdMask
+}
+
 func (rq *RenderQueue) SetScissor(x, y, width, height uint16) (id uint16) {
 	id = rq.ctx.AddClipRect(x, y, width, height)
 	rq.drawCall.scissor = id
@@ -223,6 +246,16 @@ func (rq *RenderQueue) SetViewTransform(id uint8, view, proj *f32.Mat4, flags ui
 
 // conversion: depth range [-int16, int16]
 func (rq *RenderQueue) Submit(id uint8, program uint16, depth int32) uint32 {
+	if n := int(rq.drawCallNum); n >= len(rq.drawCallList) {
+		if n >= rq.limit {
+			fail("draw-call", rq.limit)
+			rq.drawCall.reset()
+			rq.ub.Seek(uint32(rq.uniformBegin))
+			return 0
+		}
+		rq.grow(rq.limit)
+	}
+
 	// uniform range
 	rq.uniformEnd = uint16(rq.ub.GetPos())
 
@@ -244,6 +277,7 @@ func (rq *RenderQueue) Submit(id uint8, program uint16, depth int32) uint32 {
 
 	rq.drawCallList[rq.drawCallNum] = rq.drawCall
 	rq.drawCallNum++
+	rq.frame.count(&rq.drawCall)
 
 	// reset state
 	rq.drawCall.reset()
@@ -264,17 +298,22 @@ func (rq *RenderQueue) Flush() int {
 	)
 
 	// Sort by SortKey
+	start := time.Now()
 	switch rq.SortMode {
 	case Ascending:
 		sort.Stable(ByKeyAscending{sortKeys, sortVals})
 	case Descending:
 		sort.Stable(ByKeyDescending{sortKeys, sortVals})
 	}
+	rq.frame.SortTime = time.Since(start)
+	rq.frame.UniformBytes = int(rq.ub.GetPos())
+	rq.frame.UniformCapacity = len(rq.ub.buffer)
 
 	// Draw respect to sorted values
 	rq.ctx.Draw(sortKeys, sortVals, drawList)
 
 	// Clear counter
+	rq.last, rq.frame = rq.frame, FrameStats{}
 	rq.drawCallNum = 0
 	rq.uniformBegin = 0
 	rq.uniformEnd = 0
@@ -284,6 +323,24 @@ func (rq *RenderQueue) Flush() int {
 	return int(num)
 }
 
+// grow doubles the render list, no more than limit.
+func (rq *RenderQueue) grow(limit int) {
+	size := len(rq.drawCallList) * 2
+	if size < MAX_QUEUE_SIZE {
+		size = MAX_QUEUE_SIZE
+	}
+	if size > limit {
+		size = limit
+	}
+	sortKey := make([]uint64, size)
+	copy(sortKey, rq.sortKey)
+	sortValues := make([]uint16, size)
+	copy(sortValues, rq.sortValues)
+	drawCallList := make([]RenderDraw, size)
+	copy(drawCallList, rq.drawCallList)
+	rq.sortKey, rq.sortValues, rq.drawCallList = sortKey, sortValues, drawCallList
+}
+
 // For 2D games, batch-system will reduce draw-call obviously,
 // A simple default sort method will be OK.
 
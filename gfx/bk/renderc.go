@@ -1,7 +1,9 @@
 package bk
 
 import (
+	"image"
 	"log"
+	"unsafe"
 
 	"sckorok/hid/gl"
 )
@@ -23,7 +25,10 @@ type RenderContext struct {
 	// clips rect, index-0 is a default zero-rect.
 	clips []Rect
 
+	// the back-buffer and its viewport, restored after drawing into a
+	// render target
 	backBufferFbo uint32
+	viewport      [4]int32
 }
 
 func NewRenderContext(r *ResManager, ub *UniformBuffer) *RenderContext {
@@ -35,13 +40,23 @@ func NewRenderContext(r *ResManager, ub *UniformBuffer) *RenderContext {
 }
 
 func (ctx *RenderContext) Init() {
+	if gSoft != nil {
+		return
+	}
 	ctx.vaoSupport = gl.NeedVao()
 	if ctx.vaoSupport {
 		gl.GenVertexArrays(1, &ctx.vao)
 	}
+	// not zero on iOS
+	var fbo int32
+	gl.GetIntegerv(gl.FRAMEBUFFER_BINDING, &fbo)
+	ctx.backBufferFbo = uint32(fbo)
 }
 
 func (ctx *RenderContext) Shutdown() {
+	if gSoft != nil {
+		return
+	}
 	if ctx.vao != 0 {
 		gl.BindVertexArray(0)
 		gl.DeleteVertexArrays(1, &ctx.vao)
@@ -51,7 +66,9 @@ func (ctx *RenderContext) Shutdown() {
 // Reset OpenGL state, then each frame has same starting state.
 func (ctx *RenderContext) Reset() {
 	ctx.clips = ctx.clips[:1]
-	gl.Disable(gl.SCISSOR_TEST)
+	if gSoft == nil {
+		gl.Disable(gl.SCISSOR_TEST)
+	}
 }
 
 func (ctx *RenderContext) AddClipRect(x, y, w, h uint16) uint16 {
@@ -66,6 +83,11 @@ func (ctx *RenderContext) AddClipRect(x, y, w, h uint16) uint16 {
 }
 
 func (ctx *RenderContext) Draw(sortKeys []uint64, sortValues []uint16, drawList []RenderDraw) {
+	if gSoft != nil {
+		gSoft.draw(ctx, sortKeys, sortValues, drawList)
+		return
+	}
+
 	// if vao support
 	if defaultVao := ctx.vao; 0 != defaultVao {
 		gl.BindVertexArray(defaultVao)
@@ -98,6 +120,15 @@ func (ctx *RenderContext) Draw(sortKeys []uint64, sortValues []uint16, drawList
 		changedStencil := currentState.stencil ^ draw.stencil
 		currentState.stencil = newStencil
 
+		// render target
+		if target := draw.target; currentState.target != target {
+			if currentState.target == InvalidId {
+				gl.GetIntegerv(gl.VIEWPORT, &ctx.viewport[0])
+			}
+			currentState.target = target
+			ctx.bindTarget(target)
+		}
+
 		// 2. Scissor
 		if scissor := draw.scissor; currentState.scissor != scissor {
 			currentState.scissor = scissor
@@ -115,13 +146,14 @@ func (ctx *RenderContext) Draw(sortKeys []uint64, sortValues []uint16, drawList
 		if changedStencil != 0 {
 			if newStencil != 0 {
 				if (gDebug & DebugQueue) != 0 {
-					log.Println("Renderc disable stencil")
+					log.Println("Renderc enable stencil")
 				}
 				gl.Enable(gl.STENCIL_TEST)
+				ctx.bindStencil(newStencil)
 			} else {
 				gl.Disable(gl.STENCIL_TEST)
 				if (gDebug & DebugQueue) != 0 {
-					log.Println("Renderc enable stencil")
+					log.Println("Renderc disable stencil")
 				}
 			}
 		}
@@ -184,6 +216,55 @@ func (ctx *RenderContext) Draw(sortKeys []uint64, sortValues []uint16, drawList
 			gl.DrawArrays(prim, int32(draw.firstIndex), int32(draw.num))
 		}
 	}
+
+	// back to the back-buffer
+	if currentState.target != InvalidId {
+		ctx.bindTarget(InvalidId)
+	}
+}
+
+// bindTarget binds the frame-buffer of render target and sets the viewport to
+// the size of it.
+func (ctx *RenderContext) bindTarget(target uint16) {
+	if target == InvalidId {
+		vp := ctx.viewport
+		gl.BindFramebuffer(gl.FRAMEBUFFER, ctx.backBufferFbo)
+		gl.Viewport(vp[0], vp[1], vp[2], vp[3])
+		return
+	}
+	tex := ctx.R.textures[target]
+	gl.BindFramebuffer(gl.FRAMEBUFFER, tex.fbo)
+	gl.Viewport(0, 0, int32(tex.Width), int32(tex.Height))
+}
+
+// ReadFrame reads the pixels in current viewport, rows are flipped since
+// OpenGL's origin is bottom-left.
+func (ctx *RenderContext) ReadFrame() *image.RGBA {
+	if gSoft != nil {
+		src := gSoft.target
+		img := image.NewRGBA(src.Rect)
+		copy(img.Pix, src.Pix)
+		return img
+	}
+
+	var vp [4]int32
+	gl.GetIntegerv(gl.VIEWPORT, &vp[0])
+	w, h := int(vp[2]), int(vp[3])
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	if w == 0 || h == 0 {
+		return img
+	}
+	gl.ReadPixels(vp[0], vp[1], vp[2], vp[3], gl.RGBA, gl.UNSIGNED_BYTE, unsafe.Pointer(&img.Pix[0]))
+
+	row := make([]byte, img.Stride)
+	for y := 0; y < h/2; y++ {
+		top := img.Pix[y*img.Stride : (y+1)*img.Stride]
+		bottom := img.Pix[(h-1-y)*img.Stride : (h-y)*img.Stride]
+		copy(row, top)
+		copy(top, bottom)
+		copy(bottom, row)
+	}
+	return img
 }
 
 func (ctx *RenderContext) updateResolution() {
@@ -221,6 +302,16 @@ func (ctx *RenderContext) bindUniform(begin, end uint32) {
 	}
 }
 
+func (ctx *RenderContext) bindStencil(stencil uint32) {
+	test, ref, mask, fail, zfail, pass := stencilDecode(stencil)
+	fn := uint32(gl.ALWAYS)
+	if test != 0 {
+		fn = g_CmpFunc[test]
+	}
+	gl.StencilFunc(fn, int32(ref), uint32(mask))
+	gl.StencilOp(g_StencilOp[fail], g_StencilOp[zfail], g_StencilOp[pass])
+}
+
 func (ctx *RenderContext) bindAttributes() {
 
 }
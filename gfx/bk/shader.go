@@ -26,9 +26,11 @@ type Shader struct {
 
 // 如果 AttrBinds 指定了一个 Stream，但是 Stream 并没有提供相应的数据(stride < Offset)
 // 此时应该 disable 当前 Attribute,
+// 如果 Stream 的 VertexBuffer 有 VertexLayout，按名字查找 Attribute 的格式
 func (sh *Shader) BindAttributes(R *ResManager, streams []Stream) {
 	var bindStream uint16 = UInt16Max
 	var bindStride uint16
+	var bindLayout *VertexLayout
 	for i := uint32(0); i < sh.numAttr; i++ {
 		bind := sh.AttrBinds[i]
 		stream := streams[bind.stream]
@@ -38,16 +40,17 @@ func (sh *Shader) BindAttributes(R *ResManager, streams []Stream) {
 			gl.BindBuffer(gl.ARRAY_BUFFER, buffer.Id)
 			bindStream = bind.stream
 			bindStride = buffer.layout
+			bindLayout = R.streamLayout(&stream)
 		}
 
 		slot := uint32(bind.slot)
-		enable := bindStride != 0
+		comp, enable := bind.format(bindLayout)
+		enable = enable && bindStride != 0
 
 		if enable {
 			gl.EnableVertexAttribArray(slot)
 
 			var (
-				comp   = bind.comp
 				num    = int32(comp.Num)
 				xType  = g_AttrType[comp.Type]
 				offset = int(comp.Offset)
@@ -74,10 +77,43 @@ type AttribBind struct {
 	stream uint16 // stream index
 
 	comp VertexComp // attribute component format
+	name string     // attribute name, to find the format in VertexLayout
+}
+
+// format returns the format of attribute in the layout of stream, or the
+// bound format if the stream has no layout.
+func (bind *AttribBind) format(layout *VertexLayout) (comp VertexComp, ok bool) {
+	if layout == nil {
+		return bind.comp, true
+	}
+	return layout.Find(bind.name)
+}
+
+// streamLayout returns the VertexLayout of stream, nil if it has no layout.
+func (rm *ResManager) streamLayout(stream *Stream) *VertexLayout {
+	if v := stream.vertexFormat; v != InvalidId && int(v) < len(rm.layouts) {
+		return rm.layouts[v]
+	}
+	return nil
+}
+
+// BindLayout binds the attributes of VertexLayout to the stream, the
+// attributes not used by the shader are skipped.
+func (sh *Shader) BindLayout(stream uint32, layout uint16) {
+	ok, l := R.Layout(layout)
+	if !ok {
+		log.Printf("fail to bind layout: %d", layout&IdMask)
+		return
+	}
+	for _, attr := range l.Attrs {
+		if attribLocation(sh.Program, attr.Name+"\x00") >= 0 {
+			sh.AddAttributeBinding(attr.Name+"\x00", stream, attr.VertexComp)
+		}
+	}
 }
 
 func (sh *Shader) AddAttributeBinding(attr string, stream uint32, comp VertexComp) {
-	slot := gl.GetAttribLocation(sh.Program, attr)
+	slot := attribLocation(sh.Program, attr)
 
 	if slot < 0 {
 		log.Printf("fail to bind attribute: %v, %s", comp, attr)
@@ -91,6 +127,7 @@ func (sh *Shader) AddAttributeBinding(attr string, stream uint32, comp VertexCom
 	bind.slot = uint16(slot)
 	bind.stream = uint16(stream)
 	bind.comp = comp
+	bind.name = attrName(attr)
 
 	sh.numAttr++
 }
@@ -104,10 +141,17 @@ type GLShader struct {
 }
 
 func (s *GLShader) Use() {
+	if gSoft != nil {
+		return
+	}
 	gl.UseProgram(s.Program)
 }
 
 func (s *GLShader) Create(vsh, fsh string) error {
+	if gSoft != nil {
+		s.Program = gSoft.newProgram(vsh, fsh)
+		return nil
+	}
 	if program, err := Compile(vsh, fsh); err == nil {
 		s.Program = program
 		//gl.BindFragDataLocation(program, 0, "gl_FragColor\x00")
@@ -157,11 +201,11 @@ func (s *GLShader) SetVec4fArray(name string, array []float32, count int32) {
 }
 
 func (s *GLShader) GetAttrLocation(attr string) uint32 {
-	return uint32(gl.GetAttribLocation(s.Program, attr))
+	return uint32(attribLocation(s.Program, attr))
 }
 
 func (s *GLShader) GetUniformLocation(uniform string) uint32 {
-	return uint32(gl.GetUniformLocation(s.Program, uniform))
+	return uint32(uniformLocation(s.Program, uniform))
 }
 
 func GetErrors() string {
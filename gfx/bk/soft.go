@@ -0,0 +1,658 @@
+package bk
+
+import (
+	"image"
+	"image/draw"
+	"math"
+	"regexp"
+	"strings"
+	"unsafe"
+
+	"sckorok/hid/gl"
+)
+
+// SoftRenderer is a pure-Go backend of the bk-api, it rasterizes the draw calls
+// into an image.RGBA instead of an OpenGL context. It works without a window and
+// renders the same result on every platform, so it can be used in golden-image
+// tests and headless tools.
+//
+// Shaders are not executed, the renderer implements the fixed pipeline used by
+// all the engine shaders: position is `proj * model * vec4(xyuv.xy, 1, 1)` and
+// fragment color is `texture(tex, xyuv.zw) * rgba`. A program declaring a `cutoff`
+// uniform discards the fragments whose texture alpha is less than it, like the
+// mask shader. Only triangles and triangle strips are drawn; blend modes, scissor
+// and stencil are supported, depth-test and color-write masks are ignored. A
+// render target is drawn into the image of texture, without stencil.
+type SoftRenderer struct {
+	target  *image.RGBA
+	stencil []uint8
+
+	// resources, the index is the Id of buffer/texture/program, 0 is not used
+	buffers  [][]byte
+	textures []*image.RGBA
+	programs []*softProgram
+}
+
+// NewSoftRenderer creates a renderer with a width x height frame-buffer.
+func NewSoftRenderer(width, height int) *SoftRenderer {
+	return &SoftRenderer{
+		target:   image.NewRGBA(image.Rect(0, 0, width, height)),
+		stencil:  make([]uint8, width*height),
+		buffers:  make([][]byte, 1),
+		textures: make([]*image.RGBA, 1),
+		programs: make([]*softProgram, 1),
+	}
+}
+
+// UseSoftRenderer replaces the OpenGL backend with the software renderer, resources
+// are created by the renderer after this call. Pass nil to switch back to OpenGL.
+// It should be called before any resource is allocated.
+func UseSoftRenderer(sr *SoftRenderer) {
+	gSoft = sr
+	if sr != nil {
+		size := sr.target.Rect.Size()
+		gRenderQ.Reset(uint16(size.X), uint16(size.Y), 1)
+	}
+}
+
+// SoftRendering returns true if the software renderer is in use.
+func SoftRendering() bool {
+	return gSoft != nil
+}
+
+// Image returns the frame-buffer.
+func (sr *SoftRenderer) Image() *image.RGBA {
+	return sr.target
+}
+
+// Clear fills the frame-buffer with the color, rgba's format is 0xRRGGBBAA.
+func (sr *SoftRenderer) Clear(rgba uint32) {
+	pix := sr.target.Pix
+	for i := 0; i < len(pix); i += 4 {
+		pix[i+0] = uint8(rgba >> 24)
+		pix[i+1] = uint8(rgba >> 16)
+		pix[i+2] = uint8(rgba >> 8)
+		pix[i+3] = uint8(rgba)
+	}
+}
+
+// ClearStencil fills the stencil-buffer with the value.
+func (sr *SoftRenderer) ClearStencil(s uint8) {
+	for i := range sr.stencil {
+		sr.stencil[i] = s
+	}
+}
+
+// current backend, nil means OpenGL
+var gSoft *SoftRenderer
+
+// -- buffers
+
+func (sr *SoftRenderer) newBuffer(size uint32, data unsafe.Pointer) uint32 {
+	buf := make([]byte, size)
+	if data != nil {
+		copy(buf, unsafe.Slice((*byte)(data), size))
+	}
+	sr.buffers = append(sr.buffers, buf)
+	return uint32(len(sr.buffers) - 1)
+}
+
+func (sr *SoftRenderer) updateBuffer(id, offset, size uint32, data unsafe.Pointer) {
+	if int(id) < len(sr.buffers) && sr.buffers[id] != nil {
+		copy(sr.buffers[id][offset:], unsafe.Slice((*byte)(data), size))
+	}
+}
+
+func (sr *SoftRenderer) deleteBuffer(id uint32) {
+	if int(id) < len(sr.buffers) {
+		sr.buffers[id] = nil
+	}
+}
+
+// -- textures
+
+func (sr *SoftRenderer) newTexture(rgba *image.RGBA) uint32 {
+	sr.textures = append(sr.textures, rgba)
+	return uint32(len(sr.textures) - 1)
+}
+
+func (sr *SoftRenderer) updateTexture(id uint32, rgba *image.RGBA, xoff, yoff, w, h int32) {
+	if int(id) < len(sr.textures) && sr.textures[id] != nil {
+		r := image.Rect(int(xoff), int(yoff), int(xoff+w), int(yoff+h))
+		draw.Draw(sr.textures[id], r, rgba, rgba.Rect.Min, draw.Src)
+	}
+}
+
+func (sr *SoftRenderer) deleteTexture(id uint32) {
+	if int(id) < len(sr.textures) {
+		sr.textures[id] = nil
+	}
+}
+
+// -- programs
+
+// softProgram keeps the names of the attributes and uniforms declared in the
+// shader source, the location is the index of the name.
+type softProgram struct {
+	attributes []string
+	uniforms   []string
+
+	// uniform values by location
+	values [][16]float32
+	// location of the matrices, -1 if not declared
+	proj, model int
+	// location of the alpha cutoff, -1 if not declared
+	cutoff int
+}
+
+var (
+	softAttribute = regexp.MustCompile(`(?m)^\s*(?:in|attribute)\s+[^;]*?(\w+)\s*;`)
+	softUniform   = regexp.MustCompile(`(?m)^\s*uniform\s+[^;]*?(\w+)\s*(?:\[\d*\])?\s*;`)
+)
+
+func (sr *SoftRenderer) newProgram(vsh, fsh string) uint32 {
+	p := &softProgram{proj: -1, model: -1}
+	for _, m := range softAttribute.FindAllStringSubmatch(vsh, -1) {
+		p.attributes = append(p.attributes, m[1])
+	}
+	for _, src := range [2]string{vsh, fsh} {
+		for _, m := range softUniform.FindAllStringSubmatch(src, -1) {
+			if indexOf(p.uniforms, m[1]) < 0 {
+				p.uniforms = append(p.uniforms, m[1])
+			}
+		}
+	}
+	p.values = make([][16]float32, len(p.uniforms))
+	if p.proj = indexOf(p.uniforms, "proj"); p.proj < 0 {
+		p.proj = indexOf(p.uniforms, "projection")
+	}
+	p.model = indexOf(p.uniforms, "model")
+	p.cutoff = indexOf(p.uniforms, "cutoff")
+
+	sr.programs = append(sr.programs, p)
+	return uint32(len(sr.programs) - 1)
+}
+
+func (sr *SoftRenderer) program(id uint32) *softProgram {
+	if int(id) < len(sr.programs) {
+		return sr.programs[id]
+	}
+	return nil
+}
+
+func (sr *SoftRenderer) attribLocation(program uint32, name string) int32 {
+	if p := sr.program(program); p != nil {
+		return int32(indexOf(p.attributes, strings.TrimRight(name, "\x00")))
+	}
+	return -1
+}
+
+func (sr *SoftRenderer) uniformLocation(program uint32, name string) int32 {
+	if p := sr.program(program); p != nil {
+		return int32(indexOf(p.uniforms, strings.TrimRight(name, "\x00")))
+	}
+	return -1
+}
+
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// attribLocation and uniformLocation select the backend.
+
+func attribLocation(program uint32, name string) int32 {
+	if gSoft != nil {
+		return gSoft.attribLocation(program, name)
+	}
+	return gl.GetAttribLocation(program, name)
+}
+
+func uniformLocation(program uint32, name string) int32 {
+	if gSoft != nil {
+		return gSoft.uniformLocation(program, name)
+	}
+	return gl.GetUniformLocation(program, name)
+}
+
+// -- draw
+
+// softVertex is a vertex in the frame-buffer space.
+type softVertex struct {
+	x, y  float32
+	u, v  float32
+	color [4]float32
+}
+
+func (sr *SoftRenderer) draw(ctx *RenderContext, sortKeys []uint64, sortValues []uint16, drawList []RenderDraw) {
+	var key SortKey
+	for i := range sortKeys {
+		key.Decode(sortKeys[i])
+		draw := &drawList[sortValues[i]]
+
+		shader := ctx.R.shaders[key.Shader]
+		p := sr.program(shader.Program)
+		if p == nil {
+			continue
+		}
+		if draw.uniformBegin < draw.uniformEnd {
+			sr.bindUniform(ctx.ub, p, uint32(draw.uniformBegin), uint32(draw.uniformEnd))
+		}
+		if draw.num == 0 {
+			continue
+		}
+		sr.drawPrimitive(ctx, shader, p, draw)
+	}
+}
+
+func (sr *SoftRenderer) bindUniform(ub *UniformBuffer, p *softProgram, begin, end uint32) {
+	ub.Seek(begin)
+	for ub.GetPos() < end {
+		opcode := ub.ReadUInt32()
+		if opcode == uint32(UniformEnd) {
+			break
+		}
+		var uType, loc, size, num uint8
+		Uniform_decode(opcode, &uType, &loc, &size, &num)
+		data := ub.ReadPointer(uint32(size) * uint32(num))
+
+		if int(loc) < len(p.values) {
+			n := int(size) * int(num) / 4
+			if n > 16 {
+				n = 16
+			}
+			copy(p.values[loc][:], unsafe.Slice((*float32)(data), n))
+		}
+	}
+}
+
+func (sr *SoftRenderer) drawPrimitive(ctx *RenderContext, shader *Shader, p *softProgram, draw *RenderDraw) {
+	pt := (draw.state & ST.PT_MASK) >> ST.PT_SHIFT
+	if pt != 0 && pt != 1 {
+		return // lines and points are not supported
+	}
+
+	// frame-buffer
+	target, stencil := sr.target, sr.stencil
+	if rt := draw.target; rt != InvalidId {
+		id := ctx.R.textures[rt].Id
+		if int(id) >= len(sr.textures) || sr.textures[id] == nil {
+			return
+		}
+		target, stencil = sr.textures[id], nil
+	}
+
+	// transform
+	mvp := softIdentity
+	if p.proj >= 0 {
+		mvp = p.values[p.proj]
+	}
+	if p.model >= 0 {
+		mvp = mulMat4(&mvp, &p.values[p.model])
+	}
+
+	// fetch vertex
+	fetch := func(index int) (v softVertex) {
+		v.color = [4]float32{1, 1, 1, 1}
+		for i := uint32(0); i < shader.numAttr; i++ {
+			bind := &shader.AttrBinds[i]
+			if int(bind.slot) >= len(p.attributes) {
+				continue
+			}
+			stream := draw.vertexBuffers[bind.stream]
+			vb := ctx.R.vertexBuffers[stream.vertexBuffer&IdMask]
+			comp, ok := bind.format(ctx.R.streamLayout(&stream))
+			if !ok || int(vb.Id) >= len(sr.buffers) || vb.layout == 0 || uint16(comp.Offset) >= vb.layout {
+				continue
+			}
+			base := (int(stream.firstVertex)+index)*int(vb.layout) + int(comp.Offset)
+			value := readAttr(sr.buffers[vb.Id], base, comp)
+
+			switch p.attributes[bind.slot] {
+			case "xyuv":
+				x, y := value[0], value[1]
+				w := mvp[3]*x + mvp[7]*y + mvp[11] + mvp[15]
+				if w == 0 {
+					w = 1
+				}
+				nx := (mvp[0]*x + mvp[4]*y + mvp[8] + mvp[12]) / w
+				ny := (mvp[1]*x + mvp[5]*y + mvp[9] + mvp[13]) / w
+				size := target.Rect.Size()
+				v.x = (nx + 1) / 2 * float32(size.X)
+				v.y = (1 - ny) / 2 * float32(size.Y)
+				v.u, v.v = value[2], value[3]
+			case "rgba":
+				v.color = value
+			}
+		}
+		return
+	}
+
+	// indices
+	var indices []byte
+	if ib := draw.indexBuffer; ib != InvalidId {
+		if id := ctx.R.indexBuffers[ib&IdMask].Id; int(id) < len(sr.buffers) {
+			indices = sr.buffers[id]
+		}
+	}
+	index := func(i int) int {
+		if indices != nil {
+			i = int(draw.firstIndex) + i
+			if 2*i+1 >= len(indices) {
+				return -1
+			}
+			return int(indices[2*i]) | int(indices[2*i+1])<<8
+		}
+		return int(draw.firstIndex) + i
+	}
+
+	r := &softRaster{
+		target:  target,
+		stencil: stencil,
+		clip:    target.Rect,
+		blend:   uint8((draw.state & ST.BLEND_MASK) >> ST.BLEND_SHIFT),
+		cutoff:  -1,
+	}
+	if stencil != nil {
+		r.sten = draw.stencil
+	}
+	if p.cutoff >= 0 {
+		r.cutoff = p.values[p.cutoff][0]
+	}
+	if tex := draw.textures[0]; tex != InvalidId {
+		if id := ctx.R.textures[tex&IdMask].Id; int(id) < len(sr.textures) {
+			r.texture = sr.textures[id]
+		}
+	}
+	if clip := ctx.clips[draw.scissor]; !clip.isZero() {
+		h := target.Rect.Dy()
+		x, y := int(clip.x), h-int(clip.y)-int(clip.h)
+		r.clip = r.clip.Intersect(image.Rect(x, y, x+int(clip.w), y+int(clip.h)))
+	}
+
+	var tri [3]softVertex
+	step := 3
+	if pt == 1 {
+		step = 1
+	}
+	for i := 0; i+2 < int(draw.num); i += step {
+		ok := true
+		for k := 0; k < 3; k++ {
+			vi := index(i + k)
+			if vi < 0 {
+				ok = false
+				break
+			}
+			tri[k] = fetch(vi)
+		}
+		if ok {
+			r.triangle(&tri[0], &tri[1], &tri[2])
+		}
+	}
+}
+
+var softIdentity = [16]float32{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}
+
+// column-major matrix multiply: a * b
+func mulMat4(a, b *[16]float32) (m [16]float32) {
+	for c := 0; c < 4; c++ {
+		for r := 0; r < 4; r++ {
+			m[c*4+r] = a[r]*b[c*4] + a[4+r]*b[c*4+1] + a[8+r]*b[c*4+2] + a[12+r]*b[c*4+3]
+		}
+	}
+	return
+}
+
+func readAttr(buf []byte, offset int, comp VertexComp) (v [4]float32) {
+	v[3] = 1
+	norm := comp.Normalized&0x01 != 0
+	for i := 0; i < int(comp.Num) && i < 4; i++ {
+		switch comp.Type {
+		case AttrInt8:
+			if o := offset + i; o < len(buf) {
+				v[i] = float32(int8(buf[o]))
+				if norm {
+					v[i] /= 0x7F
+				}
+			}
+		case AttrUInt8:
+			if o := offset + i; o < len(buf) {
+				v[i] = float32(buf[o])
+				if norm {
+					v[i] /= 0xFF
+				}
+			}
+		case AttrInt16:
+			if o := offset + i*2; o+1 < len(buf) {
+				v[i] = float32(int16(uint16(buf[o]) | uint16(buf[o+1])<<8))
+				if norm {
+					v[i] /= 0x7FFF
+				}
+			}
+		case AttrUInt16:
+			if o := offset + i*2; o+1 < len(buf) {
+				v[i] = float32(uint16(buf[o]) | uint16(buf[o+1])<<8)
+				if norm {
+					v[i] /= 0xFFFF
+				}
+			}
+		case AttrFloat:
+			if o := offset + i*4; o+3 < len(buf) {
+				bits := uint32(buf[o]) | uint32(buf[o+1])<<8 | uint32(buf[o+2])<<16 | uint32(buf[o+3])<<24
+				v[i] = math.Float32frombits(bits)
+			}
+		}
+	}
+	return
+}
+
+// softRaster fills triangles with edge functions, pixels are sampled at the centre.
+type softRaster struct {
+	target  *image.RGBA
+	stencil []uint8
+	texture *image.RGBA
+	clip    image.Rectangle
+	blend   uint8
+	sten    uint32
+	cutoff  float32
+}
+
+func (r *softRaster) triangle(v0, v1, v2 *softVertex) {
+	area := edge(v0, v1, v2.x, v2.y)
+	if area == 0 {
+		return
+	}
+	// same orientation for all triangles, so shared edges are walked in opposite
+	// directions and the fill rule draws the pixels on them only once
+	if area < 0 {
+		v1, v2 = v2, v1
+		area = -area
+	}
+	minX := int(math.Floor(float64(min3(v0.x, v1.x, v2.x))))
+	maxX := int(math.Ceil(float64(max3(v0.x, v1.x, v2.x))))
+	minY := int(math.Floor(float64(min3(v0.y, v1.y, v2.y))))
+	maxY := int(math.Ceil(float64(max3(v0.y, v1.y, v2.y))))
+	bound := image.Rect(minX, minY, maxX, maxY).Intersect(r.clip)
+
+	for y := bound.Min.Y; y < bound.Max.Y; y++ {
+		py := float32(y) + .5
+		for x := bound.Min.X; x < bound.Max.X; x++ {
+			px := float32(x) + .5
+			w0 := edge(v1, v2, px, py)
+			w1 := edge(v2, v0, px, py)
+			w2 := edge(v0, v1, px, py)
+			if !inside(w0, v1, v2) || !inside(w1, v2, v0) || !inside(w2, v0, v1) {
+				continue
+			}
+			l0, l1, l2 := w0/area, w1/area, w2/area
+			t := r.sample(v0.u*l0+v1.u*l1+v2.u*l2, v0.v*l0+v1.v*l1+v2.v*l2)
+			// discarded fragments don't touch the stencil-buffer
+			if t[3] < r.cutoff {
+				continue
+			}
+			if r.sten != 0 && !r.stencilTest(x, y) {
+				continue
+			}
+			var c [4]float32
+			for i := range c {
+				c[i] = v0.color[i]*l0 + v1.color[i]*l1 + v2.color[i]*l2
+			}
+			for i := range c {
+				c[i] *= t[i]
+			}
+			r.write(x, y, c)
+		}
+	}
+}
+
+func edge(a, b *softVertex, x, y float32) float32 {
+	return (b.x-a.x)*(y-a.y) - (b.y-a.y)*(x-a.x)
+}
+
+// fill rule: a pixel exactly on the edge belongs to one of the two triangles
+func inside(w float32, a, b *softVertex) bool {
+	if w != 0 {
+		return w > 0
+	}
+	dy, dx := b.y-a.y, b.x-a.x
+	return dy > 0 || (dy == 0 && dx < 0)
+}
+
+// bilinear filter, clamp to edge. Without texture the color is black like
+// an incomplete texture in OpenGL.
+func (r *softRaster) sample(u, v float32) (c [4]float32) {
+	tex := r.texture
+	if tex == nil {
+		c[3] = 1
+		return
+	}
+	w, h := tex.Rect.Dx(), tex.Rect.Dy()
+	fx, fy := u*float32(w)-.5, v*float32(h)-.5
+	x0, y0 := int(math.Floor(float64(fx))), int(math.Floor(float64(fy)))
+	ax, ay := fx-float32(x0), fy-float32(y0)
+
+	texel := func(x, y int) []uint8 {
+		x, y = clampInt(x, 0, w-1), clampInt(y, 0, h-1)
+		i := y*tex.Stride + x*4
+		return tex.Pix[i : i+4]
+	}
+	p00, p10 := texel(x0, y0), texel(x0+1, y0)
+	p01, p11 := texel(x0, y0+1), texel(x0+1, y0+1)
+	for i := range c {
+		top := float32(p00[i])*(1-ax) + float32(p10[i])*ax
+		bottom := float32(p01[i])*(1-ax) + float32(p11[i])*ax
+		c[i] = (top*(1-ay) + bottom*ay) / 0xFF
+	}
+	return
+}
+
+// blend the color with frame-buffer, see g_Blend
+func (r *softRaster) write(x, y int, c [4]float32) {
+	i := y*r.target.Stride + x*4
+	pix := r.target.Pix[i : i+4]
+
+	var src, dst float32 = 1, 0
+	switch r.blend {
+	case 2: // premultiplied
+		src, dst = 1, 1-c[3]
+	case 3: // non-premultiplied
+		src, dst = c[3], 1-c[3]
+	case 4: // additive
+		src, dst = c[3], 1
+	}
+	for k := range pix {
+		v := c[k]*src + float32(pix[k])/0xFF*dst
+		pix[k] = uint8(clampF(v, 0, 1)*0xFF + .5)
+	}
+}
+
+// stencil test and stencil operation, there is no depth-buffer, so the
+// depth test always passes.
+func (r *softRaster) stencilTest(x, y int) bool {
+	test, ref, mask, fail, _, pass := stencilDecode(r.sten)
+	i := y*r.target.Rect.Dx() + x
+	s := r.stencil[i]
+
+	a, b := ref&mask, s&mask
+	var ok bool
+	switch test {
+	case 1:
+		ok = a < b
+	case 2:
+		ok = a <= b
+	case 3:
+		ok = a == b
+	case 4:
+		ok = a >= b
+	case 5:
+		ok = a > b
+	case 6:
+		ok = a != b
+	case 7:
+		ok = false
+	default:
+		ok = true
+	}
+	op := fail
+	if ok {
+		op = pass
+	}
+	r.stencil[i] = stencilOp(op, s, ref)
+	return ok
+}
+
+func stencilOp(op uint32, s, ref uint8) uint8 {
+	switch op {
+	case 1:
+		return 0
+	case 2:
+		return ref
+	case 3:
+		if s < 0xFF {
+			s++
+		}
+	case 4:
+		if s > 0 {
+			s--
+		}
+	case 5:
+		return ^s
+	case 6:
+		return s + 1
+	case 7:
+		return s - 1
+	}
+	return s
+}
+
+func min3(a, b, c float32) float32 {
+	return float32(math.Min(float64(a), math.Min(float64(b), float64(c))))
+}
+
+func max3(a, b, c float32) float32 {
+	return float32(math.Max(float64(a), math.Max(float64(b), float64(c))))
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func clampF(v, lo, hi float32) float32 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
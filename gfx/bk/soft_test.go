@@ -0,0 +1,336 @@
+package bk
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	"sckorok/math/f32"
+)
+
+var update = flag.Bool("update", false, "update the golden images in testdata")
+
+const testVsh = `
+#version 330
+
+uniform mat4 proj;
+
+in vec4 xyuv;
+in vec4 rgba;
+
+out vec4 outColor;
+out vec2 outTexCoord;
+
+void main() {
+	outColor = rgba;
+	outTexCoord = xyuv.zw;
+	gl_Position = proj * vec4(xyuv.xy, 1, 1);
+}
+` + "\x00"
+
+const testFsh = `
+#version 330
+
+uniform sampler2D tex;
+
+in vec2 outTexCoord;
+in vec4 outColor;
+
+out vec4 outputColor;
+
+void main() {
+	outputColor = texture(tex, outTexCoord) * outColor;
+}
+` + "\x00"
+
+type testVertex struct {
+	X, Y, U, V float32
+	RGBA       uint32
+}
+
+// softScene allocates a shader, a 2x2 texture and a quad index buffer.
+type softScene struct {
+	sr      *SoftRenderer
+	shader  uint16
+	texture uint16
+	index   uint16
+	proj    uint16
+}
+
+func newSoftScene(t *testing.T) *softScene {
+	sr := NewSoftRenderer(32, 32)
+	UseSoftRenderer(sr)
+	R.Init()
+	t.Cleanup(func() {
+		// the next test should not inherit the state of an unsubmitted draw
+		gRenderQ.drawCall.reset()
+		UseSoftRenderer(nil)
+	})
+
+	s := &softScene{sr: sr}
+	id, sh := R.AllocShader(testVsh, testFsh)
+	sh.AddAttributeBinding("xyuv\x00", 0, VertexComp{4, AttrFloat, 0, 0})
+	sh.AddAttributeBinding("rgba\x00", 0, VertexComp{4, AttrUInt8, 16, 1})
+	s.shader = id
+	s.proj, _ = R.AllocUniform(id, "proj\x00", UniformMat4, 1)
+
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{0xFF, 0, 0, 0xFF})
+	img.Set(1, 0, color.RGBA{0, 0xFF, 0, 0xFF})
+	img.Set(0, 1, color.RGBA{0, 0, 0xFF, 0xFF})
+	img.Set(1, 1, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF})
+	s.texture, _ = R.AllocTexture(img)
+
+	index := []uint16{3, 0, 1, 3, 1, 2}
+	s.index, _ = R.AllocIndexBuffer(Memory{unsafe.Pointer(&index[0]), 12})
+
+	sr.Clear(0x404040FF)
+	return s
+}
+
+// quad submits a rectangle (x, y, w, h) with the color, origin is bottom-left.
+func (s *softScene) quad(x, y, w, h float32, rgba uint32, state uint64) {
+	vertex := []testVertex{
+		{x, y, 0, 1, rgba},
+		{x + w, y, 1, 1, rgba},
+		{x + w, y + h, 1, 0, rgba},
+		{x, y + h, 0, 0, rgba},
+	}
+	vb, _ := R.AllocVertexBuffer(Memory{unsafe.Pointer(&vertex[0]), 4 * 20}, 20)
+	proj := f32.Ortho2D(0, 32, 0, 32)
+
+	SetUniform(s.proj, unsafe.Pointer(&proj[0]))
+	SetState(state, 0)
+	SetTexture(0, 0, s.texture, 0)
+	SetVertexBuffer(0, vb, 0, 4)
+	SetIndexBuffer(s.index, 0, 6)
+	Submit(0, s.shader, 0)
+}
+
+func TestSoftBlend(t *testing.T) {
+	s := newSoftScene(t)
+
+	// RGBA uint32 is stored as little-endian: 0xAABBGGRR
+	s.quad(2, 2, 16, 16, 0xFFFFFFFF, ST_BLEND.ALPHA_NON_PREMULTIPLIED)
+	s.quad(10, 10, 16, 16, 0x80FFFFFF, ST_BLEND.ALPHA_NON_PREMULTIPLIED)
+	s.quad(20, 2, 10, 10, 0xFF0000FF, ST_BLEND.ADDITIVE)
+	Flush()
+
+	golden(t, s.sr.Image(), "soft_blend.png")
+}
+
+func TestSoftScissor(t *testing.T) {
+	s := newSoftScene(t)
+
+	SetScissor(8, 4, 16, 8)
+	s.quad(0, 0, 32, 32, 0xFFFFFFFF, ST_BLEND.ALPHA_NON_PREMULTIPLIED)
+	Flush()
+
+	img := s.sr.Image()
+	// scissor rect is in frame-buffer coordinates, origin is bottom-left
+	if c := img.RGBAAt(16, 32-8); c.A != 0xFF || c == (color.RGBA{0x40, 0x40, 0x40, 0xFF}) {
+		t.Error("pixel in scissor should be drawn:", c)
+	}
+	if c := img.RGBAAt(16, 8); c != (color.RGBA{0x40, 0x40, 0x40, 0xFF}) {
+		t.Error("pixel out of scissor should be clipped:", c)
+	}
+	golden(t, img, "soft_scissor.png")
+}
+
+func TestSoftStencil(t *testing.T) {
+	s := newSoftScene(t)
+
+	// write 1 into the stencil-buffer, the quad is drawn too
+	SetStencil(Stencil(ST_STENCIL.TEST_ALWAYS, 1, 0xFF, ST_STENCIL.OP_KEEP, ST_STENCIL.OP_KEEP, ST_STENCIL.OP_REPLACE))
+	s.quad(4, 4, 12, 12, 0xFF00FF00, ST_BLEND.ALPHA_NON_PREMULTIPLIED)
+	// draw where the stencil equals 1
+	SetStencil(Stencil(ST_STENCIL.TEST_EQUAL, 1, 0xFF, ST_STENCIL.OP_KEEP, ST_STENCIL.OP_KEEP, ST_STENCIL.OP_KEEP))
+	s.quad(8, 8, 24, 24, 0xFFFFFFFF, ST_BLEND.ALPHA_NON_PREMULTIPLIED)
+	Flush()
+
+	img := s.sr.Image()
+	if c := img.RGBAAt(24, 8); c != (color.RGBA{0x40, 0x40, 0x40, 0xFF}) {
+		t.Error("pixel out of stencil should be discarded:", c)
+	}
+	golden(t, img, "soft_stencil.png")
+}
+
+func TestSoftStencilReset(t *testing.T) {
+	s := newSoftScene(t)
+
+	// the stencil-buffer is cleared to 0, nothing passes the masked draw
+	SetStencil(Stencil(ST_STENCIL.TEST_EQUAL, 1, 0xFF, ST_STENCIL.OP_KEEP, ST_STENCIL.OP_KEEP, ST_STENCIL.OP_KEEP))
+	s.quad(0, 0, 16, 32, 0xFFFFFFFF, ST_BLEND.ALPHA_NON_PREMULTIPLIED)
+	// the next draw doesn't set stencil, it should not be masked
+	s.quad(16, 0, 16, 32, 0xFFFFFFFF, ST_BLEND.ALPHA_NON_PREMULTIPLIED)
+	Flush()
+
+	img := s.sr.Image()
+	if c := img.RGBAAt(8, 16); c != (color.RGBA{0x40, 0x40, 0x40, 0xFF}) {
+		t.Error("masked draw should be discarded:", c)
+	}
+	if c := img.RGBAAt(24, 16); c == (color.RGBA{0x40, 0x40, 0x40, 0xFF}) {
+		t.Error("unmasked draw should not inherit the stencil:", c)
+	}
+}
+
+func TestSoftRenderTarget(t *testing.T) {
+	s := newSoftScene(t)
+	rt, tex := R.AllocRenderTarget(8, 8)
+	if tex == nil {
+		t.Fatal("fail to alloc render target")
+	}
+	img := s.sr.textures[tex.Id]
+
+	// the view maps to the whole target, the left half is drawn
+	SetRenderTarget(rt)
+	s.quad(0, 0, 16, 32, 0xFFFFFFFF, ST_BLEND.ISABLE)
+	// the next draw is on the back-buffer
+	s.quad(0, 0, 4, 4, 0xFFFFFFFF, ST_BLEND.ISABLE)
+	Flush()
+
+	// the top-left texel is red
+	if c := img.RGBAAt(0, 0); c != (color.RGBA{0xFF, 0, 0, 0xFF}) {
+		t.Error("render target should be drawn:", c)
+	}
+	if c := img.RGBAAt(7, 7); c != (color.RGBA{}) {
+		t.Error("pixel out of the quad should not be drawn:", c)
+	}
+	screen := s.sr.Image()
+	if c := screen.RGBAAt(16, 16); c != (color.RGBA{0x40, 0x40, 0x40, 0xFF}) {
+		t.Error("back-buffer should not be drawn:", c)
+	}
+	if c := screen.RGBAAt(1, 30); c == (color.RGBA{0x40, 0x40, 0x40, 0xFF}) {
+		t.Error("render target should be reset after submit:", c)
+	}
+
+	// sample the render target
+	s.texture = rt
+	s.quad(0, 0, 32, 32, 0xFFFFFFFF, ST_BLEND.ISABLE)
+	Flush()
+	if c := screen.RGBAAt(0, 0); c != (color.RGBA{0xFF, 0, 0, 0xFF}) {
+		t.Error("render target should be sampled:", c)
+	}
+	if c := screen.RGBAAt(31, 31); c != (color.RGBA{}) {
+		t.Error("render target should be sampled:", c)
+	}
+}
+
+const testCutoffFsh = `
+#version 330
+
+uniform sampler2D tex;
+uniform float cutoff;
+
+in vec2 outTexCoord;
+in vec4 outColor;
+
+out vec4 outputColor;
+
+void main() {
+	if (texture(tex, outTexCoord).a < cutoff) {
+		discard;
+	}
+	outputColor = texture(tex, outTexCoord) * outColor;
+}
+` + "\x00"
+
+func TestSoftCutoff(t *testing.T) {
+	s := newSoftScene(t)
+	shader, tex, proj := s.shader, s.texture, s.proj
+
+	// the left half of texture is transparent
+	id, sh := R.AllocShader(testVsh, testCutoffFsh)
+	sh.AddAttributeBinding("xyuv\x00", 0, VertexComp{4, AttrFloat, 0, 0})
+	sh.AddAttributeBinding("rgba\x00", 0, VertexComp{4, AttrUInt8, 16, 1})
+	s.shader = id
+	s.proj, _ = R.AllocUniform(id, "proj\x00", UniformMat4, 1)
+	cutoff, _ := R.AllocUniform(id, "cutoff\x00", UniformVec1, 1)
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(1, 0, image.White)
+	s.texture, _ = R.AllocTexture(img)
+
+	// discarded pixels don't write the stencil-buffer
+	v := float32(.5)
+	SetUniform(cutoff, unsafe.Pointer(&v))
+	SetStencil(Stencil(ST_STENCIL.TEST_ALWAYS, 1, 0xFF, ST_STENCIL.OP_KEEP, ST_STENCIL.OP_KEEP, ST_STENCIL.OP_REPLACE))
+	s.quad(0, 0, 32, 32, 0x00000000, ST_BLEND.ALPHA_PREMULTIPLIED)
+	Flush()
+
+	s.shader, s.texture, s.proj = shader, tex, proj
+	SetStencil(Stencil(ST_STENCIL.TEST_EQUAL, 1, 0xFF, ST_STENCIL.OP_KEEP, ST_STENCIL.OP_KEEP, ST_STENCIL.OP_KEEP))
+	s.quad(0, 0, 32, 32, 0xFFFFFFFF, ST_BLEND.ALPHA_NON_PREMULTIPLIED)
+	Flush()
+
+	img = s.sr.Image()
+	if c := img.RGBAAt(4, 16); c != (color.RGBA{0x40, 0x40, 0x40, 0xFF}) {
+		t.Error("transparent pixel should not write stencil:", c)
+	}
+	if c := img.RGBAAt(28, 16); c == (color.RGBA{0x40, 0x40, 0x40, 0xFF}) {
+		t.Error("opaque pixel should write stencil:", c)
+	}
+}
+
+func TestStencilEncode(t *testing.T) {
+	st := Stencil(ST_STENCIL.TEST_EQUAL, 3, 0x0F, ST_STENCIL.OP_ZERO, ST_STENCIL.OP_INCR, ST_STENCIL.OP_REPLACE)
+	test, ref, mask, fail, zfail, pass := stencilDecode(st)
+	if test != ST_STENCIL.TEST_EQUAL || ref != 3 || mask != 0x0F {
+		t.Error("stencil test decode error:", test, ref, mask)
+	}
+	if fail != ST_STENCIL.OP_ZERO || zfail != ST_STENCIL.OP_INCR || pass != ST_STENCIL.OP_REPLACE {
+		t.Error("stencil op decode error:", fail, zfail, pass)
+	}
+}
+
+// golden compares the image with testdata/name, a channel can be off by one
+// since float rounding differs between architectures.
+func golden(t *testing.T, img *image.RGBA, name string) {
+	t.Helper()
+	file := filepath.Join("testdata", name)
+	if *update {
+		f, err := os.Create(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if err := png.Encode(f, img); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	want, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want.Bounds() != img.Bounds() {
+		t.Fatal("image size error:", img.Bounds(), "expected:", want.Bounds())
+	}
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			r, g, b, a := want.At(x, y).RGBA()
+			c := img.RGBAAt(x, y)
+			if diff(c.R, r) > 1 || diff(c.G, g) > 1 || diff(c.B, b) > 1 || diff(c.A, a) > 1 {
+				t.Fatalf("pixel (%d, %d) is %v, expected: %v", x, y, c, want.At(x, y))
+			}
+		}
+	}
+}
+
+func diff(v uint8, w uint32) int {
+	d := int(v) - int(w>>8)
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,97 @@
+package bk
+
+import (
+	"time"
+)
+
+// FrameStats is the statistics of the draw calls in a frame.
+type FrameStats struct {
+	// draw calls with geometry, the uniform-only submissions are not counted
+	DrawCalls int
+	Vertices  int
+	Indices   int
+
+	// bytes used in the uniform buffer and the size of buffer
+	UniformBytes    int
+	UniformCapacity int
+
+	// time to sort the draw calls in Flush
+	SortTime time.Duration
+}
+
+// ResStats is the resources in use and the memory they take on GPU.
+type ResStats struct {
+	IndexBuffers  int
+	VertexBuffers int
+	Textures      int
+	Uniforms      int
+	Shaders       int
+
+	IndexMemory   int
+	VertexMemory  int
+	TextureMemory int
+}
+
+// Stats returns the statistics of the last flushed frame.
+func Stats() FrameStats {
+	return gRenderQ.last
+}
+
+// Submitted returns the draw calls and vertices submitted in current frame,
+// it's used to measure the parts of frame.
+func Submitted() (drawCalls, vertices int) {
+	return gRenderQ.frame.DrawCalls, gRenderQ.frame.Vertices
+}
+
+// count a submitted draw call
+func (fs *FrameStats) count(draw *RenderDraw) {
+	if draw.num > 0 {
+		fs.DrawCalls++
+		fs.Vertices += int(draw.vertexBuffers[0].numVertex)
+		fs.Indices += int(draw.num)
+	}
+}
+
+// Stats counts the resources in use, the texture memory assumes 4 bytes a
+// pixel and mipmaps take 1/3 more.
+func (rm *ResManager) Stats() (s ResStats) {
+	free := func(fl *FreeList) map[uint16]bool {
+		m := make(map[uint16]bool, len(fl.slots))
+		for _, v := range fl.slots {
+			m[v] = true
+		}
+		return m
+	}
+	frees := free(&rm.ibFrees)
+	for i := uint16(1); i < rm.ibIndex; i++ {
+		if !frees[i] {
+			s.IndexBuffers++
+			s.IndexMemory += int(rm.indexBuffers[i].size)
+		}
+	}
+	frees = free(&rm.vbFrees)
+	for i := uint16(1); i < rm.vbIndex; i++ {
+		if !frees[i] {
+			s.VertexBuffers++
+			s.VertexMemory += int(rm.vertexBuffers[i].size)
+		}
+	}
+	frees = free(&rm.ttFrees)
+	for i := uint16(1); i < rm.ttIndex; i++ {
+		if tex := rm.textures[i]; !frees[i] {
+			n := int(tex.Width) * int(tex.Height) * 4
+			if tex.Options.Mipmap {
+				n += n / 3
+			}
+			s.Textures++
+			s.TextureMemory += n
+		}
+	}
+	if rm.umIndex > 0 {
+		s.Uniforms = int(rm.umIndex) - 1 - len(rm.umFrees.slots)
+	}
+	if rm.shIndex > 0 {
+		s.Shaders = int(rm.shIndex) - 1 - len(rm.shFrees.slots)
+	}
+	return
+}
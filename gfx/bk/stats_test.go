@@ -0,0 +1,35 @@
+package bk
+
+import (
+	"testing"
+)
+
+func TestFrameStats(t *testing.T) {
+	s := newSoftScene(t)
+	res := R.Stats()
+
+	s.quad(2, 2, 8, 8, 0xFFFFFFFF, ST_BLEND.ALPHA_PREMULTIPLIED)
+	s.quad(12, 2, 8, 8, 0xFFFFFFFF, ST_BLEND.ALPHA_PREMULTIPLIED)
+	if dc, vc := Submitted(); dc != 2 || vc != 8 {
+		t.Error("submitted draw calls error:", dc, vc)
+	}
+	// uniform-only submission
+	Touch(0)
+	Flush()
+
+	fs := Stats()
+	if fs.DrawCalls != 2 || fs.Vertices != 8 || fs.Indices != 12 {
+		t.Error("frame stats error:", fs)
+	}
+	if fs.UniformBytes == 0 || fs.UniformCapacity != UNIFORM_BUFFER_SIZE {
+		t.Error("uniform usage error:", fs.UniformBytes, fs.UniformCapacity)
+	}
+	if dc, _ := Submitted(); dc != 0 {
+		t.Error("counter should be reset after flush:", dc)
+	}
+
+	// two vertex buffers of the quads
+	if r := R.Stats(); r.VertexBuffers != res.VertexBuffers+2 || r.VertexMemory != res.VertexMemory+160 {
+		t.Error("vertex buffers in use error:", r)
+	}
+}
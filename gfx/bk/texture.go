@@ -7,6 +7,9 @@ import (
 	_ "image/jpeg"
 	_ "image/png"
 
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+
 	"sckorok/hid/gl"
 	"sckorok/math/f32"
 	"unsafe"
@@ -16,16 +19,58 @@ import (
 处理纹理相关问题
 */
 
+// Filter is the texture filter used when the texture is minified or magnified.
+type Filter uint8
+
+const (
+	FilterLinear Filter = iota
+	FilterNearest
+)
+
+// Wrap is the texture wrap mode of texture coordinates out of [0, 1].
+type Wrap uint8
+
+const (
+	WrapClamp Wrap = iota
+	WrapRepeat
+	WrapMirror
+)
+
+// TextureOptions controls how a texture is sampled and uploaded, the zero
+// value is linear filter, clamp to edge and no mipmaps.
+//
+// Mipmap generates mipmaps and the min filter also blends between mipmaps,
+// OpenGL ES 2 and WebGL 1 only support mipmaps and repeat wrap for
+// power-of-two textures. Images are converted to premultiplied alpha when
+// uploaded, set Premultiplied if the pixels of a non-premultiplied image type
+// (image.NRGBA) are premultiplied already, e.g. exported by TexturePacker.
+type TextureOptions struct {
+	MinFilter, MagFilter Filter
+	WrapS, WrapT         Wrap
+	Mipmap               bool
+	Premultiplied        bool
+}
+
 type Texture2D struct {
 	Width, Height float32
 	Id            uint32
+	Options       TextureOptions
+
+	// frame-buffer object of a render target, 0 if it's not
+	fbo uint32
 }
 
 func (t *Texture2D) Create(image image.Image) error {
+	return t.CreateOptions(image, TextureOptions{})
+}
+
+// CreateOptions creates the texture with the sampling options.
+func (t *Texture2D) CreateOptions(image image.Image, opt TextureOptions) error {
 	t.Width = float32(image.Bounds().Dx())
 	t.Height = float32(image.Bounds().Dy())
+	t.Options = opt
 
-	if id, err := newTexture(image); err != nil {
+	if id, err := newTexture(image, opt); err != nil {
 		return err
 	} else {
 		t.Id = id
@@ -33,6 +78,21 @@ func (t *Texture2D) Create(image image.Image) error {
 	return nil
 }
 
+// SetOptions changes the filter and wrap mode, mipmaps are generated if
+// Mipmap is enabled. Premultiplied has no effect after created.
+func (t *Texture2D) SetOptions(opt TextureOptions) {
+	t.Options = opt
+	if gSoft != nil {
+		return
+	}
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, t.Id)
+	setTexParameters(opt)
+	if opt.Mipmap {
+		gl.GenerateMipmap(gl.TEXTURE_2D)
+	}
+}
+
 func (t *Texture2D) Update(img image.Image, xoff, yoff int32, w, h int32) (err error) {
 	rgba := image.NewRGBA(img.Bounds())
 	if rgba.Stride != rgba.Rect.Size().X*4 {
@@ -41,6 +101,11 @@ func (t *Texture2D) Update(img image.Image, xoff, yoff int32, w, h int32) (err e
 	}
 	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
 
+	if gSoft != nil {
+		gSoft.updateTexture(t.Id, rgba, xoff, yoff, w, h)
+		return
+	}
+
 	gl.ActiveTexture(gl.TEXTURE0)
 	gl.BindTexture(gl.TEXTURE_2D, t.Id)
 	gl.TexSubImage2D(gl.TEXTURE_2D,
@@ -52,10 +117,16 @@ func (t *Texture2D) Update(img image.Image, xoff, yoff int32, w, h int32) (err e
 		gl.RGBA,
 		gl.UNSIGNED_BYTE,
 		unsafe.Pointer(&rgba.Pix[0]))
+	if t.Options.Mipmap {
+		gl.GenerateMipmap(gl.TEXTURE_2D)
+	}
 	return
 }
 
 func (t *Texture2D) Bind(stage int32) {
+	if gSoft != nil {
+		return
+	}
 	gl.ActiveTexture(gl.TEXTURE0 + uint32(stage))
 	gl.BindTexture(gl.TEXTURE_2D, t.Id)
 }
@@ -68,17 +139,54 @@ func (t *Texture2D) Sub(x, y float32, w, h float32) *SubTex {
 }
 
 func (t *Texture2D) Destroy() {
+	if gSoft != nil {
+		gSoft.deleteTexture(t.Id)
+		return
+	}
+	if t.fbo != 0 {
+		gl.DeleteFramebuffers(1, &t.fbo)
+		t.fbo = 0
+	}
 	gl.DeleteTextures(1, &t.Id)
 }
 
+// createFrameBuffer attaches the texture to a new frame-buffer object, so it
+// can be drawn into. The software renderer draws into the image directly.
+func (t *Texture2D) createFrameBuffer() error {
+	if gSoft != nil {
+		return nil
+	}
+	var bound int32
+	gl.GetIntegerv(gl.FRAMEBUFFER_BINDING, &bound)
+	gl.GenFramebuffers(1, &t.fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, t.fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, t.Id, 0)
+	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(bound))
+
+	if status != gl.FRAMEBUFFER_COMPLETE {
+		gl.DeleteFramebuffers(1, &t.fbo)
+		t.fbo = 0
+		return fmt.Errorf("incomplete frame-buffer: 0x%x", status)
+	}
+	return nil
+}
+
 // TODO 提前转换图片格式
-func newTexture(img image.Image) (uint32, error) {
-	// 3. copy image
+func newTexture(img image.Image, opt TextureOptions) (uint32, error) {
+	// 3. copy image, converts to premultiplied alpha
 	rgba := image.NewRGBA(img.Bounds())
 	if rgba.Stride != rgba.Rect.Size().X*4 {
 		return 0, fmt.Errorf("unsupported stride")
 	}
-	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
+	if nrgba, ok := img.(*image.NRGBA); ok && opt.Premultiplied && nrgba.Stride == rgba.Stride {
+		copy(rgba.Pix, nrgba.Pix)
+	} else {
+		draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
+	}
+	if gSoft != nil {
+		return gSoft.newTexture(rgba), nil
+	}
 	// 4. upload texture
 	var texture uint32
 	// 4.1 apply space
@@ -86,12 +194,7 @@ func newTexture(img image.Image) (uint32, error) {
 	gl.ActiveTexture(gl.TEXTURE0)
 	gl.BindTexture(gl.TEXTURE_2D, texture)
 	// 4.2 params
-	// 大小插值
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-	// 环绕方式
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	setTexParameters(opt)
 
 	// 4.3 upload
 	gl.TexImage2D(gl.TEXTURE_2D,
@@ -103,9 +206,44 @@ func newTexture(img image.Image) (uint32, error) {
 		gl.RGBA,
 		gl.UNSIGNED_BYTE,
 		unsafe.Pointer(&rgba.Pix[0]))
+	if opt.Mipmap {
+		gl.GenerateMipmap(gl.TEXTURE_2D)
+	}
 	return texture, nil
 }
 
+// setTexParameters sets the parameters of the bound texture.
+func setTexParameters(opt TextureOptions) {
+	// 大小插值
+	min, mag := int32(gl.LINEAR), int32(gl.LINEAR)
+	if opt.MagFilter == FilterNearest {
+		mag = gl.NEAREST
+	}
+	switch {
+	case opt.Mipmap && opt.MinFilter == FilterNearest:
+		min = gl.NEAREST_MIPMAP_NEAREST
+	case opt.Mipmap:
+		min = gl.LINEAR_MIPMAP_LINEAR
+	case opt.MinFilter == FilterNearest:
+		min = gl.NEAREST
+	}
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, min)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, mag)
+	// 环绕方式
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, glWrap(opt.WrapS))
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, glWrap(opt.WrapT))
+}
+
+func glWrap(w Wrap) int32 {
+	switch w {
+	case WrapRepeat:
+		return gl.REPEAT
+	case WrapMirror:
+		return gl.MIRRORED_REPEAT
+	}
+	return gl.CLAMP_TO_EDGE
+}
+
 ///// 还需要抽象 SubTexture 的概念出来
 type SubTex struct {
 	*Texture2D
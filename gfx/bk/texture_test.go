@@ -0,0 +1,42 @@
+package bk
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/bmp"
+)
+
+func TestTexturePremultiply(t *testing.T) {
+	sr := NewSoftRenderer(4, 4)
+	UseSoftRenderer(sr)
+	defer UseSoftRenderer(nil)
+
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 0xFF, A: 0x80})
+
+	// converted when uploaded
+	id, _ := newTexture(img, TextureOptions{})
+	if c := sr.textures[id].RGBAAt(0, 0); c.R != 0x80 || c.A != 0x80 {
+		t.Error("color should be premultiplied:", c)
+	}
+	// premultiplied already
+	img.SetNRGBA(0, 0, color.NRGBA{R: 0x40, A: 0x80})
+	id, _ = newTexture(img, TextureOptions{Premultiplied: true})
+	if c := sr.textures[id].RGBAAt(0, 0); c.R != 0x40 || c.A != 0x80 {
+		t.Error("color should be kept:", c)
+	}
+}
+
+func TestTextureBMP(t *testing.T) {
+	var buf bytes.Buffer
+	if err := bmp.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatal(err)
+	}
+	img, format, err := image.Decode(&buf)
+	if err != nil || format != "bmp" || img.Bounds().Dx() != 3 {
+		t.Error("bmp should be decoded:", format, err)
+	}
+}
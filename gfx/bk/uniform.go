@@ -1,12 +1,14 @@
 package bk
 
 import (
-	"sckorok/hid/gl"
 	"unsafe"
 )
 
 const (
+	// initial size of uniform buffer
 	UNIFORM_BUFFER_SIZE = 16 << 10
+	// the uniform range of draw call is uint16
+	UNIFORM_BUFFER_LIMIT = 64<<10 - 1
 )
 
 type UniformType uint8
@@ -37,7 +39,7 @@ type Uniform struct {
 }
 
 func (um *Uniform) create(program uint32, name string, xType UniformType, num uint32) (slot int32) {
-	slot = gl.GetUniformLocation(program, name)
+	slot = uniformLocation(program, name)
 	um.Slot = uint8(slot)
 	um.Name = name
 	um.Type = xType
@@ -47,14 +49,37 @@ func (um *Uniform) create(program uint32, name string, xType UniformType, num ui
 }
 
 type UniformBuffer struct {
-	buffer [UNIFORM_BUFFER_SIZE]uint8
+	buffer []uint8
 
 	size uint32
 	pos  uint32
 }
 
 func NewUniformBuffer() *UniformBuffer {
-	return &UniformBuffer{}
+	return &UniformBuffer{buffer: make([]uint8, UNIFORM_BUFFER_SIZE)}
+}
+
+// reserve grows the buffer to write n bytes, returns false if the buffer
+// reaches UNIFORM_BUFFER_LIMIT.
+func (ub *UniformBuffer) reserve(n uint32) bool {
+	size := ub.pos + n
+	if size <= uint32(len(ub.buffer)) {
+		return true
+	}
+	if size > UNIFORM_BUFFER_LIMIT {
+		return false
+	}
+	grow := uint32(len(ub.buffer)) * 2
+	if grow > UNIFORM_BUFFER_LIMIT {
+		grow = UNIFORM_BUFFER_LIMIT
+	}
+	if grow < size {
+		grow = size
+	}
+	buffer := make([]uint8, grow)
+	copy(buffer, ub.buffer[:ub.pos])
+	ub.buffer = buffer
+	return true
 }
 
 func (ub *UniformBuffer) GetPos() uint32 {
@@ -1,6 +1,12 @@
 package bk
 
-import "sckorok/hid/gl"
+import (
+	"sckorok/hid/gl"
+
+	"log"
+	"math"
+	"strings"
+)
 
 /// Vertex attribute type enum
 type AttrType uint8
@@ -47,6 +53,60 @@ func (comp *VertexComp) encode() uint16 {
 	return 0
 }
 
+// VertexAttr is an attribute of VertexLayout, Name is the attribute name
+// in shader, such as "xyuv".
+type VertexAttr struct {
+	Name string
+	VertexComp
+}
+
+// VertexLayout describes the attributes of a vertex. A layout is registered
+// by ResManager.AllocLayout, the vertex buffers and shaders reference it by id.
+// The attributes of vertex buffer are found by name when it's drawn, so the
+// vertex can carry more attributes than the shader needs.
+type VertexLayout struct {
+	Attrs  []VertexAttr
+	Stride uint16
+}
+
+// Add appends an attribute at the end of vertex. The offset of attribute is
+// 255 bytes at most, an attribute beyond it is dropped with a log.
+func (l *VertexLayout) Add(name string, num uint8, xType AttrType, normalized bool) *VertexLayout {
+	if l.Stride > math.MaxUint8 {
+		log.Printf("vertex layout: attribute %s at offset %d, beyond 255 bytes", attrName(name), l.Stride)
+		return l
+	}
+	comp := VertexComp{Num: num, Type: xType, Offset: uint8(l.Stride)}
+	if normalized {
+		comp.Normalized = 1
+	}
+	l.Attrs = append(l.Attrs, VertexAttr{attrName(name), comp})
+	l.Stride += uint16(num) * uint16(g_AttrType2Size[xType])
+	return l
+}
+
+// Skip adds n bytes of padding at the end of vertex.
+func (l *VertexLayout) Skip(n uint8) *VertexLayout {
+	l.Stride += uint16(n)
+	return l
+}
+
+// Find returns the format of attribute by name.
+func (l *VertexLayout) Find(name string) (comp VertexComp, ok bool) {
+	name = attrName(name)
+	for _, attr := range l.Attrs {
+		if attr.Name == name {
+			return attr.VertexComp, true
+		}
+	}
+	return
+}
+
+// attribute name without the C-string terminator
+func attrName(name string) string {
+	return strings.TrimSuffix(name, "\x00")
+}
+
 var g_AttrType = []uint32{
 	gl.BYTE,
 	gl.UNSIGNED_BYTE,
@@ -59,6 +119,6 @@ var g_AttrType = []uint32{
 var g_AttrType2Size = []int32{
 	1, 1,
 	2, 2,
-	0, // Fixed Size ?
+	4, // 16.16 fixed-point
 	4,
 }
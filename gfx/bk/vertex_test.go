@@ -0,0 +1,74 @@
+package bk
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"unsafe"
+
+	"sckorok/math/f32"
+)
+
+func TestVertexLayout(t *testing.T) {
+	l := VertexLayout{}
+	l.Add("xyuv", 4, AttrFloat, false).Add("normal\x00", 2, AttrFloat, false).Skip(4).Add("rgba", 4, AttrUInt8, true)
+	if l.Stride != 32 {
+		t.Error("stride error:", l.Stride)
+	}
+	if comp, ok := l.Find("rgba\x00"); !ok || comp != (VertexComp{4, AttrUInt8, 28, 1}) {
+		t.Error("attribute should be found by name:", comp, ok)
+	}
+	if _, ok := l.Find("uv2"); ok {
+		t.Error("unknown attribute should not be found")
+	}
+
+	// offset of attribute is stored in a byte
+	l.Skip(224).Add("uv2", 2, AttrFloat, false)
+	if _, ok := l.Find("uv2"); ok || l.Stride != 256 {
+		t.Error("attribute beyond 255 bytes should be dropped:", l.Stride)
+	}
+}
+
+func TestVertexLayoutDraw(t *testing.T) {
+	s := newSoftScene(t)
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.White)
+	s.texture, _ = R.AllocTexture(img)
+
+	// the shader binds rgba at offset 16, the vertex carries a normal before it
+	layout, _ := R.AllocLayout(*(&VertexLayout{}).
+		Add("xyuv", 4, AttrFloat, false).
+		Add("normal", 2, AttrFloat, false).
+		Add("rgba", 4, AttrUInt8, true))
+	type vertex struct {
+		X, Y, U, V float32
+		NX, NY     float32
+		RGBA       uint32
+	}
+	data := []vertex{
+		{0, 0, 0, 1, 0, 1, 0xFF0000FF},
+		{32, 0, 1, 1, 0, 1, 0xFF0000FF},
+		{32, 32, 1, 0, 0, 1, 0xFF0000FF},
+		{0, 32, 0, 0, 0, 1, 0xFF0000FF},
+	}
+	vb, buffer := R.AllocVertexBufferLayout(Memory{unsafe.Pointer(&data[0]), 4 * 28}, layout)
+	if vb == InvalidId || buffer.layout != 28 {
+		t.Fatal("vertex buffer should use the stride of layout:", vb)
+	}
+
+	proj := f32.Ortho2D(0, 32, 0, 32)
+	SetUniform(s.proj, unsafe.Pointer(&proj[0]))
+	SetState(ST_BLEND.ALPHA_NON_PREMULTIPLIED, 0)
+	SetStencil(0)
+	SetTexture(0, 0, s.texture, 0)
+	SetVertexBuffer(0, vb, 0, 4)
+	SetIndexBuffer(s.index, 0, 6)
+	Submit(0, s.shader, 0)
+	Flush()
+
+	if c := s.sr.Image().RGBAAt(16, 16); c != (color.RGBA{0xFF, 0, 0, 0xFF}) {
+		t.Error("attribute should be read with the layout of vertex buffer:", c)
+	}
+	R.Free(vb)
+	R.Free(layout)
+}
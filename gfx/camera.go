@@ -241,6 +241,13 @@ func (c *Camera) InView(xf *Transform, size, gravity f32.Vec2) bool {
 	}
 }
 
+// overlap tests the AABB in world space with the view.
+func (c *Camera) overlap(a *AABB) bool {
+	w, h := c.view.w*c.mat.sx, c.view.h*c.mat.sy
+	b := AABB{c.mat.x - w/2, c.mat.y - h/2, w, h}
+	return OverlapAB(a, &b)
+}
+
 type mat3 [9]float32 // fast culling matrix, (0, 0) as the center of the local model
 
 func (m *mat3) Initialize(x, y, angle, sx, sy float32) {
@@ -0,0 +1,195 @@
+package gfx
+
+import (
+	"sckorok/gfx/bk"
+
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"log"
+	"math"
+	"os"
+)
+
+// CaptureFrame reads the rendered frame into an image. The frame is complete
+// only after Flush, so it's better to use Capture or a Recorder, which read the
+// frame at the right time. The alpha channel is set to opaque as the screen is.
+func CaptureFrame() *image.RGBA {
+	img := bk.ReadFrame()
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xFF
+	}
+	return img
+}
+
+// Capture takes a screenshot of the next frame, fn is called with the image
+// after the frame is flushed.
+func Capture(fn func(img *image.RGBA)) {
+	capture.requests = append(capture.requests, fn)
+}
+
+// SavePNG saves the image to a PNG file.
+func SavePNG(img image.Image, file string) (err error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return
+	}
+	if err = png.Encode(f, img); err != nil {
+		f.Close()
+		return
+	}
+	return f.Close()
+}
+
+// RecordFormat is the output format of Recorder.
+type RecordFormat uint8
+
+const (
+	RecordGIF RecordFormat = iota // an animated GIF file
+	RecordPNG                     // numbered PNG files
+)
+
+// Recorder records a sequence of frames. The game is updated with a fixed
+// timestep while recording, so every frame in the output has the same duration
+// and the result doesn't depend on the speed of the machine.
+type Recorder struct {
+	format RecordFormat
+	file   string
+	step   float32
+	max    int
+
+	// frames of gif
+	images []*image.Paletted
+	delays []int
+	// number of recorded frames
+	n   int
+	err error
+}
+
+// NewGIFRecorder creates a Recorder saves the frames into an animated GIF file,
+// fps is the frame rate of the GIF, which is also the rate of the fixed timestep.
+func NewGIFRecorder(file string, fps int) *Recorder {
+	return newRecorder(RecordGIF, file, fps)
+}
+
+// NewPNGRecorder creates a Recorder saves each frame into a PNG file, pattern is
+// the format of file name with the frame number, e.g. "shots/frame-%04d.png".
+func NewPNGRecorder(pattern string, fps int) *Recorder {
+	return newRecorder(RecordPNG, pattern, fps)
+}
+
+func newRecorder(format RecordFormat, file string, fps int) *Recorder {
+	if fps <= 0 {
+		fps = 30
+	}
+	return &Recorder{format: format, file: file, step: 1 / float32(fps)}
+}
+
+// SetMaxFrames stops the recording after n frames, 0 means no limit.
+func (r *Recorder) SetMaxFrames(n int) {
+	r.max = n
+}
+
+// Step returns the fixed timestep in seconds.
+func (r *Recorder) Step() float32 {
+	return r.step
+}
+
+// Frames returns the number of recorded frames.
+func (r *Recorder) Frames() int {
+	return r.n
+}
+
+// AddFrame appends a frame, PNG file is written immediately and GIF frames are
+// written when the Recorder is closed.
+func (r *Recorder) AddFrame(img image.Image) error {
+	if r.err != nil {
+		return r.err
+	}
+	switch r.format {
+	case RecordGIF:
+		pm := image.NewPaletted(img.Bounds(), palette.Plan9)
+		draw.FloydSteinberg.Draw(pm, pm.Rect, img, img.Bounds().Min)
+		r.images = append(r.images, pm)
+		r.delays = append(r.delays, int(math.Round(float64(r.step)*100)))
+	case RecordPNG:
+		r.err = SavePNG(img, fmt.Sprintf(r.file, r.n))
+	}
+	if r.err == nil {
+		r.n++
+	}
+	return r.err
+}
+
+// Done returns true if the max frames are recorded.
+func (r *Recorder) Done() bool {
+	return r.max > 0 && r.n >= r.max
+}
+
+// Close writes the GIF file, returns the first error while recording.
+func (r *Recorder) Close() (err error) {
+	if r.err != nil || r.format != RecordGIF {
+		return r.err
+	}
+	f, err := os.Create(r.file)
+	if err != nil {
+		return
+	}
+	if err = gif.EncodeAll(f, &gif.GIF{Image: r.images, Delay: r.delays}); err != nil {
+		f.Close()
+		return
+	}
+	r.images, r.delays = nil, nil
+	return f.Close()
+}
+
+// StartRecording records the following frames with the Recorder, recording
+// stops when StopRecording is called or max frames are recorded.
+func StartRecording(r *Recorder) {
+	capture.recorder = r
+}
+
+// StopRecording stops the recording and closes the Recorder.
+func StopRecording() (err error) {
+	if r := capture.recorder; r != nil {
+		capture.recorder = nil
+		err = r.Close()
+	}
+	return
+}
+
+// Recording returns current Recorder, nil if not recording.
+func Recording() *Recorder {
+	return capture.recorder
+}
+
+var capture struct {
+	requests []func(img *image.RGBA)
+	recorder *Recorder
+}
+
+// called after the frame is flushed
+func captureFrame() {
+	if len(capture.requests) == 0 && capture.recorder == nil {
+		return
+	}
+	img := CaptureFrame()
+	for _, fn := range capture.requests {
+		fn(img)
+	}
+	capture.requests = capture.requests[:0]
+
+	if r := capture.recorder; r != nil {
+		if err := r.AddFrame(img); err != nil {
+			log.Println("capture: fail to record frame,", err)
+		}
+		if r.Done() || r.err != nil {
+			if err := StopRecording(); err != nil {
+				log.Println("capture: fail to save record,", err)
+			}
+		}
+	}
+}
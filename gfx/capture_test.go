@@ -0,0 +1,80 @@
+package gfx
+
+import (
+	"image"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sckorok/gfx/bk"
+)
+
+func TestCapture(t *testing.T) {
+	sr := bk.NewSoftRenderer(8, 4)
+	bk.UseSoftRenderer(sr)
+	defer bk.UseSoftRenderer(nil)
+
+	sr.Clear(0xFF000080)
+	var got bool
+	Capture(func(img *image.RGBA) {
+		got = true
+		if c := img.RGBAAt(7, 3); c.R != 0xFF || c.A != 0xFF {
+			t.Error("captured color error:", c)
+		}
+	})
+	captureFrame()
+	if !got {
+		t.Error("capture callback is not called")
+	}
+}
+
+func TestRecordGIF(t *testing.T) {
+	sr := bk.NewSoftRenderer(8, 4)
+	bk.UseSoftRenderer(sr)
+	defer bk.UseSoftRenderer(nil)
+
+	file := filepath.Join(t.TempDir(), "record.gif")
+	r := NewGIFRecorder(file, 25)
+	r.SetMaxFrames(3)
+	StartRecording(r)
+	for i := 0; i < 5; i++ {
+		sr.Clear(uint32(i*50)<<24 | 0xFF)
+		captureFrame()
+	}
+	if Recording() != nil {
+		t.Fatal("recording should stop after max frames")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	g, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 3 || g.Delay[0] != 4 {
+		t.Error("gif error, frames:", len(g.Image), "delay:", g.Delay)
+	}
+}
+
+func TestRecordPNG(t *testing.T) {
+	sr := bk.NewSoftRenderer(8, 4)
+	bk.UseSoftRenderer(sr)
+	defer bk.UseSoftRenderer(nil)
+
+	dir := t.TempDir()
+	StartRecording(NewPNGRecorder(filepath.Join(dir, "frame-%02d.png"), 60))
+	captureFrame()
+	captureFrame()
+	if err := StopRecording(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"frame-00.png", "frame-01.png"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package gfx
+
+import (
+	"sckorok/engi"
+	"sckorok/gfx/bk"
+	"sckorok/math"
+	"sckorok/math/f32"
+)
+
+// ColorMatrix transforms the rgb of a color, each row is the weights of
+// r, g, b and an offset:
+//
+//	r' = m[0][0]*r + m[0][1]*g + m[0][2]*b + m[0][3]
+//
+// The alpha is not changed.
+type ColorMatrix [3]f32.Vec4
+
+// IdentityColorMatrix returns the matrix that keeps the color.
+func IdentityColorMatrix() ColorMatrix {
+	return ColorMatrix{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}}
+}
+
+// SaturationMatrix scales the saturation, 0 is grayscale and 1 keeps the color.
+func SaturationMatrix(s float32) ColorMatrix {
+	return ColorMatrix{
+		{.213 + .787*s, .715 - .715*s, .072 - .072*s, 0},
+		{.213 - .213*s, .715 + .285*s, .072 - .072*s, 0},
+		{.213 - .213*s, .715 - .715*s, .072 + .928*s, 0},
+	}
+}
+
+// GrayscaleMatrix converts the color to grayscale by amount in [0, 1].
+func GrayscaleMatrix(amount float32) ColorMatrix {
+	return SaturationMatrix(1 - amount)
+}
+
+// HueMatrix rotates the hue by the angle in radians.
+func HueMatrix(angle float32) ColorMatrix {
+	c, s := math.Cos(angle), math.Sin(angle)
+	return ColorMatrix{
+		{.213 + c*.787 - s*.213, .715 - c*.715 - s*.715, .072 - c*.072 + s*.928, 0},
+		{.213 - c*.213 + s*.143, .715 + c*.285 + s*.140, .072 - c*.072 - s*.283, 0},
+		{.213 - c*.213 - s*.787, .715 - c*.715 + s*.715, .072 + c*.928 + s*.072, 0},
+	}
+}
+
+// BrightnessMatrix adds v to each channel, v is in [-1, 1].
+func BrightnessMatrix(v float32) ColorMatrix {
+	return ColorMatrix{{1, 0, 0, v}, {0, 1, 0, v}, {0, 0, 1, v}}
+}
+
+// Mul returns the matrix that applies n and then m.
+func (m ColorMatrix) Mul(n ColorMatrix) (r ColorMatrix) {
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 4; j++ {
+			r[i][j] = m[i][0]*n[0][j] + m[i][1]*n[1][j] + m[i][2]*n[2][j]
+		}
+		r[i][3] += m[i][3]
+	}
+	return
+}
+
+// Transform applies the matrix to the color.
+func (m ColorMatrix) Transform(c Color) Color {
+	var (
+		r, g, b = float32(c.R) / 0xFF, float32(c.G) / 0xFF, float32(c.B) / 0xFF
+		v       [3]uint8
+	)
+	for i := range v {
+		x := m[i][0]*r + m[i][1]*g + m[i][2]*b + m[i][3]
+		v[i] = uint8(math.Clamp(x, 0, 1)*0xFF + .5)
+	}
+	return Color{v[0], v[1], v[2], c.A}
+}
+
+// ColorEffectComp changes the color of sprite beyond the multiplied color of
+// SpriteComp.SetColor, the operations are applied in the order:
+//
+//  1. the color matrix, e.g. grayscale or hue shift
+//  2. the additive color, e.g. flashing white on hit
+//  3. the outline drawn around the opaque pixels
+//
+// The effect data is packed into vertex attributes, so sprites with different
+// effects are still drawn in one batch. It needs the shader of
+// ColorEffectRenderFeature, and it's ignored by the sprites with a custom
+// Material.
+type ColorEffectComp struct {
+	engi.Entity
+	matrix ColorMatrix
+	add    Color
+
+	outline Color
+	width   float32
+
+	enabled bool
+}
+
+// SetMatrix sets the color matrix.
+func (ec *ColorEffectComp) SetMatrix(m ColorMatrix) {
+	ec.matrix = m
+}
+
+func (ec *ColorEffectComp) Matrix() ColorMatrix {
+	return ec.matrix
+}
+
+// SetGrayscale replaces the color matrix with a grayscale matrix.
+func (ec *ColorEffectComp) SetGrayscale(amount float32) {
+	ec.matrix = GrayscaleMatrix(amount)
+}
+
+// SetHueShift replaces the color matrix with a hue rotation in radians.
+func (ec *ColorEffectComp) SetHueShift(angle float32) {
+	ec.matrix = HueMatrix(angle)
+}
+
+// SetAdd sets the additive color, the alpha is the strength. A white color
+// with alpha 255 flashes the sprite white.
+func (ec *ColorEffectComp) SetAdd(c Color) {
+	ec.add = c
+}
+
+func (ec *ColorEffectComp) Add() Color {
+	return ec.add
+}
+
+// SetOutline sets the outline color and width in pixels of texture. The
+// outline is drawn inside the quad of sprite, so the texture should have
+// transparent padding of the width.
+func (ec *ColorEffectComp) SetOutline(c Color, width float32) {
+	ec.outline, ec.width = c, width
+}
+
+func (ec *ColorEffectComp) Outline() (c Color, width float32) {
+	return ec.outline, ec.width
+}
+
+// Reset removes all the operations.
+func (ec *ColorEffectComp) Reset() {
+	ec.matrix = IdentityColorMatrix()
+	ec.add = Transparent
+	ec.outline, ec.width = Transparent, 0
+}
+
+func (ec *ColorEffectComp) SetEnabled(v bool) {
+	ec.enabled = v
+}
+
+func (ec *ColorEffectComp) Enabled() bool {
+	return ec.enabled
+}
+
+// active returns true if any operation changes the sprite.
+func (ec *ColorEffectComp) active() bool {
+	return ec.enabled && (ec.add.A > 0 || (ec.width > 0 && ec.outline.A > 0) || ec.matrix != IdentityColorMatrix())
+}
+
+// vertex data of the effect, see colorEffectLayout
+type effectVertex struct {
+	R, G, B f32.Vec4
+	Add     uint32
+	Outline uint32
+	Offset  f32.Vec2
+}
+
+// vertex layout of the effect stream, stream 1 of the batch
+func colorEffectLayout() (l bk.VertexLayout) {
+	l.Add("cmr", 4, bk.AttrFloat, false).
+		Add("cmg", 4, bk.AttrFloat, false).
+		Add("cmb", 4, bk.AttrFloat, false).
+		Add("add", 4, bk.AttrUInt8, true).
+		Add("outline", 4, bk.AttrUInt8, true).
+		Add("offset", 2, bk.AttrFloat, false)
+	return
+}
+
+// vertex returns the vertex data of effect for the sprite, the outline width
+// is converted to the uv offset.
+func (ec *ColorEffectComp) vertex(spr Sprite) (v effectVertex) {
+	m := ec.matrix
+	v.R, v.G, v.B = m[0], m[1], m[2]
+
+	k := uint32(ec.add.A)
+	v.Add = Color{uint8(uint32(ec.add.R) * k / 0xFF), uint8(uint32(ec.add.G) * k / 0xFF), uint8(uint32(ec.add.B) * k / 0xFF), 0xFF}.U32()
+
+	if ec.width > 0 && ec.outline.A > 0 && spr != nil {
+		v.Outline = ec.outline.U32()
+		rg, sz := spr.Region(), spr.Size()
+		w, h := sz.Width, sz.Height
+		if rg.Rotated {
+			w, h = h, w
+		}
+		if w > 0 && h > 0 {
+			v.Offset = f32.Vec2{ec.width * math.ABS(rg.X2-rg.X1) / w, ec.width * math.ABS(rg.Y2-rg.Y1) / h}
+		}
+	}
+	return
+}
+
+type ColorEffectTable struct {
+	comps      []ColorEffectComp
+	_map       map[uint32]int
+	index, cap int
+
+	// material of the effect shader, zero if the shader is not set
+	material uint16
+}
+
+func NewColorEffectTable(cap int) *ColorEffectTable {
+	return &ColorEffectTable{
+		cap:  cap,
+		_map: make(map[uint32]int),
+	}
+}
+
+func (et *ColorEffectTable) NewComp(entity engi.Entity) (ec *ColorEffectComp) {
+	if size := len(et.comps); et.index >= size {
+		et.comps = colorEffectResize(et.comps, size+STEP)
+	}
+	ei := entity.Index()
+	if v, ok := et._map[ei]; ok {
+		ec = &et.comps[v]
+		return
+	}
+	ec = &et.comps[et.index]
+	ec.Entity = entity
+	ec.Reset()
+	ec.enabled = true
+	et._map[ei] = et.index
+	et.index++
+	return
+}
+
+func (et *ColorEffectTable) Alive(entity engi.Entity) bool {
+	ei := entity.Index()
+	if v, ok := et._map[ei]; ok {
+		return et.comps[v].Entity != 0
+	}
+	return false
+}
+
+func (et *ColorEffectTable) Comp(entity engi.Entity) (ec *ColorEffectComp) {
+	ei := entity.Index()
+	if v, ok := et._map[ei]; ok {
+		ec = &et.comps[v]
+	}
+	return
+}
+
+func (et *ColorEffectTable) Delete(entity engi.Entity) {
+	ei := entity.Index()
+	if v, ok := et._map[ei]; ok {
+		if tail := et.index - 1; v != tail && tail > 0 {
+			et.comps[v] = et.comps[tail]
+			// remap index
+			tComp := et.comps[tail]
+			ei := tComp.Entity.Index()
+			et._map[ei] = v
+			et.comps[tail] = ColorEffectComp{}
+		} else {
+			et.comps[tail] = ColorEffectComp{}
+		}
+
+		et.index -= 1
+		delete(et._map, ei)
+	}
+}
+
+func (et *ColorEffectTable) Size() (size, cap int) {
+	return et.index, et.cap
+}
+
+func (et *ColorEffectTable) Destroy() {
+	et.comps = make([]ColorEffectComp, 0)
+	et._map = make(map[uint32]int)
+	et.index = 0
+}
+
+// Effect returns the active effect of entity and the material to draw it,
+// nil if the entity has no active effect or the shader is not set. It's safe
+// to call with a nil table.
+func (et *ColorEffectTable) Effect(entity engi.Entity) (ec *ColorEffectComp, mat uint16) {
+	if et == nil || et.material == 0 {
+		return nil, 0
+	}
+	if v, ok := et._map[entity.Index()]; ok && et.comps[v].active() {
+		return &et.comps[v], et.material
+	}
+	return nil, 0
+}
+
+func colorEffectResize(slice []ColorEffectComp, size int) []ColorEffectComp {
+	newSlice := make([]ColorEffectComp, size)
+	copy(newSlice, slice)
+	return newSlice
+}
+
+// ColorEffectRenderFeature owns the shader of color effects, the sprites
+// with effects are drawn by the SpriteRenderFeature with it.
+type ColorEffectRenderFeature struct {
+	id int
+	et *ColorEffectTable
+
+	material uint16
+}
+
+func (f *ColorEffectRenderFeature) SetTable(et *ColorEffectTable) {
+	f.et = et
+	if et != nil {
+		et.material = f.material
+	}
+}
+
+// 此处初始化所有的依赖
+func (f *ColorEffectRenderFeature) Register(rs *RenderSystem) {
+	// init table
+	for _, t := range rs.TableList {
+		switch table := t.(type) {
+		case *ColorEffectTable:
+			f.SetTable(table)
+		}
+	}
+	// add new feature
+	f.id = rs.Accept(f)
+}
+
+// SetShader sets the effect shader, it reads the effect from the attributes
+// <cmr, cmg, cmb, add, outline, offset> of the vertex stream 1.
+func (f *ColorEffectRenderFeature) SetShader(vsh, fsh string) {
+	id, m := M.NewShader("effect", vsh, fsh)
+	if m.program == bk.InvalidId {
+		M.Delete(id)
+		return
+	}
+	if layout, _ := bk.R.AllocLayout(colorEffectLayout()); layout != bk.InvalidId {
+		if ok, sh := bk.R.Shader(m.program); ok {
+			sh.BindLayout(1, layout)
+		}
+	}
+	f.material = id
+	if f.et != nil {
+		f.et.material = id
+	}
+}
+
+// Material returns the effect material, zero if the shader is not set.
+func (f *ColorEffectRenderFeature) Material() uint16 {
+	return f.material
+}
+
+func (f *ColorEffectRenderFeature) Extract(v *View) {
+
+}
+
+func (f *ColorEffectRenderFeature) Draw(nodes RenderNodes) {
+
+}
+
+func (f *ColorEffectRenderFeature) Flush() {
+
+}
+
+// effectBatchObject draws a BatchObject with the color effect.
+type effectBatchObject struct {
+	BatchObject
+	vertex effectVertex
+}
+
+func (ebo effectBatchObject) FillEffect(buf []effectVertex) {
+	for i := range buf {
+		buf[i] = ebo.vertex
+	}
+}
+
+// withEffect returns the BatchObject with the color effect, or the object
+// itself if effect is nil.
+func withEffect(obj BatchObject, effect *effectVertex) BatchObject {
+	if effect != nil {
+		return effectBatchObject{obj, *effect}
+	}
+	return obj
+}
@@ -0,0 +1,91 @@
+package gfx
+
+import (
+	"testing"
+	"unsafe"
+
+	"sckorok/engi"
+	"sckorok/math"
+)
+
+func TestColorMatrix(t *testing.T) {
+	red := Color{0xFF, 0, 0, 0x80}
+	if c := IdentityColorMatrix().Transform(red); c != red {
+		t.Error("identity should keep the color:", c)
+	}
+	if c := GrayscaleMatrix(1).Transform(red); c.R != c.G || c.G != c.B || c.A != 0x80 {
+		t.Error("grayscale should have equal channels:", c)
+	}
+	if c := HueMatrix(2 * math.Pi).Transform(red); c.R < 0xFE || c.G > 1 || c.B > 1 {
+		t.Error("a full turn should keep the hue:", c)
+	}
+
+	// brightness then grayscale
+	m := GrayscaleMatrix(1).Mul(BrightnessMatrix(.5))
+	if c := m.Transform(Color{0, 0, 0, 0xFF}); c.R != 0x80 || c.G != 0x80 {
+		t.Error("matrix should apply the right side first:", c)
+	}
+}
+
+func TestColorEffect(t *testing.T) {
+	em := engi.NewEntityManager()
+	et := NewColorEffectTable(4)
+	e := em.New()
+	ec := et.NewComp(e)
+
+	// inactive without the shader or any operation
+	if c, _ := et.Effect(e); c != nil {
+		t.Error("effect needs the shader")
+	}
+	et.material = 1
+	if c, _ := et.Effect(e); c != nil {
+		t.Error("effect without operation should be inactive")
+	}
+	if c, _ := (*ColorEffectTable)(nil).Effect(e); c != nil {
+		t.Error("nil table should be safe")
+	}
+
+	// half white flash and a 2 pixels outline on a 4x6 sprite
+	ec.SetAdd(Color{0xFF, 0xFF, 0xFF, 0x80})
+	ec.SetOutline(Color{0, 0, 0xFF, 0xFF}, 2)
+	if c, mat := et.Effect(e); c != ec || mat != 1 {
+		t.Fatal("effect should be active:", c, mat)
+	}
+	v := ec.vertex(packedTex{})
+	if add := U32Color(v.Add); add.R != 0x80 || add.G != 0x80 {
+		t.Error("additive color should be scaled by alpha:", add)
+	}
+	if v.Offset[0] != .5 || v.Offset[1] != float32(2)/6 {
+		t.Error("outline width should be converted to uv:", v.Offset)
+	}
+	if unsafe.Sizeof(v) != uintptr(colorEffectLayout().Stride) {
+		t.Error("vertex should match the layout:", unsafe.Sizeof(v))
+	}
+
+	ec.SetEnabled(false)
+	if c, _ := et.Effect(e); c != nil {
+		t.Error("disabled effect should be inactive")
+	}
+}
+
+// emptyQuad is a BatchObject of 4 vertices
+type emptyQuad struct{}
+
+func (emptyQuad) Fill(buf []PosTexColorVertex) {}
+func (emptyQuad) Size() int                    { return 4 }
+
+func TestEffectBatch(t *testing.T) {
+	bc := &BatchContext{}
+	bc.init()
+
+	v := effectVertex{Add: 0xFFFFFFFF}
+	bc.begin(0, 1, 0)
+	bc.drawComp(withEffect(emptyQuad{}, nil))
+	if bc.effect != nil || bc.effectUsed {
+		t.Error("effect stream should not be used without effects")
+	}
+	bc.drawComp(withEffect(emptyQuad{}, &v))
+	if !bc.effectUsed || bc.effect[4] != v || bc.effect[7] != v {
+		t.Error("effect should be filled in parallel with the vertex")
+	}
+}
@@ -86,8 +86,11 @@ func OverlapAB(a, b *AABB) bool {
 	return false
 }
 
+// zOrder also keeps the sorting layer and the custom sort key.
 type zOrder struct {
 	value int16
+	layer uint8
+	key   int32
 }
 
 func (zo *zOrder) SetZOrder(z int16) {
@@ -98,6 +101,30 @@ func (zo *zOrder) Z() int16 {
 	return zo.value
 }
 
+// SetLayer moves the object to the sorting layer, see SortingLayers.
+func (zo *zOrder) SetLayer(id uint8) {
+	zo.layer = id
+}
+
+func (zo *zOrder) Layer() uint8 {
+	return zo.layer
+}
+
+// SetSortKey sets the key used in a SortCustom layer, the key is clamped to
+// 24 bits.
+func (zo *zOrder) SetSortKey(key int32) {
+	zo.key = key
+}
+
+func (zo *zOrder) SortKey() int32 {
+	return zo.key
+}
+
+// sortId packs the SortId of the object at world y.
+func (zo *zOrder) sortId(y float32, m, b uint16) uint64 {
+	return Layers.Pack(zo.layer, zo.value, y, zo.key, m, b)
+}
+
 type batchId struct {
 	value uint16
 }
@@ -110,18 +137,62 @@ func (b *batchId) BatchId() uint16 {
 	return b.value
 }
 
-func PackSortId(z int16, b uint16) (sid uint32) {
-	sid = uint32(int32(z) + 0xFFFF>>1)
-	sid = (sid << 16) + uint32(b)
-	return
+type materialId struct {
+	value uint16
+}
+
+// SetMaterial sets the Material, zero means the default material.
+func (m *materialId) SetMaterial(id uint16) {
+	m.value = id
 }
 
-func UnpackSortId(sortId uint32) (z int16, b uint16) {
+func (m *materialId) Material() uint16 {
+	return m.value
+}
+
+// SortId FORMAT
+// 64bit:
+// 0x FF FFFFFF FFFF FFFF
+//    ^  ^      ^    ^
+//    |  |      |    +---- batch-id(texture)
+//    |  |      +--------- material
+//    |  +---------------- sort key(z-order, y or custom key)
+//    +------------------- order of sorting layer
+// Objects in the same layer are sorted by the sort key, then material and
+// texture, this will minimise the state switches. See SortingLayers.
+func PackSortId(z int16, b uint16) (sid uint64) {
+	return PackSortIdM(z, 0, b)
+}
+
+// PackSortId with material, the object is in the DefaultLayer.
+func PackSortIdM(z int16, m, b uint16) (sid uint64) {
+	return packSortId(Layers.Order(DefaultLayer), uint32(int32(z)+0xFFFF>>1), m, b)
+}
+
+func packSortId(order uint8, key uint32, m, b uint16) uint64 {
+	return uint64(order)<<56 | uint64(key&sortKeyMax)<<32 | uint64(m)<<16 | uint64(b)
+}
+
+// UnpackSortId returns the z-order and batch-id packed in SortId. The sort
+// key is a z-order only in the SortZOrder layers, z is zero in the others.
+func UnpackSortId(sortId uint64) (z int16, b uint16) {
 	b = uint16(sortId & 0xFFFF)
-	z = int16(int32(sortId>>16) - 0xFFFF>>1)
+	if Layers.modeAt(UnpackLayer(sortId)) == SortZOrder {
+		z = int16(int32(sortId>>32&0xFFFF) - 0xFFFF>>1)
+	}
 	return
 }
 
+// UnpackMaterial returns the material packed in SortId.
+func UnpackMaterial(sortId uint64) uint16 {
+	return uint16(sortId >> 16 & 0xFFFF)
+}
+
+// UnpackLayer returns the order of sorting layer packed in SortId.
+func UnpackLayer(sortId uint64) uint8 {
+	return uint8(sortId >> 56)
+}
+
 // format <x,y,u,v rgba>
 var P4C4 = []bk.VertexComp{
 	{Num: 4, Type: bk.AttrFloat, Offset: 0, Normalized: 0},
@@ -0,0 +1,84 @@
+package gfx
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestPackSortId(t *testing.T) {
+	sid := PackSortIdM(-10, 3, 0x2001)
+
+	if z, b := UnpackSortId(sid); z != -10 || b != 0x2001 {
+		t.Error("fail to unpack z-order and batch-id:", z, b)
+	}
+	if m := UnpackMaterial(sid); m != 3 {
+		t.Error("fail to unpack material:", m)
+	}
+}
+
+// Objects should be sorted by z-order first, then material and texture.
+func TestSortIdOrder(t *testing.T) {
+	nodes := RenderNodes{
+		{PackSortIdM(1, 0, 2), 0},
+		{PackSortIdM(0, 2, 1), 1},
+		{PackSortIdM(0, 1, 2), 2},
+		{PackSortIdM(-1, 2, 2), 3},
+		{PackSortIdM(0, 1, 1), 4},
+	}
+	sort.Stable(nodes)
+
+	expect := []uint32{3, 4, 2, 1, 0}
+	for i, n := range nodes {
+		if n.Value != expect[i] {
+			t.Error("sort order error:", i, n.Value, "expected:", expect[i])
+		}
+	}
+}
+
+func TestSortingLayers(t *testing.T) {
+	layers := NewSortingLayers()
+	bg := layers.Add("background", SortZOrder)
+	world := layers.Add("world", SortYDescending)
+	hud := layers.Add("hud", SortZOrder)
+	layers.SetOrder("background", "Default", "world", "hud")
+
+	if layers.Order(bg) != 0 || layers.Order(DefaultLayer) != 1 || layers.Order(hud) != 3 {
+		t.Fatal("layer order error:", layers.Order(bg), layers.Order(DefaultLayer), layers.Order(hud))
+	}
+	nodes := RenderNodes{
+		{layers.Pack(hud, -100, 0, 0, 0, 0), 0},
+		{layers.Pack(world, 100, 10, 0, 0, 0), 1},  // front
+		{layers.Pack(world, -100, 50, 0, 1, 0), 2}, // behind
+		{layers.Pack(bg, 100, 0, 0, 0, 0), 3},
+		{layers.Pack(DefaultLayer, 0, 0, 0, 0, 0), 4},
+	}
+	sort.Stable(nodes)
+
+	expect := []uint32{3, 4, 2, 1, 0}
+	for i, n := range nodes {
+		if n.Value != expect[i] {
+			t.Error("sort order error:", i, n.Value, "expected:", expect[i])
+		}
+	}
+	if l := UnpackLayer(nodes[4].SortId); l != 3 {
+		t.Error("fail to unpack layer:", l)
+	}
+
+	// z-order is unpacked only in the z-order layers
+	defer func(l *SortingLayers) { Layers = l }(Layers)
+	Layers = layers
+	if z, _ := UnpackSortId(layers.Pack(hud, -100, 0, 0, 0, 0)); z != -100 {
+		t.Error("fail to unpack z-order:", z)
+	}
+	if z, _ := UnpackSortId(layers.Pack(world, 100, 10, 0, 0, 0)); z != 0 {
+		t.Error("y sorted layer should have no z-order:", z)
+	}
+
+	// custom key, then material
+	custom := layers.Add("custom", SortCustom)
+	a := layers.Pack(custom, 0, 0, -5, 2, 0)
+	b := layers.Pack(custom, 0, 0, 3, 1, 0)
+	if a >= b || UnpackMaterial(a) != 2 {
+		t.Error("custom key should be sorted first")
+	}
+}
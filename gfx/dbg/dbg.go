@@ -16,8 +16,9 @@ const (
 	FPS DebugEnum = 1 << iota
 	Stats
 	Draw
+	Inspector
 
-	ALL  = FPS | Stats | Draw
+	ALL  = FPS | Stats | Draw | Inspector
 	None = DebugEnum(0)
 )
 
@@ -281,6 +282,10 @@ func (buff *TextShapeBuffer) String(x, y float32, chars string, scale float32) {
 	w, h := font_width*scale, font_height*scale
 
 	for i, N := 0, len(chars); i < N; i++ {
+		// buffer is full
+		if int(buff.pos)+4 > len(buff.vertex) {
+			return
+		}
 		b := buff.vertex[buff.pos : buff.pos+4]
 		buff.pos += 4
 
@@ -1,6 +1,8 @@
 package dbg
 
 import (
+	"sckorok/gfx/bk"
+
 	"fmt"
 )
 
@@ -20,10 +22,21 @@ func HudFunc(fn func() string) {
 	hud.verbs = append(hud.verbs, fn())
 }
 
+// Inspect adds a line to the inspector panel, the panel is drawn at the
+// top-right of screen if the Inspector flag is set.
+func Inspect(format string, args ...interface{}) {
+	if (DEBUG & Inspector) != 0 {
+		hud.inspects = append(hud.inspects, fmt.Sprintf(format, args...))
+	}
+}
+
 // Game internal state.
 type HudLog struct {
 	verbs []string
 	drawCall, fps int
+
+	// lines of inspector panel
+	inspects []string
 }
 
 func (hud *HudLog) draw() {
@@ -38,6 +51,11 @@ func (hud *HudLog) draw() {
 		drawDrawCall(x, y, hud.drawCall)
 	}
 
+	// warn the exhausted pools of bk
+	if err := bk.Err(); DEBUG != None && err != nil {
+		drawWarning(x, y, err.Error())
+	}
+
 	// draw string
 	if (DEBUG & Stats) != 0 {
 		d := float32(0)
@@ -48,10 +66,37 @@ func (hud *HudLog) draw() {
 			d += 10
 		}
 	}
+
+	// draw inspector
+	if (DEBUG&Inspector) != 0 && len(hud.inspects) > 0 {
+		drawInspector(x+gRender.view.w-inspectorWidth, y+gRender.view.h, hud.inspects)
+	}
 }
 
 func (hud *HudLog) reset() {
 	hud.verbs = hud.verbs[:0]
+	hud.inspects = hud.inspects[:0]
+}
+
+const inspectorWidth = 300
+
+// draw the lines from the top-left corner (x, y) on a dark background
+func drawInspector(x, y float32, lines []string) {
+	h := float32(len(lines)*10 + 10)
+	Color(0xC0000000)
+	gBuffer.Rect(x, y-h, inspectorWidth, h)
+
+	Color(0xFFFFFFFF)
+	for i, str := range lines {
+		gBuffer.String(x+5, y-float32(i+1)*10-5, str, .5)
+	}
+	Color(0xFF000000)
+}
+
+func drawWarning(x, y float32, str string) {
+	Color(0xFF0000FF)
+	gBuffer.String(x+5, y+40, "WARNING: "+str, .6)
+	Color(0xFF000000)
 }
 
 func drawFps(x, y float32, fps int) {
@@ -61,6 +61,12 @@ func LoadBitmap(img, config io.Reader, scale int) (Font, error) {
 	}
 	f.gWidth = float32(gw)
 	f.gHeight = float32(gh)
+	if len(fc.Kernings) > 0 {
+		f.kerning = make(map[[2]rune]float32, len(fc.Kernings))
+		for _, k := range fc.Kernings {
+			f.kerning[[2]rune{k.First, k.Second}] = float32(k.Amount)
+		}
+	}
 	// log.Println("dump:", f)
 	return f, nil
 }
@@ -86,4 +92,11 @@ type fontConfig struct {
 		YOffset int  `json:"yoffset,string"`
 		Advance int  `json:"advance,string"`
 	} `json:"glyphs"`
+
+	// Kernings adjusts the advance between two runes, optional.
+	Kernings []struct {
+		First  rune `json:"first,string"`
+		Second rune `json:"second,string"`
+		Amount int  `json:"amount,string"`
+	} `json:"kernings"`
 }
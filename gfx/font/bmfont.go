@@ -0,0 +1,428 @@
+package font
+
+import (
+	"sckorok/gfx/bk"
+
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// BMFont is an AngelCode bitmap font, the glyphs may be in several pages.
+type BMFont struct {
+	pages []bmPage
+
+	glyphs  map[rune]Glyph
+	kerning map[[2]rune]float32
+	metrics Metrics
+
+	gWidth  float32 // Largest glyph width.
+	gHeight float32 // Line height.
+}
+
+type bmPage struct {
+	id   uint16
+	w, h float32
+}
+
+// LoadBMFont loads an AngelCode BMFont from the .fnt file, the text, XML and
+// binary formats are supported. open is called to open the images of pages,
+// the name is the file in the .fnt file, relative to the .fnt file.
+func LoadBMFont(fnt io.Reader, open func(name string) (io.ReadCloser, error)) (*BMFont, error) {
+	data, err := ioutil.ReadAll(fnt)
+	if err != nil {
+		return nil, err
+	}
+	var desc bmDesc
+	switch trimmed := bytes.TrimSpace(data); {
+	case bytes.HasPrefix(data, []byte("BMF")):
+		err = desc.parseBinary(data)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		err = desc.parseXML(data)
+	default:
+		err = desc.parseText(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f := &BMFont{
+		glyphs:  make(map[rune]Glyph, len(desc.chars)),
+		metrics: Metrics{LineHeight: float32(desc.lineHeight), Base: float32(desc.base)},
+		gHeight: float32(desc.lineHeight),
+	}
+	for _, file := range desc.pages {
+		if err := f.loadPage(file, open); err != nil {
+			f.Dispose()
+			return nil, err
+		}
+	}
+	for _, c := range desc.chars {
+		if c.page >= len(f.pages) {
+			continue
+		}
+		f.glyphs[rune(c.id)] = Glyph{
+			Rune:    rune(c.id),
+			Page:    uint16(c.page),
+			X:       float32(c.x),
+			Y:       float32(c.y),
+			Width:   float32(c.width),
+			Height:  float32(c.height),
+			XOffset: float32(c.xoffset),
+			YOffset: float32(c.yoffset),
+			Advance: c.xadvance,
+		}
+		if w := float32(c.width); w > f.gWidth {
+			f.gWidth = w
+		}
+	}
+	if len(desc.kernings) > 0 {
+		f.kerning = make(map[[2]rune]float32, len(desc.kernings))
+		for _, k := range desc.kernings {
+			f.kerning[[2]rune{rune(k.first), rune(k.second)}] = float32(k.amount)
+		}
+	}
+	return f, nil
+}
+
+func (f *BMFont) loadPage(file string, open func(name string) (io.ReadCloser, error)) error {
+	r, err := open(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return fmt.Errorf("font: page %s, %v", file, err)
+	}
+	rgba, ok := img.(*image.RGBA)
+	if !ok || rgba.Rect.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+		draw.Draw(rgba, rgba.Rect, img, img.Bounds().Min, draw.Src)
+	}
+	// add a white pixel at (0, 0)
+	rgba.Set(0, 0, color.White)
+
+	id, _ := bk.R.AllocTexture(rgba)
+	if id == bk.InvalidId {
+		return fmt.Errorf("font: fail to create texture for page %s", file)
+	}
+	f.pages = append(f.pages, bmPage{id, float32(rgba.Rect.Dx()), float32(rgba.Rect.Dy())})
+	return nil
+}
+
+// Tex2D returns the texture of the first page.
+func (f *BMFont) Tex2D() (id uint16, tex *bk.Texture2D) {
+	return f.Page(0)
+}
+
+func (f *BMFont) Page(i int) (id uint16, tex *bk.Texture2D) {
+	if i < len(f.pages) {
+		id = f.pages[i].id
+		if ok, t := bk.R.Texture(id); ok {
+			tex = t
+		}
+	}
+	return
+}
+
+// Version is always zero, glyphs are never evicted.
+func (f *BMFont) Version() uint32 {
+	return 0
+}
+
+// Pages returns the number of pages.
+func (f *BMFont) Pages() int {
+	return len(f.pages)
+}
+
+func (f *BMFont) Glyph(r rune) (g Glyph, ok bool) {
+	g, ok = f.glyphs[r]
+	return
+}
+
+// Bounds returns the largest glyph width and the line height.
+func (f *BMFont) Bounds() (gw, gh float32) {
+	return f.gWidth, f.gHeight
+}
+
+func (f *BMFont) Metrics() Metrics {
+	return f.metrics
+}
+
+func (f *BMFont) Kern(r0, r1 rune) float32 {
+	return f.kerning[[2]rune{r0, r1}]
+}
+
+func (f *BMFont) Frame(r rune) (u1, v1, u2, v2 float32) {
+	g := f.glyphs[r]
+	if int(g.Page) >= len(f.pages) {
+		return
+	}
+	p := f.pages[g.Page]
+	u1, v1 = g.X/p.w, g.Y/p.h
+	u2, v2 = (g.X+g.Width)/p.w, (g.Y+g.Height)/p.h
+	return
+}
+
+// Dispose releases the pages.
+func (f *BMFont) Dispose() {
+	for _, p := range f.pages {
+		bk.R.Free(p.id)
+	}
+	f.pages = nil
+}
+
+// the content of .fnt file
+type bmDesc struct {
+	lineHeight, base int
+	pages            []string
+	chars            []bmChar
+	kernings         []bmKerning
+}
+
+type bmChar struct {
+	id, x, y, width, height    int
+	xoffset, yoffset, xadvance int
+	page                       int
+}
+
+type bmKerning struct {
+	first, second, amount int
+}
+
+// parseText parses the text format, each line is a tag followed by key=value
+// pairs, e.g. `char id=65 x=10 y=0 width=12 ...`.
+func (d *bmDesc) parseText(data []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		tag, attrs := splitBMLine(scanner.Text())
+		var err error
+		switch tag {
+		case "common":
+			err = attrs.ints(map[string]*int{"lineHeight": &d.lineHeight, "base": &d.base})
+		case "page":
+			var id int
+			if err = attrs.ints(map[string]*int{"id": &id}); err == nil {
+				err = d.setPage(id, attrs["file"])
+			}
+		case "char":
+			var c bmChar
+			if err = attrs.ints(c.fields()); err == nil {
+				d.chars = append(d.chars, c)
+			}
+		case "kerning":
+			var k bmKerning
+			if err = attrs.ints(k.fields()); err == nil {
+				d.kernings = append(d.kernings, k)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("font: bmfont %s, %v", tag, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func (d *bmDesc) setPage(id int, file string) error {
+	if id < 0 || id > 0xFF {
+		return fmt.Errorf("invalid page id %d", id)
+	}
+	for len(d.pages) <= id {
+		d.pages = append(d.pages, "")
+	}
+	d.pages[id] = file
+	return nil
+}
+
+type bmAttrs map[string]string
+
+// ints parses the attributes into the fields, missing attributes are skipped.
+func (a bmAttrs) ints(fields map[string]*int) error {
+	for k, p := range fields {
+		v, ok := a[k]
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%s=%s", k, v)
+		}
+		*p = n
+	}
+	return nil
+}
+
+func (c *bmChar) fields() map[string]*int {
+	return map[string]*int{
+		"id": &c.id, "x": &c.x, "y": &c.y, "width": &c.width, "height": &c.height,
+		"xoffset": &c.xoffset, "yoffset": &c.yoffset, "xadvance": &c.xadvance, "page": &c.page,
+	}
+}
+
+func (k *bmKerning) fields() map[string]*int {
+	return map[string]*int{"first": &k.first, "second": &k.second, "amount": &k.amount}
+}
+
+// splitBMLine splits a line into the tag and attributes, values may be quoted.
+func splitBMLine(line string) (tag string, attrs bmAttrs) {
+	line = strings.TrimSpace(line)
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		tag, line = line[:i], line[i+1:]
+	} else {
+		return line, nil
+	}
+	attrs = make(bmAttrs)
+	for {
+		line = strings.TrimLeft(line, " \t")
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return
+		}
+		key, rest := line[:eq], line[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				value, line = rest[1:], ""
+			} else {
+				value, line = rest[1:end+1], rest[end+2:]
+			}
+		} else if end := strings.IndexAny(rest, " \t"); end >= 0 {
+			value, line = rest[:end], rest[end:]
+		} else {
+			value, line = rest, ""
+		}
+		attrs[key] = value
+	}
+}
+
+// parseXML parses the XML format, the element names and attributes are the
+// same with the text format.
+func (d *bmDesc) parseXML(data []byte) error {
+	var doc struct {
+		Common struct {
+			LineHeight int `xml:"lineHeight,attr"`
+			Base       int `xml:"base,attr"`
+		} `xml:"common"`
+		Pages []struct {
+			Id   int    `xml:"id,attr"`
+			File string `xml:"file,attr"`
+		} `xml:"pages>page"`
+		Chars []struct {
+			Id       int `xml:"id,attr"`
+			X        int `xml:"x,attr"`
+			Y        int `xml:"y,attr"`
+			Width    int `xml:"width,attr"`
+			Height   int `xml:"height,attr"`
+			XOffset  int `xml:"xoffset,attr"`
+			YOffset  int `xml:"yoffset,attr"`
+			XAdvance int `xml:"xadvance,attr"`
+			Page     int `xml:"page,attr"`
+		} `xml:"chars>char"`
+		Kernings []struct {
+			First  int `xml:"first,attr"`
+			Second int `xml:"second,attr"`
+			Amount int `xml:"amount,attr"`
+		} `xml:"kernings>kerning"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("font: bmfont xml, %v", err)
+	}
+	d.lineHeight, d.base = doc.Common.LineHeight, doc.Common.Base
+	for _, p := range doc.Pages {
+		if err := d.setPage(p.Id, p.File); err != nil {
+			return err
+		}
+	}
+	for _, c := range doc.Chars {
+		d.chars = append(d.chars, bmChar{c.Id, c.X, c.Y, c.Width, c.Height, c.XOffset, c.YOffset, c.XAdvance, c.Page})
+	}
+	for _, k := range doc.Kernings {
+		d.kernings = append(d.kernings, bmKerning{k.First, k.Second, k.Amount})
+	}
+	return nil
+}
+
+// block types of the binary format
+const (
+	bmBlockInfo = iota + 1
+	bmBlockCommon
+	bmBlockPages
+	bmBlockChars
+	bmBlockKerning
+)
+
+var errBMBinary = errors.New("font: invalid bmfont binary")
+
+// parseBinary parses the binary format version 3.
+func (d *bmDesc) parseBinary(data []byte) error {
+	if len(data) < 4 || data[3] != 3 {
+		return errors.New("font: only bmfont binary version 3 is supported")
+	}
+	le := binary.LittleEndian
+	for data = data[4:]; len(data) > 0; {
+		if len(data) < 5 {
+			return errBMBinary
+		}
+		typ, size := data[0], int(le.Uint32(data[1:]))
+		if len(data) < 5+size {
+			return errBMBinary
+		}
+		block := data[5 : 5+size]
+		data = data[5+size:]
+
+		switch typ {
+		case bmBlockCommon:
+			if size < 10 {
+				return errBMBinary
+			}
+			d.lineHeight, d.base = int(le.Uint16(block)), int(le.Uint16(block[2:]))
+		case bmBlockPages:
+			// null terminated strings
+			for id := 0; len(block) > 0; id++ {
+				end := bytes.IndexByte(block, 0)
+				if end < 0 {
+					end = len(block) - 1
+				}
+				if err := d.setPage(id, string(block[:end])); err != nil {
+					return err
+				}
+				block = block[end+1:]
+			}
+		case bmBlockChars:
+			for ; len(block) >= 20; block = block[20:] {
+				d.chars = append(d.chars, bmChar{
+					id:       int(le.Uint32(block)),
+					x:        int(le.Uint16(block[4:])),
+					y:        int(le.Uint16(block[6:])),
+					width:    int(le.Uint16(block[8:])),
+					height:   int(le.Uint16(block[10:])),
+					xoffset:  int(int16(le.Uint16(block[12:]))),
+					yoffset:  int(int16(le.Uint16(block[14:]))),
+					xadvance: int(int16(le.Uint16(block[16:]))),
+					page:     int(block[18]),
+				})
+			}
+		case bmBlockKerning:
+			for ; len(block) >= 10; block = block[10:] {
+				d.kernings = append(d.kernings, bmKerning{
+					first:  int(le.Uint32(block)),
+					second: int(le.Uint32(block[4:])),
+					amount: int(int16(le.Uint16(block[8:]))),
+				})
+			}
+		}
+	}
+	return nil
+}
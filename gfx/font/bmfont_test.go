@@ -0,0 +1,145 @@
+package font
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"sckorok/gfx/bk"
+)
+
+const bmText = `info face="Test Font" size=32 bold=0 italic=0 padding=0,0,0,0 spacing=1,1
+common lineHeight=36 base=29 scaleW=64 scaleH=32 pages=2 packed=0
+page id=0 file="test_0.png"
+page id=1 file="test_1.png"
+chars count=2
+char id=65   x=2     y=4     width=20    height=24    xoffset=1     yoffset=5     xadvance=22    page=0  chnl=15
+char id=86   x=8     y=0     width=18    height=24    xoffset=-1    yoffset=5     xadvance=19    page=1  chnl=15
+kernings count=1
+kerning first=65  second=86  amount=-3
+`
+
+const bmXML = `<?xml version="1.0"?>
+<font>
+  <info face="Test Font" size="32"/>
+  <common lineHeight="36" base="29" scaleW="64" scaleH="32" pages="2"/>
+  <pages>
+    <page id="0" file="test_0.png"/>
+    <page id="1" file="test_1.png"/>
+  </pages>
+  <chars count="2">
+    <char id="65" x="2" y="4" width="20" height="24" xoffset="1" yoffset="5" xadvance="22" page="0" chnl="15"/>
+    <char id="86" x="8" y="0" width="18" height="24" xoffset="-1" yoffset="5" xadvance="19" page="1" chnl="15"/>
+  </chars>
+  <kernings count="1">
+    <kerning first="65" second="86" amount="-3"/>
+  </kernings>
+</font>
+`
+
+func bmBinary() []byte {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	block := func(typ byte, data []byte) {
+		b.WriteByte(typ)
+		binary.Write(&b, le, uint32(len(data)))
+		b.Write(data)
+	}
+	b.WriteString("BMF\x03")
+
+	info := []byte{32, 0, 0, 0, 100, 0, 1, 0, 0, 0, 0, 1, 1, 0}
+	block(bmBlockInfo, append(info, "Test Font\x00"...))
+
+	var common bytes.Buffer
+	binary.Write(&common, le, []uint16{36, 29, 64, 32, 2})
+	common.Write([]byte{0, 0, 0, 0, 0})
+	block(bmBlockCommon, common.Bytes())
+
+	block(bmBlockPages, []byte("test_0.png\x00test_1.png\x00"))
+
+	var chars bytes.Buffer
+	binary.Write(&chars, le, struct {
+		Id               uint32
+		X, Y, W, H       uint16
+		XOff, YOff, XAdv int16
+		Page, Chnl       uint8
+	}{65, 2, 4, 20, 24, 1, 5, 22, 0, 15})
+	binary.Write(&chars, le, struct {
+		Id               uint32
+		X, Y, W, H       uint16
+		XOff, YOff, XAdv int16
+		Page, Chnl       uint8
+	}{86, 8, 0, 18, 24, -1, 5, 19, 1, 15})
+	block(bmBlockChars, chars.Bytes())
+
+	var kerning bytes.Buffer
+	binary.Write(&kerning, le, struct {
+		First, Second uint32
+		Amount        int16
+	}{65, 86, -3})
+	block(bmBlockKerning, kerning.Bytes())
+	return b.Bytes()
+}
+
+func TestLoadBMFont(t *testing.T) {
+	bk.UseSoftRenderer(bk.NewSoftRenderer(4, 4))
+	bk.R.Init()
+	defer bk.UseSoftRenderer(nil)
+
+	var page bytes.Buffer
+	if err := png.Encode(&page, image.NewRGBA(image.Rect(0, 0, 64, 32))); err != nil {
+		t.Fatal(err)
+	}
+	var opened []string
+	open := func(name string) (io.ReadCloser, error) {
+		opened = append(opened, name)
+		return ioutil.NopCloser(bytes.NewReader(page.Bytes())), nil
+	}
+
+	formats := []struct {
+		name string
+		data []byte
+	}{
+		{"text", []byte(bmText)},
+		{"xml", []byte(bmXML)},
+		{"binary", bmBinary()},
+	}
+	for _, format := range formats {
+		opened = opened[:0]
+		fnt, err := LoadBMFont(bytes.NewReader(format.data), open)
+		if err != nil {
+			t.Fatalf("%s: %v", format.name, err)
+		}
+		if len(opened) != 2 || opened[0] != "test_0.png" || opened[1] != "test_1.png" {
+			t.Errorf("%s: opened pages %v", format.name, opened)
+		}
+		if fnt.Pages() != 2 {
+			t.Errorf("%s: pages = %d, want 2", format.name, fnt.Pages())
+		}
+		if m := fnt.Metrics(); m.LineHeight != 36 || m.Base != 29 {
+			t.Errorf("%s: metrics = %+v", format.name, m)
+		}
+		a, ok := fnt.Glyph('A')
+		if !ok || a.Page != 0 || a.X != 2 || a.Y != 4 || a.Width != 20 || a.XOffset != 1 || a.Advance != 22 {
+			t.Errorf("%s: glyph A = %+v", format.name, a)
+		}
+		v, ok := fnt.Glyph('V')
+		if !ok || v.Page != 1 || v.XOffset != -1 || v.Advance != 19 {
+			t.Errorf("%s: glyph V = %+v", format.name, v)
+		}
+		if k := fnt.Kern('A', 'V'); k != -3 {
+			t.Errorf("%s: kerning AV = %v, want -3", format.name, k)
+		}
+		if u1, v1, u2, v2 := fnt.Frame('V'); u1 != 8.0/64 || v1 != 0 || u2 != 26.0/64 || v2 != 24.0/32 {
+			t.Errorf("%s: frame V = %v %v %v %v", format.name, u1, v1, u2, v2)
+		}
+		if id0, _ := fnt.Page(0); id0 == bk.InvalidId {
+			t.Errorf("%s: page 0 has no texture", format.name)
+		}
+		fnt.Dispose()
+	}
+}
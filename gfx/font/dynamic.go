@@ -0,0 +1,313 @@
+package font
+
+import (
+	"sckorok/gfx/bk"
+
+	"github.com/golang/freetype/truetype"
+	xfont "golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"log"
+)
+
+// Paged is implemented by the fonts which store glyphs in several textures,
+// Glyph.Page is the index of the texture.
+//
+// Glyphs may be evicted from the textures, Version changes after that and the
+// glyphs should be looked up again.
+type Paged interface {
+	// Page returns the texture of the page, and marks the page is used in current
+	// frame, a page used in current frame is never evicted.
+	Page(i int) (id uint16, tex *bk.Texture2D)
+	Version() uint32
+}
+
+// AdvanceFrame should be called once per frame, the dynamic fonts use frame
+// number to find the pages not used recently.
+func AdvanceFrame() {
+	frame++
+}
+
+// current frame, starts from 1, so a new page isn't treated as used
+var frame uint32 = 1
+
+// DynamicConfig configures a DynamicFont.
+//
+// Size is the font size in points. Each page is a PageSize x PageSize texture
+// (default 512), at most MaxPages (default 4) pages are allocated, after that
+// the page least recently used is cleared to make room for new glyphs.
+// Preload runes are rasterized at load time.
+type DynamicConfig struct {
+	Size     int
+	PageSize int
+	MaxPages int
+	Preload  []rune
+}
+
+// DynamicFont is a TrueType font rasterizes glyphs on demand. Glyphs are packed
+// into pages(textures) row by row, new pages are added when the pages are full.
+type DynamicFont struct {
+	face   xfont.Face
+	ascent fixed.Int26_6
+
+	pageSize int
+	maxPages int
+	pages    []*glyphPage
+
+	glyphs  map[rune]Glyph
+	missing map[rune]bool
+	version uint32
+
+	gWidth  float32 // Largest glyph width.
+	gHeight float32 // Largest glyph height.
+}
+
+// glyph rows in a page
+type glyphShelf struct {
+	y, h, x int
+}
+
+type glyphPage struct {
+	id      uint16
+	shelves []glyphShelf
+	bottom  int
+	used    uint32
+}
+
+// the first rows are reserved for the white pixel at (0, 0)
+const pageReserved = 2
+
+// LoadDynamic loads a TrueType font from the given stream, glyphs are
+// rasterized when they are used the first time.
+func LoadDynamic(r io.Reader, dc DynamicConfig) (*DynamicFont, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	ttf, err := truetype.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if dc.PageSize <= 0 {
+		dc.PageSize = 512
+	}
+	if dc.MaxPages <= 0 {
+		dc.MaxPages = 4
+	}
+
+	face := truetype.NewFace(ttf, &truetype.Options{
+		Size: float64(dc.Size),
+		DPI:  72,
+	})
+	gb := ttf.Bounds(fixed.I(dc.Size))
+	f := &DynamicFont{
+		face:     face,
+		ascent:   face.Metrics().Ascent,
+		pageSize: dc.PageSize,
+		maxPages: dc.MaxPages,
+		glyphs:   make(map[rune]Glyph),
+		missing:  make(map[rune]bool),
+		gWidth:   fixed2f32(gb.Max.X - gb.Min.X),
+		gHeight:  fixed2f32(gb.Max.Y - gb.Min.Y),
+	}
+	for _, r := range dc.Preload {
+		f.Glyph(r)
+	}
+	return f, nil
+}
+
+// Tex2D returns the texture of the first page.
+func (f *DynamicFont) Tex2D() (id uint16, tex *bk.Texture2D) {
+	if len(f.pages) == 0 {
+		f.addPage()
+	}
+	return f.Page(0)
+}
+
+func (f *DynamicFont) Page(i int) (id uint16, tex *bk.Texture2D) {
+	if i < len(f.pages) {
+		p := f.pages[i]
+		p.used = frame
+		id = p.id
+		if ok, t := bk.R.Texture(id); ok {
+			tex = t
+		}
+	}
+	return
+}
+
+func (f *DynamicFont) Version() uint32 {
+	return f.version
+}
+
+// Pages returns the number of allocated pages.
+func (f *DynamicFont) Pages() int {
+	return len(f.pages)
+}
+
+// Glyph returns the glyph of the rune, it's rasterized if not in the pages.
+func (f *DynamicFont) Glyph(r rune) (g Glyph, ok bool) {
+	if g, ok = f.glyphs[r]; ok {
+		f.pages[g.Page].used = frame
+		return
+	}
+	if f.missing[r] {
+		return
+	}
+	return f.rasterize(r)
+}
+
+func (f *DynamicFont) Bounds() (gw, gh float32) {
+	return f.gWidth, f.gHeight
+}
+
+func (f *DynamicFont) Metrics() Metrics {
+	return faceMetrics(f.face)
+}
+
+func (f *DynamicFont) Kern(r0, r1 rune) float32 {
+	return fixed2f32(f.face.Kern(r0, r1))
+}
+
+func (f *DynamicFont) Frame(r rune) (u1, v1, u2, v2 float32) {
+	g := f.glyphs[r]
+	size := float32(f.pageSize)
+	u1, v1 = g.X/size, g.Y/size
+	u2, v2 = (g.X+g.Width)/size, (g.Y+g.Height)/size
+	return
+}
+
+// Dispose releases all the pages.
+func (f *DynamicFont) Dispose() {
+	for _, p := range f.pages {
+		bk.R.Free(p.id)
+	}
+	f.pages = nil
+	f.glyphs = make(map[rune]Glyph)
+	f.version++
+}
+
+// use the same metrics as LoadTrueType
+func (f *DynamicFont) rasterize(r rune) (g Glyph, ok bool) {
+	bb, advance, ok := f.face.GlyphBounds(r)
+	if !ok {
+		f.missing[r] = true
+		return
+	}
+	padding := fixed.I(2)
+	adjust := padding / 2
+	gw, gh := bb.Max.X-bb.Min.X, bb.Max.Y-bb.Min.Y
+	w, h := (gw + padding).Ceil(), (gh + padding).Ceil()
+
+	page, x, y, ok := f.alloc(w, h)
+	if !ok {
+		log.Printf("font: no room for glyph %q, all pages are in use", r)
+		return
+	}
+
+	// draw glyph with the padding
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	d := fixed.Point26_6{X: adjust - bb.Min.X, Y: adjust - bb.Min.Y}
+	if dr, mask, mp, _, drawn := f.face.Glyph(d, r); drawn {
+		draw.DrawMask(img, dr, image.White, image.Point{}, mask, mp, draw.Over)
+	}
+	if ok, tex := bk.R.Texture(f.pages[page].id); ok {
+		tex.Update(img, int32(x), int32(y), int32(w), int32(h))
+	}
+
+	g = Glyph{
+		Rune:    r,
+		Page:    uint16(page),
+		Advance: int(advance.Floor()),
+		X:       float32(x),
+		Y:       float32(y),
+		Width:   fixed2f32(gw + padding),
+		Height:  fixed2f32(gh + padding),
+
+		XOffset: fixed2f32(bb.Min.X),
+		YOffset: fixed2f32(f.ascent + bb.Min.Y - padding),
+	}
+	f.glyphs[r] = g
+	return g, true
+}
+
+// alloc finds room in the pages, adds a new page or evicts a page.
+func (f *DynamicFont) alloc(w, h int) (page, x, y int, ok bool) {
+	if w > f.pageSize || h > f.pageSize-pageReserved {
+		return
+	}
+	for i, p := range f.pages {
+		if x, y, ok = p.alloc(w, h, f.pageSize); ok {
+			p.used = frame
+			return i, x, y, ok
+		}
+	}
+	if len(f.pages) < f.maxPages {
+		page = f.addPage()
+	} else if page = f.evict(); page < 0 {
+		return
+	}
+	p := f.pages[page]
+	p.used = frame
+	x, y, ok = p.alloc(w, h, f.pageSize)
+	return
+}
+
+func (f *DynamicFont) addPage() int {
+	img := image.NewRGBA(image.Rect(0, 0, f.pageSize, f.pageSize))
+	// add a white pixel at (0, 0)
+	img.Set(0, 0, color.White)
+	id, _ := bk.R.AllocTexture(img)
+	f.pages = append(f.pages, &glyphPage{id: id, bottom: pageReserved})
+	return len(f.pages) - 1
+}
+
+// evict clears the page least recently used, return -1 if all the pages are
+// used in current frame.
+func (f *DynamicFont) evict() (page int) {
+	page = -1
+	for i, p := range f.pages {
+		if p.used != frame && (page < 0 || p.used < f.pages[page].used) {
+			page = i
+		}
+	}
+	if page < 0 {
+		return
+	}
+	for r, g := range f.glyphs {
+		if int(g.Page) == page {
+			delete(f.glyphs, r)
+		}
+	}
+	p := f.pages[page]
+	p.shelves, p.bottom = p.shelves[:0], pageReserved
+	f.version++
+	return
+}
+
+// alloc finds the lowest shelf fits the glyph, or starts a new shelf.
+func (p *glyphPage) alloc(w, h, size int) (x, y int, ok bool) {
+	best := -1
+	for i, s := range p.shelves {
+		if h <= s.h && s.x+w <= size && (best < 0 || s.h < p.shelves[best].h) {
+			best = i
+		}
+	}
+	if best < 0 {
+		if p.bottom+h > size {
+			return
+		}
+		p.shelves = append(p.shelves, glyphShelf{y: p.bottom, h: h})
+		p.bottom += h
+		best = len(p.shelves) - 1
+	}
+	s := &p.shelves[best]
+	x, y = s.x, s.y
+	s.x += w
+	return x, y, true
+}
@@ -0,0 +1,82 @@
+package font
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+
+	"sckorok/gfx/bk"
+)
+
+func loadDynamic(t *testing.T, dc DynamicConfig) *DynamicFont {
+	bk.UseSoftRenderer(bk.NewSoftRenderer(4, 4))
+	bk.R.Init()
+	t.Cleanup(func() { bk.UseSoftRenderer(nil) })
+
+	f, err := LoadDynamic(bytes.NewReader(goregular.TTF), dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestDynamicGlyph(t *testing.T) {
+	f := loadDynamic(t, DynamicConfig{Size: 16, PageSize: 128, Preload: []rune("abc")})
+	if f.Pages() != 1 {
+		t.Fatal("preload should allocate a page")
+	}
+	g, ok := f.Glyph('Ж')
+	if !ok || g.Width <= 0 || g.Advance <= 0 {
+		t.Fatal("fail to rasterize glyph on demand:", g)
+	}
+	a, _ := f.Glyph('a')
+	if a.X == g.X && a.Y == g.Y {
+		t.Error("glyphs overlap:", a, g)
+	}
+	if u1, v1, u2, v2 := f.Frame('Ж'); u1 >= u2 || v1 >= v2 || u2 > 1 || v2 > 1 {
+		t.Error("frame error:", u1, v1, u2, v2)
+	}
+}
+
+func TestDynamicPages(t *testing.T) {
+	f := loadDynamic(t, DynamicConfig{Size: 16, PageSize: 64, MaxPages: 2})
+
+	// fill two pages
+	var runes []rune
+	for r := 'A'; f.Pages() < 2 || len(runes) < 40; r++ {
+		if _, ok := f.Glyph(r); ok {
+			runes = append(runes, r)
+		}
+	}
+	if f.Pages() != 2 {
+		t.Fatal("page size error:", f.Pages())
+	}
+
+	// pages are in use in current frame, can't evict
+	version := f.Version()
+	for r := 'a'; r <= 'z'; r++ {
+		f.Glyph(r)
+	}
+	if f.Version() != version {
+		t.Fatal("pages used in current frame are evicted")
+	}
+
+	// the first page isn't used in next frame
+	AdvanceFrame()
+	f.Page(1)
+	for r := '0'; r <= '9'; r++ {
+		if _, ok := f.Glyph(r); !ok {
+			t.Fatal("fail to add glyph after eviction:", string(r))
+		}
+	}
+	if f.Version() == version {
+		t.Fatal("version should change after eviction")
+	}
+	if g, ok := f.glyphs['0']; !ok || g.Page != 0 {
+		t.Error("glyph should be added to the evicted page:", g)
+	}
+	if _, ok := f.glyphs[runes[0]]; ok {
+		t.Error("glyphs of the evicted page should be removed")
+	}
+}
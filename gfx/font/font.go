@@ -4,6 +4,8 @@ import (
 	"sckorok/gfx/bk"
 	"sckorok/math/f32"
 
+	xfont "golang.org/x/image/font"
+
 	"image"
 	"image/color"
 
@@ -26,12 +28,22 @@ const (
 // Glyph returns the matrix of rune.
 // Bounds returns the largest width and height for any of the glyphs
 // in the fontAtlas.
+// Metrics returns the line height and base line.
 // SizeOf measure the text and returns the width and height.
 type Font interface {
 	Tex2D() (uint16, *bk.Texture2D)
 	Glyph(rune rune) (g Glyph, ok bool)
 	Bounds() (gw, gh float32)
 	Frame(rune rune) (x1, y1, x2, y2 float32)
+	Metrics() Metrics
+}
+
+// Metrics is the vertical metrics of a font in pixels. LineHeight is the
+// distance between lines, Base is the distance from top of line to the base
+// line.
+type Metrics struct {
+	LineHeight float32
+	Base       float32
 }
 
 type Disposer interface {
@@ -51,6 +63,12 @@ type fontAtlas struct {
 	glyphs map[rune]Glyph
 	w, h   uint16
 
+	// kerning from the TrueType face or the pairs
+	face    xfont.Face
+	kerning map[[2]rune]float32
+
+	metrics Metrics
+
 	regions []float32
 
 	// fallback
@@ -74,6 +92,23 @@ func (f *fontAtlas) Bounds() (float32, float32) {
 	return f.gWidth, f.gHeight
 }
 
+// Metrics returns the metrics of TrueType face, the glyph height is used
+// if the font has no metrics.
+func (f *fontAtlas) Metrics() Metrics {
+	if f.face != nil {
+		return faceMetrics(f.face)
+	}
+	if f.metrics.LineHeight == 0 {
+		return Metrics{LineHeight: f.gHeight, Base: f.gHeight}
+	}
+	return f.metrics
+}
+
+func faceMetrics(face xfont.Face) Metrics {
+	m := face.Metrics()
+	return Metrics{LineHeight: fixed2f32(m.Height), Base: fixed2f32(m.Ascent)}
+}
+
 func (f *fontAtlas) Frame(r rune) (u1, v1, u2, v2 float32) {
 	g := f.glyphs[r]
 	u1, v1 = float32(g.X)/f.texWidth, float32(g.Y)/f.texHeight
@@ -81,6 +116,13 @@ func (f *fontAtlas) Frame(r rune) (u1, v1, u2, v2 float32) {
 	return
 }
 
+func (f *fontAtlas) Kern(r0, r1 rune) float32 {
+	if f.face != nil {
+		return fixed2f32(f.face.Kern(r0, r1))
+	}
+	return f.kerning[[2]rune{r0, r1}]
+}
+
 // Release release fontAtlas resources.
 func (f *fontAtlas) Dispose() {
 	bk.R.Free(f.id)
@@ -104,6 +146,9 @@ func (f *fontAtlas) loadTex(img *image.RGBA) error {
 	if id, _ := bk.R.AllocTexture(img); id != bk.InvalidId {
 		f.id = id
 	}
+	if bk.SoftRendering() {
+		return nil
+	}
 	return checkGLError()
 }
 
@@ -10,8 +10,10 @@ type Point struct {
 //
 // Advance determines the distance to the next glyph.
 // This is used to properly align non-monospaced fonts.
+// Page is the texture index of the glyph, see Paged.
 type Glyph struct {
 	Rune rune
+	Page uint16
 
 	X      float32
 	Y      float32
@@ -0,0 +1,253 @@
+package font
+
+import (
+	"unicode/utf8"
+)
+
+// Kerning is implemented by the fonts have kerning pairs, Kern returns the
+// adjustment of advance between two runes in pixels of the font.
+type Kerning interface {
+	Kern(r0, r1 rune) float32
+}
+
+// Align is the horizontal alignment of lines.
+type Align uint8
+
+const (
+	AlignLeft Align = iota
+	AlignCenter
+	AlignRight
+	AlignJustify // spaces are stretched, the last line of paragraph is left aligned
+)
+
+// WrapMode decides where a line can break.
+type WrapMode uint8
+
+const (
+	WrapWord WrapMode = iota // break at spaces, long words are broken at any rune
+	WrapChar                 // break at any rune
+)
+
+// LayoutConfig configures the text layout.
+//
+// Size is the font size, zero means the size of the font. Lines are wrapped
+// if they are wider than WrapWidth, zero means no wrapping. Lines are aligned
+// in the width of the widest line. LineHeight is the distance between lines
+// in multiple of the font's line height, zero means 1. LetterSpacing is added
+// to the advance of every rune.
+type LayoutConfig struct {
+	Size          float32
+	WrapWidth     float32
+	Wrap          WrapMode
+	Align         Align
+	LineHeight    float32
+	LetterSpacing float32
+}
+
+// Positioned is a glyph placed by Layout. X is the position of pen on the line,
+// Y is the distance from top of the text to top of the line, both are scaled
+// with the font size. Scale is the scale of glyph metrics. Font is the font of
+// the glyph, nil for icons. Run is the index of the Run, Index is the index of
+// the rune in the text without line breaks.
+type Positioned struct {
+	Glyph
+	X, Y  float32
+	Scale float32
+	Font  Font
+	Line  int
+	Run   int
+	Index int
+}
+
+// a laid out line, glyphs[start:end]
+type layoutLine struct {
+	start, end int
+	width      float32
+	hard       bool // ends with '\n' or the end of text
+}
+
+// IconRune is the rune of the glyphs placed for icons.
+const IconRune = '\uFFFC'
+
+// Layout places the glyphs of text, the glyphs are appended to buf. It returns
+// the glyphs and the bounds of the text.
+func Layout(f Font, text string, lc LayoutConfig, buf []Positioned) (glyphs []Positioned, w, h float32) {
+	return LayoutRuns(f, []Run{{Text: text}}, lc, buf)
+}
+
+// LayoutRuns places the glyphs of styled runs, f is the font of the runs
+// without font. Icons are as high as the font size by default and sit on the
+// bottom of the line.
+func LayoutRuns(f Font, runs []Run, lc LayoutConfig, buf []Positioned) (glyphs []Positioned, w, h float32) {
+	_, gh := f.Bounds()
+	size := lc.Size
+	if size == 0 {
+		size = gh
+	}
+	lineHeight := lc.LineHeight
+	if lineHeight == 0 {
+		lineHeight = 1
+	}
+	if m := f.Metrics(); m.LineHeight > 0 && gh > 0 {
+		lineHeight *= m.LineHeight / gh
+	}
+
+	var (
+		lines     []layoutLine
+		start     = len(buf)
+		pen       float32
+		prev      = rune(-1)
+		prevFont  Font
+		lastBreak = -1 // the first glyph after last space of current line
+		index     = 0
+	)
+	// newLine finishes the line at glyphs[start:end], the next line starts at next
+	newLine := func(end, next int, hard bool) {
+		lines = append(lines, layoutLine{start: start, end: end, hard: hard})
+		start, lastBreak, prev = next, -1, -1
+	}
+	// place appends the glyph at pen, wraps the line if it's too long
+	place := func(p Positioned, x, advance float32) {
+		// wrap before the glyph, spaces are never wrapped
+		if lc.WrapWidth > 0 && p.Rune != ' ' && x+advance > lc.WrapWidth && hasGlyph(buf[start:]) {
+			if lc.Wrap == WrapWord && lastBreak > start && lastBreak < len(buf) {
+				// move the last word to next line
+				shift := buf[lastBreak].X
+				for i := lastBreak; i < len(buf); i++ {
+					buf[i].X -= shift
+				}
+				newLine(lastBreak, lastBreak, false)
+				x -= shift
+			} else {
+				newLine(len(buf), len(buf), false)
+				x = 0
+			}
+		}
+		p.X = x
+		buf = append(buf, p)
+		pen = x + advance + lc.LetterSpacing
+		if p.Rune == ' ' {
+			lastBreak = len(buf)
+		}
+	}
+
+	for ri, run := range runs {
+		if icon := run.Icon; icon != nil {
+			ih := icon.Height
+			if ih == 0 {
+				ih = size
+			}
+			iw := icon.Width
+			if iw == 0 {
+				iw = ih
+			}
+			g := Glyph{Rune: IconRune, Width: iw, Height: ih, YOffset: size - ih, Advance: int(iw + .5)}
+			place(Positioned{Glyph: g, Scale: 1, Run: ri, Index: index}, pen, iw)
+			index++
+			prev = -1
+			continue
+		}
+		rf := run.Font
+		if rf == nil {
+			rf = f
+		}
+		scale := float32(1)
+		if _, rh := rf.Bounds(); lc.Size != 0 && rh != 0 {
+			scale = size / rh
+		}
+		kerning, _ := rf.(Kerning)
+		if rf != prevFont {
+			prev, prevFont = -1, rf
+		}
+
+		for _, r := range run.Text {
+			if r == '\n' {
+				newLine(len(buf), len(buf), true)
+				pen = 0
+				continue
+			}
+			index++
+			g, ok := rf.Glyph(r)
+			if !ok {
+				continue
+			}
+			x := pen
+			if kerning != nil && prev >= 0 {
+				x += kerning.Kern(prev, r) * scale
+			}
+			place(Positioned{Glyph: g, Scale: scale, Font: rf, Run: ri, Index: index - 1}, x, float32(g.Advance)*scale)
+			prev = r
+		}
+	}
+	lines = append(lines, layoutLine{start: start, end: len(buf), hard: true})
+
+	// width of lines without trailing spaces
+	for i := range lines {
+		ln := &lines[i]
+		for j := ln.end - 1; j >= ln.start; j-- {
+			if g := &buf[j]; g.Rune != ' ' {
+				ln.width = g.X + float32(g.Advance)*g.Scale
+				break
+			}
+		}
+		if ln.width > w {
+			w = ln.width
+		}
+	}
+
+	// align and set lines
+	for i, ln := range lines {
+		line := buf[ln.start:ln.end]
+		y := float32(i) * size * lineHeight
+		var offset, extra float32
+		switch lc.Align {
+		case AlignCenter:
+			offset = (w - ln.width) / 2
+		case AlignRight:
+			offset = w - ln.width
+		case AlignJustify:
+			if n := spaces(line); !ln.hard && n > 0 {
+				extra = (w - ln.width) / float32(n)
+			}
+		}
+		var stretch float32
+		for j := range line {
+			line[j].X += offset + stretch
+			line[j].Y, line[j].Line = y, i
+			if line[j].Rune == ' ' {
+				stretch += extra
+			}
+		}
+	}
+	h = float32(len(lines)-1)*size*lineHeight + size
+	return buf, w, h
+}
+
+// Measure returns the bounds of the text laid out with the config.
+func Measure(f Font, text string, lc LayoutConfig) (w, h float32) {
+	_, w, h = Layout(f, text, lc, make([]Positioned, 0, utf8.RuneCountInString(text)))
+	return
+}
+
+func hasGlyph(line []Positioned) bool {
+	for i := range line {
+		if line[i].Rune != ' ' {
+			return true
+		}
+	}
+	return false
+}
+
+// the spaces between words
+func spaces(line []Positioned) (n int) {
+	end := len(line)
+	for end > 0 && line[end-1].Rune == ' ' {
+		end--
+	}
+	for _, g := range line[:end] {
+		if g.Rune == ' ' {
+			n++
+		}
+	}
+	return
+}
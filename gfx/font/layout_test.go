@@ -0,0 +1,105 @@
+package font
+
+import (
+	"testing"
+
+	"sckorok/gfx/bk"
+)
+
+// monoFont is a monospaced font, every rune is 10 pixels wide and 20 pixels
+// high, "AV" has kerning -2.
+type monoFont struct{}
+
+func (monoFont) Tex2D() (uint16, *bk.Texture2D) { return 0, nil }
+func (monoFont) Bounds() (gw, gh float32)       { return 10, 20 }
+func (monoFont) Metrics() Metrics               { return Metrics{LineHeight: 20, Base: 16} }
+func (monoFont) Frame(r rune) (x1, y1, x2, y2 float32) {
+	return
+}
+func (monoFont) Glyph(r rune) (g Glyph, ok bool) {
+	return Glyph{Rune: r, Width: 10, Height: 20, Advance: 10}, true
+}
+func (monoFont) Kern(r0, r1 rune) float32 {
+	if r0 == 'A' && r1 == 'V' {
+		return -2
+	}
+	return 0
+}
+
+func lineOf(glyphs []Positioned) (lines []string) {
+	for _, g := range glyphs {
+		for len(lines) <= g.Line {
+			lines = append(lines, "")
+		}
+		lines[g.Line] += string(g.Rune)
+	}
+	return
+}
+
+func TestLayoutWrap(t *testing.T) {
+	cases := []struct {
+		text  string
+		mode  WrapMode
+		lines []string
+	}{
+		{"aaa bb cc", WrapWord, []string{"aaa ", "bb cc"}},
+		{"aaa bb cc", WrapChar, []string{"aaa b", "b cc"}},
+		{"aaaaaaa bbb", WrapWord, []string{"aaaaa", "aa ", "bbb"}},
+		{"a\nbb", WrapWord, []string{"a", "bb"}},
+	}
+	for _, c := range cases {
+		glyphs, w, h := Layout(monoFont{}, c.text, LayoutConfig{WrapWidth: 50, Wrap: c.mode}, nil)
+		lines := lineOf(glyphs)
+		if len(lines) != len(c.lines) {
+			t.Errorf("%q: lines %q, want %q", c.text, lines, c.lines)
+			continue
+		}
+		for i := range lines {
+			if lines[i] != c.lines[i] {
+				t.Errorf("%q: lines %q, want %q", c.text, lines, c.lines)
+				break
+			}
+		}
+		if w > 50 || h != float32(len(c.lines)*20) {
+			t.Errorf("%q: size error: %v, %v", c.text, w, h)
+		}
+	}
+}
+
+func TestLayoutAlign(t *testing.T) {
+	text := "aa b\nc"
+	glyphs, w, _ := Layout(monoFont{}, text, LayoutConfig{Align: AlignRight}, nil)
+	if w != 40 || glyphs[4].X != 30 {
+		t.Error("right align error:", w, glyphs[4].X)
+	}
+	glyphs, _, _ = Layout(monoFont{}, text, LayoutConfig{Align: AlignCenter}, nil)
+	if glyphs[4].X != 15 {
+		t.Error("center align error:", glyphs[4].X)
+	}
+
+	// the last line isn't justified
+	glyphs, _, _ = Layout(monoFont{}, "a b ccc dd", LayoutConfig{WrapWidth: 60, Align: AlignJustify}, nil)
+	if x := glyphs[2].X; x != 50 {
+		t.Error("justify error:", lineOf(glyphs), x)
+	}
+	if x := glyphs[8].X; x != 40 {
+		t.Error("last line should be left aligned:", x)
+	}
+}
+
+func TestLayoutSpacing(t *testing.T) {
+	glyphs, w, h := Layout(monoFont{}, "AVA\nA", LayoutConfig{Size: 40, LineHeight: 1.5, LetterSpacing: 1}, nil)
+	// scale 2, kerning -4
+	if x := glyphs[1].X; x != 21-4 {
+		t.Error("kerning error:", x)
+	}
+	if w != 17+21+20 || h != 40*1.5+40 {
+		t.Error("size error:", w, h)
+	}
+	if y := glyphs[3].Y; y != 60 {
+		t.Error("line height error:", y)
+	}
+	if mw, mh := Measure(monoFont{}, "AVA\nA", LayoutConfig{Size: 40, LineHeight: 1.5, LetterSpacing: 1}); mw != w || mh != h {
+		t.Error("measure error:", mw, mh)
+	}
+}
@@ -0,0 +1,269 @@
+package font
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Run is a piece of text in the same style.
+//
+// Font nil means the base font, Color zero means the base color, the color
+// uses the byte-order 0xAABBGGRR. Wave and Shake are the amplitude of the
+// per-rune animations in pixels. A Run with Icon draws the icon instead of text.
+type Run struct {
+	Text  string
+	Font  Font
+	Color uint32
+	Icon  *Icon
+
+	Wave  float32
+	Shake float32
+}
+
+// Icon is an image drawn inline with the text, usually a sub-texture in an
+// atlas. Tex is the texture id, Region is the uv rectangle <u1, v1, u2, v2>.
+// Height zero means the font size, Width zero means the Height.
+type Icon struct {
+	Tex           uint16
+	Region        [4]float32
+	Width, Height float32
+}
+
+// MarkupStyle provides the fonts and icons used by the markup.
+type MarkupStyle struct {
+	Bold, Italic, BoldItalic Font
+
+	// fonts of [font=name] tag
+	Fonts map[string]Font
+
+	// icons of [icon=name] tag
+	Icon func(name string) (Icon, bool)
+}
+
+// default amplitude of [wave] and [shake]
+const (
+	DefaultWave  = 3
+	DefaultShake = 1.5
+)
+
+// ParseMarkup parses the BBCode-style markup into runs:
+//
+//	[b]bold[/b] [i]italic[/i] [font=name]...[/font]
+//	[color=red]...[/color] [color=#FF8000]...[/color] [color=#FF800080]...[/color]
+//	[wave]...[/wave] [wave=5]...[/wave] [shake]...[/shake] [shake=2]...[/shake]
+//	[icon=name]
+//
+// "[[" is a literal "[". Unknown or invalid tags are kept as text, unclosed
+// tags last to the end of text. ms can be nil if no font or icon is used.
+func ParseMarkup(markup string, ms *MarkupStyle) (runs []Run) {
+	if ms == nil {
+		ms = &MarkupStyle{}
+	}
+	var (
+		p    markupParser
+		text strings.Builder
+	)
+	p.ms = ms
+	flush := func() {
+		if text.Len() > 0 {
+			runs = append(runs, p.run(text.String()))
+			text.Reset()
+		}
+	}
+	for i := 0; i < len(markup); {
+		c := markup[i]
+		if c != '[' {
+			text.WriteByte(c)
+			i++
+			continue
+		}
+		if i+1 < len(markup) && markup[i+1] == '[' {
+			text.WriteByte('[')
+			i += 2
+			continue
+		}
+		end := strings.IndexByte(markup[i:], ']')
+		if end < 0 {
+			text.WriteString(markup[i:])
+			break
+		}
+		tag := markup[i+1 : i+end]
+		if icon, ok := p.icon(tag); ok {
+			flush()
+			run := p.run("")
+			run.Icon = &icon
+			runs = append(runs, run)
+		} else if flush(); !p.apply(tag) {
+			text.WriteString(markup[i : i+end+1])
+		}
+		i += end + 1
+	}
+	flush()
+	return
+}
+
+// Plain returns the text of runs without markup, an icon is IconRune.
+func Plain(runs []Run) string {
+	var b strings.Builder
+	for _, r := range runs {
+		if r.Icon != nil {
+			b.WriteRune(IconRune)
+		} else {
+			b.WriteString(r.Text)
+		}
+	}
+	return b.String()
+}
+
+type markupParser struct {
+	ms           *MarkupStyle
+	bold, italic int
+	fonts        []Font
+	colors       []uint32
+	waves        []float32
+	shakes       []float32
+}
+
+func (p *markupParser) run(text string) Run {
+	r := Run{Text: text}
+	if n := len(p.fonts); n > 0 {
+		r.Font = p.fonts[n-1]
+	} else if p.bold > 0 && p.italic > 0 && p.ms.BoldItalic != nil {
+		r.Font = p.ms.BoldItalic
+	} else if p.bold > 0 && p.ms.Bold != nil {
+		r.Font = p.ms.Bold
+	} else if p.italic > 0 && p.ms.Italic != nil {
+		r.Font = p.ms.Italic
+	}
+	if n := len(p.colors); n > 0 {
+		r.Color = p.colors[n-1]
+	}
+	if n := len(p.waves); n > 0 {
+		r.Wave = p.waves[n-1]
+	}
+	if n := len(p.shakes); n > 0 {
+		r.Shake = p.shakes[n-1]
+	}
+	return r
+}
+
+func (p *markupParser) icon(tag string) (icon Icon, ok bool) {
+	name, value := splitTag(tag)
+	if name != "icon" || p.ms.Icon == nil {
+		return
+	}
+	return p.ms.Icon(value)
+}
+
+func (p *markupParser) apply(tag string) bool {
+	name, value := splitTag(tag)
+	switch name {
+	case "b":
+		p.bold++
+	case "/b":
+		p.bold = dec(p.bold)
+	case "i":
+		p.italic++
+	case "/i":
+		p.italic = dec(p.italic)
+	case "font":
+		f, ok := p.ms.Fonts[value]
+		if !ok {
+			return false
+		}
+		p.fonts = append(p.fonts, f)
+	case "/font":
+		if n := len(p.fonts); n > 0 {
+			p.fonts = p.fonts[:n-1]
+		}
+	case "color":
+		c, ok := parseColor(value)
+		if !ok {
+			return false
+		}
+		p.colors = append(p.colors, c)
+	case "/color":
+		if n := len(p.colors); n > 0 {
+			p.colors = p.colors[:n-1]
+		}
+	case "wave":
+		v, ok := parseAmplitude(value, DefaultWave)
+		if !ok {
+			return false
+		}
+		p.waves = append(p.waves, v)
+	case "/wave":
+		if n := len(p.waves); n > 0 {
+			p.waves = p.waves[:n-1]
+		}
+	case "shake":
+		v, ok := parseAmplitude(value, DefaultShake)
+		if !ok {
+			return false
+		}
+		p.shakes = append(p.shakes, v)
+	case "/shake":
+		if n := len(p.shakes); n > 0 {
+			p.shakes = p.shakes[:n-1]
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func splitTag(tag string) (name, value string) {
+	if i := strings.IndexByte(tag, '='); i >= 0 {
+		return strings.TrimSpace(tag[:i]), strings.TrimSpace(tag[i+1:])
+	}
+	return strings.TrimSpace(tag), ""
+}
+
+func dec(n int) int {
+	if n > 0 {
+		n--
+	}
+	return n
+}
+
+func parseAmplitude(v string, dft float32) (float32, bool) {
+	if v == "" {
+		return dft, true
+	}
+	f, err := strconv.ParseFloat(v, 32)
+	return float32(f), err == nil
+}
+
+var namedColors = map[string]uint32{
+	"white":   0xFFFFFFFF,
+	"black":   0xFF000000,
+	"gray":    0xFF888888,
+	"red":     0xFF0000FF,
+	"green":   0xFF00FF00,
+	"blue":    0xFFFF0000,
+	"yellow":  0xFF00FFFF,
+	"cyan":    0xFFFFFF00,
+	"magenta": 0xFFFF00FF,
+	"orange":  0xFF0080FF,
+}
+
+// parseColor parses a named color or #RRGGBB[AA] into 0xAABBGGRR, the color
+// is pre-multiplied by alpha.
+func parseColor(v string) (uint32, bool) {
+	if c, ok := namedColors[strings.ToLower(v)]; ok {
+		return c, true
+	}
+	if !strings.HasPrefix(v, "#") || (len(v) != 7 && len(v) != 9) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(v[1:], 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	if len(v) == 7 {
+		n = n<<8 | 0xFF
+	}
+	r, g, b, a := uint32(n>>24), uint32(n>>16&0xFF), uint32(n>>8&0xFF), uint32(n&0xFF)
+	r, g, b = r*a/0xFF, g*a/0xFF, b*a/0xFF
+	return a<<24 | b<<16 | g<<8 | r, true
+}
@@ -0,0 +1,87 @@
+package font
+
+import (
+	"testing"
+)
+
+func TestParseMarkup(t *testing.T) {
+	bold := monoFont{}
+	ms := &MarkupStyle{
+		Bold: bold,
+		Icon: func(name string) (Icon, bool) {
+			return Icon{Tex: 7, Width: 12}, name == "coin"
+		},
+	}
+	runs := ParseMarkup("a[color=#FF000080]b[b]c[/b][/color][[d[wave]e[/wave][icon=coin][x]", ms)
+	want := []Run{
+		{Text: "a"},
+		{Text: "b", Color: 0x80000080},
+		{Text: "c", Color: 0x80000080, Font: bold},
+		{Text: "[d"},
+		{Text: "e", Wave: DefaultWave},
+		{Icon: &Icon{}},
+		{Text: "[x]"},
+	}
+	if len(runs) != len(want) {
+		t.Fatalf("runs %+v", runs)
+	}
+	for i, r := range runs {
+		w := want[i]
+		if r.Text != w.Text || r.Color != w.Color || r.Font != w.Font || r.Wave != w.Wave || (r.Icon == nil) != (w.Icon == nil) {
+			t.Errorf("run %d: %+v, want %+v", i, r, w)
+		}
+	}
+	if s := Plain(runs); s != "abc[de\uFFFC[x]" {
+		t.Errorf("plain text: %q", s)
+	}
+}
+
+func TestTypewriter(t *testing.T) {
+	rt := NewRichText("ab\ncd", nil)
+	if rt.Len() != 4 {
+		t.Fatal("length error:", rt.Len())
+	}
+	var revealed []int
+	done := false
+	rt.Typewriter.Speed = 10
+	rt.Typewriter.OnReveal = func(i int) { revealed = append(revealed, i) }
+	rt.Typewriter.OnComplete = func() { done = true }
+
+	rt.Update(0.15)
+	if len(revealed) != 2 || rt.Alpha(1) != .5 || rt.Alpha(2) != 0 {
+		t.Error("progress error:", revealed, rt.Alpha(1), rt.Alpha(2))
+	}
+	if c := rt.Fade(1, 0xFFFFFFFF); c>>24 != 0x7F {
+		t.Errorf("fade error: %x", c)
+	}
+	rt.Typewriter.Skip()
+	if !done || len(revealed) != 4 || rt.Typewriter.Progress() != 1 {
+		t.Error("skip error:", done, revealed)
+	}
+
+	// changed runs continue from the revealed runes
+	rt.Runs = append(rt.Runs, Run{Text: "ef"})
+	rt.Refresh()
+	if rt.Typewriter.Done() || rt.Typewriter.Progress()*3 != 2 {
+		t.Error("refresh should update the length:", rt.Typewriter.Progress())
+	}
+	rt.Typewriter.Skip()
+	if rt.Alpha(5) != 1 {
+		t.Error("new runes should be revealed:", rt.Alpha(5))
+	}
+}
+
+func TestLayoutRuns(t *testing.T) {
+	runs := []Run{{Text: "ab"}, {Icon: &Icon{Width: 30, Height: 10}}, {Text: "c"}}
+	glyphs, w, _ := LayoutRuns(monoFont{}, runs, LayoutConfig{Size: 40}, nil)
+	icon := glyphs[2]
+	if icon.Rune != IconRune || icon.X != 40 || icon.YOffset != 30 || icon.Run != 1 {
+		t.Error("icon error:", icon)
+	}
+	if c := glyphs[3]; c.X != 70 || c.Index != 3 || c.Scale != 2 {
+		t.Error("glyph after icon error:", c)
+	}
+	if w != 90 {
+		t.Error("width error:", w)
+	}
+}
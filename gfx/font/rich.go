@@ -0,0 +1,180 @@
+package font
+
+import (
+	"math"
+)
+
+// RichText is a styled text with animations, it's parsed from markup, see
+// ParseMarkup. The animations advance with Update, it's called by the render
+// feature for TextComp, GUI should call it every frame.
+type RichText struct {
+	Runs []Run
+
+	// reveals the runes one by one, it shows all the runes if Speed is zero
+	Typewriter Typewriter
+
+	// time in seconds, drives the wave and shake
+	time float32
+}
+
+// NewRichText parses the markup into a RichText.
+func NewRichText(markup string, ms *MarkupStyle) *RichText {
+	rt := &RichText{Runs: ParseMarkup(markup, ms)}
+	rt.Refresh()
+	return rt
+}
+
+// Refresh updates the length of Typewriter, it should be called after Runs
+// is changed. TextComp.SetRichText calls it.
+func (rt *RichText) Refresh() {
+	rt.Typewriter.setTotal(rt.Len())
+}
+
+// Len returns the number of runes without line breaks, an icon is a rune.
+func (rt *RichText) Len() (n int) {
+	for _, r := range rt.Runs {
+		if r.Icon != nil {
+			n++
+			continue
+		}
+		for _, c := range r.Text {
+			if c != '\n' {
+				n++
+			}
+		}
+	}
+	return
+}
+
+// Update advances the animations.
+func (rt *RichText) Update(dt float32) {
+	rt.time += dt
+	rt.Typewriter.update(dt)
+}
+
+// wave runs through the runes in a second
+const (
+	waveSpeed = 2 * math.Pi
+	wavePhase = 0.6
+	shakeRate = 20 // shake offset changes 20 times per second
+)
+
+// Offset returns the animated offset of the rune, index is Positioned.Index.
+func (rt *RichText) Offset(run, index int) (dx, dy float32) {
+	r := &rt.Runs[run]
+	if r.Wave != 0 {
+		dy = r.Wave * float32(math.Sin(float64(rt.time*waveSpeed-float32(index)*wavePhase)))
+	}
+	if r.Shake != 0 {
+		seed := uint32(rt.time*shakeRate)*0x9E3779B1 ^ uint32(index)*0x85EBCA77
+		dx += r.Shake * (hashUnit(seed) - .5) * 2
+		dy += r.Shake * (hashUnit(seed^0xC2B2AE3D) - .5) * 2
+	}
+	return
+}
+
+// Alpha returns the opacity of the rune, it fades in when it's revealed.
+func (rt *RichText) Alpha(index int) float32 {
+	return rt.Typewriter.Rune(index)
+}
+
+// Fade multiplies the pre-multiplied color by the opacity of the rune.
+func (rt *RichText) Fade(index int, c uint32) uint32 {
+	alpha := rt.Alpha(index)
+	if alpha >= 1 {
+		return c
+	}
+	a := uint32(alpha * 0xFF)
+	r, g, b, ca := c&0xFF, c>>8&0xFF, c>>16&0xFF, c>>24
+	return (ca*a/0xFF)<<24 | (b*a/0xFF)<<16 | (g*a/0xFF)<<8 | r*a/0xFF
+}
+
+// hashUnit maps a seed to [0, 1)
+func hashUnit(x uint32) float32 {
+	x ^= x >> 16
+	x *= 0x7FEB352D
+	x ^= x >> 15
+	x *= 0x846CA68B
+	x ^= x >> 16
+	return float32(x>>8) / (1 << 24)
+}
+
+// Typewriter reveals the runes one by one. Speed is the number of runes per
+// second, zero shows all the runes. OnReveal is called when a rune starts to
+// show, OnComplete is called when all the runes are shown.
+type Typewriter struct {
+	Speed      float32
+	OnReveal   func(index int)
+	OnComplete func()
+
+	// runes revealed, the fraction is the progress of the last rune
+	progress float32
+	total    int
+	done     bool
+}
+
+// Restart hides all the runes and starts again.
+func (tw *Typewriter) Restart() {
+	tw.progress, tw.done = 0, false
+}
+
+// Skip shows all the runes immediately.
+func (tw *Typewriter) Skip() {
+	if tw.Speed != 0 {
+		tw.update(float32(tw.total) / tw.Speed)
+	}
+}
+
+// Progress returns the progress of the whole text in [0, 1].
+func (tw *Typewriter) Progress() float32 {
+	if tw.Speed == 0 || tw.total == 0 {
+		return 1
+	}
+	return float32(math.Min(float64(tw.progress/float32(tw.total)), 1))
+}
+
+// Rune returns the progress of the rune in [0, 1], 0 is hidden.
+func (tw *Typewriter) Rune(index int) float32 {
+	if tw.Speed == 0 {
+		return 1
+	}
+	return float32(math.Max(0, math.Min(1, float64(tw.progress-float32(index)))))
+}
+
+// Done returns true if all the runes are shown.
+func (tw *Typewriter) Done() bool {
+	return tw.Speed == 0 || tw.done
+}
+
+// setTotal keeps the revealed runes, the new runes are revealed next.
+func (tw *Typewriter) setTotal(n int) {
+	tw.total = n
+	if tw.progress > float32(n) {
+		tw.progress = float32(n)
+	}
+	if tw.progress < float32(n) {
+		tw.done = false
+	}
+}
+
+func (tw *Typewriter) update(dt float32) {
+	if tw.Speed == 0 || tw.done {
+		return
+	}
+	last := int(math.Ceil(float64(tw.progress)))
+	tw.progress += dt * tw.Speed
+	if n := float32(tw.total); tw.progress > n {
+		tw.progress = n
+	}
+	if fn := tw.OnReveal; fn != nil {
+		for i, n := last, int(math.Ceil(float64(tw.progress))); i < n; i++ {
+			fn(i)
+		}
+	}
+	if tw.progress >= float32(tw.total) {
+		tw.done = true
+		if fn := tw.OnComplete; fn != nil {
+			fn()
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package font
+
+import (
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/math/fixed"
+
+	"image"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"math"
+)
+
+// DistanceField is implemented by the fonts storing signed distance fields
+// instead of coverage. The distance is encoded as 0.5 + d/(2*Spread), so the
+// edge of glyph is at 0.5 and a value reaches 0 or 1 at Spread pixels away.
+// MSDF fonts store distances of 3 channels in RGB, the distance is the median
+// of them. Other fonts store the distance in all channels.
+type DistanceField interface {
+	Spread() float32
+	MSDF() bool
+}
+
+// SDFConfig configures the distance field generated from TrueType.
+//
+// Size is the font size in points, Spread is the range of distance in pixels
+// (default Size/8, at least 2). Glyphs are rasterized Scale times bigger than
+// Size to compute a precise field (default 4).
+type SDFConfig struct {
+	Size   int
+	Spread int
+	Scale  int
+	Runes  []rune
+}
+
+// sdfFont is a font atlas stores distance field.
+type sdfFont struct {
+	*fontAtlas
+	spread float32
+	msdf   bool
+}
+
+func (f *sdfFont) Spread() float32 {
+	return f.spread
+}
+
+func (f *sdfFont) MSDF() bool {
+	return f.msdf
+}
+
+// LoadSDF loads a TrueType font and generates the signed distance field of the
+// runes into one texture.
+func LoadSDF(r io.Reader, sc SDFConfig) (Font, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	ttf, err := truetype.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if sc.Spread <= 0 {
+		sc.Spread = sc.Size / 8
+		if sc.Spread < 2 {
+			sc.Spread = 2
+		}
+	}
+	if sc.Scale <= 0 {
+		sc.Scale = 4
+	}
+	if len(sc.Runes) == 0 {
+		sc.Runes = ASCII(sc.Size).Runes()
+	}
+
+	// glyphs are rasterized at high resolution, metrics use the normal size
+	face := truetype.NewFace(ttf, &truetype.Options{Size: float64(sc.Size), DPI: 72})
+	large := truetype.NewFace(ttf, &truetype.Options{Size: float64(sc.Size * sc.Scale), DPI: 72})
+
+	var (
+		iw, ih = 1024, 1024
+		img    = image.NewRGBA(image.Rect(0, 0, iw, ih))
+		margin = sc.Spread + 1
+		ascent = face.Metrics().Ascent
+		x, y   = 2, 2
+		rowH   = 0
+		gb     = ttf.Bounds(fixed.I(sc.Size))
+	)
+	f := &fontAtlas{glyphs: make(map[rune]Glyph), face: face}
+	for _, ch := range sc.Runes {
+		bb, advance, ok := face.GlyphBounds(ch)
+		if !ok {
+			continue
+		}
+		field := glyphField(large, ch, sc.Scale, margin)
+		if field == nil {
+			continue
+		}
+		w, h := field.Rect.Dx(), field.Rect.Dy()
+		if x+w > iw {
+			x, y, rowH = 2, y+rowH+1, 0
+		}
+		if y+h > ih {
+			break // atlas is full
+		}
+		draw.Draw(img, image.Rect(x, y, x+w, y+h), field, image.Point{}, draw.Src)
+
+		m := fixed.I(margin)
+		f.addGlyphs(ch, Glyph{
+			Rune:    ch,
+			Advance: int(advance.Floor()),
+			X:       float32(x),
+			Y:       float32(y),
+			Width:   float32(w),
+			Height:  float32(h),
+
+			XOffset: fixed2f32(bb.Min.X - m),
+			YOffset: fixed2f32(ascent + bb.Min.Y - m),
+		})
+		x += w + 1
+		if h > rowH {
+			rowH = h
+		}
+	}
+	f.gWidth = fixed2f32(gb.Max.X - gb.Min.X)
+	f.gHeight = fixed2f32(gb.Max.Y - gb.Min.Y)
+
+	if err = f.loadTex(img); err != nil {
+		return nil, err
+	}
+	return &sdfFont{fontAtlas: f, spread: float32(sc.Spread)}, nil
+}
+
+// LoadSDFBitmap loads a pre-baked distance field atlas, the config file has the
+// same format with LoadBitmap. spread is the distance range in pixels used when
+// the atlas was baked, msdf is true for multi-channel distance field.
+func LoadSDFBitmap(img, config io.Reader, spread float32, msdf bool) (Font, error) {
+	fnt, err := LoadBitmap(img, config, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &sdfFont{fontAtlas: fnt.(*fontAtlas), spread: spread, msdf: msdf}, nil
+}
+
+// glyphField rasterizes the glyph with the large face and returns the distance
+// field scaled down, the field has margin pixels around the glyph.
+func glyphField(large interface {
+	GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool)
+	Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool)
+}, ch rune, scale, margin int) *image.RGBA {
+	bb, _, ok := large.GlyphBounds(ch)
+	if !ok {
+		return nil
+	}
+	// size in output pixels
+	w := int(math.Ceil(fixed2f64(bb.Max.X-bb.Min.X)/float64(scale))) + 2*margin
+	h := int(math.Ceil(fixed2f64(bb.Max.Y-bb.Min.Y)/float64(scale))) + 2*margin
+
+	// coverage mask in high resolution
+	mw, mh := w*scale, h*scale
+	mask := image.NewAlpha(image.Rect(0, 0, mw, mh))
+	dot := fixed.Point26_6{X: fixed.I(margin*scale) - bb.Min.X, Y: fixed.I(margin*scale) - bb.Min.Y}
+	if dr, m, mp, _, ok := large.Glyph(dot, ch); ok {
+		draw.DrawMask(mask, dr, image.Opaque, image.Point{}, m, mp, draw.Over)
+	}
+
+	// squared distance to the nearest pixel inside and outside
+	inside := make([]float64, mw*mh)
+	outside := make([]float64, mw*mh)
+	for i, a := range mask.Pix {
+		if a >= 0x80 {
+			outside[i] = 0
+			inside[i] = math.Inf(1)
+		} else {
+			outside[i] = math.Inf(1)
+			inside[i] = 0
+		}
+	}
+	edt(outside, mw, mh)
+	edt(inside, mw, mh)
+
+	spread := float64(margin - 1)
+	field := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// sample at the centre of output pixel
+			mx, my := x*scale+scale/2, y*scale+scale/2
+			i := my*mw + mx
+			d := (math.Sqrt(inside[i]) - math.Sqrt(outside[i])) / float64(scale)
+			v := 0.5 + d/(2*spread)
+			c := uint8(math.Max(0, math.Min(1, v))*0xFF + .5)
+			o := field.PixOffset(x, y)
+			field.Pix[o+0], field.Pix[o+1], field.Pix[o+2], field.Pix[o+3] = c, c, c, c
+		}
+	}
+	return field
+}
+
+// edt computes the squared euclidean distance transform in place, zero
+// elements are the feature points. Felzenszwalb & Huttenlocher's algorithm.
+func edt(grid []float64, w, h int) {
+	n := w
+	if h > n {
+		n = h
+	}
+	f := make([]float64, n)
+	d := make([]float64, n)
+	v := make([]int, n)
+	z := make([]float64, n+1)
+
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			f[y] = grid[y*w+x]
+		}
+		edt1d(f[:h], d[:h], v, z)
+		for y := 0; y < h; y++ {
+			grid[y*w+x] = d[y]
+		}
+	}
+	for y := 0; y < h; y++ {
+		copy(f[:w], grid[y*w:(y+1)*w])
+		edt1d(f[:w], d[:w], v, z)
+		copy(grid[y*w:(y+1)*w], d[:w])
+	}
+}
+
+func edt1d(f, d []float64, v []int, z []float64) {
+	n := len(f)
+	k := 0
+	v[0] = 0
+	z[0], z[1] = math.Inf(-1), math.Inf(1)
+	for q := 1; q < n; q++ {
+		if math.IsInf(f[q], 1) {
+			continue
+		}
+		if math.IsInf(f[v[k]], 1) {
+			// replace an infinite parabola
+			v[k] = q
+			continue
+		}
+		s := ((f[q] + float64(q*q)) - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+		for s <= z[k] {
+			k--
+			s = ((f[q] + float64(q*q)) - (f[v[k]] + float64(v[k]*v[k]))) / float64(2*q-2*v[k])
+		}
+		k++
+		v[k] = q
+		z[k], z[k+1] = s, math.Inf(1)
+	}
+	k = 0
+	for q := 0; q < n; q++ {
+		for z[k+1] < float64(q) {
+			k++
+		}
+		dq := float64(q - v[k])
+		d[q] = dq*dq + f[v[k]]
+	}
+}
+
+func fixed2f64(v fixed.Int26_6) float64 {
+	return float64(v) / (1 << 6)
+}
@@ -0,0 +1,62 @@
+package font
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"sckorok/gfx/bk"
+)
+
+func TestEDT(t *testing.T) {
+	const w, h = 7, 5
+	grid := make([]float64, w*h)
+	for i := range grid {
+		grid[i] = math.Inf(1)
+	}
+	grid[2*w+3] = 0
+	edt(grid, w, h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if d, want := grid[y*w+x], float64((x-3)*(x-3)+(y-2)*(y-2)); d != want {
+				t.Fatalf("distance at (%d, %d) = %v, want %v", x, y, d, want)
+			}
+		}
+	}
+}
+
+func TestSDFGlyph(t *testing.T) {
+	bk.UseSoftRenderer(bk.NewSoftRenderer(4, 4))
+	bk.R.Init()
+	defer bk.UseSoftRenderer(nil)
+
+	fnt, err := LoadSDF(bytes.NewReader(goregular.TTF), SDFConfig{Size: 32, Runes: []rune("Il")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if df, ok := fnt.(DistanceField); !ok || df.Spread() != 4 || df.MSDF() {
+		t.Fatal("should be a distance field font with default spread")
+	}
+	g, ok := fnt.Glyph('I')
+	if !ok {
+		t.Fatal("glyph not found")
+	}
+
+	ttf, _ := truetype.Parse(goregular.TTF)
+	large := truetype.NewFace(ttf, &truetype.Options{Size: 32 * 4, DPI: 72})
+	f := glyphField(large, 'I', 4, 5)
+	w, h := f.Rect.Dx(), f.Rect.Dy()
+	if int(g.Width) != w || int(g.Height) != h {
+		t.Error("glyph size error:", g, w, h)
+	}
+	// the stem of 'I' is inside, the corner of margin is out of spread
+	if c := f.RGBAAt(w/2, h/2); c.A <= 0x80 {
+		t.Error("center of glyph should be inside:", c)
+	}
+	if c := f.RGBAAt(0, 0); c.A != 0 {
+		t.Error("corner should be out of spread:", c)
+	}
+}
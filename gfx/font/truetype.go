@@ -46,7 +46,7 @@ func LoadTrueType(r io.Reader, lc TTFConfig) (*fontAtlas, error) {
 	})
 
 	// new font-atlas
-	f := &fontAtlas{glyphs: make(map[rune]Glyph)}
+	f := &fontAtlas{glyphs: make(map[rune]Glyph), face: face}
 
 	// Iterate over all relevant glyphs in the truetype fontAtlas and
 	// draw them all to the image buffer.
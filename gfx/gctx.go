@@ -2,6 +2,7 @@ package gfx
 
 import (
 	"sckorok/gfx/bk"
+	"sckorok/gfx/font"
 	"sort"
 	"unsafe"
 )
@@ -21,7 +22,9 @@ func Init(pixelRatio float32) {
 
 func Flush() (num int) {
 	num = bk.Flush()
+	captureFrame()
 	Context.Step()
+	font.AdvanceFrame()
 	return
 }
 
@@ -0,0 +1,173 @@
+package gfx
+
+import (
+	"math"
+)
+
+// SortMode decides how the objects in a sorting layer are ordered.
+type SortMode uint8
+
+const (
+	// by z-order, the default mode
+	SortZOrder SortMode = iota
+	// by world Y, lower Y is drawn first
+	SortYAscending
+	// by world Y, higher Y is drawn first, for top-down games with Y up
+	SortYDescending
+	// by the key set with SetSortKey
+	SortCustom
+)
+
+// DefaultLayer is the layer of objects without SetLayer.
+const DefaultLayer uint8 = 0
+
+// SortingLayers is an order table of named layers. Layers are drawn from the
+// bottom to the top, the order can be changed from data with SetOrder. The
+// objects in a layer are sorted by the SortMode of layer, then material and
+// texture.
+type SortingLayers struct {
+	layers []sortingLayer
+	names  map[string]uint8
+}
+
+type sortingLayer struct {
+	name  string
+	order uint8
+	mode  SortMode
+}
+
+// NewSortingLayers creates a table with the "Default" layer.
+func NewSortingLayers() *SortingLayers {
+	t := &SortingLayers{names: make(map[string]uint8)}
+	t.Add("Default", SortZOrder)
+	return t
+}
+
+// Add adds a layer on the top and returns the layer id. If the name is added
+// already, it updates the mode and returns the id. There are 256 layers at most.
+func (t *SortingLayers) Add(name string, mode SortMode) (id uint8) {
+	if id, ok := t.names[name]; ok {
+		t.layers[id].mode = mode
+		return id
+	}
+	if len(t.layers) > math.MaxUint8 {
+		return DefaultLayer
+	}
+	id = uint8(len(t.layers))
+	t.layers = append(t.layers, sortingLayer{name, id, mode})
+	t.names[name] = id
+	return
+}
+
+// Layer returns the id of layer.
+func (t *SortingLayers) Layer(name string) (id uint8, ok bool) {
+	id, ok = t.names[name]
+	return
+}
+
+// Name returns the name of layer.
+func (t *SortingLayers) Name(id uint8) string {
+	if int(id) < len(t.layers) {
+		return t.layers[id].name
+	}
+	return ""
+}
+
+// SetOrder reorders the layers from the bottom to the top, unknown names are
+// added. Layers not in the list keep their relative order below the listed.
+func (t *SortingLayers) SetOrder(names ...string) {
+	listed := make(map[uint8]bool, len(names))
+	for _, name := range names {
+		listed[t.Add(name, t.modeOf(name))] = true
+	}
+	order := uint8(0)
+	for i := range t.layers {
+		if !listed[uint8(i)] {
+			t.layers[i].order = order
+			order++
+		}
+	}
+	for _, name := range names {
+		t.layers[t.names[name]].order = order
+		order++
+	}
+}
+
+// Order returns the position of layer in the table, zero is the bottom.
+func (t *SortingLayers) Order(id uint8) uint8 {
+	if int(id) < len(t.layers) {
+		return t.layers[id].order
+	}
+	return 0
+}
+
+// SetMode sets the SortMode of layer.
+func (t *SortingLayers) SetMode(id uint8, mode SortMode) {
+	if int(id) < len(t.layers) {
+		t.layers[id].mode = mode
+	}
+}
+
+// Mode returns the SortMode of layer.
+func (t *SortingLayers) Mode(id uint8) SortMode {
+	if int(id) < len(t.layers) {
+		return t.layers[id].mode
+	}
+	return SortZOrder
+}
+
+// modeAt returns the SortMode of layer at the order, see UnpackLayer.
+func (t *SortingLayers) modeAt(order uint8) SortMode {
+	for i := range t.layers {
+		if l := &t.layers[i]; l.order == order {
+			return l.mode
+		}
+	}
+	return SortZOrder
+}
+
+func (t *SortingLayers) modeOf(name string) SortMode {
+	if id, ok := t.names[name]; ok {
+		return t.layers[id].mode
+	}
+	return SortZOrder
+}
+
+// Pack packs the SortId of an object in the layer. z is the z-order, y is the
+// world Y and key is the custom key, the SortMode of layer decides which one
+// is used.
+func (t *SortingLayers) Pack(layer uint8, z int16, y float32, key int32, m, b uint16) uint64 {
+	var k uint32
+	switch t.Mode(layer) {
+	case SortZOrder:
+		k = uint32(int32(z) + 0xFFFF>>1)
+	case SortYAscending:
+		k = sortKeyY(y)
+	case SortYDescending:
+		k = sortKeyMax - sortKeyY(y)
+	case SortCustom:
+		k = sortKeyInt(int64(key))
+	}
+	return packSortId(t.Order(layer), k, m, b)
+}
+
+// 24 bits sort key
+const sortKeyMax = 1<<24 - 1
+
+// Y is sorted in 1/4 pixel
+func sortKeyY(y float32) uint32 {
+	return sortKeyInt(int64(math.Floor(float64(y) * 4)))
+}
+
+func sortKeyInt(v int64) uint32 {
+	v += 1 << 23
+	if v < 0 {
+		v = 0
+	} else if v > sortKeyMax {
+		v = sortKeyMax
+	}
+	return uint32(v)
+}
+
+// Layers is the sorting layer table used by all render features.
+var Layers = NewSortingLayers()
@@ -0,0 +1,883 @@
+package gfx
+
+import (
+	"sckorok/engi"
+	"sckorok/gfx/bk"
+	"sckorok/math"
+	"sckorok/math/f32"
+
+	"image"
+	"image/color"
+	"log"
+	"sort"
+)
+
+// LightType is the type of a light.
+type LightType uint8
+
+const (
+	// lights all directions in the radius
+	PointLight LightType = iota
+	// lights a cone in the radius
+	SpotLight
+	// lights everything from the direction, like the sun
+	DirectionalLight
+)
+
+// Each light is a quad drawn into the light map, the occluder edges casting its
+// shadow are a row of a texture, so the lights in a frame and the edges of a
+// light are limited. The dropped ones are logged once and counted in
+// RenderStats.
+const (
+	// max lights in a frame, directional lights and the lights near to the
+	// camera are used first
+	MaxLights = 32
+	// max occluder edges of a light, the extra edges don't cast shadow
+	MaxOccluderEdges = 64
+)
+
+// LightComp is a light at the position of entity. The objects drawn with the
+// lit material (see LightRenderFeature) are lit by the lights in the view,
+// lights don't affect other objects.
+type LightComp struct {
+	engi.Entity
+	typ       LightType
+	color     Color
+	intensity float32
+	radius    float32
+	height    float32
+	falloff   float32
+	direction float32
+
+	// spot light
+	cone, penumbra float32
+
+	// shadow
+	shadow   bool
+	softness float32
+
+	enabled bool
+}
+
+func (lc *LightComp) SetType(typ LightType) {
+	lc.typ = typ
+}
+
+func (lc *LightComp) Type() LightType {
+	return lc.typ
+}
+
+func (lc *LightComp) SetColor(c Color) {
+	lc.color = c
+}
+
+func (lc *LightComp) Color() Color {
+	return lc.color
+}
+
+func (lc *LightComp) SetIntensity(v float32) {
+	lc.intensity = v
+}
+
+func (lc *LightComp) Intensity() float32 {
+	return lc.intensity
+}
+
+// SetRadius sets the range of point and spot light, the light fades to zero
+// at the radius. For directional light it's the length of shadows.
+func (lc *LightComp) SetRadius(r float32) {
+	lc.radius = r
+}
+
+func (lc *LightComp) Radius() float32 {
+	return lc.radius
+}
+
+// SetHeight sets the distance of light above the sprites, a higher light
+// makes the normal maps flatter. The height of directional light is relative
+// to the unit direction, 1 means the light comes in 45 degrees.
+func (lc *LightComp) SetHeight(h float32) {
+	lc.height = h
+}
+
+func (lc *LightComp) Height() float32 {
+	return lc.height
+}
+
+// SetFalloff sets the exponent of attenuation, 1 is linear.
+func (lc *LightComp) SetFalloff(v float32) {
+	lc.falloff = v
+}
+
+func (lc *LightComp) Falloff() float32 {
+	return lc.falloff
+}
+
+// SetDirection sets the direction of spot and directional light in radians.
+func (lc *LightComp) SetDirection(rad float32) {
+	lc.direction = rad
+}
+
+func (lc *LightComp) Direction() float32 {
+	return lc.direction
+}
+
+// SetCone sets the half angle of spot light in radians, penumbra in [0, 1] is
+// the soft part of the cone.
+func (lc *LightComp) SetCone(angle, penumbra float32) {
+	lc.cone, lc.penumbra = angle, math.Clamp(penumbra, 0, 1)
+}
+
+func (lc *LightComp) Cone() (angle, penumbra float32) {
+	return lc.cone, lc.penumbra
+}
+
+// SetShadow enables the shadows of occluders, softness is the size of light
+// source, the shadows are hard if it's zero.
+func (lc *LightComp) SetShadow(enable bool, softness float32) {
+	lc.shadow, lc.softness = enable, softness
+}
+
+func (lc *LightComp) Shadow() (enable bool, softness float32) {
+	return lc.shadow, lc.softness
+}
+
+func (lc *LightComp) SetEnabled(v bool) {
+	lc.enabled = v
+}
+
+func (lc *LightComp) Enabled() bool {
+	return lc.enabled
+}
+
+type LightTable struct {
+	comps      []LightComp
+	_map       map[uint32]int
+	index, cap int
+}
+
+func NewLightTable(cap int) *LightTable {
+	return &LightTable{
+		cap:  cap,
+		_map: make(map[uint32]int),
+	}
+}
+
+func (lt *LightTable) NewComp(entity engi.Entity) (lc *LightComp) {
+	if size := len(lt.comps); lt.index >= size {
+		lt.comps = lightResize(lt.comps, size+STEP)
+	}
+	ei := entity.Index()
+	if v, ok := lt._map[ei]; ok {
+		lc = &lt.comps[v]
+		return
+	}
+	lc = &lt.comps[lt.index]
+	lc.Entity = entity
+	lc.color = White
+	lc.intensity = 1
+	lc.radius = 200
+	lc.height = 50
+	lc.falloff = 1
+	lc.cone, lc.penumbra = math.Pi/4, .2
+	lc.enabled = true
+	lt._map[ei] = lt.index
+	lt.index++
+	return
+}
+
+func (lt *LightTable) Alive(entity engi.Entity) bool {
+	ei := entity.Index()
+	if v, ok := lt._map[ei]; ok {
+		return lt.comps[v].Entity != 0
+	}
+	return false
+}
+
+func (lt *LightTable) Comp(entity engi.Entity) (lc *LightComp) {
+	ei := entity.Index()
+	if v, ok := lt._map[ei]; ok {
+		lc = &lt.comps[v]
+	}
+	return
+}
+
+func (lt *LightTable) Delete(entity engi.Entity) {
+	ei := entity.Index()
+	if v, ok := lt._map[ei]; ok {
+		if tail := lt.index - 1; v != tail && tail > 0 {
+			lt.comps[v] = lt.comps[tail]
+			// remap index
+			tComp := lt.comps[tail]
+			ei := tComp.Entity.Index()
+			lt._map[ei] = v
+			lt.comps[tail] = LightComp{}
+		} else {
+			lt.comps[tail] = LightComp{}
+		}
+
+		lt.index -= 1
+		delete(lt._map, ei)
+	}
+}
+
+func (lt *LightTable) Size() (size, cap int) {
+	return lt.index, lt.cap
+}
+
+func (lt *LightTable) Destroy() {
+	lt.comps = make([]LightComp, 0)
+	lt._map = make(map[uint32]int)
+	lt.index = 0
+}
+
+func lightResize(slice []LightComp, size int) []LightComp {
+	newSlice := make([]LightComp, size)
+	copy(newSlice, slice)
+	return newSlice
+}
+
+// OccluderComp is a polygon that casts shadows, the points are in the local
+// space of entity.
+type OccluderComp struct {
+	engi.Entity
+	points  []f32.Vec2
+	enabled bool
+}
+
+// SetPolygon sets the points of a closed polygon.
+func (oc *OccluderComp) SetPolygon(points ...f32.Vec2) {
+	oc.points = append(oc.points[:0], points...)
+}
+
+// SetBox sets a w*h box centered at the entity.
+func (oc *OccluderComp) SetBox(w, h float32) {
+	x, y := w/2, h/2
+	oc.SetPolygon(f32.Vec2{-x, -y}, f32.Vec2{x, -y}, f32.Vec2{x, y}, f32.Vec2{-x, y})
+}
+
+func (oc *OccluderComp) Points() []f32.Vec2 {
+	return oc.points
+}
+
+func (oc *OccluderComp) SetEnabled(v bool) {
+	oc.enabled = v
+}
+
+func (oc *OccluderComp) Enabled() bool {
+	return oc.enabled
+}
+
+type OccluderTable struct {
+	comps      []OccluderComp
+	_map       map[uint32]int
+	index, cap int
+}
+
+func NewOccluderTable(cap int) *OccluderTable {
+	return &OccluderTable{
+		cap:  cap,
+		_map: make(map[uint32]int),
+	}
+}
+
+func (ot *OccluderTable) NewComp(entity engi.Entity) (oc *OccluderComp) {
+	if size := len(ot.comps); ot.index >= size {
+		ot.comps = occluderResize(ot.comps, size+STEP)
+	}
+	ei := entity.Index()
+	if v, ok := ot._map[ei]; ok {
+		oc = &ot.comps[v]
+		return
+	}
+	oc = &ot.comps[ot.index]
+	oc.Entity = entity
+	oc.enabled = true
+	ot._map[ei] = ot.index
+	ot.index++
+	return
+}
+
+func (ot *OccluderTable) Alive(entity engi.Entity) bool {
+	ei := entity.Index()
+	if v, ok := ot._map[ei]; ok {
+		return ot.comps[v].Entity != 0
+	}
+	return false
+}
+
+func (ot *OccluderTable) Comp(entity engi.Entity) (oc *OccluderComp) {
+	ei := entity.Index()
+	if v, ok := ot._map[ei]; ok {
+		oc = &ot.comps[v]
+	}
+	return
+}
+
+func (ot *OccluderTable) Delete(entity engi.Entity) {
+	ei := entity.Index()
+	if v, ok := ot._map[ei]; ok {
+		if tail := ot.index - 1; v != tail && tail > 0 {
+			ot.comps[v] = ot.comps[tail]
+			// remap index
+			tComp := ot.comps[tail]
+			ei := tComp.Entity.Index()
+			ot._map[ei] = v
+			ot.comps[tail] = OccluderComp{}
+		} else {
+			ot.comps[tail] = OccluderComp{}
+		}
+
+		ot.index -= 1
+		delete(ot._map, ei)
+	}
+}
+
+func (ot *OccluderTable) Size() (size, cap int) {
+	return ot.index, ot.cap
+}
+
+func (ot *OccluderTable) Destroy() {
+	ot.comps = make([]OccluderComp, 0)
+	ot._map = make(map[uint32]int)
+	ot.index = 0
+}
+
+func occluderResize(slice []OccluderComp, size int) []OccluderComp {
+	newSlice := make([]OccluderComp, size)
+	copy(newSlice, slice)
+	return newSlice
+}
+
+// LightRenderFeature is the light pass of RenderSystem. It collects the lights
+// and occluder edges in the view every frame, then before the sorted nodes are
+// drawn:
+//
+//  1. the lit nodes are drawn into the normal buffer with their normal maps,
+//     see NormalDrawer and SpriteComp.SetNormalMap
+//  2. the ambient light and the lights are accumulated into the light map,
+//     a quad per light with the light shader and additive blending
+//
+// Objects using the lit material multiply their color by the light map at the
+// same screen position. Both buffers are render targets of the screen size,
+// the light map keeps half of the light, so the lights brighten up to 2 times.
+// At most MaxLights lights and MaxOccluderEdges edges a light are used in a
+// frame, the others are dropped, see Dropped.
+//
+// Each light takes 4 vec4 in the <light> uniform of the light shader:
+//
+//	[0] position.xy, height, type (3 is the ambient light)
+//	[1] color * intensity, radius
+//	[2] direction.xy, cos(cone), softness
+//	[3] shadow, falloff, width of penumbra in cosine, row of edges
+//
+// The occluder edges of a light are a row of the <edges> texture, an edge
+// (x1, y1, x2, y2) takes two texels of 16 bits fixed point. They are relative
+// to the <occluders> uniform: origin.xy, scale and the number of edges.
+type LightRenderFeature struct {
+	id int
+	rs *RenderSystem
+	R  *BatchRender
+
+	lt *LightTable
+	ot *OccluderTable
+	xt *TransformTable
+
+	// lit material, it composites the light map
+	material uint16
+	// light material and an instance per light, the ambient light is drawn
+	// without blending
+	light, ambientLight uint16
+	instances           []uint16
+	// normal material and the instance clears the normal buffer
+	normal, clear uint16
+
+	// flat normal map, and the light map if there is no lighting
+	flat, unlit uint16
+	// render targets of the screen size
+	normalBuffer, lightMap uint16
+	size                   [2]int
+
+	// occluder edges in world space, and a row of encoded edges per light
+	edges       []f32.Vec4
+	edgeImage   *image.RGBA
+	edgeTexture uint16
+
+	ambient f32.Vec4
+
+	// current frame
+	camera  *Camera
+	view    *View
+	visible []visibleLight
+	lit     RenderNodes
+
+	// lights and edges over the limits in current frame
+	dropped struct {
+		lights, edges int
+		logged        bool
+	}
+}
+
+type visibleLight struct {
+	*LightComp
+	position f32.Vec2
+	distance float32
+
+	// uniforms of the light shader
+	uniforms  [4]f32.Vec4
+	occluders f32.Vec4
+}
+
+// the type of ambient light in the light shader
+const lightTypeAmbient = 3
+
+func (f *LightRenderFeature) SetTable(lt *LightTable, ot *OccluderTable, xt *TransformTable) {
+	f.lt, f.ot, f.xt = lt, ot, xt
+}
+
+// 此处初始化所有的依赖
+func (f *LightRenderFeature) Register(rs *RenderSystem) {
+	// init render
+	for _, r := range rs.RenderList {
+		if br, ok := r.(*BatchRender); ok {
+			f.R = br
+			break
+		}
+	}
+	// init table
+	for _, t := range rs.TableList {
+		switch table := t.(type) {
+		case *LightTable:
+			f.lt = table
+		case *OccluderTable:
+			f.ot = table
+		case *TransformTable:
+			f.xt = table
+		}
+	}
+	// white ambient, as if there is no lighting
+	f.SetAmbient(White, 1)
+	// add new feature, use the index as id
+	f.rs = rs
+	f.id = rs.Accept(f)
+}
+
+// SetShader creates the lit material with the shader, it's named "lit". The
+// <lightMap> sampler is the light map, objects are unlit before the light and
+// normal shaders are set.
+func (f *LightRenderFeature) SetShader(vsh, fsh string) {
+	id, m := M.NewShader("lit", vsh, fsh)
+	if m.program == bk.InvalidId {
+		M.Delete(id)
+		return
+	}
+	f.textures()
+	m.SetTexture("lightMap", f.unlit)
+	f.material = id
+}
+
+// SetLightShader sets the shader accumulates a light into the light map, it
+// samples the normal buffer with <tex> and the occluder edges with <edges>.
+func (f *LightRenderFeature) SetLightShader(vsh, fsh string) {
+	id, m := M.NewShader("", vsh, fsh)
+	if m.program == bk.InvalidId {
+		M.Delete(id)
+		return
+	}
+	f.textures()
+	// the instances copy the uniforms of base
+	m.SetVec4Array("light", make([]f32.Vec4, 4))
+	m.SetVec4("occluders", f32.Vec4{})
+	m.SetTexture("edges", f.edgeTexture)
+	m.SetBlend(bk.ST_BLEND.ADDITIVE)
+	f.light = id
+
+	id, m = M.NewInstance("", f.light)
+	m.SetBlend(bk.ST_BLEND.ISABLE)
+	f.ambientLight = id
+}
+
+// SetNormalShader sets the shader draws the lit nodes into the normal buffer,
+// the <normalMap> sampler is a flat normal map if the sprite has none.
+func (f *LightRenderFeature) SetNormalShader(vsh, fsh string) {
+	id, m := M.NewShader("", vsh, fsh)
+	if m.program == bk.InvalidId {
+		M.Delete(id)
+		return
+	}
+	f.textures()
+	m.SetTexture("normalMap", f.flat)
+	f.normal = id
+
+	id, m = M.NewInstance("", f.normal)
+	m.SetBlend(bk.ST_BLEND.ISABLE)
+	f.clear = id
+}
+
+// textures creates the flat normal map, the unlit light map and the texture
+// of occluder edges once.
+func (f *LightRenderFeature) textures() {
+	if f.flat != bk.InvalidId {
+		return
+	}
+	pixel := func(c color.RGBA) uint16 {
+		img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+		img.Set(0, 0, c)
+		id, _ := bk.R.AllocTexture(img)
+		return id
+	}
+	f.flat = pixel(color.RGBA{0x80, 0x80, 0xFF, 0xFF})
+	f.unlit = pixel(color.RGBA{0x80, 0x80, 0x80, 0xFF})
+
+	if f.edgeImage == nil {
+		f.edgeImage = image.NewRGBA(image.Rect(0, 0, 2*MaxOccluderEdges, MaxLights))
+	}
+	f.edgeTexture, _ = bk.R.AllocTextureOptions(f.edgeImage, bk.TextureOptions{
+		MinFilter: bk.FilterNearest,
+		MagFilter: bk.FilterNearest,
+	})
+}
+
+// Material returns the lit material, zero if the shader is not set.
+func (f *LightRenderFeature) Material() uint16 {
+	return f.material
+}
+
+// SetAmbient sets the ambient light, it lights everything evenly.
+func (f *LightRenderFeature) SetAmbient(c Color, intensity float32) {
+	k := intensity / 0xFF
+	f.ambient = f32.Vec4{float32(c.R) * k, float32(c.G) * k, float32(c.B) * k, 1}
+}
+
+func (f *LightRenderFeature) Extract(v *View) {
+	f.camera, f.view = v.Camera, v
+	f.collect(v.Camera)
+}
+
+// PreDraw draws the normal buffer and the light map, if there are nodes
+// using the lit material in the view.
+func (f *LightRenderFeature) PreDraw() {
+	if f.view == nil || f.R == nil || M.Get(f.material) == nil || M.Get(f.light) == nil || M.Get(f.normal) == nil {
+		return
+	}
+	f.lit = f.lit[:0]
+	for _, node := range f.view.RenderNodes {
+		if UnpackMaterial(node.SortId) == f.material {
+			f.lit = append(f.lit, node)
+		}
+	}
+	f.view = nil
+	if len(f.lit) == 0 || !f.resize(f.camera.Screen()) {
+		return
+	}
+	f.drawNormals()
+	f.drawLights()
+}
+
+func (f *LightRenderFeature) Draw(nodes RenderNodes) {
+
+}
+
+func (f *LightRenderFeature) Flush() {
+
+}
+
+// resize allocates the render targets when the screen size changes, the lit
+// material uses the unlit light map if it fails.
+func (f *LightRenderFeature) resize(w, h float32) bool {
+	size := [2]int{int(w), int(h)}
+	if size == f.size {
+		return f.lightMap != bk.InvalidId
+	}
+	for _, id := range []uint16{f.normalBuffer, f.lightMap} {
+		if id != bk.InvalidId {
+			bk.R.Free(id)
+		}
+	}
+	f.normalBuffer, f.lightMap, f.size = bk.InvalidId, bk.InvalidId, size
+
+	lightMap := f.unlit
+	if size[0] > 0 && size[1] > 0 {
+		f.normalBuffer, _ = bk.R.AllocRenderTarget(size[0], size[1])
+		if f.normalBuffer != bk.InvalidId {
+			f.lightMap, _ = bk.R.AllocRenderTarget(size[0], size[1])
+		}
+		if f.lightMap != bk.InvalidId {
+			lightMap = f.lightMap
+		}
+	}
+	M.Get(f.material).SetTexture("lightMap", lightMap)
+	return f.lightMap != bk.InvalidId
+}
+
+// drawNormals clears the normal buffer to flat normals, then the features
+// draw their lit nodes in the sorted order.
+func (f *LightRenderFeature) drawNormals() {
+	var (
+		render     = f.R
+		l, r, b, t = f.camera.P()
+		nodes, n   = f.lit, len(f.lit)
+	)
+	render.SetRenderTarget(f.normalBuffer)
+	render.BeginMaterial(f.clear, f.flat, 0)
+	render.Draw(lightBatchObject{l, b, r, t})
+	render.End()
+	render.Flush()
+
+	for i, j := 0, 0; i < n; i = j {
+		fi := nodes[i].Value >> 16
+		j = i + 1
+		for j < n && nodes[j].Value>>16 == fi {
+			j++
+		}
+		if nd, ok := f.rs.FeatureList[fi].(NormalDrawer); ok {
+			nd.DrawNormals(nodes[i:j], f.normal, f.normalBuffer)
+		}
+	}
+}
+
+// drawLights uploads the occluder edges, then draws the ambient light over
+// the view and a quad in the range of each light into the light map.
+func (f *LightRenderFeature) drawLights() {
+	var (
+		render     = f.R
+		l, r, b, t = f.camera.P()
+		rows       = 0
+	)
+	for i := range f.visible {
+		if f.visible[i].occluders[3] > 0 {
+			rows = i + 1
+		}
+	}
+	if ok, tex := bk.R.Texture(f.edgeTexture); ok && rows > 0 {
+		sub := f.edgeImage.SubImage(image.Rect(0, 0, 2*MaxOccluderEdges, rows))
+		tex.Update(sub, 0, 0, 2*MaxOccluderEdges, int32(rows))
+	}
+
+	M.Get(f.ambientLight).SetVec4Array("light", []f32.Vec4{{0, 0, 0, lightTypeAmbient}, f.ambient})
+	render.SetRenderTarget(f.lightMap)
+	render.BeginMaterial(f.ambientLight, f.normalBuffer, 0)
+	render.Draw(lightBatchObject{l, b, r, t})
+	render.End()
+
+	for i := range f.visible {
+		vl := &f.visible[i]
+		if i == len(f.instances) {
+			id, _ := M.NewInstance("", f.light)
+			f.instances = append(f.instances, id)
+		}
+		m := M.Get(f.instances[i])
+		m.SetVec4Array("light", vl.uniforms[:])
+		m.SetVec4("occluders", vl.occluders)
+
+		obj := lightBatchObject{l, b, r, t}
+		if vl.typ != DirectionalLight {
+			p, radius := vl.position, vl.radius
+			obj = lightBatchObject{p[0] - radius, p[1] - radius, p[0] + radius, p[1] + radius}
+		}
+		if render.batchUsed == len(render.BatchList) {
+			render.Flush()
+		}
+		render.SetRenderTarget(f.lightMap)
+		render.BeginMaterial(f.instances[i], f.normalBuffer, 0)
+		render.Draw(obj)
+		render.End()
+	}
+	render.Flush()
+}
+
+// collect packs the lights and edges of current frame.
+func (f *LightRenderFeature) collect(camera *Camera) {
+	f.visible = f.visible[:0]
+	f.dropped.lights, f.dropped.edges = 0, 0
+	if f.lt == nil || f.xt == nil {
+		return
+	}
+	cx, cy := camera.Position()
+	for i := range f.lt.comps[:f.lt.index] {
+		lc := &f.lt.comps[i]
+		if !lc.enabled || lc.intensity <= 0 {
+			continue
+		}
+		vl := visibleLight{LightComp: lc, distance: -1}
+		if lc.typ != DirectionalLight {
+			xf := f.xt.Comp(lc.Entity)
+			if xf == nil || !camera.InView(xf, f32.Vec2{2 * lc.radius, 2 * lc.radius}, f32.Vec2{.5, .5}) {
+				continue
+			}
+			p := xf.world.Position
+			dx, dy := p[0]-cx, p[1]-cy
+			vl.position, vl.distance = p, dx*dx+dy*dy
+		}
+		f.visible = append(f.visible, vl)
+	}
+	sort.SliceStable(f.visible, func(i, j int) bool {
+		return f.visible[i].distance < f.visible[j].distance
+	})
+	if len(f.visible) > MaxLights {
+		f.dropped.lights = len(f.visible) - MaxLights
+		f.visible = f.visible[:MaxLights]
+	}
+
+	shadow := false
+	for i := range f.visible {
+		var (
+			vl     = &f.visible[i]
+			l      = &vl.uniforms
+			k      = vl.intensity / 0xFF
+			c      = vl.color
+			outer  = math.Cos(vl.cone)
+			inner  = math.Cos(vl.cone * (1 - vl.penumbra))
+			casted = float32(0)
+		)
+		if vl.shadow {
+			casted, shadow = 1, true
+		}
+		l[0] = f32.Vec4{vl.position[0], vl.position[1], vl.height, float32(vl.typ)}
+		l[1] = f32.Vec4{float32(c.R) * k, float32(c.G) * k, float32(c.B) * k, vl.radius}
+		l[2] = f32.Vec4{math.Cos(vl.direction), math.Sin(vl.direction), outer, vl.softness}
+		l[3] = f32.Vec4{casted, vl.falloff, math.Max(inner-outer, 1e-3), (float32(i) + .5) / MaxLights}
+	}
+	if shadow && f.ot != nil {
+		f.collectEdges(camera)
+	}
+	// log again if the limits are exceeded after a frame under them
+	if d := &f.dropped; d.lights == 0 && d.edges == 0 {
+		d.logged = false
+	} else if !d.logged {
+		d.logged = true
+		log.Printf("light: %d lights and %d occluder edges over the limits (%d, %d) are dropped", d.lights, d.edges, MaxLights, MaxOccluderEdges)
+	}
+}
+
+// Dropped returns the lights and occluder edges dropped in current frame, see
+// MaxLights and MaxOccluderEdges.
+func (f *LightRenderFeature) Dropped() (lights, edges int) {
+	return f.dropped.lights, f.dropped.edges
+}
+
+// collectEdges transforms the edges of occluders to world space, then encodes
+// the edges in the range of each shadow casting light to its row of edges.
+// A directional light uses the edges around the view.
+func (f *LightRenderFeature) collectEdges(camera *Camera) {
+	f.edges = f.edges[:0]
+	m := f32.Mat3{}
+	for i := range f.ot.comps[:f.ot.index] {
+		oc := &f.ot.comps[i]
+		xf := f.xt.Comp(oc.Entity)
+		if !oc.enabled || xf == nil || len(oc.points) < 2 {
+			continue
+		}
+		srt := xf.world
+		m.Initialize(srt.Position[0], srt.Position[1], srt.Rotation, srt.Scale[0], srt.Scale[1], 0, 0, 0, 0)
+		for j := range oc.points {
+			p1, p2 := oc.points[j], oc.points[(j+1)%len(oc.points)]
+			x1, y1 := m.Transform(p1[0], p1[1])
+			x2, y2 := m.Transform(p2[0], p2[1])
+			f.edges = append(f.edges, f32.Vec4{x1, y1, x2, y2})
+		}
+	}
+	if f.edgeImage == nil {
+		f.edgeImage = image.NewRGBA(image.Rect(0, 0, 2*MaxOccluderEdges, MaxLights))
+	}
+
+	l, r, b, t := camera.P()
+	center, half := f32.Vec2{(l + r) / 2, (b + t) / 2}, math.Max(r-l, t-b)/2
+	for i := range f.visible {
+		vl := &f.visible[i]
+		if !vl.shadow {
+			continue
+		}
+		origin, scale := vl.position, vl.radius+vl.softness
+		if vl.typ == DirectionalLight {
+			origin, scale = center, half+scale
+		}
+		if scale <= 0 {
+			continue
+		}
+		var (
+			row = f.edgeImage.Pix[i*f.edgeImage.Stride:]
+			n   = 0
+		)
+		for _, e := range f.edges {
+			if vl.typ != DirectionalLight && segmentDistance2(origin[0], origin[1], e[0], e[1], e[2], e[3]) >= scale*scale {
+				continue
+			}
+			c, ok := clipSegment((e[0]-origin[0])/scale, (e[1]-origin[1])/scale, (e[2]-origin[0])/scale, (e[3]-origin[1])/scale)
+			if !ok {
+				continue
+			}
+			if n == MaxOccluderEdges {
+				f.dropped.edges++
+				continue
+			}
+			for k, v := range c {
+				row[n*8+k*2], row[n*8+k*2+1] = fixed16(v)
+			}
+			n++
+		}
+		vl.occluders = f32.Vec4{origin[0], origin[1], scale, float32(n)}
+	}
+}
+
+// fixed16 encodes v in [-1, 1] to 16 bits, high byte first.
+func fixed16(v float32) (hi, lo uint8) {
+	q := uint16(math.Clamp((v+1)/2, 0, 1)*0xFFFF + .5)
+	return uint8(q >> 8), uint8(q)
+}
+
+// clipSegment clips the segment to the square [-1, 1], ok is false if the
+// segment is outside.
+func clipSegment(x1, y1, x2, y2 float32) (c f32.Vec4, ok bool) {
+	dx, dy := x2-x1, y2-y1
+	t0, t1 := float32(0), float32(1)
+	for _, pq := range [4][2]float32{{-dx, x1 + 1}, {dx, 1 - x1}, {-dy, y1 + 1}, {dy, 1 - y1}} {
+		p, q := pq[0], pq[1]
+		if p == 0 {
+			if q < 0 {
+				return
+			}
+			continue
+		}
+		if r := q / p; p < 0 {
+			if r > t1 {
+				return
+			}
+			t0 = math.Max(t0, r)
+		} else {
+			if r < t0 {
+				return
+			}
+			t1 = math.Min(t1, r)
+		}
+	}
+	return f32.Vec4{x1 + t0*dx, y1 + t0*dy, x1 + t1*dx, y1 + t1*dy}, true
+}
+
+// squared distance from (px, py) to the segment
+func segmentDistance2(px, py, x1, y1, x2, y2 float32) float32 {
+	dx, dy := x2-x1, y2-y1
+	t := float32(0)
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Clamp(((px-x1)*dx+(py-y1)*dy)/l, 0, 1)
+	}
+	ex, ey := x1+t*dx-px, y1+t*dy-py
+	return ex*ex + ey*ey
+}
+
+// lightBatchObject is a rectangle in world space, the light shader finds the
+// pixels of normal buffer by the screen position.
+type lightBatchObject struct {
+	x1, y1, x2, y2 float32
+}
+
+func (lbo lightBatchObject) Size() int {
+	return 4
+}
+
+func (lbo lightBatchObject) Fill(buf []PosTexColorVertex) {
+	buf[0] = PosTexColorVertex{lbo.x1, lbo.y1, 0, 1, 0xFFFFFFFF}
+	buf[1] = PosTexColorVertex{lbo.x2, lbo.y1, 1, 1, 0xFFFFFFFF}
+	buf[2] = PosTexColorVertex{lbo.x2, lbo.y2, 1, 0, 0xFFFFFFFF}
+	buf[3] = PosTexColorVertex{lbo.x1, lbo.y2, 0, 0, 0xFFFFFFFF}
+}
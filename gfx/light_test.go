@@ -0,0 +1,228 @@
+package gfx
+
+import (
+	"testing"
+
+	"sckorok/engi"
+	"sckorok/gfx/bk"
+	"sckorok/math/f32"
+)
+
+func TestLightCollect(t *testing.T) {
+	em := engi.NewEntityManager()
+	xt := NewTransformTable(16)
+	lt := NewLightTable(16)
+	ot := NewOccluderTable(16)
+
+	camera := &Camera{}
+	camera.initialize()
+	camera.SetViewPort(100, 100)
+	camera.MoveTo(50, 50)
+
+	light := func(x, y float32, typ LightType) *LightComp {
+		e := em.New()
+		xt.NewComp(e).SetPosition(f32.Vec2{x, y})
+		lc := lt.NewComp(e)
+		lc.SetType(typ)
+		lc.SetRadius(40)
+		return lc
+	}
+	far := light(80, 80, PointLight)
+	near := light(50, 50, PointLight)
+	near.SetShadow(true, 2)
+	light(500, 500, PointLight) // out of view
+	sun := light(0, 0, DirectionalLight)
+	sun.SetColor(Color{0xFF, 0, 0, 0xFF})
+	sun.SetIntensity(.5)
+
+	// a 10x10 box in the range of near light, and one out of range
+	for _, p := range []f32.Vec2{{60, 50}, {-200, 0}} {
+		e := em.New()
+		xt.NewComp(e).SetPosition(p)
+		ot.NewComp(e).SetBox(10, 10)
+	}
+
+	f := &LightRenderFeature{}
+	f.SetTable(lt, ot, xt)
+	f.collect(camera)
+
+	if len(f.visible) != 3 {
+		t.Fatal("count of lights:", len(f.visible))
+	}
+	if f.visible[0].LightComp != sun || f.visible[1].LightComp != near || f.visible[2].LightComp != far {
+		t.Error("directional and near lights should be first")
+	}
+	if l := f.visible[0].uniforms; l[0][3] != float32(DirectionalLight) || l[1] != (f32.Vec4{.5, 0, 0, 40}) {
+		t.Error("directional light packed error:", l)
+	}
+	if l := f.visible[1].uniforms; l[0] != (f32.Vec4{50, 50, 50, 0}) || l[2][3] != 2 || l[3][0] != 1 || l[3][3] != 1.5/MaxLights {
+		t.Error("point light packed error:", l)
+	}
+	if o := f.visible[1].occluders; o != (f32.Vec4{50, 50, 42, 4}) {
+		t.Fatal("edges should be relative to the range of light:", o)
+	}
+	// the first edge (55, 45)-(65, 45) in the row of near light
+	row := f.edgeImage.Pix[f.edgeImage.Stride:]
+	want := [4]float32{5, -5, 15, -5}
+	for i, v := range want {
+		if d := decodeFixed16(row[i*2:]) - v/42; d < -1e-4 || d > 1e-4 {
+			t.Errorf("edge encoded error at %d: %f", i, d)
+		}
+	}
+
+	// no shadow, no edge
+	near.SetShadow(false, 0)
+	f.collect(camera)
+	if o := f.visible[1].occluders; o[3] != 0 {
+		t.Error("edges without shadow casting light:", o)
+	}
+
+	// over the limits: 3 + MaxLights lights, 17 boxes of 4 edges
+	near.SetShadow(true, 2)
+	for i := 0; i < MaxLights; i++ {
+		light(20, 20, PointLight)
+	}
+	for i := 0; i < 16; i++ {
+		e := em.New()
+		xt.NewComp(e).SetPosition(f32.Vec2{40, 50})
+		ot.NewComp(e).SetBox(10, 10)
+	}
+	f.collect(camera)
+	if lights, edges := f.Dropped(); lights != 3 || edges != 68-MaxOccluderEdges {
+		t.Error("lights and edges over the limits should be counted:", lights, edges)
+	}
+	if len(f.visible) != MaxLights || f.visible[1].occluders[3] != MaxOccluderEdges {
+		t.Error("count of lights and edges:", len(f.visible), f.visible[1].occluders)
+	}
+
+	// logged once until the counts are back to zero
+	if !f.dropped.logged {
+		t.Error("dropped lights should be logged")
+	}
+	for i := range lt.comps[:lt.index] {
+		lt.comps[i].SetEnabled(false)
+	}
+	f.collect(camera)
+	if f.dropped.logged {
+		t.Error("log should be reset without dropped lights")
+	}
+}
+
+// decodes the 16 bits fixed point written by fixed16
+func decodeFixed16(b []uint8) float32 {
+	return float32(uint16(b[0])<<8|uint16(b[1]))/0xFFFF*2 - 1
+}
+
+// the uniforms of lit, light and normal shaders, the software renderer only
+// needs the names
+const testLightFsh = `
+#version 330
+
+uniform sampler2D tex;
+uniform sampler2D lightMap;
+uniform sampler2D normalMap;
+uniform sampler2D edges;
+uniform vec4 light[4];
+uniform vec4 occluders;
+
+in vec2 outTexCoord;
+in vec4 outColor;
+
+out vec4 outputColor;
+
+void main() {
+	outputColor = texture(tex, outTexCoord) * outColor;
+}
+` + "\x00"
+
+// normalDrawer records the lit nodes to draw
+type normalDrawer struct {
+	nodes            RenderNodes
+	material, target uint16
+}
+
+func (d *normalDrawer) Extract(v *View)        {}
+func (d *normalDrawer) Draw(nodes RenderNodes) {}
+func (d *normalDrawer) Flush()                 {}
+
+func (d *normalDrawer) DrawNormals(nodes RenderNodes, material, target uint16) {
+	d.nodes = append(d.nodes, nodes...)
+	d.material, d.target = material, target
+}
+
+func TestLightPass(t *testing.T) {
+	bk.UseSoftRenderer(bk.NewSoftRenderer(16, 16))
+	defer bk.UseSoftRenderer(nil)
+	bk.R.Init()
+
+	em := engi.NewEntityManager()
+	xt := NewTransformTable(16)
+	lt := NewLightTable(16)
+	ot := NewOccluderTable(16)
+	e := em.New()
+	xt.NewComp(e).SetPosition(f32.Vec2{8, 8})
+	lt.NewComp(e).SetShadow(true, 1)
+	e = em.New()
+	xt.NewComp(e).SetPosition(f32.Vec2{12, 8})
+	ot.NewComp(e).SetBox(2, 2)
+
+	camera := &Camera{}
+	camera.initialize()
+	camera.SetViewPort(16, 16)
+	camera.MoveTo(8, 8)
+
+	rs := &RenderSystem{}
+	rs.RegisterRender(0, NewBatchRender(testSdfVsh, testLightFsh))
+	rs.TableList = []interface{}{lt, ot, xt}
+	nd := &normalDrawer{}
+	rs.Accept(nd)
+	f := &LightRenderFeature{}
+	f.Register(rs)
+	f.SetShader(testSdfVsh, testLightFsh)
+	f.SetLightShader(testSdfVsh, testLightFsh)
+	f.SetNormalShader(testSdfVsh, testLightFsh)
+
+	lit := SortObject{SortId: uint64(f.material) << 16}
+	v := &View{Camera: camera, RenderNodes: RenderNodes{lit, {}}}
+	f.Extract(v)
+	calls, _ := bk.Submitted()
+	f.PreDraw()
+
+	// normal buffer, ambient light and the point light
+	if n, _ := bk.Submitted(); n-calls != 3 {
+		t.Error("draw calls of light pass:", n-calls)
+	}
+	if len(nd.nodes) != 1 || nd.nodes[0] != lit || nd.material != f.normal || nd.target != f.normalBuffer {
+		t.Error("lit nodes should be drawn into the normal buffer:", nd)
+	}
+	ok, tex := bk.R.Texture(f.lightMap)
+	if !ok || tex.Width != 16 || tex.Height != 16 {
+		t.Fatal("light map should be allocated in screen size")
+	}
+	if m := M.Get(f.material); m.texture.id != f.lightMap {
+		t.Error("lit material should sample the light map:", m.texture.id)
+	}
+	if f.visible[0].occluders[3] != 4 {
+		t.Error("edges of the light:", f.visible[0].occluders)
+	}
+	bk.Flush()
+
+	// the targets are kept until the screen size changes
+	lightMap := f.lightMap
+	f.Extract(v)
+	f.PreDraw()
+	if f.lightMap != lightMap {
+		t.Error("light map should be reused")
+	}
+	bk.Flush()
+
+	// no lit node, no light pass
+	v.RenderNodes = v.RenderNodes[1:]
+	f.Extract(v)
+	calls, _ = bk.Submitted()
+	f.PreDraw()
+	if n, _ := bk.Submitted(); n != calls {
+		t.Error("light pass without lit nodes:", n-calls)
+	}
+	bk.Flush()
+}
@@ -0,0 +1,495 @@
+package gfx
+
+import (
+	"sckorok/engi"
+	"sckorok/gfx/bk"
+	"sckorok/math"
+	"sckorok/math/f32"
+
+	"math/bits"
+	"sort"
+)
+
+// MaskShape is the shape of a stencil mask.
+type MaskShape uint8
+
+const (
+	// a w*h rectangle
+	MaskRect MaskShape = iota
+	// an ellipse in the w*h rectangle
+	MaskEllipse
+	// the opaque pixels of a sprite, see MaskRenderFeature.SetAlphaShader
+	MaskSprite
+)
+
+// segments of the ellipse
+const maskSegments = 32
+
+// MaskComp marks the entity as a stencil mask, all the Transform children of
+// the entity (not the entity itself) are clipped by it. Masks can be nested,
+// a child mask is clipped by its parent mask too.
+//
+// Each mask takes some bits of the 8-bit stencil value: a level of nesting
+// needs log2(n+1) bits, n is the max number of sibling masks in the level.
+// The masks that don't fit in the 8 bits are ignored. Sibling masks shouldn't
+// overlap, the later one replaces the earlier one where they overlap.
+type MaskComp struct {
+	engi.Entity
+	shape   MaskShape
+	sprite  Sprite
+	width   float32
+	height  float32
+	gravity struct {
+		x, y float32
+	}
+	enabled bool
+}
+
+// SetRect uses a w*h rectangle as mask.
+func (mc *MaskComp) SetRect(w, h float32) {
+	mc.shape, mc.sprite = MaskRect, nil
+	mc.width, mc.height = w, h
+}
+
+// SetEllipse uses an ellipse in the w*h rectangle as mask.
+func (mc *MaskComp) SetEllipse(w, h float32) {
+	mc.shape, mc.sprite = MaskEllipse, nil
+	mc.width, mc.height = w, h
+}
+
+// SetSprite uses the alpha of sprite as mask, the size of sprite is used if
+// no size is set.
+func (mc *MaskComp) SetSprite(spt Sprite) {
+	mc.shape, mc.sprite = MaskSprite, spt
+	if mc.width == 0 || mc.height == 0 {
+		size := spt.Size()
+		mc.width, mc.height = size.Width, size.Height
+	}
+}
+
+func (mc *MaskComp) Shape() MaskShape {
+	return mc.shape
+}
+
+func (mc *MaskComp) Sprite() Sprite {
+	return mc.sprite
+}
+
+func (mc *MaskComp) SetSize(w, h float32) {
+	mc.width, mc.height = w, h
+}
+
+func (mc *MaskComp) Size() (w, h float32) {
+	return mc.width, mc.height
+}
+
+func (mc *MaskComp) SetGravity(x, y float32) {
+	mc.gravity.x, mc.gravity.y = x, y
+}
+
+func (mc *MaskComp) Gravity() (x, y float32) {
+	return mc.gravity.x, mc.gravity.y
+}
+
+// SetEnabled enables or disables the mask, the children of a disabled mask
+// are not clipped.
+func (mc *MaskComp) SetEnabled(v bool) {
+	mc.enabled = v
+}
+
+func (mc *MaskComp) Enabled() bool {
+	return mc.enabled
+}
+
+type MaskTable struct {
+	comps      []MaskComp
+	_map       map[uint32]int
+	index, cap int
+
+	// resolved every frame by MaskRenderFeature
+	xt       *TransformTable
+	nodes    []maskNode
+	order    []int
+	stencils map[uint32]uint32
+}
+
+// maskNode is the resolved mask, id is the stencil value and read is the
+// bits of id used by the mask and its parents.
+type maskNode struct {
+	parent, depth int
+	slot          int
+	id, read      uint8
+	write         uint32
+}
+
+func NewMaskTable(cap int) *MaskTable {
+	return &MaskTable{
+		cap:      cap,
+		_map:     make(map[uint32]int),
+		stencils: make(map[uint32]uint32),
+	}
+}
+
+func (mt *MaskTable) NewComp(entity engi.Entity) (mc *MaskComp) {
+	if size := len(mt.comps); mt.index >= size {
+		mt.comps = maskResize(mt.comps, size+STEP)
+	}
+	ei := entity.Index()
+	if v, ok := mt._map[ei]; ok {
+		mc = &mt.comps[v]
+		return
+	}
+	mc = &mt.comps[mt.index]
+	mc.Entity = entity
+	mc.gravity.x, mc.gravity.y = .5, .5
+	mc.enabled = true
+	mt._map[ei] = mt.index
+	mt.index++
+	return
+}
+
+func (mt *MaskTable) Alive(entity engi.Entity) bool {
+	ei := entity.Index()
+	if v, ok := mt._map[ei]; ok {
+		return mt.comps[v].Entity != 0
+	}
+	return false
+}
+
+func (mt *MaskTable) Comp(entity engi.Entity) (mc *MaskComp) {
+	ei := entity.Index()
+	if v, ok := mt._map[ei]; ok {
+		mc = &mt.comps[v]
+	}
+	return
+}
+
+func (mt *MaskTable) Delete(entity engi.Entity) {
+	ei := entity.Index()
+	if v, ok := mt._map[ei]; ok {
+		if tail := mt.index - 1; v != tail && tail > 0 {
+			mt.comps[v] = mt.comps[tail]
+			// remap index
+			tComp := mt.comps[tail]
+			ei := tComp.Entity.Index()
+			mt._map[ei] = v
+			mt.comps[tail] = MaskComp{}
+		} else {
+			mt.comps[tail] = MaskComp{}
+		}
+
+		mt.index -= 1
+		delete(mt._map, ei)
+	}
+}
+
+func (mt *MaskTable) Size() (size, cap int) {
+	return mt.index, mt.cap
+}
+
+func (mt *MaskTable) Destroy() {
+	mt.comps = make([]MaskComp, 0)
+	mt._map = make(map[uint32]int)
+	mt.index = 0
+	mt.order = mt.order[:0]
+	mt.stencils = make(map[uint32]uint32)
+}
+
+// Stencil returns the stencil state to draw the entity, it's clipped by the
+// nearest mask in its parents. Zero means the entity is not clipped.
+func (mt *MaskTable) Stencil(entity engi.Entity) uint32 {
+	if mt == nil || len(mt.stencils) == 0 {
+		return 0
+	}
+	xf := mt.xt.Comp(entity)
+	if xf == nil {
+		return 0
+	}
+	for p := xf.Parent(); p != nil; p = p.Parent() {
+		if s, ok := mt.stencils[p.Entity.Index()]; ok {
+			return s
+		}
+	}
+	return 0
+}
+
+// resolve finds the parent of masks and allocates the stencil values, the
+// masks are drawn in order, parents before children.
+func (mt *MaskTable) resolve(xt *TransformTable) {
+	mt.xt = xt
+	mt.order = mt.order[:0]
+	for k := range mt.stencils {
+		delete(mt.stencils, k)
+	}
+	n := mt.index
+	if n == 0 || xt == nil {
+		return
+	}
+	if cap(mt.nodes) < n {
+		mt.nodes = make([]maskNode, n)
+	}
+	nodes := mt.nodes[:n]
+	for i := range nodes {
+		nodes[i] = maskNode{parent: -1, depth: -1}
+	}
+
+	// parents
+	for i := range nodes {
+		mc := &mt.comps[i]
+		if !mc.enabled {
+			continue
+		}
+		if xf := xt.Comp(mc.Entity); xf != nil {
+			nodes[i].parent = mt.parentOf(xf)
+			mt.order = append(mt.order, i)
+		}
+	}
+
+	// depth and the slot in siblings, slot 0 means no mask
+	var (
+		counts = make(map[int]int)
+		levels []int
+	)
+	var depthOf func(i int) int
+	depthOf = func(i int) int {
+		if node := &nodes[i]; node.depth < 0 {
+			if node.parent < 0 {
+				node.depth = 0
+			} else {
+				node.depth = depthOf(node.parent) + 1
+			}
+		}
+		return nodes[i].depth
+	}
+	for _, i := range mt.order {
+		d := depthOf(i)
+		counts[nodes[i].parent]++
+		nodes[i].slot = counts[nodes[i].parent]
+		for len(levels) <= d {
+			levels = append(levels, 0)
+		}
+		if nodes[i].slot > levels[d] {
+			levels[d] = nodes[i].slot
+		}
+	}
+	sort.SliceStable(mt.order, func(a, b int) bool {
+		return nodes[mt.order[a]].depth < nodes[mt.order[b]].depth
+	})
+
+	// bits of each level
+	shifts := make([]int, len(levels)+1)
+	for d, max := range levels {
+		shifts[d+1] = shifts[d] + bits.Len(uint(max))
+	}
+	order := mt.order[:0]
+	for _, i := range mt.order {
+		node := &nodes[i]
+		if shifts[node.depth+1] > 8 {
+			continue
+		}
+		var parent maskNode
+		if node.parent >= 0 {
+			parent = nodes[node.parent]
+		}
+		node.id = parent.id | uint8(node.slot<<shifts[node.depth])
+		node.read = uint8(1<<shifts[node.depth+1] - 1)
+		if node.parent < 0 {
+			node.write = bk.Stencil(bk.ST_STENCIL.TEST_ALWAYS, node.id, 0xFF,
+				bk.ST_STENCIL.OP_KEEP, bk.ST_STENCIL.OP_KEEP, bk.ST_STENCIL.OP_REPLACE)
+		} else {
+			node.write = bk.Stencil(bk.ST_STENCIL.TEST_EQUAL, node.id, parent.read,
+				bk.ST_STENCIL.OP_KEEP, bk.ST_STENCIL.OP_KEEP, bk.ST_STENCIL.OP_REPLACE)
+		}
+		mt.stencils[mt.comps[i].Entity.Index()] = bk.Stencil(bk.ST_STENCIL.TEST_EQUAL, node.id, node.read,
+			bk.ST_STENCIL.OP_KEEP, bk.ST_STENCIL.OP_KEEP, bk.ST_STENCIL.OP_KEEP)
+		order = append(order, i)
+	}
+	mt.order = order
+}
+
+// parentOf returns the index of the nearest enabled mask in the parents.
+func (mt *MaskTable) parentOf(xf *Transform) int {
+	for p := xf.Parent(); p != nil; p = p.Parent() {
+		if v, ok := mt._map[p.Entity.Index()]; ok && mt.comps[v].enabled {
+			return v
+		}
+	}
+	return -1
+}
+
+func maskResize(slice []MaskComp, size int) []MaskComp {
+	newSlice := make([]MaskComp, size)
+	copy(newSlice, slice)
+	return newSlice
+}
+
+// MaskRenderFeature writes the masks into the stencil-buffer before the other
+// objects are drawn, masks don't write color. The other features clip objects
+// with MaskTable.Stencil, objects with the same stencil state are batched.
+type MaskRenderFeature struct {
+	id int
+
+	R  *BatchRender
+	mt *MaskTable
+	xt *TransformTable
+
+	// discards the transparent pixels of sprite masks
+	material uint16
+}
+
+func (f *MaskRenderFeature) SetRender(render *BatchRender) {
+	f.R = render
+}
+
+func (f *MaskRenderFeature) SetTable(mt *MaskTable, xt *TransformTable) {
+	f.mt, f.xt = mt, xt
+}
+
+// 此处初始化所有的依赖
+func (f *MaskRenderFeature) Register(rs *RenderSystem) {
+	// init render
+	for _, r := range rs.RenderList {
+		if br, ok := r.(*BatchRender); ok {
+			f.R = br
+			break
+		}
+	}
+	// init table
+	for _, t := range rs.TableList {
+		switch table := t.(type) {
+		case *MaskTable:
+			f.mt = table
+		case *TransformTable:
+			f.xt = table
+		}
+	}
+	// add new feature, use the index as id
+	f.id = rs.Accept(f)
+}
+
+// SetAlphaShader sets the shader of sprite masks, it should discard the
+// fragments whose texture alpha is less than the <cutoff> uniform. Without
+// it, a sprite mask covers the whole rectangle of sprite.
+func (f *MaskRenderFeature) SetAlphaShader(vsh, fsh string) {
+	id, m := M.NewShader("", vsh, fsh)
+	if m.program == bk.InvalidId {
+		M.Delete(id)
+		return
+	}
+	m.SetFloat("cutoff", .5)
+	f.material = id
+}
+
+// SetAlphaCutoff sets the alpha below which the pixels of sprite masks are
+// discarded, the default value is 0.5.
+func (f *MaskRenderFeature) SetAlphaCutoff(v float32) {
+	if m := M.Get(f.material); m != nil {
+		m.SetFloat("cutoff", v)
+	}
+}
+
+func (f *MaskRenderFeature) Extract(v *View) {
+	if f.mt != nil {
+		f.mt.resolve(f.xt)
+	}
+}
+
+func (f *MaskRenderFeature) Draw(nodes RenderNodes) {
+
+}
+
+// PreDraw draws the masks, parents before children.
+func (f *MaskRenderFeature) PreDraw() {
+	if f.mt == nil || len(f.mt.order) == 0 {
+		return
+	}
+	var (
+		mt     = f.mt
+		render = f.R
+		obj    = maskBatchObject{}
+	)
+	for _, i := range mt.order {
+		mc := &mt.comps[i]
+		mat, tex := uint16(0), bk.InvalidId
+		if mc.shape == MaskSprite && mc.sprite != nil {
+			mat, tex = f.material, mc.sprite.Tex()
+		}
+		if render.batchUsed == len(render.BatchList) {
+			render.Flush()
+		}
+		obj.MaskComp, obj.Transform = mc, f.xt.Comp(mc.Entity)
+		render.SetStencil(mt.nodes[i].write)
+		render.BeginMaterial(mat, tex, 0)
+		render.Draw(obj)
+		render.End()
+	}
+	render.Flush()
+}
+
+func (f *MaskRenderFeature) Flush() {
+
+}
+
+type maskBatchObject struct {
+	*MaskComp
+	*Transform
+}
+
+func (mbo maskBatchObject) Size() int {
+	if mbo.shape == MaskEllipse {
+		return 4 * maskSegments
+	}
+	return 4
+}
+
+// Fill writes transparent vertices, so the mask doesn't change the color
+// with the premultiplied blending.
+func (mbo maskBatchObject) Fill(buf []PosTexColorVertex) {
+	var (
+		srt  = mbo.Transform.world
+		c    = mbo.MaskComp
+		w, h = c.width, c.height
+	)
+	m := f32.Mat3{}
+	m.Initialize(srt.Position[0], srt.Position[1], srt.Rotation, srt.Scale[0], srt.Scale[1], w*c.gravity.x, h*c.gravity.y, 0, 0)
+
+	for i := range buf {
+		buf[i] = PosTexColorVertex{}
+	}
+	if c.shape == MaskEllipse {
+		// a fan of degenerated quads: center, p1, p2, p2
+		cx, cy := w/2, h/2
+		x1, y1 := m.Transform(w, cy)
+		ox, oy := m.Transform(cx, cy)
+		for i := 0; i < maskSegments; i++ {
+			a := float32(i+1) * 2 * math.Pi / maskSegments
+			x2, y2 := m.Transform(cx+cx*math.Cos(a), cy+cy*math.Sin(a))
+			q := buf[i*4 : i*4+4]
+			q[0].X, q[0].Y = ox, oy
+			q[1].X, q[1].Y = x1, y1
+			q[2].X, q[2].Y = x2, y2
+			q[3].X, q[3].Y = x2, y2
+			x1, y1 = x2, y2
+		}
+		return
+	}
+
+	if c.shape == MaskSprite && c.sprite != nil {
+		rg := c.sprite.Region()
+		if rg.Rotated {
+			buf[1].U, buf[1].V = rg.X1, rg.Y2
+			buf[2].U, buf[2].V = rg.X2, rg.Y2
+			buf[3].U, buf[3].V = rg.X2, rg.Y1
+			buf[0].U, buf[0].V = rg.X1, rg.Y1
+		} else {
+			buf[0].U, buf[0].V = rg.X1, rg.Y2
+			buf[1].U, buf[1].V = rg.X2, rg.Y2
+			buf[2].U, buf[2].V = rg.X2, rg.Y1
+			buf[3].U, buf[3].V = rg.X1, rg.Y1
+		}
+	}
+	buf[0].X, buf[0].Y = m.Transform(0, 0)
+	buf[1].X, buf[1].Y = m.Transform(w, 0)
+	buf[2].X, buf[2].Y = m.Transform(w, h)
+	buf[3].X, buf[3].Y = m.Transform(0, h)
+}
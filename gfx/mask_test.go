@@ -0,0 +1,62 @@
+package gfx
+
+import (
+	"testing"
+
+	"sckorok/engi"
+	"sckorok/gfx/bk"
+)
+
+func TestMaskStencil(t *testing.T) {
+	em := engi.NewEntityManager()
+	xt := NewTransformTable(16)
+	mt := NewMaskTable(16)
+
+	// a, b are root masks, c is a mask in a
+	a, b, c, item, free := em.New(), em.New(), em.New(), em.New(), em.New()
+	xa, xc, xi := xt.NewComp(a), xt.NewComp(c), xt.NewComp(item)
+	xt.NewComp(b)
+	xt.NewComp(free)
+	xa.LinkChildren(xc)
+	xc.LinkChildren(xi)
+
+	mt.NewComp(c).SetEllipse(10, 10)
+	mt.NewComp(a).SetRect(100, 100)
+	mt.NewComp(b).SetRect(100, 100)
+	mt.resolve(xt)
+
+	if len(mt.order) != 3 || mt.comps[mt.order[2]].Entity != c {
+		t.Fatal("parent masks should be drawn first:", mt.order)
+	}
+	nc := mt.nodes[0]
+	if nc.id != 1|1<<2 || nc.read != 7 {
+		t.Error("nested mask id error:", nc.id, nc.read)
+	}
+	_, ref, mask, _, _, _ := bkStencilDecode(mt.Stencil(item))
+	if ref != nc.id || mask != 7 {
+		t.Error("item should be clipped by the nearest mask:", ref, mask)
+	}
+
+	// content of a passes in the area of c
+	_, ref, mask, _, _, _ = bkStencilDecode(mt.Stencil(c))
+	if ref&mask != nc.id&mask {
+		t.Error("parent's content should be drawn in the child mask:", ref, mask)
+	}
+	if s := mt.Stencil(free); s != 0 {
+		t.Error("entity without mask should not be clipped:", s)
+	}
+
+	// disabled mask
+	mt.Comp(c).SetEnabled(false)
+	mt.resolve(xt)
+	if _, ref, _, _, _, _ = bkStencilDecode(mt.Stencil(item)); ref != 1 {
+		t.Error("item should be clipped by a if c is disabled:", ref)
+	}
+}
+
+func bkStencilDecode(s uint32) (test uint32, ref, mask uint8, fail, zfail, pass uint32) {
+	ref = uint8(s & bk.ST_STENCIL.REF_MASK)
+	mask = uint8((s & bk.ST_STENCIL.READ_MASK) >> bk.ST_STENCIL.READ_SHIFT)
+	test = (s & bk.ST_STENCIL.TEST_MASK) >> bk.ST_STENCIL.TEST_SHIFT
+	return
+}
@@ -0,0 +1,307 @@
+package gfx
+
+import (
+	"sckorok/gfx/bk"
+	"sckorok/math/f32"
+
+	"log"
+	"unsafe"
+)
+
+// Material describes how a render object should be drawn: shader program,
+// uniform values, extra texture slots and blend state.
+//
+// A Material without shader uses the default program of the render
+// (the "batch" shader for BatchRender and the "mesh" shader for MeshRender),
+// so it's cheap to create a Material just to change the blend mode.
+//
+// Custom shader must follow the same attribute and uniform names with the
+// default shader: <xyuv, rgba> as attribute, <proj, tex> as uniform, mesh
+// shader should also provide a <model> uniform.
+type Material struct {
+	// shader program, InvalidId means the default program
+	program uint16
+
+	// blend state, zero means the default state
+	state uint64
+
+	// uniform handle
+	umhProjection uint16
+	umhModel      uint16
+	umhSampler0   uint16
+
+	// extra texture slot, stage 0 is used by the sprite itself
+	texture struct {
+		sampler uint16
+		id      uint16
+	}
+
+	// custom uniform
+	uniforms []materialUniform
+
+	// the program is owned by the base Material, see NewInstance
+	shared bool
+}
+
+type materialUniform struct {
+	id   uint16
+	name string
+	data [16]float32
+
+	// values of array uniform, nil if not an array
+	array []float32
+}
+
+// SetShader compiles the shader and uses it to draw the Material.
+func (m *Material) SetShader(vsh, fsh string) {
+	id, sh := bk.R.AllocShader(vsh, fsh)
+	if id == bk.InvalidId {
+		return
+	}
+	m.program = id
+	sh.Use()
+
+	// setup attribute
+	sh.AddAttributeBinding("xyuv\x00", 0, P4C4[0])
+	sh.AddAttributeBinding("rgba\x00", 0, P4C4[1])
+
+	s0 := int32(0)
+	// setup uniform
+	if uid, _ := bk.R.AllocUniform(id, "proj\x00", bk.UniformMat4, 1); uid != bk.InvalidId {
+		m.umhProjection = uid
+	}
+	if sh.GetUniformLocation("model\x00") != invalidLocation {
+		if uid, _ := bk.R.AllocUniform(id, "model\x00", bk.UniformMat4, 1); uid != bk.InvalidId {
+			m.umhModel = uid
+		}
+	}
+	if uid, _ := bk.R.AllocUniform(id, "tex\x00", bk.UniformSampler, 1); uid != bk.InvalidId {
+		m.umhSampler0 = uid
+		bk.SetUniform(uid, unsafe.Pointer(&s0))
+	}
+	bk.Submit(0, id, 0)
+}
+
+// Program returns the shader program, InvalidId if the Material uses the default program.
+func (m *Material) Program() uint16 {
+	return m.program
+}
+
+// SetBlend sets the blend state, the value is defined by bk.ST_BLEND.
+func (m *Material) SetBlend(blend uint64) {
+	m.state = blend
+}
+
+// Blend returns the blend state.
+func (m *Material) Blend() uint64 {
+	return m.state
+}
+
+// SetTexture binds a texture to the sampler at stage 1.
+func (m *Material) SetTexture(sampler string, tex uint16) {
+	if m.program == bk.InvalidId {
+		log.Printf("material: texture %s needs a custom shader", sampler)
+		return
+	}
+	if m.texture.sampler == bk.InvalidId {
+		if id, _ := bk.R.AllocUniform(m.program, sampler+"\x00", bk.UniformSampler, 1); id != bk.InvalidId {
+			s1 := int32(1)
+			m.texture.sampler = id
+			bk.SetUniform(id, unsafe.Pointer(&s1))
+			bk.Submit(0, m.program, 0)
+		}
+	}
+	m.texture.id = tex
+}
+
+// SetFloat sets a float uniform.
+func (m *Material) SetFloat(name string, v float32) {
+	if um := m.uniform(name, bk.UniformVec1); um != nil {
+		um.data[0] = v
+	}
+}
+
+// SetVec4 sets a vec4 uniform.
+func (m *Material) SetVec4(name string, v f32.Vec4) {
+	if um := m.uniform(name, bk.UniformVec4); um != nil {
+		copy(um.data[:], v[:])
+	}
+}
+
+// SetMat4 sets a mat4 uniform.
+func (m *Material) SetMat4(name string, v *f32.Mat4) {
+	if um := m.uniform(name, bk.UniformMat4); um != nil {
+		copy(um.data[:], v[:])
+	}
+}
+
+// SetVec4Array sets a vec4 array uniform, the length of array is fixed by
+// the first call.
+func (m *Material) SetVec4Array(name string, v []f32.Vec4) {
+	um := m.uniformN(name, bk.UniformVec4, len(v))
+	if um == nil {
+		return
+	}
+	for i := 0; i < len(v) && 4*i < len(um.array); i++ {
+		copy(um.array[4*i:], v[i][:])
+	}
+}
+
+// SetInt sets a int uniform.
+func (m *Material) SetInt(name string, v int32) {
+	if um := m.uniform(name, bk.UniformInt1); um != nil {
+		*(*int32)(unsafe.Pointer(&um.data[0])) = v
+	}
+}
+
+func (m *Material) uniform(name string, xType bk.UniformType) *materialUniform {
+	return m.uniformN(name, xType, 0)
+}
+
+// uniformN finds or allocates the uniform, n > 0 allocates a vec4 array.
+func (m *Material) uniformN(name string, xType bk.UniformType, n int) *materialUniform {
+	for i := range m.uniforms {
+		if m.uniforms[i].name == name {
+			return &m.uniforms[i]
+		}
+	}
+	if m.program == bk.InvalidId {
+		log.Printf("material: uniform %s needs a custom shader", name)
+		return nil
+	}
+	num := uint32(1)
+	if n > 0 {
+		num = uint32(n)
+	}
+	id, _ := bk.R.AllocUniform(m.program, name+"\x00", xType, num)
+	if id == bk.InvalidId {
+		return nil
+	}
+	um := materialUniform{id: id, name: name}
+	if n > 0 {
+		um.array = make([]float32, 4*n)
+	}
+	m.uniforms = append(m.uniforms, um)
+	return &m.uniforms[len(m.uniforms)-1]
+}
+
+// apply writes uniform values and extra textures to current draw-call.
+func (m *Material) apply() {
+	for i := range m.uniforms {
+		um := &m.uniforms[i]
+		if um.array != nil {
+			bk.SetUniform(um.id, unsafe.Pointer(&um.array[0]))
+		} else {
+			bk.SetUniform(um.id, unsafe.Pointer(&um.data[0]))
+		}
+	}
+	if tex := m.texture; tex.sampler != bk.InvalidId {
+		bk.SetTexture(1, tex.sampler, tex.id, 0)
+	}
+}
+
+func (m *Material) release() {
+	if m.program != bk.InvalidId && !m.shared {
+		bk.R.Free(m.program)
+	}
+	*m = Material{}
+}
+
+const invalidLocation = ^uint32(0)
+
+// Material Resource Manager
+// Material id starts from 1, zero means the default material. The materials
+// are allocated one by one, so the pointers stay valid when more are created.
+type MaterialManager struct {
+	materials []*Material
+	frees     []uint16
+
+	// name to id
+	names map[string]uint16
+}
+
+// New creates a new Material with the default program.
+func (mm *MaterialManager) New(name string) (id uint16, m *Material) {
+	if n := len(mm.frees); n > 0 {
+		id = mm.frees[n-1]
+		mm.frees = mm.frees[:n-1]
+	} else {
+		id = uint16(len(mm.materials))
+		mm.materials = append(mm.materials, &Material{})
+	}
+	m = mm.materials[id]
+	if name != "" {
+		mm.names[name] = id
+	}
+	return
+}
+
+// NewShader creates a new Material with custom shader.
+func (mm *MaterialManager) NewShader(name string, vsh, fsh string) (id uint16, m *Material) {
+	id, m = mm.New(name)
+	m.SetShader(vsh, fsh)
+	return
+}
+
+// NewInstance creates a new Material sharing the shader program and uniforms
+// of the base Material. The uniform values are copied and can be changed
+// without affecting the base, no shader is compiled so an instance is cheap to
+// create and delete. The base must not be deleted before the instances.
+func (mm *MaterialManager) NewInstance(name string, base uint16) (id uint16, m *Material) {
+	b := mm.Get(base)
+	if b == nil {
+		return
+	}
+	inst := *b
+	inst.shared = true
+	inst.uniforms = make([]materialUniform, len(b.uniforms))
+	for i, um := range b.uniforms {
+		if um.array != nil {
+			um.array = append([]float32(nil), um.array...)
+		}
+		inst.uniforms[i] = um
+	}
+	id, m = mm.New(name)
+	*m = inst
+	return
+}
+
+// Get returns the Material by id.
+func (mm *MaterialManager) Get(id uint16) (m *Material) {
+	if id != 0 && int(id) < len(mm.materials) {
+		m = mm.materials[id]
+	}
+	return
+}
+
+// Find returns the Material by name.
+func (mm *MaterialManager) Find(name string) (id uint16, m *Material) {
+	if v, ok := mm.names[name]; ok {
+		id, m = v, mm.materials[v]
+	}
+	return
+}
+
+// Delete release the Material and it's shader program.
+func (mm *MaterialManager) Delete(id uint16) {
+	if m := mm.Get(id); m != nil {
+		m.release()
+		mm.frees = append(mm.frees, id)
+		for k, v := range mm.names {
+			if v == id {
+				delete(mm.names, k)
+			}
+		}
+	}
+}
+
+// MaterialManager as a global variable.
+var M *MaterialManager
+
+func init() {
+	// skip first index - 0
+	M = &MaterialManager{
+		materials: make([]*Material, 1),
+		names:     make(map[string]uint16),
+	}
+}
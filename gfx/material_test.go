@@ -0,0 +1,23 @@
+package gfx
+
+import (
+	"testing"
+
+	"sckorok/gfx/bk"
+)
+
+func TestMaterialPointer(t *testing.T) {
+	id, m := M.New("")
+	var ids []uint16
+	for i := 0; i < 10; i++ {
+		n, _ := M.New("")
+		ids = append(ids, n)
+	}
+	m.SetBlend(bk.ST_BLEND.ADDITIVE)
+	if M.Get(id).Blend() != bk.ST_BLEND.ADDITIVE {
+		t.Error("material pointer should stay valid after creating more materials")
+	}
+	for _, n := range append(ids, id) {
+		M.Delete(n)
+	}
+}
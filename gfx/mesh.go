@@ -18,7 +18,7 @@ type Mesh struct {
 
 	// res handle
 	textureId uint16
-	//padding   uint16
+	material  uint16
 
 	IndexId  uint16
 	VertexId uint16
@@ -71,6 +71,15 @@ func (m *Mesh) SetTexture(id uint16) {
 	m.textureId = id
 }
 
+// SetMaterial sets the Material, zero means the default material.
+func (m *Mesh) SetMaterial(id uint16) {
+	m.material = id
+}
+
+func (m *Mesh) Material() uint16 {
+	return m.material
+}
+
 func (m *Mesh) SetVertex(v []PosTexColorVertex) {
 	m.vertex = v
 }
@@ -194,6 +203,7 @@ type MeshRenderFeature struct {
 	R  *MeshRender
 	mt *MeshTable
 	xt *TransformTable
+	kt *MaskTable
 }
 
 // 此处初始化所有的依赖
@@ -217,6 +227,8 @@ func (f *MeshRenderFeature) Register(rs *RenderSystem) {
 			f.mt = table
 		case *TransformTable:
 			f.xt = table
+		case *MaskTable:
+			f.kt = table
 		}
 	}
 	// add new feature
@@ -231,7 +243,7 @@ func (f *MeshRenderFeature) Extract(v *View) {
 	)
 	for i, m := range f.mt.comps[:f.mt.index] {
 		if xf := xt.Comp(m.Entity); m.visible && camera.InView(xf, m.size, f32.Vec2{.5, .5}) {
-			sid := PackSortId(m.zOrder.value, 0)
+			sid := m.zOrder.sortId(xf.world.Position[1], m.material, 0)
 			val := fi + uint32(i)
 			v.RenderNodes = append(v.RenderNodes, SortObject{sid, val})
 		}
@@ -262,6 +274,7 @@ func (f *MeshRenderFeature) Draw(nodes RenderNodes) {
 		mat4[10] = 1
 		mat4[15] = 1
 
+		mr.SetStencil(f.kt.Stencil(entity))
 		mr.Draw(&mesh.Mesh, &mat4, int32(mesh.zOrder.value))
 	}
 }
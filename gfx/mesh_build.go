@@ -0,0 +1,420 @@
+package gfx
+
+import (
+	"sckorok/math"
+	"sckorok/math/f32"
+)
+
+/// Mesh building utilities, the results can be passed to Mesh.SetVertex and
+/// Mesh.SetIndex directly. A mesh indexes its vertices with uint16, so the
+/// builders return nil if the mesh needs more than 65535 vertices.
+
+const maxMeshVertex = 0xFFFF
+
+// Triangulate triangulates a simple polygon with holes by ear clipping. The
+// outline and holes can be in any winding order, the holes must lie inside
+// the outline and not overlap each other. The indices refer to the points of
+// outline followed by the points of each hole, the triangles are in
+// counter-clockwise order.
+func Triangulate(outline []f32.Vec2, holes ...[]f32.Vec2) []uint16 {
+	if len(outline) < 3 {
+		return nil
+	}
+	points := make([]f32.Vec2, 0, len(outline))
+	points = append(points, outline...)
+	for _, h := range holes {
+		points = append(points, h...)
+	}
+	if len(points) > maxMeshVertex {
+		return nil
+	}
+
+	// outline in ccw order, holes in cw order
+	ring := polygonRing(outline, 0, true)
+	var rings [][]int
+	base := len(outline)
+	for _, h := range holes {
+		if len(h) >= 3 {
+			rings = append(rings, polygonRing(h, base, false))
+		}
+		base += len(h)
+	}
+	ring = bridgeHoles(points, ring, rings)
+	return earClip(points, ring)
+}
+
+// polygonRing returns the indices of polygon in the given winding order.
+func polygonRing(poly []f32.Vec2, base int, ccw bool) []int {
+	ring := make([]int, len(poly))
+	reverse := (polygonArea(poly) > 0) != ccw
+	for i := range ring {
+		if reverse {
+			ring[i] = base + len(poly) - 1 - i
+		} else {
+			ring[i] = base + i
+		}
+	}
+	return ring
+}
+
+// polygonArea returns the signed area, positive if the polygon is ccw.
+func polygonArea(poly []f32.Vec2) (area float32) {
+	for i, p := range poly {
+		q := poly[(i+1)%len(poly)]
+		area += p[0]*q[1] - q[0]*p[1]
+	}
+	return area / 2
+}
+
+// bridgeHoles merges the holes into the outline. The rightmost vertex of
+// each hole is connected to a visible vertex of the outline, the holes are
+// merged from right to left so a bridge never crosses a later hole.
+func bridgeHoles(points []f32.Vec2, ring []int, holes [][]int) []int {
+	rightmost := func(h []int) int {
+		k := 0
+		for i, v := range h {
+			if points[v][0] > points[h[k]][0] {
+				k = i
+			}
+		}
+		return k
+	}
+	// sort by the rightmost x, insertion sort is enough for a few holes
+	for i := 1; i < len(holes); i++ {
+		for j := i; j > 0; j-- {
+			a, b := holes[j-1], holes[j]
+			if points[a[rightmost(a)]][0] >= points[b[rightmost(b)]][0] {
+				break
+			}
+			holes[j-1], holes[j] = b, a
+		}
+	}
+	for _, h := range holes {
+		k := rightmost(h)
+		p := bridgeVertex(points, ring, points[h[k]])
+		if p < 0 {
+			continue
+		}
+		merged := make([]int, 0, len(ring)+len(h)+2)
+		merged = append(merged, ring[:p+1]...)
+		for i := 0; i <= len(h); i++ {
+			merged = append(merged, h[(k+i)%len(h)])
+		}
+		merged = append(merged, ring[p])
+		merged = append(merged, ring[p+1:]...)
+		ring = merged
+	}
+	return ring
+}
+
+// bridgeVertex returns the position in ring of a vertex visible from m.
+func bridgeVertex(points []f32.Vec2, ring []int, m f32.Vec2) int {
+	// cast a ray to +x, find the nearest edge
+	n := len(ring)
+	best, hit := -1, float32(0)
+	for i := 0; i < n; i++ {
+		a, b := points[ring[i]], points[ring[(i+1)%n]]
+		if (a[1]-m[1])*(b[1]-m[1]) > 0 || a[1] == b[1] {
+			continue
+		}
+		x := a[0] + (m[1]-a[1])*(b[0]-a[0])/(b[1]-a[1])
+		if x < m[0] || (best >= 0 && x >= hit) {
+			continue
+		}
+		// the endpoint with larger x
+		best, hit = i, x
+		if b[0] > a[0] {
+			best = (i + 1) % n
+		}
+	}
+	if best < 0 {
+		return -1
+	}
+
+	// the reflex vertices inside the triangle (m, hit, p) may block the
+	// bridge, pick the one with the smallest angle to the ray
+	p := points[ring[best]]
+	in := f32.Vec2{hit, m[1]}
+	angle := float32(-1)
+	for i := 0; i < n; i++ {
+		v := points[ring[i]]
+		if v == p || !earReflex(points, ring, i) || !pointInTriangle(v, m, in, p) {
+			continue
+		}
+		d := v.Sub(m)
+		if cos := d[0] / math.Max(d.Len(), 1e-6); cos > angle {
+			angle, best = cos, i
+		}
+	}
+	return best
+}
+
+// earReflex returns true if the vertex i of ring is reflex.
+func earReflex(points []f32.Vec2, ring []int, i int) bool {
+	n := len(ring)
+	a, b, c := points[ring[(i+n-1)%n]], points[ring[i]], points[ring[(i+1)%n]]
+	return b.Sub(a).Cross(c.Sub(b)) < 0
+}
+
+func pointInTriangle(p, a, b, c f32.Vec2) bool {
+	d1 := b.Sub(a).Cross(p.Sub(a))
+	d2 := c.Sub(b).Cross(p.Sub(b))
+	d3 := a.Sub(c).Cross(p.Sub(c))
+	neg := d1 < 0 || d2 < 0 || d3 < 0
+	pos := d1 > 0 || d2 > 0 || d3 > 0
+	return !(neg && pos)
+}
+
+// earClip clips the ears of a ccw ring.
+func earClip(points []f32.Vec2, ring []int) []uint16 {
+	ring = append([]int(nil), ring...)
+	index := make([]uint16, 0, (len(ring)-2)*3)
+	for n := len(ring); n > 3; n = len(ring) {
+		ear := -1
+		for i := 0; i < n && ear < 0; i++ {
+			if isEar(points, ring, i) {
+				ear = i
+			}
+		}
+		if ear < 0 {
+			// degenerated polygon, cut the least reflex vertex to go on
+			ear = 0
+			for i := 1; i < n; i++ {
+				if !earReflex(points, ring, i) {
+					ear = i
+					break
+				}
+			}
+		}
+		a, b, c := ring[(ear+n-1)%n], ring[ear], ring[(ear+1)%n]
+		if earArea(points, a, b, c) > 0 {
+			index = append(index, uint16(a), uint16(b), uint16(c))
+		}
+		ring = append(ring[:ear], ring[ear+1:]...)
+	}
+	if earArea(points, ring[0], ring[1], ring[2]) > 0 {
+		index = append(index, uint16(ring[0]), uint16(ring[1]), uint16(ring[2]))
+	}
+	return index
+}
+
+func earArea(points []f32.Vec2, a, b, c int) float32 {
+	return points[b].Sub(points[a]).Cross(points[c].Sub(points[b]))
+}
+
+func isEar(points []f32.Vec2, ring []int, i int) bool {
+	n := len(ring)
+	ia, ib, ic := ring[(i+n-1)%n], ring[i], ring[(i+1)%n]
+	if earArea(points, ia, ib, ic) <= 0 {
+		return false
+	}
+	a, b, c := points[ia], points[ib], points[ic]
+	for j := 0; j < n; j++ {
+		// the bridge duplicates vertices, skip the same points
+		v := points[ring[j]]
+		if v == a || v == b || v == c {
+			continue
+		}
+		if pointInTriangle(v, a, b, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// regionUV returns the texture coordinate of region, (s, t) is the normalized
+// coordinate from the bottom-left corner.
+func regionUV(rg Region, s, t float32) (u, v float32) {
+	if rg.Rotated {
+		return rg.X1 + t*(rg.X2-rg.X1), rg.Y1 + s*(rg.Y2-rg.Y1)
+	}
+	return rg.X1 + s*(rg.X2-rg.X1), rg.Y2 + t*(rg.Y1-rg.Y2)
+}
+
+func texRegion(tex Tex2D) Region {
+	if tex == nil {
+		return Region{0, 0, 1, 1, false}
+	}
+	return tex.Region()
+}
+
+// PolygonMesh builds a mesh of the polygon with holes, the texture is stretched
+// to the bounding box of polygon.
+func PolygonMesh(tex Tex2D, outline []f32.Vec2, holes ...[]f32.Vec2) (vertex []PosTexColorVertex, index []uint16) {
+	if index = Triangulate(outline, holes...); len(index) == 0 {
+		return nil, nil
+	}
+	n := len(outline)
+	for _, h := range holes {
+		n += len(h)
+	}
+	vertex = make([]PosTexColorVertex, 0, n)
+	add := func(p f32.Vec2) {
+		vertex = append(vertex, PosTexColorVertex{X: p[0], Y: p[1], RGBA: 0xFFFFFFFF})
+	}
+	for _, p := range outline {
+		add(p)
+	}
+	for _, h := range holes {
+		for _, p := range h {
+			add(p)
+		}
+	}
+
+	// bounding box
+	lo, hi := outline[0], outline[0]
+	for _, p := range outline {
+		lo = f32.Vec2{math.Min(lo[0], p[0]), math.Min(lo[1], p[1])}
+		hi = f32.Vec2{math.Max(hi[0], p[0]), math.Max(hi[1], p[1])}
+	}
+	w, h := math.Max(hi[0]-lo[0], 1e-6), math.Max(hi[1]-lo[1], 1e-6)
+	rg := texRegion(tex)
+	for i := range vertex {
+		v := &vertex[i]
+		v.U, v.V = regionUV(rg, (v.X-lo[0])/w, (v.Y-lo[1])/h)
+	}
+	return
+}
+
+// StripMesh extrudes a polyline into a strip of the width, the joints are
+// mitered. The texture is stretched along the polyline, the left side of the
+// direction maps to the top of texture.
+func StripMesh(tex Tex2D, points []f32.Vec2, width float32) (vertex []PosTexColorVertex, index []uint16) {
+	// drop the duplicated points
+	pts := make([]f32.Vec2, 0, len(points))
+	for _, p := range points {
+		if n := len(pts); n == 0 || !p.Sub(pts[n-1]).IsZero() {
+			pts = append(pts, p)
+		}
+	}
+	n := len(pts)
+	if n < 2 || n*2 > maxMeshVertex {
+		return nil, nil
+	}
+
+	length := make([]float32, n)
+	for i := 1; i < n; i++ {
+		length[i] = length[i-1] + pts[i].Sub(pts[i-1]).Len()
+	}
+	var (
+		rg    = texRegion(tex)
+		half  = width / 2
+		total = length[n-1]
+	)
+	normal := func(a, b f32.Vec2) f32.Vec2 {
+		d := b.Sub(a).Norm()
+		return f32.Vec2{-d[1], d[0]}
+	}
+	vertex = make([]PosTexColorVertex, n*2)
+	for i, p := range pts {
+		var miter f32.Vec2
+		scale := half
+		switch {
+		case i == 0:
+			miter = normal(p, pts[1])
+		case i == n-1:
+			miter = normal(pts[i-1], p)
+		default:
+			n0, n1 := normal(pts[i-1], p), normal(p, pts[i+1])
+			if m := n0.Add(n1); m.IsZero() {
+				miter = n0
+			} else {
+				miter = m.Norm()
+				// limit the sharp joint to twice of the width
+				scale = half / math.Max(miter.Dot(n0), .25)
+			}
+		}
+		l, r := p.Add(miter.Mul(scale)), p.Sub(miter.Mul(scale))
+		s := length[i] / total
+		vl, vr := &vertex[i*2], &vertex[i*2+1]
+		vl.X, vl.Y, vl.RGBA = l[0], l[1], 0xFFFFFFFF
+		vr.X, vr.Y, vr.RGBA = r[0], r[1], 0xFFFFFFFF
+		vl.U, vl.V = regionUV(rg, s, 1)
+		vr.U, vr.V = regionUV(rg, s, 0)
+	}
+
+	index = make([]uint16, 0, (n-1)*6)
+	for i := 0; i < n-1; i++ {
+		l0, r0, l1, r1 := uint16(i*2), uint16(i*2+1), uint16(i*2+2), uint16(i*2+3)
+		index = append(index, r0, r1, l1, r0, l1, l0)
+	}
+	return
+}
+
+// TerrainMesh builds a terrain of the height profile, the heights are sampled
+// at every step from x = 0. The terrain fills from the surface to the depth
+// below it, or to y = 0 if depth is zero. The texture repeats on each column,
+// so the step is usually the width of texture.
+func TerrainMesh(tex Tex2D, heights []float32, step, depth float32) (vertex []PosTexColorVertex, index []uint16) {
+	n := len(heights) - 1
+	if n < 1 || n*4 > maxMeshVertex {
+		return nil, nil
+	}
+	rg := texRegion(tex)
+	bottom := func(h float32) float32 {
+		if depth > 0 {
+			return h - depth
+		}
+		return 0
+	}
+	vertex = make([]PosTexColorVertex, n*4)
+	index = make([]uint16, 0, n*6)
+	for i := 0; i < n; i++ {
+		x0, x1 := float32(i)*step, float32(i+1)*step
+		h0, h1 := heights[i], heights[i+1]
+		buf := vertex[i*4 : i*4+4]
+		buf[0].X, buf[0].Y = x0, bottom(h0)
+		buf[1].X, buf[1].Y = x1, bottom(h1)
+		buf[2].X, buf[2].Y = x1, h1
+		buf[3].X, buf[3].Y = x0, h0
+		buf[0].U, buf[0].V = regionUV(rg, 0, 0)
+		buf[1].U, buf[1].V = regionUV(rg, 1, 0)
+		buf[2].U, buf[2].V = regionUV(rg, 1, 1)
+		buf[3].U, buf[3].V = regionUV(rg, 0, 1)
+		for j := range buf {
+			buf[j].RGBA = 0xFFFFFFFF
+		}
+		k := uint16(i * 4)
+		index = append(index, k, k+1, k+2, k, k+2, k+3)
+	}
+	return
+}
+
+// GridMesh slices the sprite into a grid of cols x rows cells, the grid is
+// centered at the origin with the size of sprite. The vertices are in row
+// major order from the bottom-left corner, so the vertex of column i and row j
+// is at j*(cols+1)+i. Moving the vertices deforms the sprite.
+func GridMesh(tex Tex2D, cols, rows int) (vertex []PosTexColorVertex, index []uint16) {
+	if cols < 1 || rows < 1 || (cols+1)*(rows+1) > maxMeshVertex {
+		return nil, nil
+	}
+	var size Size
+	if tex != nil {
+		size = tex.Size()
+	}
+	vertex = make([]PosTexColorVertex, (cols+1)*(rows+1))
+	fillGrid(vertex, texRegion(tex), size.Width, size.Height, cols, rows)
+	index = make([]uint16, 0, cols*rows*6)
+	for j := 0; j < rows; j++ {
+		for i := 0; i < cols; i++ {
+			k := uint16(j*(cols+1) + i)
+			up := k + uint16(cols+1)
+			index = append(index, k, k+1, up+1, k, up+1, up)
+		}
+	}
+	return
+}
+
+// fillGrid fills the vertices of a grid centered at the origin.
+func fillGrid(buf []PosTexColorVertex, rg Region, w, h float32, cols, rows int) {
+	for j := 0; j <= rows; j++ {
+		t := float32(j) / float32(rows)
+		for i := 0; i <= cols; i++ {
+			s := float32(i) / float32(cols)
+			v := &buf[j*(cols+1)+i]
+			v.X, v.Y = (s-.5)*w, (t-.5)*h
+			v.U, v.V = regionUV(rg, s, t)
+			v.RGBA = 0xFFFFFFFF
+		}
+	}
+}
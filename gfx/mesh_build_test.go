@@ -0,0 +1,104 @@
+package gfx
+
+import (
+	"testing"
+
+	"sckorok/math"
+	"sckorok/math/f32"
+)
+
+// meshArea returns the area of triangles, negative if a triangle is cw
+func meshArea(points []f32.Vec2, index []uint16) (area float32) {
+	for i := 0; i+2 < len(index); i += 3 {
+		a, b, c := points[index[i]], points[index[i+1]], points[index[i+2]]
+		tri := b.Sub(a).Cross(c.Sub(a)) / 2
+		if tri < 0 {
+			return -1
+		}
+		area += tri
+	}
+	return
+}
+
+func TestTriangulate(t *testing.T) {
+	// concave L shape in cw order
+	l := []f32.Vec2{{0, 0}, {0, 2}, {1, 2}, {1, 1}, {2, 1}, {2, 0}}
+	index := Triangulate(l)
+	if len(index) != 12 {
+		t.Fatal("polygon of 6 vertices should have 4 triangles:", index)
+	}
+	if a := meshArea(l, index); math.ABS(a-3) > 1e-4 {
+		t.Error("triangles should cover the polygon in ccw order:", a)
+	}
+
+	// square with two holes
+	outline := []f32.Vec2{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+	h1 := []f32.Vec2{{2, 2}, {4, 2}, {4, 4}, {2, 4}}
+	h2 := []f32.Vec2{{6, 6}, {6, 8}, {8, 8}, {8, 6}}
+	index = Triangulate(outline, h1, h2)
+	points := append(append(append([]f32.Vec2{}, outline...), h1...), h2...)
+	if a := meshArea(points, index); math.ABS(a-92) > 1e-3 {
+		t.Error("holes should be cut from the polygon:", a)
+	}
+	for _, i := range index {
+		if int(i) >= len(points) {
+			t.Fatal("index out of the points:", i)
+		}
+	}
+
+	if Triangulate(outline[:2]) != nil {
+		t.Error("polygon needs 3 vertices at least")
+	}
+}
+
+func TestMeshBuild(t *testing.T) {
+	tex := packedTex{}
+
+	// polygon mesh maps the bounding box to the region
+	v, i := PolygonMesh(tex, []f32.Vec2{{0, 0}, {4, 0}, {4, 2}, {0, 2}})
+	if len(v) != 4 || len(i) != 6 {
+		t.Fatal("polygon mesh error:", len(v), len(i))
+	}
+	if v[2].U != 1 || v[2].V != 0 || v[0].U != 0 || v[0].V != 1 {
+		t.Error("texture should be stretched to the bounding box:", v)
+	}
+
+	// strip of width 2 along a right angle
+	v, i = StripMesh(tex, []f32.Vec2{{0, 0}, {10, 0}, {10, 0}, {10, 10}}, 2)
+	if len(v) != 6 || len(i) != 12 {
+		t.Fatal("duplicated points should be dropped:", len(v), len(i))
+	}
+	near := func(v PosTexColorVertex, x, y float32) bool {
+		return math.ABS(v.X-x) < 1e-4 && math.ABS(v.Y-y) < 1e-4
+	}
+	if !near(v[0], 0, 1) || !near(v[1], 0, -1) || !near(v[2], 9, 1) || !near(v[3], 11, -1) {
+		t.Error("strip should be extruded with mitered joints:", v[:4])
+	}
+	if v[2].U != .5 || v[5].U != 1 {
+		t.Error("texture should be stretched along the polyline:", v[2].U, v[5].U)
+	}
+
+	// terrain fills to y = 0 or the depth under surface
+	v, i = TerrainMesh(tex, []float32{4, 6, 5}, 8, 0)
+	if len(v) != 8 || len(i) != 12 {
+		t.Fatal("terrain should have a quad per column:", len(v), len(i))
+	}
+	if v[4].X != 8 || v[4].Y != 0 || v[6].X != 16 || v[6].Y != 5 {
+		t.Error("terrain column error:", v[4:])
+	}
+	if v, _ = TerrainMesh(tex, []float32{4, 6}, 8, 1); v[0].Y != 3 || v[1].Y != 5 {
+		t.Error("terrain should follow the surface with depth:", v)
+	}
+
+	// grid
+	v, i = GridMesh(tex, 2, 3)
+	if len(v) != 12 || len(i) != 36 {
+		t.Fatal("grid mesh error:", len(v), len(i))
+	}
+	if v[0].X != -2 || v[0].Y != -3 || v[11].U != 1 || v[11].V != 0 {
+		t.Error("grid vertex error:", v[0], v[11])
+	}
+	if v, i = GridMesh(tex, 0, 1); v != nil || i != nil {
+		t.Error("grid needs a cell at least")
+	}
+}
@@ -21,6 +21,12 @@ type MeshRender struct {
 	umhProjection uint16 // Projection
 	umhModel      uint16 // Model
 	umhSampler0   uint16 // Sampler0
+
+	// projection matrix, shared by materials
+	projection f32.Mat4
+
+	// stencil state of the next draw
+	stencil uint32
 }
 
 func NewMeshRender(vsh, fsh string) *MeshRender {
@@ -61,10 +67,10 @@ func NewMeshRender(vsh, fsh string) *MeshRender {
 
 func (mr *MeshRender) SetCamera(camera *Camera) {
 	left, right, bottom, top := camera.P()
-	p := f32.Ortho2D(left, right, bottom, top)
+	mr.projection = f32.Ortho2D(left, right, bottom, top)
 
 	// setup uniform
-	bk.SetUniform(mr.umhProjection, unsafe.Pointer(&p[0]))
+	bk.SetUniform(mr.umhProjection, unsafe.Pointer(&mr.projection[0]))
 	bk.Submit(0, mr.program, 0)
 }
 
@@ -78,18 +84,42 @@ func (mr *MeshRender) Extract(visibleObjects []uint32) {
 
 }
 
+// SetStencil sets the stencil state of the next Draw.
+func (mr *MeshRender) SetStencil(stencil uint32) {
+	mr.stencil = stencil
+}
+
 // draw
 func (mr *MeshRender) Draw(m *Mesh, mat4 *f32.Mat4, depth int32) {
+	program, state := mr.program, mr.stateFlags
+	sampler, model := mr.umhSampler0, mr.umhModel
+
+	// material
+	if mat := M.Get(m.material); mat != nil {
+		if mat.state != 0 {
+			state = mat.state
+		}
+		if mat.program != bk.InvalidId {
+			program, sampler, model = mat.program, mat.umhSampler0, mat.umhModel
+			bk.SetUniform(mat.umhProjection, unsafe.Pointer(&mr.projection[0]))
+		}
+		mat.apply()
+	}
+
 	// state
-	bk.SetState(mr.stateFlags, mr.rgba)
-	bk.SetTexture(0, mr.umhSampler0, m.textureId, 0)
+	bk.SetState(state, mr.rgba)
+	bk.SetStencil(mr.stencil)
+	bk.SetTexture(0, sampler, m.textureId, 0)
+	mr.stencil = 0
 
 	// set uniform - mvp
-	bk.SetUniform(mr.umhModel, unsafe.Pointer(&mat4[0]))
+	if model != bk.InvalidId {
+		bk.SetUniform(model, unsafe.Pointer(&mat4[0]))
+	}
 
 	// set vertex
 	bk.SetVertexBuffer(0, m.VertexId, uint32(m.FirstVertex), uint32(m.NumVertex))
 	bk.SetIndexBuffer(m.IndexId, uint32(m.FirstIndex), uint32(m.NumIndex))
 	//
-	bk.Submit(0, mr.program, depth)
+	bk.Submit(0, program, depth)
 }
@@ -0,0 +1,204 @@
+package gfx
+
+import (
+	"sckorok/math"
+	"sckorok/math/f32"
+)
+
+// SliceMode decides how the edges and centre of a nine-slice sprite fill the space.
+type SliceMode uint8
+
+const (
+	SliceStretch SliceMode = iota
+	SliceTile
+)
+
+// NineSlice splits the sprite into 9 parts with insets, the insets are in pixels
+// of the texture. Corners keep the original size, edges and centre are stretched
+// or tiled to fill the size of the SpriteComp.
+//
+//	+----+----+----+ Top
+//	|    |    |    |
+//	+----+----+----+
+//	|    |    |    |
+//	+----+----+----+
+//	|    |    |    |
+//	+----+----+----+ Bottom
+//	Left        Right
+type NineSlice struct {
+	Left, Right float32
+	Bottom, Top float32
+
+	Edge   SliceMode
+	Center SliceMode
+}
+
+// SetNineSlice enables the nine-slice mode of the sprite.
+func (sc *SpriteComp) SetNineSlice(ns NineSlice) {
+	sc.slice = &ns
+}
+
+// NineSlice returns the nine-slice setting, ok is false if the mode is disabled.
+func (sc *SpriteComp) NineSlice() (ns NineSlice, ok bool) {
+	if sc.slice != nil {
+		ns, ok = *sc.slice, true
+	}
+	return
+}
+
+// ClearNineSlice disables the nine-slice mode.
+func (sc *SpriteComp) ClearNineSlice() {
+	sc.slice = nil
+}
+
+// max segments in one dimension when tiling, fall back to stretch if exceeded
+const maxSliceSegments = 64
+
+// a segment in one dimension: position [p0, p1] and normalized texture coordinate [t0, t1]
+type sliceSegment struct {
+	p0, p1 float32
+	t0, t1 float32
+}
+
+// sliceBatchObject fills the quads of a nine-slice sprite, the segments are
+// computed in build() and reused between sprites.
+type sliceBatchObject struct {
+	*SpriteComp
+	*Transform
+
+	// edge columns(left, middle..., right) and middle columns of centre
+	cols, centerCols []sliceSegment
+	// edge rows(bottom, middle..., top) and middle rows of centre
+	rows, centerRows []sliceSegment
+}
+
+func (sbo *sliceBatchObject) build(sc *SpriteComp, xf *Transform) {
+	sbo.SpriteComp, sbo.Transform = sc, xf
+	ns, sz := sc.slice, sc.Sprite.Size()
+
+	sbo.cols = sliceSegments(sbo.cols[:0], sc.width, sz.Width, ns.Left, ns.Right, ns.Edge)
+	sbo.rows = sliceSegments(sbo.rows[:0], sc.height, sz.Height, ns.Bottom, ns.Top, ns.Edge)
+	if ns.Center == ns.Edge {
+		sbo.centerCols = append(sbo.centerCols[:0], sbo.cols[1:len(sbo.cols)-1]...)
+		sbo.centerRows = append(sbo.centerRows[:0], sbo.rows[1:len(sbo.rows)-1]...)
+	} else {
+		cols := sliceSegments(sbo.centerCols[:0], sc.width, sz.Width, ns.Left, ns.Right, ns.Center)
+		rows := sliceSegments(sbo.centerRows[:0], sc.height, sz.Height, ns.Bottom, ns.Top, ns.Center)
+		sbo.centerCols = append(cols[:0], cols[1:len(cols)-1]...)
+		sbo.centerRows = append(rows[:0], rows[1:len(rows)-1]...)
+	}
+}
+
+// sliceSegments splits the size into segments: the first and last segments are
+// the insets, there is at least one segment in the middle.
+func sliceSegments(segs []sliceSegment, size, texSize, a, b float32, mode SliceMode) []sliceSegment {
+	if texSize <= 0 {
+		return append(segs, sliceSegment{}, sliceSegment{0, size, 0, 1}, sliceSegment{size, size, 1, 1})
+	}
+	ta, tb := a/texSize, 1-b/texSize
+
+	// scale the insets down if the size is too small
+	if a+b > size {
+		k := size / (a + b)
+		a, b = a*k, b*k
+	}
+	segs = append(segs, sliceSegment{0, a, 0, ta})
+
+	middle, source := size-a-b, texSize-a-b
+	if n := math.Ceil(middle / source); mode == SliceTile && source > 0 && n <= maxSliceSegments {
+		for i := 0; i < int(n); i++ {
+			p := a + float32(i)*source
+			if w := a + middle - p; w < source {
+				segs = append(segs, sliceSegment{p, p + w, ta, ta + (tb-ta)*w/source})
+			} else {
+				segs = append(segs, sliceSegment{p, p + source, ta, tb})
+			}
+		}
+	} else {
+		segs = append(segs, sliceSegment{a, a + middle, ta, tb})
+	}
+	if len(segs) == 1 {
+		// empty middle
+		segs = append(segs, sliceSegment{a, a, ta, tb})
+	}
+	return append(segs, sliceSegment{size - b, size, tb, 1})
+}
+
+// border quads + centre quads
+func (sbo *sliceBatchObject) Size() int {
+	n := len(sbo.cols)*2 + (len(sbo.rows)-2)*2 + len(sbo.centerCols)*len(sbo.centerRows)
+	return n * 4
+}
+
+func (sbo *sliceBatchObject) Fill(buf []PosTexColorVertex) {
+	var (
+		srt  = sbo.Transform.world
+		p    = srt.Position
+		c    = sbo.SpriteComp
+		rows = sbo.rows
+		cols = sbo.cols
+		n    = 0
+	)
+	// Transform matrix
+	m := f32.Mat3{}
+	m.Initialize(p[0], p[1], srt.Rotation, srt.Scale[0], srt.Scale[1], c.width*c.gravity.x, c.height*c.gravity.y, 0, 0)
+
+	// bottom and top row
+	for _, row := range [2]sliceSegment{rows[0], rows[len(rows)-1]} {
+		for _, col := range cols {
+			sbo.fillQuad(buf[n:n+4], &m, col, row)
+			n += 4
+		}
+	}
+	// left and right column
+	for _, row := range rows[1 : len(rows)-1] {
+		for _, col := range [2]sliceSegment{cols[0], cols[len(cols)-1]} {
+			sbo.fillQuad(buf[n:n+4], &m, col, row)
+			n += 4
+		}
+	}
+	// centre
+	for _, row := range sbo.centerRows {
+		for _, col := range sbo.centerCols {
+			sbo.fillQuad(buf[n:n+4], &m, col, row)
+			n += 4
+		}
+	}
+}
+
+// fill a quad, the winding order is the same as normal sprite.
+func (sbo *sliceBatchObject) fillQuad(buf []PosTexColorVertex, m *f32.Mat3, col, row sliceSegment) {
+	c := sbo.SpriteComp
+	x0, x1, y0, y1 := col.p0, col.p1, row.p0, row.p1
+
+	// flip geometry, so the insets are mirrored too
+	if c.flipX == 1 {
+		x0, x1 = c.width-x0, c.width-x1
+	}
+	if c.flipY == 1 {
+		y0, y1 = c.height-y0, c.height-y1
+	}
+	buf[0].X, buf[0].Y = m.Transform(x0, y0)
+	buf[1].X, buf[1].Y = m.Transform(x1, y0)
+	buf[2].X, buf[2].Y = m.Transform(x1, y1)
+	buf[3].X, buf[3].Y = m.Transform(x0, y1)
+
+	// texture, (s, t) is the normalized coordinate from the bottom-left corner
+	rg := c.Sprite.Region()
+	uv := func(s, t float32) (u, v float32) {
+		if rg.Rotated {
+			return rg.X1 + t*(rg.X2-rg.X1), rg.Y1 + s*(rg.Y2-rg.Y1)
+		}
+		return rg.X1 + s*(rg.X2-rg.X1), rg.Y2 + t*(rg.Y1-rg.Y2)
+	}
+	buf[0].U, buf[0].V = uv(col.t0, row.t0)
+	buf[1].U, buf[1].V = uv(col.t1, row.t0)
+	buf[2].U, buf[2].V = uv(col.t1, row.t1)
+	buf[3].U, buf[3].V = uv(col.t0, row.t1)
+
+	// Color
+	buf[0].RGBA = c.color
+	buf[1].RGBA = c.color
+	buf[2].RGBA = c.color
+	buf[3].RGBA = c.color
+}
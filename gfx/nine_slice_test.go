@@ -0,0 +1,49 @@
+package gfx
+
+import "testing"
+
+func TestSliceStretch(t *testing.T) {
+	// texture: 32, insets: 8, 8
+	segs := sliceSegments(nil, 100, 32, 8, 8, SliceStretch)
+	expect := []sliceSegment{
+		{0, 8, 0, .25},
+		{8, 92, .25, .75},
+		{92, 100, .75, 1},
+	}
+	if len(segs) != len(expect) {
+		t.Fatal("segment size error:", segs)
+	}
+	for i, s := range segs {
+		if s != expect[i] {
+			t.Error("segment error:", i, s, "expected:", expect[i])
+		}
+	}
+}
+
+func TestSliceTile(t *testing.T) {
+	// middle: 84 = 16 * 5 + 4
+	segs := sliceSegments(nil, 100, 32, 8, 8, SliceTile)
+	if len(segs) != 2+6 {
+		t.Fatal("segment size error:", len(segs))
+	}
+	if s := segs[1]; s != (sliceSegment{8, 24, .25, .75}) {
+		t.Error("first tile error:", s)
+	}
+	if s := segs[6]; s != (sliceSegment{88, 92, .25, .375}) {
+		t.Error("last tile should be clipped:", s)
+	}
+	if s := segs[7]; s != (sliceSegment{92, 100, .75, 1}) {
+		t.Error("inset error:", s)
+	}
+}
+
+// insets are scaled down if the sprite is smaller than the insets
+func TestSliceSmall(t *testing.T) {
+	segs := sliceSegments(nil, 8, 32, 8, 8, SliceTile)
+	if len(segs) != 3 {
+		t.Fatal("segment size error:", segs)
+	}
+	if segs[0].p1 != 4 || segs[1].p0 != segs[1].p1 || segs[2].p0 != 4 {
+		t.Error("inset should be scaled:", segs)
+	}
+}
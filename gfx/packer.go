@@ -0,0 +1,228 @@
+package gfx
+
+import (
+	"sckorok/gfx/bk"
+
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// Packer packs images into shared texture pages at runtime, so the sprites
+// using them can be drawn in one batch. A page is an Atlas named "name#page",
+// images can be added at any time, e.g. avatars downloaded or text baked.
+//
+// Images are placed with the skyline bottom-left algorithm. Padding is the
+// empty space between images, Extrude repeats the border pixels of an image
+// to avoid bleeding when the sprite is scaled or filtered.
+type Packer struct {
+	Padding, Extrude int
+
+	name  string
+	w, h  int
+	pages []packPage
+
+	// name to sub-texture
+	names map[string]SubTex
+}
+
+type packPage struct {
+	id   uint16
+	name string
+	sky  skyline
+}
+
+// NewPacker creates a packer, the size of pages is w*h.
+func NewPacker(name string, w, h int) *Packer {
+	return &Packer{
+		Padding: 1,
+		Extrude: 1,
+		name:    name,
+		w:       w,
+		h:       h,
+		names:   make(map[string]SubTex),
+	}
+}
+
+// Add packs the image and returns the sub-texture, a new page is created if
+// there is no space. The name can be used to find it later, an image with
+// the same name is added only once.
+func (p *Packer) Add(name string, img image.Image) (tex SubTex, err error) {
+	if tex, ok := p.names[name]; ok && name != "" {
+		return tex, nil
+	}
+	var (
+		e    = p.Extrude
+		w, h = img.Bounds().Dx(), img.Bounds().Dy()
+		bw   = w + 2*e + p.Padding
+		bh   = h + 2*e + p.Padding
+	)
+	if bw > p.w || bh > p.h {
+		return tex, fmt.Errorf("packer: image %q (%dx%d) is larger than page", name, w, h)
+	}
+
+	pi, x, y := -1, 0, 0
+	for i := range p.pages {
+		if xx, yy, ok := p.pages[i].sky.insert(bw, bh); ok {
+			pi, x, y = i, xx, yy
+			break
+		}
+	}
+	if pi < 0 {
+		if err = p.newPage(); err != nil {
+			return
+		}
+		pi = len(p.pages) - 1
+		x, y, _ = p.pages[pi].sky.insert(bw, bh)
+	}
+
+	page := &p.pages[pi]
+	_, t := bk.R.Texture(page.id)
+	if err = t.Update(extrude(img, e), int32(x), int32(y), int32(w+2*e), int32(h+2*e)); err != nil {
+		return
+	}
+	at := R.Atlas(page.name)
+	at.AddItem(float32(x+e), float32(y+e), float32(w), float32(h), name, false)
+	tex, _ = at.GetByIndex(int(at.index) - 1)
+	if name != "" {
+		p.names[name] = tex
+	}
+	return
+}
+
+// Get returns the sub-texture added with the name.
+func (p *Packer) Get(name string) (tex SubTex, ok bool) {
+	tex, ok = p.names[name]
+	return
+}
+
+// Pages returns the number of pages.
+func (p *Packer) Pages() int {
+	return len(p.pages)
+}
+
+// Page returns the texture of the page.
+func (p *Packer) Page(i int) Tex2D {
+	return NewTex(p.pages[i].id)
+}
+
+// Dispose deletes the pages, the sub-textures are invalid after disposed.
+func (p *Packer) Dispose() {
+	for _, page := range p.pages {
+		R.Delete(page.name)
+		bk.R.Free(page.id)
+	}
+	p.pages = nil
+	p.names = make(map[string]SubTex)
+}
+
+func (p *Packer) newPage() error {
+	id, _ := bk.R.AllocTexture(image.NewRGBA(image.Rect(0, 0, p.w, p.h)))
+	if id == bk.InvalidId {
+		return errors.New("packer: fail to create page")
+	}
+	name := fmt.Sprintf("%s#%d", p.name, len(p.pages))
+	R.NewAtlas(id, 16, name)
+	p.pages = append(p.pages, packPage{id: id, name: name, sky: newSkyline(p.w, p.h)})
+	return nil
+}
+
+// extrude copies the image into a new image with e pixels border, the border
+// repeats the edge pixels of image.
+func extrude(img image.Image, e int) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w+2*e, h+2*e))
+	draw.Draw(dst, image.Rect(e, e, e+w, e+h), img, b.Min, draw.Src)
+	if e == 0 || w == 0 || h == 0 {
+		return dst
+	}
+	for y := e; y < e+h; y++ {
+		for x := 0; x < e; x++ {
+			dst.SetRGBA(x, y, dst.RGBAAt(e, y))
+			dst.SetRGBA(e+w+x, y, dst.RGBAAt(e+w-1, y))
+		}
+	}
+	for y := 0; y < e; y++ {
+		copy(dst.Pix[dst.PixOffset(0, y):dst.PixOffset(0, y+1)], dst.Pix[dst.PixOffset(0, e):dst.PixOffset(0, e+1)])
+		copy(dst.Pix[dst.PixOffset(0, e+h+y):dst.PixOffset(0, e+h+y+1)], dst.Pix[dst.PixOffset(0, e+h-1):dst.PixOffset(0, e+h)])
+	}
+	return dst
+}
+
+// skyline tracks the top edge of packed rectangles, each node is a segment
+// of the edge.
+type skyline struct {
+	w, h  int
+	nodes []skyNode
+}
+
+type skyNode struct {
+	x, y, w int
+}
+
+func newSkyline(w, h int) skyline {
+	return skyline{w: w, h: h, nodes: []skyNode{{0, 0, w}}}
+}
+
+// insert finds the position with the lowest top edge, the narrower segment
+// wins if two positions are the same height.
+func (s *skyline) insert(w, h int) (x, y int, ok bool) {
+	best, bestY, bestW := -1, s.h, s.w+1
+	for i := range s.nodes {
+		if y, fit := s.fit(i, w, h); fit {
+			if y < bestY || (y == bestY && s.nodes[i].w < bestW) {
+				best, bestY, bestW = i, y, s.nodes[i].w
+			}
+		}
+	}
+	if best < 0 {
+		return
+	}
+	x, y, ok = s.nodes[best].x, bestY, true
+
+	// new segment, then shrink the segments under it
+	s.nodes = append(s.nodes, skyNode{})
+	copy(s.nodes[best+1:], s.nodes[best:])
+	s.nodes[best] = skyNode{x, y + h, w}
+	for i := best + 1; i < len(s.nodes); {
+		n, prev := &s.nodes[i], s.nodes[i-1]
+		if shrink := prev.x + prev.w - n.x; shrink > 0 {
+			n.x += shrink
+			if n.w -= shrink; n.w <= 0 {
+				s.nodes = append(s.nodes[:i], s.nodes[i+1:]...)
+				continue
+			}
+		}
+		break
+	}
+	// merge segments at the same height
+	for i := 0; i < len(s.nodes)-1; {
+		if s.nodes[i].y == s.nodes[i+1].y {
+			s.nodes[i].w += s.nodes[i+1].w
+			s.nodes = append(s.nodes[:i+1], s.nodes[i+2:]...)
+		} else {
+			i++
+		}
+	}
+	return
+}
+
+// fit returns the y of a w*h rectangle placed at the segment i.
+func (s *skyline) fit(i, w, h int) (y int, ok bool) {
+	x := s.nodes[i].x
+	if x+w > s.w {
+		return
+	}
+	for left := w; left > 0; i++ {
+		if s.nodes[i].y > y {
+			y = s.nodes[i].y
+		}
+		if y+h > s.h {
+			return
+		}
+		left -= s.nodes[i].w
+	}
+	return y, true
+}
@@ -0,0 +1,91 @@
+package gfx
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"sckorok/gfx/bk"
+)
+
+func TestSkyline(t *testing.T) {
+	sky := newSkyline(64, 64)
+	var rects []image.Rectangle
+	for i := 0; ; i++ {
+		w, h := 5+i%7*3, 4+i%5*4
+		x, y, ok := sky.insert(w, h)
+		if !ok {
+			break
+		}
+		r := image.Rect(x, y, x+w, y+h)
+		if !r.In(image.Rect(0, 0, 64, 64)) {
+			t.Fatal("rect out of page:", r)
+		}
+		for _, o := range rects {
+			if o.Overlaps(r) {
+				t.Fatal("rects overlap:", o, r)
+			}
+		}
+		rects = append(rects, r)
+	}
+	if len(rects) < 15 {
+		t.Error("too few rects packed:", len(rects))
+	}
+}
+
+func TestPacker(t *testing.T) {
+	bk.UseSoftRenderer(bk.NewSoftRenderer(4, 4))
+	bk.R.Init()
+	defer bk.UseSoftRenderer(nil)
+
+	p := NewPacker("test", 32, 32)
+	defer p.Dispose()
+
+	img := image.NewRGBA(image.Rect(0, 0, 7, 7))
+	img.Set(0, 0, color.RGBA{R: 0xFF, A: 0xFF})
+
+	a, err := p.Add("a", img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := p.Add("a", img); b != a {
+		t.Error("same name should be added once")
+	}
+	if sz := a.Size(); sz.Width != 7 || sz.Height != 7 {
+		t.Error("size error:", sz)
+	}
+	// extruded by 1 pixel
+	if rg := a.Region(); rg.X1 != 1.0/32 || rg.Y1 != 1.0/32 || rg.X2 != 8.0/32 {
+		t.Error("region error:", rg)
+	}
+
+	// 3x3 images (10x10 with extrusion and padding) fill a page, the rest go to the next page
+	var last SubTex
+	for i := 0; i < 9; i++ {
+		if last, err = p.Add("", img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.Pages() != 2 || last.Tex() == a.Tex() {
+		t.Error("images should be packed into a new page:", p.Pages())
+	}
+	if tex, ok := p.Get("a"); !ok || tex != a {
+		t.Error("fail to get sub-texture by name")
+	}
+	if _, err := p.Add("big", image.NewRGBA(image.Rect(0, 0, 40, 8))); err == nil {
+		t.Error("image larger than page should fail")
+	}
+}
+
+func TestExtrude(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{R: 0xFF, A: 0xFF})
+	img.Set(1, 1, color.RGBA{G: 0xFF, A: 0xFF})
+	dst := extrude(img, 2)
+	if c := dst.RGBAAt(0, 0); c.R != 0xFF {
+		t.Error("top-left corner should repeat:", c)
+	}
+	if c := dst.RGBAAt(5, 5); c.G != 0xFF {
+		t.Error("bottom-right corner should repeat:", c)
+	}
+}
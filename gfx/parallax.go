@@ -0,0 +1,328 @@
+package gfx
+
+import (
+	"sckorok/engi"
+	"sckorok/math"
+	"sckorok/math/f32"
+)
+
+// ParallaxComp binds the layer of entity, a sprite or a tile map, to the
+// camera. The position of layer is updated every frame:
+//
+//	position = origin + camera * (1 - factor) + speed * time
+//
+// A layer with factor 1 moves as the world, a layer with factor 0 stays on
+// the screen, the layers in between scroll slower than the world and look far
+// away. The Transform of layer should not have a parent.
+//
+// A repeated layer is drawn as many times as needed to cover the view, the
+// texture of sprite or the edges of tile map should be seamless.
+type ParallaxComp struct {
+	engi.Entity
+	origin f32.Vec2
+	factor f32.Vec2
+	speed  f32.Vec2
+
+	// auto-scrolled offset
+	scroll f32.Vec2
+
+	repeatX, repeatY bool
+	// size of a copy, zero to use the size of layer
+	size f32.Vec2
+
+	enabled bool
+
+	// copies to cover the view, updated every frame
+	nx, ny int
+	step   f32.Vec2
+}
+
+// SetOrigin sets the position of layer when the camera is at (0, 0).
+func (pc *ParallaxComp) SetOrigin(x, y float32) {
+	pc.origin = f32.Vec2{x, y}
+}
+
+func (pc *ParallaxComp) Origin() (x, y float32) {
+	return pc.origin[0], pc.origin[1]
+}
+
+// SetFactor sets the scroll factor of each axis.
+func (pc *ParallaxComp) SetFactor(x, y float32) {
+	pc.factor = f32.Vec2{x, y}
+}
+
+func (pc *ParallaxComp) Factor() (x, y float32) {
+	return pc.factor[0], pc.factor[1]
+}
+
+// SetSpeed sets the auto-scroll speed in pixels per second.
+func (pc *ParallaxComp) SetSpeed(x, y float32) {
+	pc.speed = f32.Vec2{x, y}
+}
+
+func (pc *ParallaxComp) Speed() (x, y float32) {
+	return pc.speed[0], pc.speed[1]
+}
+
+// ResetScroll moves the auto-scrolled layer back to the origin.
+func (pc *ParallaxComp) ResetScroll() {
+	pc.scroll = f32.Vec2{}
+}
+
+// SetRepeat repeats the layer infinitely on the axis.
+func (pc *ParallaxComp) SetRepeat(x, y bool) {
+	pc.repeatX, pc.repeatY = x, y
+}
+
+func (pc *ParallaxComp) Repeat() (x, y bool) {
+	return pc.repeatX, pc.repeatY
+}
+
+// SetSize sets the distance between the repeated copies, the default is the
+// size of sprite or tile map.
+func (pc *ParallaxComp) SetSize(w, h float32) {
+	pc.size = f32.Vec2{w, h}
+}
+
+func (pc *ParallaxComp) Size() (w, h float32) {
+	return pc.size[0], pc.size[1]
+}
+
+func (pc *ParallaxComp) SetEnabled(v bool) {
+	pc.enabled = v
+}
+
+func (pc *ParallaxComp) Enabled() bool {
+	return pc.enabled
+}
+
+// update places the layer, size and gravity are the size and pivot of layer.
+// The first copy of a repeated layer is placed at the left-bottom of view.
+func (pc *ParallaxComp) update(dt float32, camera *Camera, xf *Transform, size, gravity f32.Vec2) {
+	pc.scroll = pc.scroll.Add(pc.speed.Mul(dt))
+
+	cx, cy := camera.Position()
+	p := f32.Vec2{
+		pc.origin[0] + cx*(1-pc.factor[0]) + pc.scroll[0],
+		pc.origin[1] + cy*(1-pc.factor[1]) + pc.scroll[1],
+	}
+	if pc.size[0] > 0 && pc.size[1] > 0 {
+		size = pc.size
+	}
+	scale := xf.world.Scale
+	pc.step = f32.Vec2{size[0] * math.ABS(scale[0]), size[1] * math.ABS(scale[1])}
+	pc.nx, pc.ny = 1, 1
+
+	left, right, bottom, top := camera.P()
+	if w := pc.step[0]; pc.repeatX && w > 0 {
+		p[0], pc.nx = parallaxWrap(p[0]-w*gravity[0], w, left, right)
+		p[0] += w * gravity[0]
+	}
+	if h := pc.step[1]; pc.repeatY && h > 0 {
+		p[1], pc.ny = parallaxWrap(p[1]-h*gravity[1], h, bottom, top)
+		p[1] += h * gravity[1]
+	}
+	xf.SetPosition(p)
+}
+
+// parallaxWrap moves the edge of layer to the first copy at or before min,
+// returns the edge and copies to cover [min, max].
+func parallaxWrap(edge, size, min, max float32) (float32, int) {
+	edge += math.Floor((min-edge)/size) * size
+	n := int(math.Ceil((max - edge) / size))
+	if n < 1 {
+		n = 1
+	}
+	return edge, n
+}
+
+type ParallaxTable struct {
+	comps      []ParallaxComp
+	_map       map[uint32]int
+	index, cap int
+}
+
+func NewParallaxTable(cap int) *ParallaxTable {
+	return &ParallaxTable{
+		cap:  cap,
+		_map: make(map[uint32]int),
+	}
+}
+
+func (pt *ParallaxTable) NewComp(entity engi.Entity) (pc *ParallaxComp) {
+	if size := len(pt.comps); pt.index >= size {
+		pt.comps = parallaxResize(pt.comps, size+STEP)
+	}
+	ei := entity.Index()
+	if v, ok := pt._map[ei]; ok {
+		pc = &pt.comps[v]
+		return
+	}
+	pc = &pt.comps[pt.index]
+	pc.Entity = entity
+	pc.factor = f32.Vec2{1, 1}
+	pc.nx, pc.ny = 1, 1
+	pc.enabled = true
+	pt._map[ei] = pt.index
+	pt.index++
+	return
+}
+
+func (pt *ParallaxTable) Alive(entity engi.Entity) bool {
+	ei := entity.Index()
+	if v, ok := pt._map[ei]; ok {
+		return pt.comps[v].Entity != 0
+	}
+	return false
+}
+
+func (pt *ParallaxTable) Comp(entity engi.Entity) (pc *ParallaxComp) {
+	ei := entity.Index()
+	if v, ok := pt._map[ei]; ok {
+		pc = &pt.comps[v]
+	}
+	return
+}
+
+func (pt *ParallaxTable) Delete(entity engi.Entity) {
+	ei := entity.Index()
+	if v, ok := pt._map[ei]; ok {
+		if tail := pt.index - 1; v != tail && tail > 0 {
+			pt.comps[v] = pt.comps[tail]
+			// remap index
+			tComp := pt.comps[tail]
+			ei := tComp.Entity.Index()
+			pt._map[ei] = v
+			pt.comps[tail] = ParallaxComp{}
+		} else {
+			pt.comps[tail] = ParallaxComp{}
+		}
+
+		pt.index -= 1
+		delete(pt._map, ei)
+	}
+}
+
+func (pt *ParallaxTable) Size() (size, cap int) {
+	return pt.index, pt.cap
+}
+
+func (pt *ParallaxTable) Destroy() {
+	pt.comps = make([]ParallaxComp, 0)
+	pt._map = make(map[uint32]int)
+	pt.index = 0
+}
+
+// Copies returns the copies of layer to cover the view and the distance
+// between them, it's (1, 1) if the entity is not a repeated layer.
+// It's safe to call with a nil table.
+func (pt *ParallaxTable) Copies(entity engi.Entity) (nx, ny int, step f32.Vec2) {
+	if pt != nil {
+		if v, ok := pt._map[entity.Index()]; ok && pt.comps[v].enabled {
+			pc := &pt.comps[v]
+			return pc.nx, pc.ny, pc.step
+		}
+	}
+	return 1, 1, step
+}
+
+func parallaxResize(slice []ParallaxComp, size int) []ParallaxComp {
+	newSlice := make([]ParallaxComp, size)
+	copy(newSlice, slice)
+	return newSlice
+}
+
+// ParallaxRenderFeature updates the parallax layers before they are drawn by
+// the sprite and tile map features, so the layers move with the camera in
+// the same frame.
+type ParallaxRenderFeature struct {
+	id     int
+	camera *Camera
+
+	pt  *ParallaxTable
+	xt  *TransformTable
+	st  *SpriteTable
+	tmt *TileMapTable
+}
+
+func (f *ParallaxRenderFeature) SetTable(pt *ParallaxTable, xt *TransformTable) {
+	f.pt, f.xt = pt, xt
+}
+
+// 此处初始化所有的依赖
+func (f *ParallaxRenderFeature) Register(rs *RenderSystem) {
+	f.camera = &rs.MainCamera
+	// init table
+	for _, t := range rs.TableList {
+		switch table := t.(type) {
+		case *ParallaxTable:
+			f.pt = table
+		case *TransformTable:
+			f.xt = table
+		case *SpriteTable:
+			f.st = table
+		case *TileMapTable:
+			f.tmt = table
+		}
+	}
+	// add new feature
+	f.id = rs.Accept(f)
+}
+
+// Update places the layers with the camera.
+func (f *ParallaxRenderFeature) Update(dt float32) {
+	for i := range f.pt.comps[:f.pt.index] {
+		pc := &f.pt.comps[i]
+		xf := f.xt.Comp(pc.Entity)
+		if !pc.enabled || xf == nil {
+			continue
+		}
+		var size, gravity f32.Vec2
+		if sc := f.spriteComp(pc.Entity); sc != nil {
+			size, gravity = f32.Vec2{sc.width, sc.height}, f32.Vec2{sc.gravity.x, sc.gravity.y}
+		} else if tc := f.tileMapComp(pc.Entity); tc != nil {
+			size[0], size[1] = tc.Size()
+		}
+		pc.update(dt, f.camera, xf, size, gravity)
+	}
+}
+
+func (f *ParallaxRenderFeature) spriteComp(entity engi.Entity) *SpriteComp {
+	if f.st != nil {
+		return f.st.Comp(entity)
+	}
+	return nil
+}
+
+func (f *ParallaxRenderFeature) tileMapComp(entity engi.Entity) *TileMapComp {
+	if f.tmt != nil {
+		return f.tmt.Comp(entity)
+	}
+	return nil
+}
+
+func (f *ParallaxRenderFeature) Extract(v *View) {
+
+}
+
+func (f *ParallaxRenderFeature) Draw(nodes RenderNodes) {
+
+}
+
+func (f *ParallaxRenderFeature) Flush() {
+
+}
+
+// repeatBatchObject draws a BatchObject at an offset.
+type repeatBatchObject struct {
+	BatchObject
+	dx, dy float32
+}
+
+func (rbo repeatBatchObject) Fill(buf []PosTexColorVertex) {
+	rbo.BatchObject.Fill(buf)
+	for i := range buf {
+		buf[i].X += rbo.dx
+		buf[i].Y += rbo.dy
+	}
+}
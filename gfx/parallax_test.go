@@ -0,0 +1,62 @@
+package gfx
+
+import (
+	"testing"
+
+	"sckorok/engi"
+	"sckorok/math/f32"
+)
+
+func TestParallaxUpdate(t *testing.T) {
+	em := engi.NewEntityManager()
+	xt := NewTransformTable(4)
+	st := NewSpriteTable(4)
+	pt := NewParallaxTable(4)
+
+	camera := &Camera{}
+	camera.initialize()
+	camera.SetViewPort(320, 480)
+	camera.MoveTo(1000, 240)
+
+	// a 100x50 sprite centered at the position
+	e := em.New()
+	xf := xt.NewComp(e)
+	st.NewComp(e).SetSize(100, 50)
+	pc := pt.NewComp(e)
+	pc.SetFactor(.5, 0)
+	pc.SetRepeat(true, false)
+
+	f := &ParallaxRenderFeature{camera: camera, st: st}
+	f.SetTable(pt, xt)
+	f.Update(0)
+
+	// view [840, 1160], layer at 500, copies from 800
+	if p := xf.Position(); p != (f32.Vec2{800, 240}) {
+		t.Error("layer should scroll with the factor:", p)
+	}
+	if nx, ny, step := pt.Copies(e); nx != 5 || ny != 1 || step != (f32.Vec2{100, 50}) {
+		t.Error("copies should cover the view:", nx, ny, step)
+	}
+
+	// zoom out, view [680, 1320], copies from 650
+	camera.ScaleTo(2, 2)
+	f.Update(0)
+	if nx, _, _ := pt.Copies(e); nx != 7 || xf.Position()[0] != 700 {
+		t.Error("copies should cover the zoomed view:", nx, xf.Position())
+	}
+
+	// auto-scroll, layer at 450, copies from 600
+	pc.SetSpeed(-50, 0)
+	f.Update(1)
+	if nx, _, _ := pt.Copies(e); nx != 8 || xf.Position()[0] != 650 {
+		t.Error("auto-scroll error:", nx, xf.Position())
+	}
+
+	// not a repeated layer
+	if nx, ny, _ := pt.Copies(em.New()); nx != 1 || ny != 1 {
+		t.Error("entity without parallax should be drawn once:", nx, ny)
+	}
+	if nx, ny, _ := (*ParallaxTable)(nil).Copies(e); nx != 1 || ny != 1 {
+		t.Error("nil table should be safe:", nx, ny)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"sckorok/gfx/dbg"
 	"sckorok/math/f32"
 	"sort"
+	"time"
 )
 
 type RenderType int32
@@ -48,6 +49,31 @@ type RenderFeature interface {
 	Flush()
 }
 
+// Updater is implemented by the RenderFeature has animations, it's updated
+// before extraction.
+type Updater interface {
+	Update(dt float32)
+}
+
+// PreDrawer is implemented by the RenderFeature draws before the sorted nodes,
+// e.g. the stencil masks.
+type PreDrawer interface {
+	PreDraw()
+}
+
+// NormalDrawer is implemented by the RenderFeature draws its lit nodes into the
+// normal buffer of LightRenderFeature, with the material and render target
+// given. The lit nodes of other features are flat.
+type NormalDrawer interface {
+	DrawNormals(nodes RenderNodes, material, target uint16)
+}
+
+// LightStats is implemented by the RenderFeature drops the lights or occluder
+// edges over its limits, the numbers of last frame are in RenderStats.
+type LightStats interface {
+	Dropped() (lights, edges int)
+}
+
 // 所有的Table和Render都在此管理
 // 其它的 RenderFeature 在此提取依赖
 // 这样的话， RenderSystem 就沦为一个管理 RenderFeature 和 Table 的地方
@@ -70,6 +96,9 @@ type RenderSystem struct {
 
 	// feature knows how to use render-data and render
 	FeatureList []RenderFeature
+
+	// statistics of last frame
+	stats RenderStats
 }
 
 func (th *RenderSystem) RequireTable(tables []interface{}) {
@@ -113,6 +142,15 @@ func (th *RenderSystem) Update(dt float32) {
 
 	// build view
 	v := th.View
+	stats := &th.stats
+	stats.reset(th.FeatureList)
+
+	// animate
+	for _, f := range th.FeatureList {
+		if u, ok := f.(Updater); ok {
+			u.Update(dt)
+		}
+	}
 
 	// extract
 	for _, f := range th.FeatureList {
@@ -126,7 +164,17 @@ func (th *RenderSystem) Update(dt float32) {
 	//sort.Slice(nodes, func(i, j int) bool {
 	//	return nodes[i].SortId < nodes[j].SortId
 	//})
+	start := time.Now()
 	sort.Stable(nodes)
+	stats.SortTime = time.Since(start)
+	stats.Nodes = n
+
+	// pre-draw
+	for i, f := range th.FeatureList {
+		if p, ok := f.(PreDrawer); ok {
+			stats.measure(i, p.PreDraw)
+		}
+	}
 
 	// draw
 	for i, j := 0, 0; i < n; i = j {
@@ -135,19 +183,47 @@ func (th *RenderSystem) Update(dt float32) {
 		for j < n && nodes[j].Value>>16 == fi {
 			j++
 		}
-		f := th.FeatureList[fi]
-		f.Draw(v.RenderNodes[i:j])
+		f, group := th.FeatureList[fi], v.RenderNodes[i:j]
+		stats.measure(int(fi), func() { f.Draw(group) })
+		stats.Features[fi].Nodes += j - i
 	}
 
 	// flush, release any resource
+	for i, f := range th.FeatureList {
+		stats.measure(i, f.Flush)
+	}
+
+	// dropped lights
 	for _, f := range th.FeatureList {
-		f.Flush()
+		if ls, ok := f.(LightStats); ok {
+			lights, edges := ls.Dropped()
+			stats.DroppedLights += lights
+			stats.DroppedEdges += edges
+		}
+	}
+
+	// batch breaks
+	for _, r := range th.RenderList {
+		if br, ok := r.(*BatchRender); ok {
+			for c, n := range br.breaks {
+				stats.Breaks[c] += n
+			}
+			br.breaks = [BreakCauses]int{}
+		}
+	}
+	if (dbg.DEBUG & dbg.Inspector) != 0 {
+		stats.inspect()
 	}
 
 	// view reset
 	th.View.RenderNodes = th.View.RenderNodes[:0]
 }
 
+// Stats returns the statistics of the last frame.
+func (th *RenderSystem) Stats() *RenderStats {
+	return &th.stats
+}
+
 func (th *RenderSystem) Destroy() {
 
 }
@@ -2,6 +2,7 @@ package gfx
 
 import (
 	"sckorok/engi"
+	"sckorok/gfx/bk"
 	"sckorok/math/f32"
 )
 
@@ -15,6 +16,7 @@ type SpriteComp struct {
 	Sprite
 	zOrder
 	batchId
+	materialId
 
 	color uint32
 	flipX uint16
@@ -26,15 +28,40 @@ type SpriteComp struct {
 		x, y float32
 	}
 	visible bool
+
+	// nine-slice mode, nil if disabled
+	slice *NineSlice
+
+	// trim of packed sprite, zero if not trimmed
+	trim Trim
+
+	// normal map for lighting, nil if not set
+	normal Tex2D
+
+	// vertex deformation, nil if not deformed
+	deform *spriteDeform
 }
 
+// SetSprite sets the sprite, a trimmed sprite uses the original size and the
+// pivot of a packed sprite replaces the gravity.
 func (sc *SpriteComp) SetSprite(spt Sprite) {
 	sc.Sprite = spt
 	sc.batchId.value = spt.Tex()
 
+	size := spt.Size()
+	sc.trim = Trim{}
+	if p, ok := spt.(Packed); ok {
+		if trim, ok := p.Trim(); ok {
+			sc.trim = trim
+			size = trim.Source
+		}
+		if x, y, ok := p.Pivot(); ok {
+			sc.gravity.x, sc.gravity.y = x, y
+		}
+	}
+
 	// optional size
 	if sc.width == 0 || sc.height == 0 {
-		size := spt.Size()
 		sc.width = size.Width
 		sc.height = size.Height
 	}
@@ -58,6 +85,17 @@ func (sc *SpriteComp) Gravity() (x, y float32) {
 	return sc.gravity.x, sc.gravity.y
 }
 
+// SetNormalMap sets the normal map of a sprite using the lit material, it
+// should have the same layout as the texture of sprite. The normals are in the
+// tangent space of an unrotated sprite.
+func (sc *SpriteComp) SetNormalMap(normal Tex2D) {
+	sc.normal = normal
+}
+
+func (sc *SpriteComp) NormalMap() Tex2D {
+	return sc.normal
+}
+
 func (sc *SpriteComp) SetVisible(v bool) {
 	sc.visible = v
 }
@@ -185,6 +223,12 @@ type SpriteRenderFeature struct {
 	R  *BatchRender
 	st *SpriteTable
 	xt *TransformTable
+	mt *MaskTable
+	pt *ParallaxTable
+	et *ColorEffectTable
+
+	// reused by nine-slice sprites
+	slice sliceBatchObject
 }
 
 func (f *SpriteRenderFeature) SetRender(render *BatchRender) {
@@ -216,6 +260,12 @@ func (f *SpriteRenderFeature) Register(rs *RenderSystem) {
 			f.st = table
 		case *TransformTable:
 			f.xt = table
+		case *MaskTable:
+			f.mt = table
+		case *ParallaxTable:
+			f.pt = table
+		case *ColorEffectTable:
+			f.et = table
 		}
 	}
 	// add new feature, use the index as id
@@ -234,7 +284,12 @@ func (f *SpriteRenderFeature) Extract(v *View) {
 		g := f32.Vec2{spr.gravity.x, spr.gravity.y}
 
 		if spr.visible && camera.InView(xf, sz, g) {
-			sid := PackSortId(spr.zOrder.value, spr.batchId.value)
+			mat := spr.materialId.value
+			// color effect replaces the default material
+			if ec, m := f.et.Effect(spr.Entity); ec != nil && mat == 0 {
+				mat = m
+			}
+			sid := spr.zOrder.sortId(xf.world.Position[1], mat, spr.batchId.value)
 			val := fi + uint32(i)
 			v.RenderNodes = append(v.RenderNodes, SortObject{sid, val})
 		}
@@ -243,29 +298,73 @@ func (f *SpriteRenderFeature) Extract(v *View) {
 
 func (f *SpriteRenderFeature) Draw(nodes RenderNodes) {
 	var (
-		st, xt = f.st, f.xt
-		sortId = uint32(0xFFFFFFFF)
-		begin  = false
-		render = f.R
+		st      = f.st
+		sortId  = uint64(0xFFFFFFFFFFFFFFFF)
+		stencil = uint32(0)
+		begin   = false
+		render  = f.R
 	)
 
 	// batch draw!
-	var spriteBatchObject = spriteBatchObject{}
 	for _, b := range nodes {
 		ii := b.Value & 0xFFFF
-		if sid := b.SortId & 0xFFFF; sortId != sid {
+		// split batch on material, texture or mask changes
+		sten := f.mt.Stencil(st.comps[ii].Entity)
+		if sid := b.SortId & 0xFFFFFFFF; sortId != sid || stencil != sten {
 			if begin {
 				render.End()
 			}
-			sortId = sid
+			sortId, stencil = sid, sten
 			begin = true
 			tex2d := st.comps[ii].Sprite.Tex()
 			depth, _ := UnpackSortId(b.SortId)
-			render.Begin(tex2d, depth)
+			render.SetStencil(stencil)
+			render.BeginMaterial(UnpackMaterial(b.SortId), tex2d, depth)
+		}
+		// color effect, the default material is replaced in Extract
+		var effect *effectVertex
+		if ec, _ := f.et.Effect(st.comps[ii].Entity); ec != nil && st.comps[ii].materialId.value == 0 {
+			v := ec.vertex(st.comps[ii].Sprite)
+			effect = &v
+		}
+		f.drawSprite(&st.comps[ii], effect)
+	}
+	if begin {
+		render.End()
+	}
+	render.Flush()
+}
+
+// DrawNormals draws the normal maps of lit sprites into the normal buffer of
+// LightRenderFeature, the sprites without normal map are flat.
+func (f *SpriteRenderFeature) DrawNormals(nodes RenderNodes, material, target uint16) {
+	var (
+		st     = f.st
+		tex    = bk.InvalidId
+		normal = bk.InvalidId
+		begin  = false
+		render = f.R
+	)
+	for _, b := range nodes {
+		sc := &st.comps[b.Value&0xFFFF]
+		nm := bk.InvalidId
+		if sc.normal != nil {
+			nm = sc.normal.Tex()
+		}
+		if t := sc.Sprite.Tex(); !begin || tex != t || normal != nm {
+			if begin {
+				render.End()
+			}
+			if render.batchUsed == len(render.BatchList) {
+				render.Flush()
+			}
+			tex, normal = t, nm
+			begin = true
+			render.SetRenderTarget(target)
+			render.SetNormalMap(normal)
+			render.BeginMaterial(material, tex, 0)
 		}
-		spriteBatchObject.SpriteComp = &st.comps[ii]
-		spriteBatchObject.Transform = xt.Comp(st.comps[ii].Entity)
-		render.Draw(spriteBatchObject)
+		f.drawSprite(sc, nil)
 	}
 	if begin {
 		render.End()
@@ -273,6 +372,34 @@ func (f *SpriteRenderFeature) Draw(nodes RenderNodes) {
 	render.Flush()
 }
 
+// drawSprite draws the sprite into current batch, a repeated parallax layer
+// is drawn as copies.
+func (f *SpriteRenderFeature) drawSprite(sc *SpriteComp, effect *effectVertex) {
+	var (
+		obj    BatchObject
+		xf     = f.xt.Comp(sc.Entity)
+		render = f.R
+	)
+	if sc.slice != nil {
+		f.slice.build(sc, xf)
+		obj = &f.slice
+	} else if d := sc.deform; d != nil && d.grid != nil {
+		obj = gridBatchObject{sc, xf}
+	} else {
+		obj = spriteBatchObject{sc, xf}
+	}
+	// repeated parallax layer
+	if nx, ny, step := f.pt.Copies(sc.Entity); nx*ny > 1 {
+		for y := 0; y < ny; y++ {
+			for x := 0; x < nx; x++ {
+				render.Draw(withEffect(repeatBatchObject{obj, float32(x) * step[0], float32(y) * step[1]}, effect))
+			}
+		}
+	} else {
+		render.Draw(withEffect(obj, effect))
+	}
+}
+
 func (f *SpriteRenderFeature) Flush() {
 
 }
@@ -346,12 +473,41 @@ func (sbo spriteBatchObject) Fill(buf []PosTexColorVertex) {
 	m := f32.Mat3{}
 	m.Initialize(p[0], p[1], srt.Rotation, srt.Scale[0], srt.Scale[1], ox, oy, 0, 0)
 
+	x0, y0, x1, y1 := c.rect()
+
 	// Let's go!
-	buf[0].X, buf[0].Y = m.Transform(0, 0)
-	buf[1].X, buf[1].Y = m.Transform(w, 0)
-	buf[2].X, buf[2].Y = m.Transform(w, h)
-	buf[3].X, buf[3].Y = m.Transform(0, h)
+	if d := c.deform; d != nil {
+		buf[0].X, buf[0].Y = m.Transform(d.apply(x0, y0, 0, 0, ox, oy))
+		buf[1].X, buf[1].Y = m.Transform(d.apply(x1, y0, 1, 0, ox, oy))
+		buf[2].X, buf[2].Y = m.Transform(d.apply(x1, y1, 1, 1, ox, oy))
+		buf[3].X, buf[3].Y = m.Transform(d.apply(x0, y1, 0, 1, ox, oy))
+		return
+	}
+	buf[0].X, buf[0].Y = m.Transform(x0, y0)
+	buf[1].X, buf[1].Y = m.Transform(x1, y0)
+	buf[2].X, buf[2].Y = m.Transform(x1, y1)
+	buf[3].X, buf[3].Y = m.Transform(x0, y1)
+
+}
 
+// rect returns the local rectangle of sprite, a trimmed sprite covers the
+// packed image in the original frame.
+func (sc *SpriteComp) rect() (x0, y0, x1, y1 float32) {
+	w, h := sc.width, sc.height
+	x0, y0, x1, y1 = 0, 0, w, h
+	if t := sc.trim; t.Source.Width > 0 && t.Source.Height > 0 {
+		sz := sc.Sprite.Size()
+		sx, sy := w/t.Source.Width, h/t.Source.Height
+		x0, y0 = t.X*sx, (t.Source.Height-t.Y-sz.Height)*sy
+		x1, y1 = x0+sz.Width*sx, y0+sz.Height*sy
+		if sc.flipX == 1 {
+			x0, x1 = w-x1, w-x0
+		}
+		if sc.flipY == 1 {
+			y0, y1 = h-y1, h-y0
+		}
+	}
+	return
 }
 
 func (sbo spriteBatchObject) Size() int {
@@ -0,0 +1,183 @@
+package gfx
+
+import (
+	"sckorok/math"
+	"sckorok/math/f32"
+)
+
+// max cells of the grid mode in one dimension
+const maxDeformGrid = 32
+
+// spriteDeform is the vertex-level deformation of a sprite. The offsets are in
+// the local space of sprite (in the unit of size, before the transform), so
+// they scale and rotate with the Entity. The nine-slice mode ignores the
+// deformation, and the culling uses the undeformed size.
+type spriteDeform struct {
+	// skew angles and the tangents
+	skew, tan f32.Vec2
+
+	// offsets of corners in the batch winding order
+	corners [4]f32.Vec2
+
+	// grid mode, the offsets of points in row major order from the
+	// bottom-left corner, nil if disabled
+	cols, rows int
+	grid       []f32.Vec2
+}
+
+func (sc *SpriteComp) deformation() *spriteDeform {
+	if sc.deform == nil {
+		sc.deform = &spriteDeform{}
+	}
+	return sc.deform
+}
+
+// SetSkew skews the sprite around the gravity, the angles are in radians.
+// Skew x shears the sprite horizontally, skew y shears it vertically.
+func (sc *SpriteComp) SetSkew(x, y float32) {
+	d := sc.deformation()
+	d.skew = f32.Vec2{x, y}
+	d.tan = f32.Vec2{math.Sin(x) / math.Cos(x), math.Sin(y) / math.Cos(y)}
+}
+
+func (sc *SpriteComp) Skew() (x, y float32) {
+	if d := sc.deform; d != nil {
+		x, y = d.skew[0], d.skew[1]
+	}
+	return
+}
+
+// SetCornerOffset moves a corner of the sprite, the corners are in the batch
+// winding order: 0 bottom-left, 1 bottom-right, 2 top-right and 3 top-left.
+// In the grid mode, the points between corners are interpolated.
+func (sc *SpriteComp) SetCornerOffset(corner int, offset f32.Vec2) {
+	if corner >= 0 && corner < 4 {
+		sc.deformation().corners[corner] = offset
+	}
+}
+
+func (sc *SpriteComp) CornerOffset(corner int) (offset f32.Vec2) {
+	if d := sc.deform; d != nil && corner >= 0 && corner < 4 {
+		offset = d.corners[corner]
+	}
+	return
+}
+
+// SetGrid subdivides the sprite into cols x rows cells, the points of grid can
+// be moved by SetGridOffset. The offsets are cleared, zero size disables the
+// grid mode. The grid has 32 x 32 cells at most.
+func (sc *SpriteComp) SetGrid(cols, rows int) {
+	d := sc.deformation()
+	if cols <= 0 || rows <= 0 {
+		d.cols, d.rows, d.grid = 0, 0, nil
+		return
+	}
+	if cols > maxDeformGrid {
+		cols = maxDeformGrid
+	}
+	if rows > maxDeformGrid {
+		rows = maxDeformGrid
+	}
+	d.cols, d.rows = cols, rows
+	d.grid = make([]f32.Vec2, (cols+1)*(rows+1))
+}
+
+func (sc *SpriteComp) Grid() (cols, rows int) {
+	if d := sc.deform; d != nil {
+		cols, rows = d.cols, d.rows
+	}
+	return
+}
+
+// SetGridOffset moves the point of column i and row j, (0, 0) is the
+// bottom-left corner.
+func (sc *SpriteComp) SetGridOffset(i, j int, offset f32.Vec2) {
+	if d := sc.deform; d != nil && i >= 0 && i <= d.cols && j >= 0 && j <= d.rows {
+		d.grid[j*(d.cols+1)+i] = offset
+	}
+}
+
+func (sc *SpriteComp) GridOffset(i, j int) (offset f32.Vec2) {
+	if d := sc.deform; d != nil && i >= 0 && i <= d.cols && j >= 0 && j <= d.rows {
+		offset = d.grid[j*(d.cols+1)+i]
+	}
+	return
+}
+
+// GridOffsets returns the offsets of all points, the point of column i and
+// row j is at j*(cols+1)+i. Scripts can deform the grid in place.
+func (sc *SpriteComp) GridOffsets() []f32.Vec2 {
+	if d := sc.deform; d != nil {
+		return d.grid
+	}
+	return nil
+}
+
+// ClearDeform removes the skew, corner offsets and grid of the sprite.
+func (sc *SpriteComp) ClearDeform() {
+	sc.deform = nil
+}
+
+// apply deforms the local point (x, y), (s, t) is the normalized position in
+// the sprite and (ox, oy) is the center of skew.
+func (d *spriteDeform) apply(x, y, s, t, ox, oy float32) (float32, float32) {
+	c := &d.corners
+	bottom := c[0].Mul(1 - s).Add(c[1].Mul(s))
+	top := c[3].Mul(1 - s).Add(c[2].Mul(s))
+	off := bottom.Mul(1 - t).Add(top.Mul(t))
+	x, y = x+off[0], y+off[1]
+	return x + (y-oy)*d.tan[0], y + (x-ox)*d.tan[1]
+}
+
+// gridBatchObject fills a quad for each cell of the grid mode.
+type gridBatchObject struct {
+	*SpriteComp
+	*Transform
+}
+
+func (gbo gridBatchObject) Fill(buf []PosTexColorVertex) {
+	var (
+		srt  = gbo.Transform.world
+		p    = srt.Position
+		c    = gbo.SpriteComp
+		d    = c.deform
+		cols = d.cols
+	)
+	ox, oy := c.width*c.gravity.x, c.height*c.gravity.y
+	m := f32.Mat3{}
+	m.Initialize(p[0], p[1], srt.Rotation, srt.Scale[0], srt.Scale[1], ox, oy, 0, 0)
+
+	x0, y0, x1, y1 := c.rect()
+	rg := c.Sprite.Region()
+	point := func(i, j int) (v PosTexColorVertex) {
+		s, t := float32(i)/float32(cols), float32(j)/float32(d.rows)
+		x, y := x0+(x1-x0)*s, y0+(y1-y0)*t
+		off := d.grid[j*(cols+1)+i]
+		x, y = d.apply(x+off[0], y+off[1], s, t, ox, oy)
+		v.X, v.Y = m.Transform(x, y)
+
+		// texture follows the flipping
+		if c.flipX == 1 {
+			s = 1 - s
+		}
+		if c.flipY == 1 {
+			t = 1 - t
+		}
+		v.U, v.V = regionUV(rg, s, t)
+		v.RGBA = c.color
+		return
+	}
+	for j := 0; j < d.rows; j++ {
+		for i := 0; i < cols; i++ {
+			quad := buf[(j*cols+i)*4:]
+			quad[0] = point(i, j)
+			quad[1] = point(i+1, j)
+			quad[2] = point(i+1, j+1)
+			quad[3] = point(i, j+1)
+		}
+	}
+}
+
+func (gbo gridBatchObject) Size() int {
+	return gbo.deform.cols * gbo.deform.rows * 4
+}
@@ -0,0 +1,65 @@
+package gfx
+
+import (
+	"testing"
+
+	"sckorok/math"
+	"sckorok/math/f32"
+)
+
+func TestSpriteDeform(t *testing.T) {
+	sc := &SpriteComp{Sprite: packedTex{}, color: 0xFFFFFFFF, width: 10, height: 10}
+	xf := &Transform{world: SRT{Scale: f32.Vec2{1, 1}}}
+	buf := make([]PosTexColorVertex, 4)
+	near := func(v PosTexColorVertex, x, y float32) bool {
+		return math.ABS(v.X-x) < 1e-4 && math.ABS(v.Y-y) < 1e-4
+	}
+
+	// skew around the gravity
+	sc.SetSkew(math.Pi/4, 0)
+	spriteBatchObject{sc, xf}.Fill(buf)
+	if !near(buf[0], 0, 0) || !near(buf[3], 10, 10) || !near(buf[2], 20, 10) {
+		t.Error("sprite should be skewed:", buf)
+	}
+
+	// corner offset
+	sc.SetSkew(0, 0)
+	sc.SetCornerOffset(2, f32.Vec2{1, 2})
+	spriteBatchObject{sc, xf}.Fill(buf)
+	if !near(buf[2], 11, 12) || !near(buf[1], 10, 0) {
+		t.Error("corner should be moved:", buf)
+	}
+	if sc.CornerOffset(2) != (f32.Vec2{1, 2}) || sc.CornerOffset(4) != (f32.Vec2{}) {
+		t.Error("corner offset error")
+	}
+
+	// grid mode interpolates the corners
+	sc.SetGrid(2, 2)
+	sc.SetGridOffset(1, 1, f32.Vec2{0, 3})
+	if cols, rows := sc.Grid(); cols != 2 || rows != 2 || len(sc.GridOffsets()) != 9 {
+		t.Fatal("grid size error:", cols, rows)
+	}
+	obj := gridBatchObject{sc, xf}
+	buf = make([]PosTexColorVertex, obj.Size())
+	obj.Fill(buf)
+	if len(buf) != 16 || !near(buf[2], 5.25, 8.5) || buf[2] != buf[12] {
+		t.Error("grid point should be moved:", buf[2], buf[12])
+	}
+	if buf[2].U != .5 || buf[2].V != .5 || buf[0].U != 0 || buf[0].V != 1 {
+		t.Error("grid texture error:", buf[0], buf[2])
+	}
+	sc.Flip(true, false)
+	obj.Fill(buf)
+	if buf[0].U != 1 || buf[5].U != 0 {
+		t.Error("grid texture should be flipped:", buf[0], buf[5])
+	}
+
+	sc.SetGrid(0, 0)
+	if sc.GridOffsets() != nil {
+		t.Error("zero size should disable the grid")
+	}
+	sc.ClearDeform()
+	if x, y := sc.Skew(); x != 0 || y != 0 || sc.deform != nil {
+		t.Error("deformation should be cleared")
+	}
+}
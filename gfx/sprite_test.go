@@ -0,0 +1,48 @@
+package gfx
+
+import (
+	"testing"
+
+	"sckorok/math/f32"
+)
+
+// packedTex is a 4x6 image trimmed from a 10x12 frame
+type packedTex struct{}
+
+func (packedTex) Tex() uint16    { return 1 }
+func (packedTex) Region() Region { return Region{X1: 0, Y1: 0, X2: 1, Y2: 1} }
+func (packedTex) Size() Size     { return Size{4, 6} }
+
+func (packedTex) Trim() (Trim, bool) {
+	return Trim{X: 2, Y: 1, Source: Size{10, 12}}, true
+}
+
+func (packedTex) Pivot() (x, y float32, ok bool) {
+	return 0, 0, true
+}
+
+func TestSpriteTrim(t *testing.T) {
+	sc := &SpriteComp{}
+	sc.SetSprite(packedTex{})
+	if sc.width != 10 || sc.height != 12 {
+		t.Fatal("trimmed sprite should use the original size:", sc.width, sc.height)
+	}
+	if sc.gravity.x != 0 || sc.gravity.y != 0 {
+		t.Fatal("pivot should replace gravity:", sc.gravity)
+	}
+
+	xf := &Transform{world: SRT{Scale: f32.Vec2{1, 1}}}
+	buf := make([]PosTexColorVertex, 4)
+	spriteBatchObject{sc, xf}.Fill(buf)
+
+	// y is from top: 12 - 1 - 6 = 5
+	if buf[0].X != 2 || buf[0].Y != 5 || buf[2].X != 6 || buf[2].Y != 11 {
+		t.Error("quad should cover the trimmed image:", buf)
+	}
+
+	sc.Flip(true, false)
+	spriteBatchObject{sc, xf}.Fill(buf)
+	if buf[0].X != 4 || buf[2].X != 8 {
+		t.Error("trimmed image should be mirrored:", buf)
+	}
+}
@@ -0,0 +1,149 @@
+package gfx
+
+import (
+	"sckorok/gfx/bk"
+	"sckorok/gfx/dbg"
+
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BreakCause is the reason that a batch is split from the previous one.
+type BreakCause uint8
+
+const (
+	BreakTexture BreakCause = iota
+	BreakMaterial
+	BreakZOrder
+	BreakMask
+	BreakNormalMap
+	// the vertex buffer of batch is full
+	BreakBuffer
+	// same state, split by the feature, e.g. sorting layers
+	BreakOther
+
+	BreakCauses
+)
+
+var breakNames = [BreakCauses]string{"texture", "material", "z-order", "mask", "normal", "buffer", "other"}
+
+func (c BreakCause) String() string {
+	if c < BreakCauses {
+		return breakNames[c]
+	}
+	return "unknown"
+}
+
+// breakCause compares the state of a new batch with the previous batch.
+func breakCause(prev *Batch, mat, tex uint16, depth int16, stencil uint32, normal uint16) BreakCause {
+	switch {
+	case prev.TextureId != tex:
+		return BreakTexture
+	case prev.material != mat:
+		return BreakMaterial
+	case prev.depth != depth:
+		return BreakZOrder
+	case prev.stencil != stencil:
+		return BreakMask
+	case prev.normal != normal:
+		return BreakNormalMap
+	}
+	return BreakOther
+}
+
+// RenderStats is the statistics of a frame drawn by the RenderSystem.
+type RenderStats struct {
+	// render nodes extracted by the features
+	Nodes int
+	// statistics of each feature, in the order of FeatureList
+	Features []FeatureStats
+	// batch breaks of BatchRender by cause
+	Breaks [BreakCauses]int
+	// time to sort the render nodes
+	SortTime time.Duration
+	// lights and occluder edges over the limits, see LightStats
+	DroppedLights, DroppedEdges int
+}
+
+// FeatureStats is the draw calls and vertices submitted by a RenderFeature.
+type FeatureStats struct {
+	Name      string
+	Nodes     int
+	DrawCalls int
+	Vertices  int
+}
+
+func (rs *RenderStats) reset(features []RenderFeature) {
+	if len(rs.Features) != len(features) {
+		rs.Features = make([]FeatureStats, len(features))
+		for i, f := range features {
+			name := fmt.Sprintf("%T", f)
+			if i := strings.LastIndexByte(name, '.'); i >= 0 {
+				name = name[i+1:]
+			}
+			rs.Features[i].Name = strings.TrimSuffix(name, "RenderFeature")
+		}
+	}
+	for i := range rs.Features {
+		fs := &rs.Features[i]
+		fs.Nodes, fs.DrawCalls, fs.Vertices = 0, 0, 0
+	}
+	rs.Nodes = 0
+	rs.Breaks = [BreakCauses]int{}
+	rs.SortTime = 0
+	rs.DroppedLights, rs.DroppedEdges = 0, 0
+}
+
+// measure adds the draw calls and vertices submitted in fn to the feature.
+func (rs *RenderStats) measure(fi int, fn func()) {
+	dc, vc := bk.Submitted()
+	fn()
+	dc1, vc1 := bk.Submitted()
+	fs := &rs.Features[fi]
+	fs.DrawCalls += dc1 - dc
+	fs.Vertices += vc1 - vc
+}
+
+// DrawCalls returns the draw calls submitted by all the features.
+func (rs *RenderStats) DrawCalls() (n int) {
+	for _, fs := range rs.Features {
+		n += fs.DrawCalls
+	}
+	return
+}
+
+// inspect shows the statistics in the inspector panel of dbg.
+func (rs *RenderStats) inspect() {
+	last, res := bk.Stats(), bk.R.Stats()
+	dbg.Inspect("frame: %d draws, %d vertices, %d nodes", last.DrawCalls, last.Vertices, rs.Nodes)
+	dbg.Inspect("sort: nodes %v, draws %v", rs.SortTime, last.SortTime)
+	dbg.Inspect("uniform: %s/%s", kb(last.UniformBytes), kb(last.UniformCapacity))
+	dbg.Inspect("texture: %d, %s", res.Textures, kb(res.TextureMemory))
+	dbg.Inspect("vertex: %d, %s index: %d, %s", res.VertexBuffers, kb(res.VertexMemory), res.IndexBuffers, kb(res.IndexMemory))
+	dbg.Inspect("shader: %d uniform: %d", res.Shaders, res.Uniforms)
+	for _, fs := range rs.Features {
+		if fs.Nodes > 0 || fs.DrawCalls > 0 {
+			dbg.Inspect("%s: %d nodes, %d draws, %d vertices", fs.Name, fs.Nodes, fs.DrawCalls, fs.Vertices)
+		}
+	}
+	var breaks []string
+	for c, n := range rs.Breaks {
+		if n > 0 {
+			breaks = append(breaks, fmt.Sprintf("%s %d", BreakCause(c), n))
+		}
+	}
+	if len(breaks) > 0 {
+		dbg.Inspect("breaks: %s", strings.Join(breaks, ", "))
+	}
+	if rs.DroppedLights > 0 || rs.DroppedEdges > 0 {
+		dbg.Inspect("light: dropped %d lights, %d edges", rs.DroppedLights, rs.DroppedEdges)
+	}
+}
+
+func kb(n int) string {
+	if n >= 1<<20 {
+		return fmt.Sprintf("%.1fM", float32(n)/(1<<20))
+	}
+	return fmt.Sprintf("%.1fK", float32(n)/(1<<10))
+}
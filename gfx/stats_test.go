@@ -0,0 +1,41 @@
+package gfx
+
+import (
+	"testing"
+)
+
+func TestBatchBreaks(t *testing.T) {
+	bc := &BatchContext{}
+	bc.init()
+
+	batch := func(mat, tex uint16, depth int16) {
+		bc.begin(mat, tex, depth)
+		bc.end()
+	}
+	batch(0, 1, 0)
+	batch(0, 2, 0) // texture
+	batch(1, 2, 0) // material
+	batch(1, 2, 1) // z-order
+	bc.stencil = 0xFF
+	batch(1, 2, 1) // mask
+	batch(1, 2, 1) // same state
+	bc.reset()
+	batch(0, 1, 0) // first batch after flush
+
+	want := [BreakCauses]int{BreakTexture: 1, BreakMaterial: 1, BreakZOrder: 1, BreakMask: 1, BreakOther: 1}
+	if bc.breaks != want {
+		t.Error("batch breaks error:", bc.breaks)
+	}
+}
+
+func TestRenderStats(t *testing.T) {
+	rs := &RenderStats{}
+	rs.reset([]RenderFeature{&SpriteRenderFeature{}, &TrailRenderFeature{}})
+	if rs.Features[0].Name != "Sprite" || rs.Features[1].Name != "Trail" {
+		t.Error("feature name error:", rs.Features)
+	}
+	rs.Features[0].DrawCalls, rs.Features[1].DrawCalls = 2, 3
+	if n := rs.DrawCalls(); n != 5 {
+		t.Error("draw calls error:", n)
+	}
+}
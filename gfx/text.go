@@ -18,6 +18,13 @@ type TextQuad struct {
 
 	// texture
 	region Region
+	tex    uint16
+
+	// color of the run, zero means the color of text
+	color uint32
+
+	// run and index of the rune, for the animations of rich text
+	run, index int32
 }
 
 // TextSprite
@@ -41,6 +48,23 @@ type TextComp struct {
 	text      string
 	vertex    []TextQuad
 	runeCount int32
+
+	// textures used by the glyphs, the text is drawn in one batch
+	// for each texture
+	textures []textTexture
+
+	// pages used by the glyphs and version of the fonts, the text is
+	// laid out again if the font's pages changed, see font.Paged
+	paged []textPages
+
+	// styled runs of rich text, nil for plain text
+	rich *font.RichText
+
+	// decoration of distance field fonts
+	effect TextEffect
+
+	// wrap, alignment and spacing, Size is the font size
+	layout font.LayoutConfig
 }
 
 func (tc *TextComp) Color() Color {
@@ -57,6 +81,7 @@ func (tc *TextComp) Text() string {
 
 func (tc *TextComp) SetText(text string) {
 	tc.text = text
+	tc.rich = nil
 	// init ebo, vbo
 	tc.runeCount = int32(len(text))
 
@@ -64,6 +89,30 @@ func (tc *TextComp) SetText(text string) {
 	tc.fillData()
 }
 
+// RichText returns the rich text, nil if the text is plain.
+func (tc *TextComp) RichText() *font.RichText {
+	return tc.rich
+}
+
+// SetRichText sets a styled text, see font.NewRichText. The animations of the
+// rich text are advanced by TextRenderFeature. Nil reverts to the plain text.
+func (tc *TextComp) SetRichText(rt *font.RichText) {
+	if rt == nil {
+		tc.SetText(tc.text)
+		return
+	}
+	rt.Refresh()
+	tc.rich = rt
+	tc.text = font.Plain(rt.Runs)
+	tc.runeCount = int32(len(tc.text))
+	tc.fillData()
+}
+
+// SetMarkup parses the markup and sets it as rich text.
+func (tc *TextComp) SetMarkup(markup string, ms *font.MarkupStyle) {
+	tc.SetRichText(font.NewRichText(markup, ms))
+}
+
 func (tc *TextComp) Gravity() (x, y float32) {
 	return tc.gravity.x, tc.gravity.y
 }
@@ -87,12 +136,52 @@ func (tc *TextComp) FontSize() float32 {
 
 func (tc *TextComp) SetFontSize(sz float32) {
 	tc.size = sz
+	tc.relayout()
 }
 
+// Size returns the bounds of the laid out text.
 func (tc *TextComp) Size() (w, h float32) {
 	return tc.width, tc.height
 }
 
+// Layout returns the layout config.
+func (tc *TextComp) Layout() font.LayoutConfig {
+	lc := tc.layout
+	lc.Size = tc.size
+	return lc
+}
+
+// SetWrap wraps the lines wider than width, zero means no wrapping.
+func (tc *TextComp) SetWrap(width float32, mode font.WrapMode) {
+	tc.layout.WrapWidth, tc.layout.Wrap = width, mode
+	tc.relayout()
+}
+
+// SetAlign sets the horizontal alignment of lines, lines are aligned in the
+// width of the widest line. Use gravity to align the whole text.
+func (tc *TextComp) SetAlign(align font.Align) {
+	tc.layout.Align = align
+	tc.relayout()
+}
+
+// SetLineHeight sets the distance between lines in multiple of font size.
+func (tc *TextComp) SetLineHeight(h float32) {
+	tc.layout.LineHeight = h
+	tc.relayout()
+}
+
+// SetLetterSpacing sets the extra space between runes.
+func (tc *TextComp) SetLetterSpacing(spacing float32) {
+	tc.layout.LetterSpacing = spacing
+	tc.relayout()
+}
+
+func (tc *TextComp) relayout() {
+	if tc.font != nil && tc.text != "" {
+		tc.fillData()
+	}
+}
+
 // generate text-vertex with the string
 //
 //		+----------+
@@ -103,49 +192,120 @@ func (tc *TextComp) Size() (w, h float32) {
 // 		+----------+
 // 1 * 1 quad for each char
 func (tc *TextComp) fillData() {
-	chars := make([]TextQuad, len(tc.text))
+	lc := tc.Layout()
+	runs := plainRuns[:]
+	if tc.rich != nil {
+		runs = tc.rich.Runs
+	} else {
+		runs[0].Text = tc.text
+	}
+	glyphs, w, h := font.LayoutRuns(tc.font, runs, lc, layoutBuffer[:0])
+	chars := make([]TextQuad, 0, len(glyphs))
+	tc.textures = tc.textures[:0]
+	tc.paged = tc.paged[:0]
+
+	for i := range glyphs {
+		glyph := &glyphs[i]
+		scale := glyph.Scale
+		vw := glyph.Width * scale
+		vh := glyph.Height * scale
+		ox := glyph.XOffset * scale
+		oy := glyph.YOffset * scale
+
+		chars = append(chars, TextQuad{})
+		char := &chars[len(chars)-1]
+		if fnt := glyph.Font; fnt != nil {
+			u1, v1, u2, v2 := fnt.Frame(glyph.Rune)
+			char.region = Region{X1: u1, Y1: v1, X2: u2, Y2: v2}
+			char.tex = tc.addTexture(fnt, glyph.Page)
+		} else {
+			icon := runs[glyph.Run].Icon
+			r := icon.Region
+			char.region = Region{X1: r[0], Y1: r[1], X2: r[2], Y2: r[3]}
+			char.tex = tc.addTexture(nil, icon.Tex)
+		}
+
+		// y-axis is up, the first line is at the top
+		char.xOffset = glyph.X + ox
+		char.yOffset = h - glyph.Y - (oy + vh)
+		char.w, char.h = vw, vh
+		char.color = runs[glyph.Run].Color
+		char.run, char.index = int32(glyph.Run), int32(glyph.Index)
+	}
+	layoutBuffer = glyphs
+
 	tc.vertex = chars
-	_, gh := tc.font.Bounds()
+	tc.width = w
+	tc.height = h
+}
 
-	var (
-		size    = struct{ w, h float32 }{}
-		scale   = tc.size / gh
-		xOffset float32
-		yOffset float32
-	)
+// shared by all the texts, glyphs are copied to quads
+var (
+	layoutBuffer []font.Positioned
+	plainRuns    [1]font.Run
+)
+
+type textTexture struct {
+	id   uint16
+	font font.Font // nil for icons
+}
 
-	if tc.size == 0 {
-		scale = 1.0
+type textPages struct {
+	font    font.Paged
+	pages   []uint16
+	version uint32
+}
+
+// addTexture records the texture of glyph, page is the texture id of icons.
+func (tc *TextComp) addTexture(fnt font.Font, page uint16) (id uint16) {
+	if paged, ok := fnt.(font.Paged); ok {
+		id, _ = paged.Page(int(page))
+		tc.addPage(paged, page)
+	} else if fnt != nil {
+		id, _ = fnt.Tex2D()
 	} else {
-		gh = tc.size
+		id = page
 	}
-	yOffset = gh
-
-	for i, r := range tc.text {
-		if glyph, ok := tc.font.Glyph(r); ok {
-			advance := float32(glyph.Advance) * scale
-			vw := glyph.Width * scale
-			vh := glyph.Height * scale
-			ox := glyph.XOffset * scale
-			oy := glyph.YOffset * scale
-
-			u1, v1, u2, v2 := tc.font.Frame(r)
-			char := &chars[i]
-
-			char.xOffset = xOffset + ox
-			char.yOffset = yOffset - (oy + vh)
-			char.w, char.h = vw, vh
-			char.region.X1, char.region.Y1 = u1, v1
-			char.region.X2, char.region.Y2 = u2, v2
-
-			// left to right shift only!
-			xOffset += advance
-			yOffset += 0
+	for _, t := range tc.textures {
+		if t.id == id {
+			return
 		}
 	}
-	size.w = xOffset + chars[len(chars)-1].w
-	tc.width = size.w
-	tc.height = gh
+	tc.textures = append(tc.textures, textTexture{id, fnt})
+	return
+}
+
+func (tc *TextComp) addPage(paged font.Paged, page uint16) {
+	var tp *textPages
+	for i := range tc.paged {
+		if tc.paged[i].font == paged {
+			tp = &tc.paged[i]
+		}
+	}
+	if tp == nil {
+		tc.paged = append(tc.paged, textPages{font: paged, version: paged.Version()})
+		tp = &tc.paged[len(tc.paged)-1]
+	}
+	for _, p := range tp.pages {
+		if p == page {
+			return
+		}
+	}
+	tp.pages = append(tp.pages, page)
+}
+
+// usePages marks the pages are used in current frame, it returns false if the
+// glyphs of a font are evicted.
+func (tc *TextComp) usePages() bool {
+	for _, tp := range tc.paged {
+		if tp.version != tp.font.Version() {
+			return false
+		}
+		for _, p := range tp.pages {
+			tp.font.Page(int(p))
+		}
+	}
+	return true
 }
 
 func (tc *TextComp) Font() font.Font {
@@ -155,10 +315,11 @@ func (tc *TextComp) Font() font.Font {
 // should have default font!!
 func (tc *TextComp) SetFont(fnt font.Font) {
 	tc.font = fnt
-	if fnt != nil && tc.batchId.value != 0 {
+	if fnt != nil {
 		tex, _ := fnt.Tex2D()
 		tc.batchId.value = tex
 	}
+	tc.relayout()
 }
 
 // TextTable
@@ -250,6 +411,18 @@ type TextRenderFeature struct {
 
 	tt *TextTable
 	xt *TransformTable
+	mt *MaskTable
+
+	// visible texts, index<<16 | page
+	nodes []uint32
+
+	// shader and materials of distance field fonts, the base material
+	// compiles the shader and the others are the instances of it
+	sdf struct {
+		vsh, fsh  string
+		base      uint16
+		materials map[textMaterialKey]textMaterial
+	}
 }
 
 // 此处初始化所有的依赖
@@ -274,6 +447,8 @@ func (f *TextRenderFeature) Register(rs *RenderSystem) {
 			f.tt = table
 		case *TransformTable:
 			f.xt = table
+		case *MaskTable:
+			f.mt = table
 		}
 	}
 	f.id = rs.Accept(f)
@@ -286,43 +461,71 @@ func (f *TextRenderFeature) Extract(v *View) {
 		fi     = uint32(f.id) << 16
 	)
 
-	for i, spr := range f.tt.comps[:f.tt.index] {
+	f.nodes = f.nodes[:0]
+	for i := range f.tt.comps[:f.tt.index] {
+		spr := &f.tt.comps[i]
 		xf := xt.Comp(spr.Entity)
 		sz := f32.Vec2{spr.width, spr.height}
 		g := f32.Vec2{spr.gravity.x, spr.gravity.y}
 
-		if spr.visible && camera.InView(xf, sz, g) {
-			sid := PackSortId(spr.zOrder.value, spr.batchId.value)
-			val := fi + uint32(i)
+		if !spr.visible || !camera.InView(xf, sz, g) {
+			continue
+		}
+		// glyphs may be evicted
+		if !spr.usePages() {
+			spr.fillData()
+			spr.usePages()
+		}
+		for ti, tex := range spr.textures {
+			sid := spr.zOrder.sortId(xf.world.Position[1], f.material(tex.font, spr.effect), tex.id)
+			val := fi + uint32(len(f.nodes))
+			f.nodes = append(f.nodes, uint32(i)<<16|uint32(ti))
 			v.RenderNodes = append(v.RenderNodes, SortObject{sid, val})
 		}
 	}
 }
 
+// Update advances the animations of rich texts.
+func (f *TextRenderFeature) Update(dt float32) {
+	for i := range f.tt.comps[:f.tt.index] {
+		if rt := f.tt.comps[i].rich; rt != nil {
+			rt.Update(dt)
+		}
+	}
+}
+
 func (f *TextRenderFeature) Draw(nodes RenderNodes) {
 	var (
-		tt, xt = f.tt, f.xt
-		sortId = uint32(0xFFFFFFFF)
-		begin  = false
-		render = f.R
+		tt, xt  = f.tt, f.xt
+		sortId  = uint64(0xFFFFFFFFFFFFFFFF)
+		stencil = uint32(0)
+		begin   = false
+		render  = f.R
 	)
 
 	// batch draw!
 	var textBatchObject = textBatchObject{}
 	for _, b := range nodes {
-		ii := b.Value & 0xFFFF
-		if sid := b.SortId & 0xFFFF; sortId != sid {
+		node := f.nodes[b.Value&0xFFFF]
+		ii, ti := node>>16, node&0xFFFF
+		tc := &tt.comps[ii]
+		tex := tc.textures[ti].id
+		// split batch on material, texture or mask changes
+		sten := f.mt.Stencil(tc.Entity)
+		if sid := b.SortId & 0xFFFFFFFF; sortId != sid || stencil != sten {
 			if begin {
 				render.End()
 			}
-			sortId = sid
+			sortId, stencil = sid, sten
 			begin = true
-			tex2d, _ := tt.comps[ii].font.Tex2D()
 			depth, _ := UnpackSortId(b.SortId)
-			render.Begin(tex2d, depth)
+			render.SetStencil(stencil)
+			render.BeginMaterial(UnpackMaterial(b.SortId), tex, depth)
 		}
-		textBatchObject.TextComp = &tt.comps[ii]
-		textBatchObject.Transform = xt.Comp(tt.comps[ii].Entity)
+		textBatchObject.TextComp = tc
+		textBatchObject.Transform = xt.Comp(tc.Entity)
+		textBatchObject.tex = tex
+		textBatchObject.multi = len(tc.textures) > 1
 		render.Draw(textBatchObject)
 	}
 	if begin {
@@ -332,12 +535,15 @@ func (f *TextRenderFeature) Draw(nodes RenderNodes) {
 }
 
 func (f *TextRenderFeature) Flush() {
-
+	f.releaseMaterials()
 }
 
+// only the glyphs on the texture are filled if the text uses several textures
 type textBatchObject struct {
 	*TextComp
 	*Transform
+	tex   uint16
+	multi bool
 }
 
 // batch system winding order
@@ -363,35 +569,59 @@ func (tbo textBatchObject) Fill(buf []PosTexColorVertex) {
 	m := f32.Mat3{}
 	m.Initialize(p[0], p[1], srt.Rotation, srt.Scale[0], srt.Scale[1], ox, oy, 0, 0)
 
-	for i, char := range tbo.vertex {
-		vi := i * 4
+	vi := -4
+	for _, char := range tbo.vertex {
+		if tbo.multi && char.tex != tbo.tex {
+			continue
+		}
+		vi += 4
+
+		color := t.color
+		if char.color != 0 {
+			color = char.color
+		}
+		x, y := char.xOffset, char.yOffset
+		if rt := t.rich; rt != nil {
+			dx, dy := rt.Offset(int(char.run), int(char.index))
+			x, y = x+dx, y+dy
+			color = rt.Fade(int(char.index), color)
+		}
 
 		// index (0, 0) <x,y,u,v>
 		v := &buf[vi+0]
-		v.X, v.Y = m.Transform(char.xOffset, char.yOffset)
+		v.X, v.Y = m.Transform(x, y)
 		v.U, v.V = char.region.X1, char.region.Y2
-		v.RGBA = t.color
+		v.RGBA = color
 
 		// index (1,0) <x,y,u,v>
 		v = &buf[vi+1]
-		v.X, v.Y = m.Transform(char.xOffset+char.w, char.yOffset)
+		v.X, v.Y = m.Transform(x+char.w, y)
 		v.U, v.V = char.region.X2, char.region.Y2
-		v.RGBA = t.color
+		v.RGBA = color
 
 		// index(1,1) <x,y,u,v>
 		v = &buf[vi+2]
-		v.X, v.Y = m.Transform(char.xOffset+char.w, char.yOffset+char.h)
+		v.X, v.Y = m.Transform(x+char.w, y+char.h)
 		v.U, v.V = char.region.X2, char.region.Y1
-		v.RGBA = t.color
+		v.RGBA = color
 
 		// index(0, 1) <x,y,u,v>
 		v = &buf[vi+3]
-		v.X, v.Y = m.Transform(char.xOffset, char.yOffset+char.h)
+		v.X, v.Y = m.Transform(x, y+char.h)
 		v.U, v.V = char.region.X1, char.region.Y1
-		v.RGBA = t.color
+		v.RGBA = color
 	}
 }
 
 func (tbo textBatchObject) Size() int {
-	return 4 * len(tbo.vertex)
+	if !tbo.multi {
+		return 4 * len(tbo.vertex)
+	}
+	n := 0
+	for _, char := range tbo.vertex {
+		if char.tex == tbo.tex {
+			n++
+		}
+	}
+	return 4 * n
 }
@@ -0,0 +1,128 @@
+package gfx
+
+import (
+	"sckorok/gfx/bk"
+	"sckorok/gfx/font"
+	"sckorok/math/f32"
+)
+
+// TextEffect decorates the text drawn with a distance field font, see
+// font.DistanceField. It has no effect on other fonts.
+//
+// Widths and offsets are in pixels of the font atlas, so they are scaled with
+// the font size. The distance field only covers font.Spread pixels around the
+// glyphs, Outline+Glow and Outline+ShadowSoftness+|Shadow| should not exceed it.
+// Colors are used as is like the text color, use PMAColor for translucent colors.
+type TextEffect struct {
+	Outline      float32
+	OutlineColor Color
+
+	Shadow         f32.Vec2
+	ShadowSoftness float32
+	ShadowColor    Color
+
+	Glow      float32
+	GlowColor Color
+}
+
+func (tc *TextComp) Effect() TextEffect {
+	return tc.effect
+}
+
+func (tc *TextComp) SetEffect(e TextEffect) {
+	tc.effect = e
+}
+
+// SetDistanceFieldShader sets the shader used to draw distance field fonts,
+// see the "sdf" shader in asset. Without the shader the distance field is drawn
+// as a normal texture.
+func (f *TextRenderFeature) SetDistanceFieldShader(vsh, fsh string) {
+	f.sdf.vsh, f.sdf.fsh = vsh, fsh
+}
+
+// the material depends on the effect and the font
+type textMaterialKey struct {
+	effect TextEffect
+	msdf   bool
+	spread float32
+	tw, th float32
+}
+
+// an instance of the distance field material, unused ones are deleted in Flush
+type textMaterial struct {
+	id   uint16
+	used bool
+}
+
+// material returns the Material to draw the glyphs of font, zero for normal
+// fonts. The shader is compiled once, the effects are uniform values of the
+// Material instances shared by the texts with the same effect.
+func (f *TextRenderFeature) material(fnt font.Font, effect TextEffect) uint16 {
+	df, ok := fnt.(font.DistanceField)
+	if !ok || f.sdf.vsh == "" {
+		return 0
+	}
+	_, tex := fnt.Tex2D()
+	if tex == nil {
+		return 0
+	}
+	key := textMaterialKey{effect, df.MSDF(), df.Spread(), tex.Width, tex.Height}
+	if tm, ok := f.sdf.materials[key]; ok {
+		if !tm.used {
+			f.sdf.materials[key] = textMaterial{tm.id, true}
+		}
+		return tm.id
+	}
+	if f.sdf.base == 0 {
+		id, m := M.NewShader("", f.sdf.vsh, f.sdf.fsh)
+		if m.Program() == bk.InvalidId {
+			M.Delete(id)
+			f.sdf.vsh = "" // don't try again
+			return 0
+		}
+		// allocate the uniforms once, the instances share them
+		setTextEffect(m, textMaterialKey{spread: 1, tw: 1, th: 1})
+		f.sdf.base = id
+	}
+	id, m := M.NewInstance("", f.sdf.base)
+	setTextEffect(m, key)
+
+	if f.sdf.materials == nil {
+		f.sdf.materials = make(map[textMaterialKey]textMaterial)
+	}
+	f.sdf.materials[key] = textMaterial{id, true}
+	return id
+}
+
+// releaseMaterials deletes the materials not used since the last call.
+func (f *TextRenderFeature) releaseMaterials() {
+	for key, tm := range f.sdf.materials {
+		if tm.used {
+			f.sdf.materials[key] = textMaterial{tm.id, false}
+		} else {
+			M.Delete(tm.id)
+			delete(f.sdf.materials, key)
+		}
+	}
+}
+
+func setTextEffect(m *Material, key textMaterialKey) {
+	// pixels to distance units
+	e, unit := &key.effect, 1/(2*key.spread)
+	var msdf float32
+	if key.msdf {
+		msdf = 1
+	}
+	m.SetFloat("msdf", msdf)
+	m.SetFloat("outline", e.Outline*unit)
+	m.SetVec4("outlineColor", colorVec4(e.OutlineColor))
+	m.SetVec4("shadowOffset", f32.Vec4{e.Shadow[0] / key.tw, -e.Shadow[1] / key.th})
+	m.SetFloat("shadowSoftness", e.ShadowSoftness*unit)
+	m.SetVec4("shadowColor", colorVec4(e.ShadowColor))
+	m.SetFloat("glow", e.Glow*unit)
+	m.SetVec4("glowColor", colorVec4(e.GlowColor))
+}
+
+func colorVec4(c Color) f32.Vec4 {
+	return f32.Vec4{float32(c.R) / 0xFF, float32(c.G) / 0xFF, float32(c.B) / 0xFF, float32(c.A) / 0xFF}
+}
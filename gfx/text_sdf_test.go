@@ -0,0 +1,99 @@
+package gfx
+
+import (
+	"image"
+	"testing"
+
+	"sckorok/gfx/bk"
+	"sckorok/gfx/font"
+)
+
+const testSdfVsh = `
+#version 330
+
+uniform mat4 proj;
+
+in vec4 xyuv;
+in vec4 rgba;
+
+out vec4 outColor;
+out vec2 outTexCoord;
+
+void main() {
+	outColor = rgba;
+	outTexCoord = xyuv.zw;
+	gl_Position = proj * vec4(xyuv.xy, 1, 1);
+}
+` + "\x00"
+
+const testSdfFsh = `
+#version 330
+
+uniform sampler2D tex;
+uniform float msdf;
+uniform float outline;
+uniform vec4 outlineColor;
+uniform vec4 shadowOffset;
+uniform float shadowSoftness;
+uniform vec4 shadowColor;
+uniform float glow;
+uniform vec4 glowColor;
+
+in vec2 outTexCoord;
+in vec4 outColor;
+
+out vec4 outputColor;
+
+void main() {
+	outputColor = texture(tex, outTexCoord) * outColor + outlineColor * outline;
+}
+` + "\x00"
+
+// sdfFont is a distance field font without glyphs
+type sdfFont struct {
+	id  uint16
+	tex *bk.Texture2D
+}
+
+func (f sdfFont) Tex2D() (uint16, *bk.Texture2D)      { return f.id, f.tex }
+func (sdfFont) Glyph(r rune) (g font.Glyph, ok bool)  { return }
+func (sdfFont) Bounds() (gw, gh float32)              { return }
+func (sdfFont) Frame(r rune) (x1, y1, x2, y2 float32) { return }
+func (sdfFont) Metrics() font.Metrics                 { return font.Metrics{} }
+func (sdfFont) Spread() float32                       { return 4 }
+func (sdfFont) MSDF() bool                            { return false }
+
+func TestTextMaterial(t *testing.T) {
+	bk.UseSoftRenderer(bk.NewSoftRenderer(4, 4))
+	defer bk.UseSoftRenderer(nil)
+	bk.R.Init()
+
+	id, tex := bk.R.AllocTexture(image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	fnt := sdfFont{id, tex}
+	f := &TextRenderFeature{}
+	f.SetDistanceFieldShader(testSdfVsh, testSdfFsh)
+
+	a := f.material(fnt, TextEffect{Outline: 1})
+	b := f.material(fnt, TextEffect{Outline: 2})
+	if a == 0 || b == 0 || a == b || f.material(fnt, TextEffect{Outline: 1}) != a {
+		t.Fatal("materials should be shared by the same effect:", a, b)
+	}
+	base := M.Get(f.sdf.base)
+	if ma, mb := M.Get(a), M.Get(b); ma.Program() != base.Program() || mb.Program() != base.Program() {
+		t.Error("materials should share the program of base")
+	}
+	if ma, mb := M.Get(a), M.Get(b); ma.uniforms[1].id != mb.uniforms[1].id || ma.uniforms[1].data[0] == mb.uniforms[1].data[0] {
+		t.Error("instances should share the uniforms with their own values")
+	}
+
+	// a is used in the next frame, b is evicted
+	f.Flush()
+	f.material(fnt, TextEffect{Outline: 1})
+	f.Flush()
+	if _, ok := f.sdf.materials[textMaterialKey{TextEffect{Outline: 2}, false, 4, 8, 8}]; ok || len(f.sdf.materials) != 1 {
+		t.Error("unused material should be deleted:", f.sdf.materials)
+	}
+	if ok, _ := bk.R.Shader(base.Program()); !ok {
+		t.Error("deleting an instance should not free the shared program")
+	}
+}
@@ -0,0 +1,24 @@
+package gfx
+
+import (
+	"testing"
+
+	"sckorok/gfx/font"
+)
+
+func TestSetRichText(t *testing.T) {
+	tc := &TextComp{}
+	tc.SetFont(sdfFont{})
+
+	rt := &font.RichText{Runs: []font.Run{{Text: "ab"}, {Text: "c"}}}
+	rt.Typewriter.Speed = 10
+	tc.SetRichText(rt)
+	if rt.Update(.1); tc.Text() != "abc" || rt.Typewriter.Done() {
+		t.Error("rich text should be set with the length:", tc.Text())
+	}
+
+	tc.SetRichText(nil)
+	if tc.RichText() != nil || tc.Text() != "abc" {
+		t.Error("nil should revert to the plain text:", tc.Text())
+	}
+}
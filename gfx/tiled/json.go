@@ -0,0 +1,300 @@
+package tiled
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+)
+
+// LoadJSON loads a map in JSON format. External tileset(.tsj/.json) will be
+// opened with the Opener, it can be nil if the map has no external tileset.
+func LoadJSON(r io.Reader, open Opener) (m *Map, err error) {
+	j := &jsonMap{}
+	if err = json.NewDecoder(r).Decode(j); err != nil {
+		return
+	}
+	if j.Infinite {
+		return nil, ErrInfinite
+	}
+	m = &Map{
+		Orientation: j.Orientation,
+		Width:       j.Width,
+		Height:      j.Height,
+		TileWidth:   j.TileWidth,
+		TileHeight:  j.TileHeight,
+		Properties:  j.Properties.convert(),
+	}
+
+	// tileset
+	for _, jts := range j.TileSets {
+		if jts.Source != "" {
+			jts, err = loadTSJ(jts.FirstGid, jts.Source, open)
+			if err != nil {
+				return
+			}
+		}
+		m.TileSets = append(m.TileSets, jts.convert())
+	}
+
+	// layers, group layer is flattened
+	if err = m.addLayers(j.Layers, rootState); err != nil {
+		m = nil
+	}
+	return
+}
+
+func (m *Map) addLayers(layers []jsonLayer, parent layerState) (err error) {
+	for _, jl := range layers {
+		visible := jl.Visible == nil || *jl.Visible
+		ls := parent.combine(jl.opacity(), visible, jl.OffsetX, jl.OffsetY, jl.Properties.convert())
+		switch jl.Type {
+		case "tilelayer":
+			l := Layer{
+				Name:       jl.Name,
+				Width:      jl.Width,
+				Height:     jl.Height,
+				Opacity:    ls.opacity,
+				Visible:    ls.visible,
+				OffsetX:    ls.offsetX,
+				OffsetY:    ls.offsetY,
+				Properties: ls.properties,
+			}
+			if l.Data, err = jl.decode(); err != nil {
+				return fmt.Errorf("tiled: layer %q, %v", jl.Name, err)
+			}
+			m.Layers = append(m.Layers, l)
+		case "objectgroup":
+			og := ObjectGroup{
+				Name:       jl.Name,
+				Opacity:    ls.opacity,
+				Visible:    ls.visible,
+				OffsetX:    ls.offsetX,
+				OffsetY:    ls.offsetY,
+				Properties: ls.properties,
+			}
+			for _, jo := range jl.Objects {
+				og.Objects = append(og.Objects, jo.convert())
+			}
+			m.ObjectGroups = append(m.ObjectGroups, og)
+		case "group":
+			if err = m.addLayers(jl.Layers, ls); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func loadTSJ(firstGid uint32, source string, open Opener) (jts jsonTileSet, err error) {
+	if open == nil {
+		err = ErrExternal
+		return
+	}
+	f, err := open(source)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if err = json.NewDecoder(f).Decode(&jts); err != nil {
+		return
+	}
+	jts.FirstGid = firstGid
+	// image path is related to the tileset file
+	if dir := path.Dir(source); jts.Image != "" && dir != "." {
+		jts.Image = path.Join(dir, jts.Image)
+	}
+	return
+}
+
+// JSON structure
+type jsonMap struct {
+	Orientation string         `json:"orientation"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	TileWidth   int            `json:"tilewidth"`
+	TileHeight  int            `json:"tileheight"`
+	Infinite    bool           `json:"infinite"`
+	Properties  jsonProperties `json:"properties"`
+	TileSets    []jsonTileSet  `json:"tilesets"`
+	Layers      []jsonLayer    `json:"layers"`
+}
+
+// all value types are converted to string
+type jsonProperties []struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+func (jp jsonProperties) convert() Properties {
+	if len(jp) == 0 {
+		return nil
+	}
+	p := make(Properties, len(jp))
+	for _, v := range jp {
+		switch x := v.Value.(type) {
+		case string:
+			p[v.Name] = x
+		case float64:
+			p[v.Name] = strconv.FormatFloat(x, 'f', -1, 64)
+		case bool:
+			p[v.Name] = strconv.FormatBool(x)
+		case nil:
+			p[v.Name] = ""
+		default:
+			p[v.Name] = fmt.Sprint(x)
+		}
+	}
+	return p
+}
+
+type jsonTileSet struct {
+	FirstGid    uint32         `json:"firstgid"`
+	Source      string         `json:"source"`
+	Name        string         `json:"name"`
+	TileWidth   int            `json:"tilewidth"`
+	TileHeight  int            `json:"tileheight"`
+	TileCount   int            `json:"tilecount"`
+	Columns     int            `json:"columns"`
+	Spacing     int            `json:"spacing"`
+	Margin      int            `json:"margin"`
+	Image       string         `json:"image"`
+	ImageWidth  int            `json:"imagewidth"`
+	ImageHeight int            `json:"imageheight"`
+	Properties  jsonProperties `json:"properties"`
+	Tiles       []struct {
+		Id         uint32         `json:"id"`
+		Properties jsonProperties `json:"properties"`
+		Animation  []struct {
+			TileId   uint32 `json:"tileid"`
+			Duration int    `json:"duration"`
+		} `json:"animation"`
+	} `json:"tiles"`
+}
+
+func (jts *jsonTileSet) convert() TileSet {
+	ts := TileSet{
+		FirstGid:    jts.FirstGid,
+		Name:        jts.Name,
+		TileWidth:   jts.TileWidth,
+		TileHeight:  jts.TileHeight,
+		TileCount:   jts.TileCount,
+		Columns:     jts.Columns,
+		Spacing:     jts.Spacing,
+		Margin:      jts.Margin,
+		Image:       jts.Image,
+		ImageWidth:  jts.ImageWidth,
+		ImageHeight: jts.ImageHeight,
+		Properties:  jts.Properties.convert(),
+		Tiles:       make(map[uint32]*Tile, len(jts.Tiles)),
+	}
+	for _, jt := range jts.Tiles {
+		t := &Tile{Id: jt.Id, Properties: jt.Properties.convert()}
+		for _, f := range jt.Animation {
+			t.Animation = append(t.Animation, Frame{f.TileId, f.Duration})
+		}
+		ts.Tiles[t.Id] = t
+	}
+	return ts
+}
+
+type jsonLayer struct {
+	Type        string         `json:"type"`
+	Name        string         `json:"name"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	Opacity     *float32       `json:"opacity"`
+	Visible     *bool          `json:"visible"`
+	OffsetX     float32        `json:"offsetx"`
+	OffsetY     float32        `json:"offsety"`
+	Properties  jsonProperties `json:"properties"`
+	Encoding    string         `json:"encoding"`
+	Compression string         `json:"compression"`
+	Data        interface{}    `json:"data"`
+	Objects     []jsonObject   `json:"objects"`
+	Layers      []jsonLayer    `json:"layers"`
+}
+
+func (jl *jsonLayer) opacity() float32 {
+	if jl.Opacity == nil {
+		return 1
+	}
+	return *jl.Opacity
+}
+
+func (jl *jsonLayer) decode() (data []Gid, err error) {
+	switch x := jl.Data.(type) {
+	case []interface{}:
+		data = make([]Gid, len(x))
+		for i, v := range x {
+			if f, ok := v.(float64); ok {
+				data[i] = Gid(uint32(f))
+			}
+		}
+	case string:
+		if jl.Encoding != "base64" {
+			return nil, fmt.Errorf("unsupported encoding: %s", jl.Encoding)
+		}
+		data, err = decodeBase64(x, jl.Compression)
+	}
+	if err == nil && len(data) != jl.Width*jl.Height {
+		err = fmt.Errorf("tile size not match: %d != %d", len(data), jl.Width*jl.Height)
+	}
+	return
+}
+
+type jsonObject struct {
+	Id         int            `json:"id"`
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Class      string         `json:"class"`
+	X          float32        `json:"x"`
+	Y          float32        `json:"y"`
+	Width      float32        `json:"width"`
+	Height     float32        `json:"height"`
+	Rotation   float32        `json:"rotation"`
+	Gid        Gid            `json:"gid"`
+	Visible    *bool          `json:"visible"`
+	Properties jsonProperties `json:"properties"`
+	Ellipse    bool           `json:"ellipse"`
+	Point      bool           `json:"point"`
+	Polygon    []Point        `json:"polygon"`
+	Polyline   []Point        `json:"polyline"`
+}
+
+func (jo *jsonObject) convert() Object {
+	o := Object{
+		Id:         jo.Id,
+		Name:       jo.Name,
+		Type:       jo.Type,
+		X:          jo.X,
+		Y:          jo.Y,
+		Width:      jo.Width,
+		Height:     jo.Height,
+		Rotation:   jo.Rotation,
+		Gid:        jo.Gid,
+		Visible:    jo.Visible == nil || *jo.Visible,
+		Properties: jo.Properties.convert(),
+	}
+	if o.Type == "" {
+		o.Type = jo.Class
+	}
+	switch {
+	case jo.Ellipse:
+		o.Shape = ShapeEllipse
+	case jo.Point:
+		o.Shape = ShapePoint
+	case jo.Polygon != nil:
+		o.Shape = ShapePolygon
+		o.Points = jo.Polygon
+	case jo.Polyline != nil:
+		o.Shape = ShapePolyline
+		o.Points = jo.Polyline
+	case jo.Gid != 0:
+		o.Shape = ShapeTile
+	}
+	return o
+}
@@ -0,0 +1,280 @@
+// Package tiled loads the map file created by the Tiled Map Editor, both
+// TMX(xml) and JSON format are supported.
+//
+// Tiled: https://www.mapeditor.org
+package tiled
+
+import (
+	"errors"
+	"io"
+	"strconv"
+)
+
+// Gid is the global tile id, the highest bits are used to store the flip flags.
+type Gid uint32
+
+const (
+	FlipHorizontal Gid = 0x80000000
+	FlipVertical   Gid = 0x40000000
+	FlipDiagonal   Gid = 0x20000000
+	RotateHex120   Gid = 0x10000000
+
+	flipMask = FlipHorizontal | FlipVertical | FlipDiagonal | RotateHex120
+)
+
+// Id returns the tile id without flip flags, zero means an empty tile.
+func (gid Gid) Id() uint32 {
+	return uint32(gid &^ flipMask)
+}
+
+func (gid Gid) FlipH() bool {
+	return gid&FlipHorizontal != 0
+}
+
+func (gid Gid) FlipV() bool {
+	return gid&FlipVertical != 0
+}
+
+// FlipD means the tile is flipped anti-diagonally, that's a 90 degree rotation
+// combined with FlipH or FlipV.
+func (gid Gid) FlipD() bool {
+	return gid&FlipDiagonal != 0
+}
+
+// Properties is the custom properties, all values are stored as string.
+type Properties map[string]string
+
+func (p Properties) String(name string) string {
+	return p[name]
+}
+
+func (p Properties) Int(name string) (v int, ok bool) {
+	if s, exist := p[name]; exist {
+		if i, err := strconv.Atoi(s); err == nil {
+			v, ok = i, true
+		}
+	}
+	return
+}
+
+func (p Properties) Float(name string) (v float32, ok bool) {
+	if s, exist := p[name]; exist {
+		if f, err := strconv.ParseFloat(s, 32); err == nil {
+			v, ok = float32(f), true
+		}
+	}
+	return
+}
+
+func (p Properties) Bool(name string) (v bool, ok bool) {
+	if s, exist := p[name]; exist {
+		if b, err := strconv.ParseBool(s); err == nil {
+			v, ok = b, true
+		}
+	}
+	return
+}
+
+// Frame is a frame of animated tile.
+type Frame struct {
+	TileId   uint32
+	Duration int // in milliseconds
+}
+
+// Tile stores the extra information of a tile in TileSet.
+type Tile struct {
+	Id         uint32
+	Properties Properties
+	Animation  []Frame
+}
+
+type TileSet struct {
+	FirstGid   uint32
+	Name       string
+	TileWidth  int
+	TileHeight int
+	TileCount  int
+	Columns    int
+	Spacing    int
+	Margin     int
+
+	// image file, related to the map file
+	Image       string
+	ImageWidth  int
+	ImageHeight int
+
+	Properties Properties
+	Tiles      map[uint32]*Tile
+}
+
+// Region returns the pixel rectangle of local tile id in the image.
+func (ts *TileSet) Region(id uint32) (x, y, w, h int) {
+	cols := ts.Columns
+	if cols == 0 && ts.TileWidth > 0 {
+		cols = (ts.ImageWidth - ts.Margin*2 + ts.Spacing) / (ts.TileWidth + ts.Spacing)
+	}
+	if cols == 0 {
+		return
+	}
+	col, row := int(id)%cols, int(id)/cols
+	x = ts.Margin + col*(ts.TileWidth+ts.Spacing)
+	y = ts.Margin + row*(ts.TileHeight+ts.Spacing)
+	w, h = ts.TileWidth, ts.TileHeight
+	return
+}
+
+// Layer is a tile layer, tiles are stored row by row from the top-left corner.
+// The layers in groups are flattened, the state of groups is combined in the
+// opacity, visibility, offsets and properties of layer.
+type Layer struct {
+	Name       string
+	Width      int
+	Height     int
+	Opacity    float32
+	Visible    bool
+	OffsetX    float32
+	OffsetY    float32
+	Properties Properties
+	Data       []Gid
+}
+
+// layerState is the state a layer inherits from its groups. The visibility is
+// AND-ed, the opacity is multiplied, the offsets are added and the properties
+// of a layer override the ones of its groups.
+type layerState struct {
+	opacity          float32
+	visible          bool
+	offsetX, offsetY float32
+	properties       Properties
+}
+
+var rootState = layerState{opacity: 1, visible: true}
+
+// combine returns the state of a child layer.
+func (ls layerState) combine(opacity float32, visible bool, x, y float32, props Properties) layerState {
+	c := layerState{ls.opacity * opacity, ls.visible && visible, ls.offsetX + x, ls.offsetY + y, props}
+	if len(ls.properties) > 0 {
+		c.properties = make(Properties, len(ls.properties)+len(props))
+		for k, v := range ls.properties {
+			c.properties[k] = v
+		}
+		for k, v := range props {
+			c.properties[k] = v
+		}
+	}
+	return c
+}
+
+// Tile returns the Gid at (x, y), (0, 0) is the top-left corner.
+func (l *Layer) Tile(x, y int) Gid {
+	if x < 0 || y < 0 || x >= l.Width || y >= l.Height {
+		return 0
+	}
+	return l.Data[y*l.Width+x]
+}
+
+type ObjectShape uint8
+
+const (
+	ShapeRect ObjectShape = iota
+	ShapeEllipse
+	ShapePoint
+	ShapePolygon
+	ShapePolyline
+	ShapeTile
+)
+
+type Point struct {
+	X, Y float32
+}
+
+// Object is a object in ObjectGroup, the coordinate is in pixels and
+// the y-axis points down as in Tiled.
+type Object struct {
+	Id       int
+	Name     string
+	Type     string
+	X, Y     float32
+	Width    float32
+	Height   float32
+	Rotation float32
+	Gid      Gid
+	Visible  bool
+
+	Shape      ObjectShape
+	Points     []Point
+	Properties Properties
+}
+
+type ObjectGroup struct {
+	Name       string
+	Opacity    float32
+	Visible    bool
+	OffsetX    float32
+	OffsetY    float32
+	Properties Properties
+	Objects    []Object
+}
+
+// Object returns the first object with the name.
+func (og *ObjectGroup) Object(name string) (obj *Object, ok bool) {
+	for i := range og.Objects {
+		if og.Objects[i].Name == name {
+			return &og.Objects[i], true
+		}
+	}
+	return
+}
+
+type Map struct {
+	Orientation string
+	Width       int
+	Height      int
+	TileWidth   int
+	TileHeight  int
+
+	Properties   Properties
+	TileSets     []TileSet
+	Layers       []Layer
+	ObjectGroups []ObjectGroup
+}
+
+// TileSet returns the TileSet which contains the gid.
+func (m *Map) TileSet(gid Gid) (ts *TileSet, index int) {
+	id := gid.Id()
+	if id == 0 {
+		return nil, -1
+	}
+	for i := len(m.TileSets) - 1; i >= 0; i-- {
+		if m.TileSets[i].FirstGid <= id {
+			return &m.TileSets[i], i
+		}
+	}
+	return nil, -1
+}
+
+// Layer returns the tile layer with the name.
+func (m *Map) Layer(name string) (l *Layer, ok bool) {
+	for i := range m.Layers {
+		if m.Layers[i].Name == name {
+			return &m.Layers[i], true
+		}
+	}
+	return
+}
+
+// ObjectGroup returns the object layer with the name.
+func (m *Map) ObjectGroup(name string) (og *ObjectGroup, ok bool) {
+	for i := range m.ObjectGroups {
+		if m.ObjectGroups[i].Name == name {
+			return &m.ObjectGroups[i], true
+		}
+	}
+	return
+}
+
+// Opener opens the external file(tileset) referenced by the map.
+type Opener func(name string) (io.ReadCloser, error)
+
+var ErrInfinite = errors.New("tiled: infinite map is not supported")
+var ErrExternal = errors.New("tiled: external tileset needs an Opener")
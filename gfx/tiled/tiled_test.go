@@ -0,0 +1,214 @@
+package tiled
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+const tmxData = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.9" orientation="orthogonal" width="3" height="2" tilewidth="16" tileheight="16" infinite="0">
+ <properties>
+  <property name="music" value="forest.ogg"/>
+  <property name="gravity" type="float" value="9.8"/>
+ </properties>
+ <tileset firstgid="1" name="ground" tilewidth="16" tileheight="16" tilecount="8" columns="4" spacing="1" margin="1">
+  <image source="ground.png" width="69" height="35"/>
+  <tile id="2">
+   <properties><property name="solid" type="bool" value="true"/></properties>
+   <animation>
+    <frame tileid="2" duration="100"/>
+    <frame tileid="3" duration="200"/>
+   </animation>
+  </tile>
+ </tileset>
+ <tileset firstgid="9" source="sets/items.tsx"/>
+ <layer id="1" name="bg" width="3" height="2">
+  <data encoding="csv">
+1,2,3,
+2147483652,0,9
+</data>
+ </layer>
+ <group id="4" name="group">
+  <group id="5" name="inner">
+   <layer id="2" name="fg" width="3" height="2" opacity="0.5" visible="0">
+    <data encoding="base64" compression="zlib">eJxjZGBgYAJiZiBmYYAAbiAGAADkABY=</data>
+   </layer>
+  </group>
+ </group>
+ <objectgroup id="3" name="spawn">
+  <object id="1" name="player" type="hero" x="8" y="24"><point/></object>
+  <object id="2" name="zone" x="0" y="0" width="32" height="16"><ellipse/></object>
+  <object id="3" name="path" x="0" y="0"><polyline points="0,0 16,8 32,0"/></object>
+  <object id="4" name="coin" gid="9" x="16" y="16" width="16" height="16"/>
+ </objectgroup>
+ <group id="6" name="hidden" offsetx="4" offsety="8" opacity="0.5" visible="0">
+  <properties>
+   <property name="depth" value="2"/>
+  </properties>
+  <group id="7" name="nested" offsetx="1" offsety="2">
+   <layer id="8" name="deco" width="1" height="1" opacity="0.5" offsetx="2">
+    <properties>
+     <property name="depth" value="3"/>
+     <property name="solid" value="true"/>
+    </properties>
+    <data encoding="csv">1</data>
+   </layer>
+  </group>
+ </group>
+</map>`
+
+const tsxItems = `<?xml version="1.0" encoding="UTF-8"?>
+<tileset name="items" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+ <image source="items.png" width="32" height="32"/>
+</tileset>`
+
+const jsonData = `{
+ "orientation": "orthogonal", "width": 3, "height": 2, "tilewidth": 16, "tileheight": 16, "infinite": false,
+ "properties": [{"name": "music", "type": "string", "value": "forest.ogg"}, {"name": "gravity", "type": "float", "value": 9.8}],
+ "tilesets": [
+  {"firstgid": 1, "name": "ground", "tilewidth": 16, "tileheight": 16, "tilecount": 8, "columns": 4, "spacing": 1, "margin": 1,
+   "image": "ground.png", "imagewidth": 69, "imageheight": 35,
+   "tiles": [{"id": 2, "properties": [{"name": "solid", "type": "bool", "value": true}],
+     "animation": [{"tileid": 2, "duration": 100}, {"tileid": 3, "duration": 200}]}]},
+  {"firstgid": 9, "source": "sets/items.tsj"}
+ ],
+ "layers": [
+  {"type": "tilelayer", "name": "bg", "width": 3, "height": 2, "data": [1, 2, 3, 2147483652, 0, 9]},
+  {"type": "group", "name": "group", "layers": [
+   {"type": "tilelayer", "name": "fg", "width": 3, "height": 2, "opacity": 0.5, "visible": false,
+    "encoding": "base64", "compression": "zlib", "data": "eJxjZGBgYAJiZiBmYYAAbiAGAADkABY="}
+  ]},
+  {"type": "objectgroup", "name": "spawn", "objects": [
+   {"id": 1, "name": "player", "type": "hero", "x": 8, "y": 24, "point": true},
+   {"id": 2, "name": "zone", "x": 0, "y": 0, "width": 32, "height": 16, "ellipse": true},
+   {"id": 3, "name": "path", "x": 0, "y": 0, "polyline": [{"x": 0, "y": 0}, {"x": 16, "y": 8}, {"x": 32, "y": 0}]},
+   {"id": 4, "name": "coin", "gid": 9, "x": 16, "y": 16, "width": 16, "height": 16}
+  ]},
+  {"type": "group", "name": "hidden", "offsetx": 4, "offsety": 8, "opacity": 0.5, "visible": false,
+   "properties": [{"name": "depth", "type": "int", "value": 2}],
+   "layers": [
+    {"type": "group", "name": "nested", "offsetx": 1, "offsety": 2, "layers": [
+     {"type": "tilelayer", "name": "deco", "width": 1, "height": 1, "opacity": 0.5, "offsetx": 2, "data": [1],
+      "properties": [{"name": "depth", "type": "int", "value": 3}, {"name": "solid", "type": "bool", "value": true}]}
+    ]}
+  ]}
+ ]
+}`
+
+const tsjItems = `{"name": "items", "tilewidth": 16, "tileheight": 16, "tilecount": 4, "columns": 2,
+ "image": "items.png", "imagewidth": 32, "imageheight": 32}`
+
+func opener(files map[string]string) Opener {
+	return func(name string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(files[name])), nil
+	}
+}
+
+func TestLoadTMX(t *testing.T) {
+	m, err := LoadTMX(strings.NewReader(tmxData), opener(map[string]string{"sets/items.tsx": tsxItems}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMap(t, m)
+}
+
+func TestLoadJSON(t *testing.T) {
+	m, err := LoadJSON(strings.NewReader(jsonData), opener(map[string]string{"sets/items.tsj": tsjItems}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMap(t, m)
+}
+
+func TestExternalTileSet(t *testing.T) {
+	if _, err := LoadTMX(strings.NewReader(tmxData), nil); err != ErrExternal {
+		t.Error("should fail without Opener, err:", err)
+	}
+	infinite := `<map width="3" height="2" infinite="1"></map>`
+	if _, err := LoadTMX(strings.NewReader(infinite), nil); err != ErrInfinite {
+		t.Error("should fail to load infinite map, err:", err)
+	}
+}
+
+func checkMap(t *testing.T, m *Map) {
+	if m.Width != 3 || m.Height != 2 || m.TileWidth != 16 || m.TileHeight != 16 {
+		t.Error("map size error:", m.Width, m.Height, m.TileWidth, m.TileHeight)
+	}
+	if m.Properties.String("music") != "forest.ogg" {
+		t.Error("string property error:", m.Properties)
+	}
+	if g, ok := m.Properties.Float("gravity"); !ok || g != 9.8 {
+		t.Error("float property error:", m.Properties)
+	}
+
+	// tileset
+	if len(m.TileSets) != 2 {
+		t.Fatal("tileset size error:", len(m.TileSets))
+	}
+	ground, items := &m.TileSets[0], &m.TileSets[1]
+	if x, y, w, h := ground.Region(5); x != 18 || y != 18 || w != 16 || h != 16 {
+		t.Error("tile region error:", x, y, w, h)
+	}
+	if tile := ground.Tiles[2]; tile == nil || len(tile.Animation) != 2 || tile.Animation[1] != (Frame{3, 200}) {
+		t.Error("tile animation error:", tile)
+	} else if solid, _ := tile.Properties.Bool("solid"); !solid {
+		t.Error("tile property error:", tile.Properties)
+	}
+	if items.FirstGid != 9 || items.Name != "items" || items.Image != "sets/items.png" {
+		t.Error("external tileset error:", items.FirstGid, items.Name, items.Image)
+	}
+	if ts, i := m.TileSet(10); ts != items || i != 1 {
+		t.Error("fail to find tileset of gid 10")
+	}
+
+	// tile layer
+	if len(m.Layers) != 3 {
+		t.Fatal("layer size error:", len(m.Layers))
+	}
+	bg, _ := m.Layer("bg")
+	if gid := bg.Tile(0, 1); gid.Id() != 4 || !gid.FlipH() || gid.FlipV() || gid.FlipD() {
+		t.Error("flipped tile error:", gid)
+	}
+	if gid := bg.Tile(2, 1); gid != 9 {
+		t.Error("tile error:", gid)
+	}
+	fg, _ := m.Layer("fg")
+	if fg.Visible || fg.Opacity != .5 {
+		t.Error("layer attribute error:", fg.Visible, fg.Opacity)
+	}
+	expect := []Gid{1, 2, 3, 4, 0, 11}
+	for i, gid := range fg.Data {
+		if gid != expect[i] {
+			t.Error("base64 data error:", fg.Data)
+			break
+		}
+	}
+
+	// state of groups is combined with the layer
+	deco, _ := m.Layer("deco")
+	if deco.Visible || deco.Opacity != .25 || deco.OffsetX != 7 || deco.OffsetY != 10 {
+		t.Error("layer should inherit the group state:", deco.Visible, deco.Opacity, deco.OffsetX, deco.OffsetY)
+	}
+	if deco.Properties.String("depth") != "3" || deco.Properties.String("solid") != "true" {
+		t.Error("layer properties should override the group:", deco.Properties)
+	}
+
+	// object layer
+	og, ok := m.ObjectGroup("spawn")
+	if !ok || len(og.Objects) != 4 {
+		t.Fatal("object layer error")
+	}
+	shapes := []ObjectShape{ShapePoint, ShapeEllipse, ShapePolyline, ShapeTile}
+	for i, o := range og.Objects {
+		if o.Shape != shapes[i] {
+			t.Error("object shape error:", o.Name, o.Shape)
+		}
+	}
+	if player, _ := og.Object("player"); player.Type != "hero" || player.X != 8 || player.Y != 24 {
+		t.Error("object error:", player)
+	}
+	if path, _ := og.Object("path"); len(path.Points) != 3 || path.Points[1] != (Point{16, 8}) {
+		t.Error("polyline error:", path.Points)
+	}
+}
@@ -0,0 +1,388 @@
+package tiled
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// LoadTMX loads a map in TMX(xml) format. External tileset(.tsx) will be
+// opened with the Opener, it can be nil if the map has no external tileset.
+func LoadTMX(r io.Reader, open Opener) (m *Map, err error) {
+	x := &xmlMap{}
+	if err = xml.NewDecoder(r).Decode(x); err != nil {
+		return
+	}
+	if x.Infinite != 0 {
+		return nil, ErrInfinite
+	}
+	m = &Map{
+		Orientation: x.Orientation,
+		Width:       x.Width,
+		Height:      x.Height,
+		TileWidth:   x.TileWidth,
+		TileHeight:  x.TileHeight,
+		Properties:  x.Properties.convert(),
+	}
+
+	// tileset
+	for _, xts := range x.TileSets {
+		if xts.Source != "" {
+			xts, err = loadTSX(xts.FirstGid, xts.Source, open)
+			if err != nil {
+				return
+			}
+		}
+		m.TileSets = append(m.TileSets, xts.convert())
+	}
+
+	// layers
+	if err = m.addTMXLayers(x.Layers, rootState); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// addTMXLayers adds the layers in the document order, groups are flattened
+// and their state is combined with the layers inside.
+func (m *Map) addTMXLayers(layers []xmlLayerItem, parent layerState) (err error) {
+	for _, xl := range layers {
+		visible := xl.Visible == nil || *xl.Visible != 0
+		ls := parent.combine(xl.opacity(), visible, xl.OffsetX, xl.OffsetY, xl.Properties.convert())
+		switch xl.XMLName.Local {
+		case "layer":
+			l := Layer{
+				Name:       xl.Name,
+				Width:      xl.Width,
+				Height:     xl.Height,
+				Opacity:    ls.opacity,
+				Visible:    ls.visible,
+				OffsetX:    ls.offsetX,
+				OffsetY:    ls.offsetY,
+				Properties: ls.properties,
+			}
+			if l.Data, err = xl.Data.decode(xl.Width * xl.Height); err != nil {
+				return fmt.Errorf("tiled: layer %q, %v", xl.Name, err)
+			}
+			m.Layers = append(m.Layers, l)
+		case "objectgroup":
+			og := ObjectGroup{
+				Name:       xl.Name,
+				Opacity:    ls.opacity,
+				Visible:    ls.visible,
+				OffsetX:    ls.offsetX,
+				OffsetY:    ls.offsetY,
+				Properties: ls.properties,
+			}
+			for _, xo := range xl.Objects {
+				og.Objects = append(og.Objects, xo.convert())
+			}
+			m.ObjectGroups = append(m.ObjectGroups, og)
+		case "group":
+			if err = m.addTMXLayers(xl.Layers, ls); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func loadTSX(firstGid uint32, source string, open Opener) (xts xmlTileSet, err error) {
+	if open == nil {
+		err = ErrExternal
+		return
+	}
+	f, err := open(source)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if err = xml.NewDecoder(f).Decode(&xts); err != nil {
+		return
+	}
+	xts.FirstGid = firstGid
+	// image path is related to the tsx file
+	if dir := path.Dir(source); xts.Image.Source != "" && dir != "." {
+		xts.Image.Source = path.Join(dir, xts.Image.Source)
+	}
+	return
+}
+
+// TMX structure
+type xmlMap struct {
+	Orientation string         `xml:"orientation,attr"`
+	Width       int            `xml:"width,attr"`
+	Height      int            `xml:"height,attr"`
+	TileWidth   int            `xml:"tilewidth,attr"`
+	TileHeight  int            `xml:"tileheight,attr"`
+	Infinite    int            `xml:"infinite,attr"`
+	Properties  xmlProperties  `xml:"properties"`
+	TileSets    []xmlTileSet   `xml:"tileset"`
+	Layers      []xmlLayerItem `xml:",any"`
+}
+
+type xmlProperties struct {
+	Property []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:"value,attr"`
+		Text  string `xml:",chardata"`
+	} `xml:"property"`
+}
+
+func (xp xmlProperties) convert() Properties {
+	if len(xp.Property) == 0 {
+		return nil
+	}
+	p := make(Properties, len(xp.Property))
+	for _, v := range xp.Property {
+		if v.Value != "" {
+			p[v.Name] = v.Value
+		} else {
+			// multi-line string is stored as text
+			p[v.Name] = v.Text
+		}
+	}
+	return p
+}
+
+type xmlTileSet struct {
+	FirstGid   uint32        `xml:"firstgid,attr"`
+	Source     string        `xml:"source,attr"`
+	Name       string        `xml:"name,attr"`
+	TileWidth  int           `xml:"tilewidth,attr"`
+	TileHeight int           `xml:"tileheight,attr"`
+	TileCount  int           `xml:"tilecount,attr"`
+	Columns    int           `xml:"columns,attr"`
+	Spacing    int           `xml:"spacing,attr"`
+	Margin     int           `xml:"margin,attr"`
+	Properties xmlProperties `xml:"properties"`
+	Image      struct {
+		Source string `xml:"source,attr"`
+		Width  int    `xml:"width,attr"`
+		Height int    `xml:"height,attr"`
+	} `xml:"image"`
+	Tiles []struct {
+		Id         uint32        `xml:"id,attr"`
+		Properties xmlProperties `xml:"properties"`
+		Animation  struct {
+			Frames []struct {
+				TileId   uint32 `xml:"tileid,attr"`
+				Duration int    `xml:"duration,attr"`
+			} `xml:"frame"`
+		} `xml:"animation"`
+	} `xml:"tile"`
+}
+
+func (xts *xmlTileSet) convert() TileSet {
+	ts := TileSet{
+		FirstGid:    xts.FirstGid,
+		Name:        xts.Name,
+		TileWidth:   xts.TileWidth,
+		TileHeight:  xts.TileHeight,
+		TileCount:   xts.TileCount,
+		Columns:     xts.Columns,
+		Spacing:     xts.Spacing,
+		Margin:      xts.Margin,
+		Image:       xts.Image.Source,
+		ImageWidth:  xts.Image.Width,
+		ImageHeight: xts.Image.Height,
+		Properties:  xts.Properties.convert(),
+		Tiles:       make(map[uint32]*Tile, len(xts.Tiles)),
+	}
+	for _, xt := range xts.Tiles {
+		t := &Tile{Id: xt.Id, Properties: xt.Properties.convert()}
+		for _, f := range xt.Animation.Frames {
+			t.Animation = append(t.Animation, Frame{f.TileId, f.Duration})
+		}
+		ts.Tiles[t.Id] = t
+	}
+	return ts
+}
+
+type xmlLayerBase struct {
+	Name       string        `xml:"name,attr"`
+	Opacity    *float32      `xml:"opacity,attr"`
+	Visible    *int          `xml:"visible,attr"`
+	OffsetX    float32       `xml:"offsetx,attr"`
+	OffsetY    float32       `xml:"offsety,attr"`
+	Properties xmlProperties `xml:"properties"`
+}
+
+func (xl *xmlLayerBase) opacity() float32 {
+	if xl.Opacity == nil {
+		return 1
+	}
+	return *xl.Opacity
+}
+
+// xmlLayerItem is a layer, object group or group. The elements are decoded
+// in the document order, so the layers in groups keep their order like the
+// JSON format.
+type xmlLayerItem struct {
+	XMLName xml.Name
+	xmlLayerBase
+
+	// layer
+	Width  int     `xml:"width,attr"`
+	Height int     `xml:"height,attr"`
+	Data   xmlData `xml:"data"`
+
+	// objectgroup
+	Objects []xmlObject `xml:"object"`
+
+	// group
+	Layers []xmlLayerItem `xml:",any"`
+}
+
+type xmlData struct {
+	Encoding    string `xml:"encoding,attr"`
+	Compression string `xml:"compression,attr"`
+	Text        string `xml:",chardata"`
+	Tiles       []struct {
+		Gid Gid `xml:"gid,attr"`
+	} `xml:"tile"`
+}
+
+func (xd *xmlData) decode(size int) (data []Gid, err error) {
+	switch xd.Encoding {
+	case "csv":
+		data, err = decodeCSV(xd.Text)
+	case "base64":
+		data, err = decodeBase64(xd.Text, xd.Compression)
+	case "":
+		data = make([]Gid, len(xd.Tiles))
+		for i, t := range xd.Tiles {
+			data[i] = t.Gid
+		}
+	default:
+		err = fmt.Errorf("unsupported encoding: %s", xd.Encoding)
+	}
+	if err == nil && len(data) != size {
+		err = fmt.Errorf("tile size not match: %d != %d", len(data), size)
+	}
+	return
+}
+
+func decodeCSV(text string) (data []Gid, err error) {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' ' || r == '\t'
+	})
+	data = make([]Gid, len(fields))
+	for i, f := range fields {
+		v, e := strconv.ParseUint(f, 10, 32)
+		if e != nil {
+			return nil, e
+		}
+		data[i] = Gid(v)
+	}
+	return
+}
+
+func decodeBase64(text, compression string) (data []Gid, err error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(text))
+	if err != nil {
+		return
+	}
+	var r io.Reader = bytes.NewReader(raw)
+	switch compression {
+	case "":
+	case "zlib":
+		if r, err = zlib.NewReader(r); err != nil {
+			return
+		}
+	case "gzip":
+		if r, err = gzip.NewReader(r); err != nil {
+			return
+		}
+	default:
+		return nil, fmt.Errorf("unsupported compression: %s", compression)
+	}
+	if raw, err = io.ReadAll(r); err != nil {
+		return
+	}
+	data = make([]Gid, len(raw)/4)
+	for i := range data {
+		data[i] = Gid(binary.LittleEndian.Uint32(raw[i*4:]))
+	}
+	return
+}
+
+type xmlObject struct {
+	Id         int           `xml:"id,attr"`
+	Name       string        `xml:"name,attr"`
+	Type       string        `xml:"type,attr"`
+	Class      string        `xml:"class,attr"`
+	X          float32       `xml:"x,attr"`
+	Y          float32       `xml:"y,attr"`
+	Width      float32       `xml:"width,attr"`
+	Height     float32       `xml:"height,attr"`
+	Rotation   float32       `xml:"rotation,attr"`
+	Gid        Gid           `xml:"gid,attr"`
+	Visible    *int          `xml:"visible,attr"`
+	Properties xmlProperties `xml:"properties"`
+	Ellipse    *struct{}     `xml:"ellipse"`
+	Point      *struct{}     `xml:"point"`
+	Polygon    *struct {
+		Points string `xml:"points,attr"`
+	} `xml:"polygon"`
+	Polyline *struct {
+		Points string `xml:"points,attr"`
+	} `xml:"polyline"`
+}
+
+func (xo *xmlObject) convert() Object {
+	o := Object{
+		Id:         xo.Id,
+		Name:       xo.Name,
+		Type:       xo.Type,
+		X:          xo.X,
+		Y:          xo.Y,
+		Width:      xo.Width,
+		Height:     xo.Height,
+		Rotation:   xo.Rotation,
+		Gid:        xo.Gid,
+		Visible:    xo.Visible == nil || *xo.Visible != 0,
+		Properties: xo.Properties.convert(),
+	}
+	if o.Type == "" {
+		o.Type = xo.Class
+	}
+	switch {
+	case xo.Ellipse != nil:
+		o.Shape = ShapeEllipse
+	case xo.Point != nil:
+		o.Shape = ShapePoint
+	case xo.Polygon != nil:
+		o.Shape = ShapePolygon
+		o.Points = parsePoints(xo.Polygon.Points)
+	case xo.Polyline != nil:
+		o.Shape = ShapePolyline
+		o.Points = parsePoints(xo.Polyline.Points)
+	case xo.Gid != 0:
+		o.Shape = ShapeTile
+	}
+	return o
+}
+
+// format: "0,0 32,0 32,32"
+func parsePoints(s string) (points []Point) {
+	for _, pair := range strings.Fields(s) {
+		xy := strings.Split(pair, ",")
+		if len(xy) != 2 {
+			continue
+		}
+		x, _ := strconv.ParseFloat(xy[0], 32)
+		y, _ := strconv.ParseFloat(xy[1], 32)
+		points = append(points, Point{float32(x), float32(y)})
+	}
+	return
+}
@@ -0,0 +1,521 @@
+package gfx
+
+import (
+	"sckorok/engi"
+	"sckorok/gfx/bk"
+	"sckorok/gfx/tiled"
+	"sckorok/math"
+	"sckorok/math/f32"
+
+	"unsafe"
+)
+
+// TileMap is a tiled.Map with the textures of it's tilesets,
+// Textures[i] is the image of Map.TileSets[i].
+type TileMap struct {
+	*tiled.Map
+	Textures []Tex2D
+}
+
+// tiles of a layer are split into chunks(ChunkSize x ChunkSize),
+// each chunk is a static mesh, so we can cull the invisible part of the map.
+const TileChunkSize = 16
+
+type tileChunk struct {
+	Mesh
+	layer int
+	dirty bool
+
+	// local bounds
+	min, max f32.Vec2
+}
+
+// an animated tile, vertex is the first vertex in chunk
+type animatedTile struct {
+	chunk  int
+	vertex int
+	gid    tiled.Gid
+	ts     *tiled.TileSet
+	tile   *tiled.Tile
+	period int
+	frame  int
+}
+
+// TileMapComp renders all visible tile layers of a TileMap, layer i is drawn
+// at z-order (Z + i). The bottom-left corner of the map is placed at the
+// position of the Transform, the y-axis points up as the other components.
+type TileMapComp struct {
+	engi.Entity
+	zOrder
+	materialId
+
+	tileMap *TileMap
+	visible bool
+	color   uint32
+
+	chunks   []tileChunk
+	animated []animatedTile
+
+	// animation clock, in milliseconds
+	clock float32
+}
+
+func (tc *TileMapComp) SetTileMap(tm *TileMap) {
+	tc.release()
+	tc.tileMap = tm
+	if tm != nil && tm.Map != nil {
+		tc.build()
+	}
+}
+
+func (tc *TileMapComp) TileMap() *TileMap {
+	return tc.tileMap
+}
+
+func (tc *TileMapComp) Color() Color {
+	return U32Color(tc.color)
+}
+
+// SetColor sets the tint color of all layers.
+func (tc *TileMapComp) SetColor(c Color) {
+	tc.color = c.U32()
+	if tc.tileMap != nil && tc.tileMap.Map != nil {
+		tc.release()
+		tc.build()
+	}
+}
+
+func (tc *TileMapComp) Visible() bool {
+	return tc.visible
+}
+
+func (tc *TileMapComp) SetVisible(v bool) {
+	tc.visible = v
+}
+
+// Size returns the size of the map in pixels.
+func (tc *TileMapComp) Size() (w, h float32) {
+	if m := tc.tileMap; m != nil && m.Map != nil {
+		w, h = float32(m.Width*m.TileWidth), float32(m.Height*m.TileHeight)
+	}
+	return
+}
+
+// MapToLocal converts the tile coordinate (column, row from the top-left corner)
+// to the local position of the tile's bottom-left corner.
+func (tc *TileMapComp) MapToLocal(col, row int) (x, y float32) {
+	if m := tc.tileMap; m != nil && m.Map != nil {
+		x = float32(col * m.TileWidth)
+		y = float32((m.Height - 1 - row) * m.TileHeight)
+	}
+	return
+}
+
+// LocalToMap converts the local position to tile coordinate.
+func (tc *TileMapComp) LocalToMap(x, y float32) (col, row int) {
+	if m := tc.tileMap; m != nil && m.Map != nil {
+		col = int(math.Floor(x / float32(m.TileWidth)))
+		row = m.Height - 1 - int(math.Floor(y/float32(m.TileHeight)))
+	}
+	return
+}
+
+// Refresh rebuilds the meshes, call it after the tile data of the map is changed.
+func (tc *TileMapComp) Refresh() {
+	tc.SetTileMap(tc.tileMap)
+}
+
+func (tc *TileMapComp) build() {
+	m := tc.tileMap
+	for li := range m.Layers {
+		layer := &m.Layers[li]
+		if !layer.Visible {
+			continue
+		}
+		color := tc.layerColor(layer.Opacity)
+		for cy := 0; cy < layer.Height; cy += TileChunkSize {
+			for cx := 0; cx < layer.Width; cx += TileChunkSize {
+				tc.buildChunk(li, cx, cy, color)
+			}
+		}
+	}
+}
+
+// one chunk may use several tilesets, split them into meshes
+func (tc *TileMapComp) buildChunk(li, cx, cy int, color uint32) {
+	var (
+		m     = tc.tileMap
+		layer = &m.Layers[li]
+		first = len(tc.chunks)
+	)
+	for y := cy; y < cy+TileChunkSize && y < layer.Height; y++ {
+		for x := cx; x < cx+TileChunkSize && x < layer.Width; x++ {
+			gid := layer.Data[y*layer.Width+x]
+			ts, tsi := m.TileSet(gid)
+			if ts == nil || tsi >= len(m.Textures) || m.Textures[tsi] == nil {
+				continue
+			}
+			tex := m.Textures[tsi].Tex()
+
+			// find the chunk which uses the same texture
+			ci := first
+			for ; ci < len(tc.chunks); ci++ {
+				if tc.chunks[ci].textureId == tex {
+					break
+				}
+			}
+			if ci == len(tc.chunks) {
+				tc.chunks = append(tc.chunks, tileChunk{layer: li})
+				tc.chunks[ci].textureId = tex
+				tc.chunks[ci].min = f32.Vec2{math.MaxFloat32, math.MaxFloat32}
+				tc.chunks[ci].max = f32.Vec2{-math.MaxFloat32, -math.MaxFloat32}
+			}
+			chunk := &tc.chunks[ci]
+
+			// position, the tile is aligned to the bottom-left corner of the cell
+			px, py := tc.MapToLocal(x, y)
+			px, py = px+layer.OffsetX, py-layer.OffsetY
+			w, h := float32(ts.TileWidth), float32(ts.TileHeight)
+			vertex := len(chunk.vertex)
+			chunk.vertex = append(chunk.vertex,
+				PosTexColorVertex{X: px, Y: py, RGBA: color},
+				PosTexColorVertex{X: px + w, Y: py, RGBA: color},
+				PosTexColorVertex{X: px + w, Y: py + h, RGBA: color},
+				PosTexColorVertex{X: px, Y: py + h, RGBA: color},
+			)
+			tileUV(chunk.vertex[vertex:], m.Textures[tsi], ts, gid.Id()-ts.FirstGid, gid)
+			chunk.min[0], chunk.min[1] = math.Min(chunk.min[0], px), math.Min(chunk.min[1], py)
+			chunk.max[0], chunk.max[1] = math.Max(chunk.max[0], px+w), math.Max(chunk.max[1], py+h)
+
+			// animated tile
+			if tile, ok := ts.Tiles[gid.Id()-ts.FirstGid]; ok && len(tile.Animation) > 0 {
+				at := animatedTile{chunk: ci, vertex: vertex, gid: gid, ts: ts, tile: tile}
+				for _, f := range tile.Animation {
+					at.period += f.Duration
+				}
+				tc.animated = append(tc.animated, at)
+			}
+		}
+	}
+	for i := first; i < len(tc.chunks); i++ {
+		chunk := &tc.chunks[i]
+		chunk.NumVertex = uint16(len(chunk.vertex))
+		chunk.NumIndex = uint16(len(chunk.vertex) / 4 * 6)
+	}
+}
+
+func (tc *TileMapComp) layerColor(opacity float32) uint32 {
+	// premultiplied alpha
+	c := U32Color(tc.color)
+	a := float32(c.A) / 255 * opacity
+	c.R = uint8(float32(c.R) * a)
+	c.G = uint8(float32(c.G) * a)
+	c.B = uint8(float32(c.B) * a)
+	c.A = uint8(a * 255)
+	return c.U32()
+}
+
+// tileUV fills the texture coordinate of a tile quad(BL, BR, TR, TL), flip
+// flags are applied in the order of Tiled: diagonal, horizontal, vertical.
+func tileUV(buf []PosTexColorVertex, tex Tex2D, ts *tiled.TileSet, id uint32, gid tiled.Gid) {
+	x, y, w, h := ts.Region(id)
+	iw, ih := float32(ts.ImageWidth), float32(ts.ImageHeight)
+	if iw == 0 || ih == 0 {
+		sz := tex.Size()
+		iw, ih = sz.Width, sz.Height
+	}
+	rg := tex.Region()
+	u1 := rg.X1 + (rg.X2-rg.X1)*float32(x)/iw
+	u2 := rg.X1 + (rg.X2-rg.X1)*float32(x+w)/iw
+	v1 := rg.Y1 + (rg.Y2-rg.Y1)*float32(y)/ih
+	v2 := rg.Y1 + (rg.Y2-rg.Y1)*float32(y+h)/ih
+
+	// corners in tile space, (0, 0) is the top-left corner
+	corners := [4][2]int{{0, 1}, {1, 1}, {1, 0}, {0, 0}}
+	for i, c := range corners {
+		s, t := c[0], c[1]
+		if gid.FlipH() {
+			s = 1 - s
+		}
+		if gid.FlipV() {
+			t = 1 - t
+		}
+		if gid.FlipD() {
+			s, t = t, s
+		}
+		buf[i].U, buf[i].V = u1, v1
+		if s == 1 {
+			buf[i].U = u2
+		}
+		if t == 1 {
+			buf[i].V = v2
+		}
+	}
+}
+
+// animate updates the uv of animated tiles.
+func (tc *TileMapComp) animate(dt float32) {
+	if len(tc.animated) == 0 {
+		return
+	}
+	tc.clock += dt * 1000
+	m := tc.tileMap
+	for i := range tc.animated {
+		at := &tc.animated[i]
+		if at.period <= 0 {
+			continue
+		}
+		t, frame := int(tc.clock)%at.period, 0
+		for ; frame < len(at.tile.Animation)-1; frame++ {
+			if t < at.tile.Animation[frame].Duration {
+				break
+			}
+			t -= at.tile.Animation[frame].Duration
+		}
+		if frame == at.frame {
+			continue
+		}
+		at.frame = frame
+		chunk := &tc.chunks[at.chunk]
+		_, tsi := m.TileSet(at.gid)
+		tileUV(chunk.vertex[at.vertex:], m.Textures[tsi], at.ts, at.tile.Animation[frame].TileId, at.gid)
+		chunk.dirty = true
+	}
+}
+
+// upload vertex data of the chunk to the gpu
+func (chunk *tileChunk) upload() {
+	if chunk.VertexId == bk.InvalidId {
+		mem := bk.Memory{Data: unsafe.Pointer(&chunk.vertex[0]), Size: uint32(len(chunk.vertex)) * 20}
+		if id, _ := bk.R.AllocVertexBuffer(mem, 20); id != bk.InvalidId {
+			chunk.VertexId = id
+		}
+		chunk.IndexId, _ = Context.SharedIndexBuffer()
+		chunk.dirty = false
+	} else if chunk.dirty {
+		if ok, vb := bk.R.VertexBuffer(chunk.VertexId); ok {
+			vb.Update(0, uint32(len(chunk.vertex))*uint32(PosTexColorVertexSize), unsafe.Pointer(&chunk.vertex[0]), false)
+		}
+		chunk.dirty = false
+	}
+}
+
+func (tc *TileMapComp) release() {
+	for i := range tc.chunks {
+		if id := tc.chunks[i].VertexId; id != bk.InvalidId {
+			bk.R.Free(id)
+		}
+	}
+	tc.chunks = tc.chunks[:0]
+	tc.animated = tc.animated[:0]
+}
+
+// TileMapTable
+type TileMapTable struct {
+	comps      []TileMapComp
+	_map       map[uint32]int
+	index, cap int
+}
+
+func NewTileMapTable(cap int) *TileMapTable {
+	return &TileMapTable{cap: cap, _map: make(map[uint32]int)}
+}
+
+func (tt *TileMapTable) NewComp(entity engi.Entity) (tc *TileMapComp) {
+	if size := len(tt.comps); tt.index >= size {
+		tt.comps = tileMapResize(tt.comps, size+STEP)
+	}
+	ei := entity.Index()
+	if v, ok := tt._map[ei]; ok {
+		return &tt.comps[v]
+	}
+	tc = &tt.comps[tt.index]
+	tc.Entity = entity
+	tc.color = 0xFFFFFFFF
+	tc.visible = true
+	tt._map[ei] = tt.index
+	tt.index++
+	return
+}
+
+// New TileMapComp with parameter
+func (tt *TileMapTable) NewCompX(entity engi.Entity, tm *TileMap) (tc *TileMapComp) {
+	tc = tt.NewComp(entity)
+	tc.SetTileMap(tm)
+	return
+}
+
+func (tt *TileMapTable) Alive(entity engi.Entity) bool {
+	if v, ok := tt._map[entity.Index()]; ok {
+		return tt.comps[v].Entity != 0
+	}
+	return false
+}
+
+func (tt *TileMapTable) Comp(entity engi.Entity) (tc *TileMapComp) {
+	if v, ok := tt._map[entity.Index()]; ok {
+		tc = &tt.comps[v]
+	}
+	return
+}
+
+func (tt *TileMapTable) Delete(entity engi.Entity) {
+	ei := entity.Index()
+	if v, ok := tt._map[ei]; ok {
+		tt.comps[v].release()
+		if tail := tt.index - 1; v != tail && tail > 0 {
+			tt.comps[v] = tt.comps[tail]
+			// remap index
+			tComp := &tt.comps[tail]
+			ei := tComp.Entity.Index()
+			tt._map[ei] = v
+			tt.comps[tail] = TileMapComp{}
+		} else {
+			tt.comps[tail] = TileMapComp{}
+		}
+
+		tt.index -= 1
+		delete(tt._map, ei)
+	}
+}
+
+func (tt *TileMapTable) Size() (size, cap int) {
+	return tt.index, tt.cap
+}
+
+func (tt *TileMapTable) Destroy() {
+	for i := range tt.comps[:tt.index] {
+		tt.comps[i].release()
+	}
+	tt.comps = make([]TileMapComp, 0)
+	tt._map = make(map[uint32]int)
+	tt.index = 0
+}
+
+// Animate steps the animated tiles, it's called by the AnimationSystem.
+func (tt *TileMapTable) Animate(dt float32) {
+	for i := range tt.comps[:tt.index] {
+		tt.comps[i].animate(dt)
+	}
+}
+
+func tileMapResize(slice []TileMapComp, size int) []TileMapComp {
+	newSlice := make([]TileMapComp, size)
+	copy(newSlice, slice)
+	return newSlice
+}
+
+// TileMapRenderFeature draws TileMapComp with the MeshRender.
+type TileMapRenderFeature struct {
+	id int
+
+	R  *MeshRender
+	tt *TileMapTable
+	xt *TransformTable
+	mt *MaskTable
+	pt *ParallaxTable
+
+	// visible chunks of current frame
+	chunks []tileChunkRef
+}
+
+// a visible chunk, offset is the position of a repeated copy
+type tileChunkRef struct {
+	comp, chunk int
+	offset      f32.Vec2
+}
+
+// 此处初始化所有的依赖
+func (f *TileMapRenderFeature) Register(rs *RenderSystem) {
+	// init render
+	for _, r := range rs.RenderList {
+		if mr, ok := r.(*MeshRender); ok {
+			f.R = mr
+			break
+		}
+	}
+	// init table
+	for _, t := range rs.TableList {
+		switch table := t.(type) {
+		case *TileMapTable:
+			f.tt = table
+		case *TransformTable:
+			f.xt = table
+		case *MaskTable:
+			f.mt = table
+		case *ParallaxTable:
+			f.pt = table
+		}
+	}
+	// add new feature
+	f.id = rs.Accept(f)
+}
+
+// Extract culls chunks with the camera, rotation of the Transform is ignored.
+func (f *TileMapRenderFeature) Extract(v *View) {
+	var (
+		fi                       = uint32(f.id) << 16
+		left, right, bottom, top = v.Camera.P()
+	)
+	for i := range f.tt.comps[:f.tt.index] {
+		tc := &f.tt.comps[i]
+		if !tc.visible || len(tc.chunks) == 0 {
+			continue
+		}
+		srt := f.xt.Comp(tc.Entity).world
+		s := srt.Scale
+		nx, ny, step := f.pt.Copies(tc.Entity)
+		for n := 0; n < nx*ny; n++ {
+			offset := f32.Vec2{float32(n%nx) * step[0], float32(n/nx) * step[1]}
+			p := srt.Position.Add(offset)
+			for ci := range tc.chunks {
+				chunk := &tc.chunks[ci]
+				x1, x2 := p[0]+chunk.min[0]*s[0], p[0]+chunk.max[0]*s[0]
+				y1, y2 := p[1]+chunk.min[1]*s[1], p[1]+chunk.max[1]*s[1]
+				if x1 > x2 {
+					x1, x2 = x2, x1
+				}
+				if y1 > y2 {
+					y1, y2 = y2, y1
+				}
+				if x2 < left || x1 > right || y2 < bottom || y1 > top {
+					continue
+				}
+				sid := Layers.Pack(tc.layer, tc.zOrder.value+int16(chunk.layer), srt.Position[1], tc.key, tc.materialId.value, chunk.textureId)
+				val := fi + uint32(len(f.chunks))
+				f.chunks = append(f.chunks, tileChunkRef{i, ci, offset})
+				v.RenderNodes = append(v.RenderNodes, SortObject{sid, val})
+			}
+		}
+	}
+}
+
+func (f *TileMapRenderFeature) Draw(nodes RenderNodes) {
+	mat4 := f32.Ident4()
+	for _, b := range nodes {
+		ref := f.chunks[b.Value&0xFFFF]
+		tc := &f.tt.comps[ref.comp]
+		chunk := &tc.chunks[ref.chunk]
+		chunk.upload()
+		chunk.material = tc.materialId.value
+
+		// construct matrix from scale/rotation/translate
+		srt := f.xt.Comp(tc.Entity).world
+		c, s := math.Cos(srt.Rotation), math.Sin(srt.Rotation)
+
+		mat4[0] = c * srt.Scale[0]
+		mat4[1] = s * srt.Scale[0]
+		mat4[4] = -s * srt.Scale[1]
+		mat4[5] = c * srt.Scale[1]
+		mat4[8] = srt.Position[0] + ref.offset[0]
+		mat4[9] = srt.Position[1] + ref.offset[1]
+
+		z, _ := UnpackSortId(b.SortId)
+		f.R.SetStencil(f.mt.Stencil(tc.Entity))
+		f.R.Draw(&chunk.Mesh, &mat4, int32(z))
+	}
+}
+
+func (f *TileMapRenderFeature) Flush() {
+	f.chunks = f.chunks[:0]
+}
@@ -0,0 +1,633 @@
+package gfx
+
+import (
+	"sckorok/engi"
+	"sckorok/math"
+	"sckorok/math/ease"
+	"sckorok/math/f32"
+)
+
+// TrailUVMode decides how the texture is mapped along the trail.
+type TrailUVMode uint8
+
+const (
+	// the texture is stretched from the head to the tail
+	TrailStretch TrailUVMode = iota
+	// the texture is repeated every tile length, see SetUVMode
+	TrailTile
+)
+
+const (
+	// max points of a trail, the oldest points are dropped if exceeded
+	maxTrailPoints = 256
+	// max quads of a tiled trail, fall back to stretch if exceeded
+	maxTrailQuads = 1024
+)
+
+// TrailCurve is a value from the head(t=0) to the tail(t=1) of trail, it's
+// linear if Ease is nil.
+type TrailCurve struct {
+	Head, Tail float32
+	Ease       ease.Function
+}
+
+func (c TrailCurve) Value(t float32) float32 {
+	if c.Ease != nil {
+		t = float32(c.Ease(float64(t)))
+	}
+	return c.Head + (c.Tail-c.Head)*t
+}
+
+// TrailGradient is a color from the head(t=0) to the tail(t=1) of trail, it's
+// linear if Ease is nil. Colors are multiplied with the texture as the color
+// of sprite, use premultiplied colors to fade out.
+type TrailGradient struct {
+	Head, Tail Color
+	Ease       ease.Function
+}
+
+func (g TrailGradient) Value(t float32) Color {
+	if g.Ease != nil {
+		t = float32(g.Ease(float64(t)))
+	}
+	return lerpColor(g.Head, g.Tail, t)
+}
+
+func lerpColor(a, b Color, t float32) Color {
+	lerp := func(x, y uint8) uint8 {
+		return uint8(float32(x) + (float32(y)-float32(x))*t)
+	}
+	return Color{lerp(a.R, b.R), lerp(a.G, b.G), lerp(a.B, b.B), lerp(a.A, b.A)}
+}
+
+// TrailComp records the world positions of entity and draws a strip along
+// them, it's used to draw the motion trails of swords, bullets and dashes.
+//
+// A point lives for the time of trail, the tail shrinks to the head and
+// fades out with the gradient when the entity stops. A trail without time
+// is limited by the length only.
+type TrailComp struct {
+	engi.Entity
+	zOrder
+	batchId
+	materialId
+
+	tex      Tex2D
+	width    TrailCurve
+	gradient TrailGradient
+	uvMode   TrailUVMode
+	tile     float32
+
+	duration    float32
+	length      float32
+	minDistance float32
+
+	emitting bool
+	visible  bool
+
+	// recorded points, the oldest first
+	points []trailPoint
+	// the last point follows the entity
+	live bool
+	// the next point starts a new strip
+	cut bool
+}
+
+type trailPoint struct {
+	pos f32.Vec2
+	age float32
+	// not connected to the older point
+	cut bool
+}
+
+// SetTexture sets the texture of trail, use a white sprite to draw a plain
+// color trail.
+func (tc *TrailComp) SetTexture(tex Tex2D) {
+	tc.tex = tex
+	tc.batchId.value = tex.Tex()
+}
+
+func (tc *TrailComp) Texture() Tex2D {
+	return tc.tex
+}
+
+// SetWidth sets the width along the trail.
+func (tc *TrailComp) SetWidth(c TrailCurve) {
+	tc.width = c
+}
+
+func (tc *TrailComp) Width() TrailCurve {
+	return tc.width
+}
+
+// SetGradient sets the color along the trail.
+func (tc *TrailComp) SetGradient(g TrailGradient) {
+	tc.gradient = g
+}
+
+func (tc *TrailComp) Gradient() TrailGradient {
+	return tc.gradient
+}
+
+// SetUVMode sets the texture mapping, tile is the length of a repeated
+// texture in TrailTile mode.
+func (tc *TrailComp) SetUVMode(mode TrailUVMode, tile float32) {
+	tc.uvMode, tc.tile = mode, tile
+}
+
+func (tc *TrailComp) UVMode() (mode TrailUVMode, tile float32) {
+	return tc.uvMode, tc.tile
+}
+
+// SetTime sets how long a point lives in seconds, zero means forever.
+func (tc *TrailComp) SetTime(t float32) {
+	tc.duration = t
+}
+
+func (tc *TrailComp) Time() float32 {
+	return tc.duration
+}
+
+// SetLength sets the max length of trail, zero means no limit.
+func (tc *TrailComp) SetLength(l float32) {
+	tc.length = l
+}
+
+func (tc *TrailComp) Length() float32 {
+	return tc.length
+}
+
+// SetMinDistance sets the min distance between the recorded points, a
+// smaller distance makes a smoother trail with more vertices.
+func (tc *TrailComp) SetMinDistance(d float32) {
+	tc.minDistance = d
+}
+
+func (tc *TrailComp) MinDistance() float32 {
+	return tc.minDistance
+}
+
+// SetEmitting pauses or resumes recording, the recorded points keep fading
+// out when paused. A new strip is started when resumed.
+func (tc *TrailComp) SetEmitting(v bool) {
+	if tc.emitting && !v {
+		tc.live = false
+		tc.cut = true
+	}
+	tc.emitting = v
+}
+
+func (tc *TrailComp) Emitting() bool {
+	return tc.emitting
+}
+
+// Clear removes all the points, e.g. when the entity is teleported.
+func (tc *TrailComp) Clear() {
+	tc.points = tc.points[:0]
+	tc.live = false
+	tc.cut = false
+}
+
+func (tc *TrailComp) SetVisible(v bool) {
+	tc.visible = v
+}
+
+func (tc *TrailComp) Visible() bool {
+	return tc.visible
+}
+
+// update records the head and drops the dead points.
+func (tc *TrailComp) update(dt float32, head f32.Vec2) {
+	pts := tc.points
+	for i := range pts {
+		pts[i].age += dt
+	}
+
+	if tc.emitting {
+		if n := len(pts); tc.live {
+			last := &pts[n-1]
+			last.pos, last.age = head, 0
+			// far enough, keep it and record a new one next time
+			if n > 1 && head.Sub(pts[n-2].pos).Len() >= tc.minDistance {
+				tc.live = false
+			}
+		} else {
+			// the first point of a strip is kept, the next one follows
+			cut := tc.cut || n == 0
+			pts = append(pts, trailPoint{pos: head, cut: cut})
+			tc.live, tc.cut = !cut, false
+		}
+	}
+	if n := len(pts); n > maxTrailPoints {
+		pts = append(pts[:0], pts[n-maxTrailPoints:]...)
+	}
+	if tc.duration > 0 {
+		pts = tc.dropOld(pts)
+	}
+	if tc.length > 0 {
+		pts = tc.dropFar(pts)
+	}
+	if len(pts) == 0 {
+		tc.live = false
+	}
+	tc.points = pts
+}
+
+// dropOld drops the points older than the time, the tail is moved to the
+// position at the time to shrink smoothly.
+func (tc *TrailComp) dropOld(pts []trailPoint) []trailPoint {
+	d, k := tc.duration, 0
+	for n := len(pts); k < n && pts[k].age >= d; k++ {
+		if k+1 < n && !pts[k+1].cut && pts[k+1].age < d {
+			break
+		}
+	}
+	pts = append(pts[:0], pts[k:]...)
+
+	if len(pts) > 1 && pts[0].age > d {
+		a, b := &pts[0], pts[1]
+		f := (a.age - d) / (a.age - b.age)
+		a.pos = a.pos.Add(b.pos.Sub(a.pos).Mul(f))
+		a.age = d
+	}
+	return pts
+}
+
+// dropFar drops the points farther than the length from the head.
+func (tc *TrailComp) dropFar(pts []trailPoint) []trailPoint {
+	var sum float32
+	for i := len(pts) - 1; i > 0; i-- {
+		if pts[i].cut {
+			continue
+		}
+		seg := pts[i].pos.Sub(pts[i-1].pos)
+		l := seg.Len()
+		if sum+l <= tc.length {
+			sum += l
+			continue
+		}
+		// cut the segment at the length
+		f := (tc.length - sum) / l
+		p := &pts[i-1]
+		p.pos = pts[i].pos.Sub(seg.Mul(f))
+		p.age = pts[i].age + (p.age-pts[i].age)*f
+		p.cut = false
+		return append(pts[:0], pts[i-1:]...)
+	}
+	return pts
+}
+
+// bound returns the bounding box of trail.
+func (tc *TrailComp) bound() (a AABB) {
+	if len(tc.points) == 0 {
+		return
+	}
+	min, max := tc.points[0].pos, tc.points[0].pos
+	for _, p := range tc.points[1:] {
+		min[0], min[1] = math.Min(min[0], p.pos[0]), math.Min(min[1], p.pos[1])
+		max[0], max[1] = math.Max(max[0], p.pos[0]), math.Max(max[1], p.pos[1])
+	}
+	r := math.Max(math.ABS(tc.width.Head), math.ABS(tc.width.Tail)) / 2
+	return AABB{min[0] - r, min[1] - r, max[0] - min[0] + 2*r, max[1] - min[1] + 2*r}
+}
+
+type TrailTable struct {
+	comps      []TrailComp
+	_map       map[uint32]int
+	index, cap int
+}
+
+func NewTrailTable(cap int) *TrailTable {
+	return &TrailTable{
+		cap:  cap,
+		_map: make(map[uint32]int),
+	}
+}
+
+func (tt *TrailTable) NewComp(entity engi.Entity) (tc *TrailComp) {
+	if size := len(tt.comps); tt.index >= size {
+		tt.comps = trailResize(tt.comps, size+STEP)
+	}
+	ei := entity.Index()
+	if v, ok := tt._map[ei]; ok {
+		tc = &tt.comps[v]
+		return
+	}
+	tc = &tt.comps[tt.index]
+	tc.Entity = entity
+	tc.width = TrailCurve{Head: 16}
+	tc.gradient = TrailGradient{Head: White, Tail: Transparent}
+	tc.tile = 32
+	tc.duration = .5
+	tc.minDistance = 4
+	tc.emitting = true
+	tc.visible = true
+	tt._map[ei] = tt.index
+	tt.index++
+	return
+}
+
+func (tt *TrailTable) Alive(entity engi.Entity) bool {
+	ei := entity.Index()
+	if v, ok := tt._map[ei]; ok {
+		return tt.comps[v].Entity != 0
+	}
+	return false
+}
+
+func (tt *TrailTable) Comp(entity engi.Entity) (tc *TrailComp) {
+	ei := entity.Index()
+	if v, ok := tt._map[ei]; ok {
+		tc = &tt.comps[v]
+	}
+	return
+}
+
+func (tt *TrailTable) Delete(entity engi.Entity) {
+	ei := entity.Index()
+	if v, ok := tt._map[ei]; ok {
+		if tail := tt.index - 1; v != tail && tail > 0 {
+			tt.comps[v] = tt.comps[tail]
+			// remap index
+			tComp := tt.comps[tail]
+			ei := tComp.Entity.Index()
+			tt._map[ei] = v
+			tt.comps[tail] = TrailComp{}
+		} else {
+			tt.comps[tail] = TrailComp{}
+		}
+
+		tt.index -= 1
+		delete(tt._map, ei)
+	}
+}
+
+func (tt *TrailTable) Size() (size, cap int) {
+	return tt.index, tt.cap
+}
+
+func (tt *TrailTable) Destroy() {
+	tt.comps = make([]TrailComp, 0)
+	tt._map = make(map[uint32]int)
+	tt.index = 0
+}
+
+func trailResize(slice []TrailComp, size int) []TrailComp {
+	newSlice := make([]TrailComp, size)
+	copy(newSlice, slice)
+	return newSlice
+}
+
+// TrailRenderFeature records the trails and draws them with BatchRender.
+type TrailRenderFeature struct {
+	id int
+
+	R  *BatchRender
+	tt *TrailTable
+	xt *TransformTable
+	mt *MaskTable
+
+	// reused by all trails
+	obj trailBatchObject
+}
+
+func (f *TrailRenderFeature) SetRender(render *BatchRender) {
+	f.R = render
+}
+
+func (f *TrailRenderFeature) SetTable(tt *TrailTable, xt *TransformTable) {
+	f.tt, f.xt = tt, xt
+}
+
+// 此处初始化所有的依赖
+func (f *TrailRenderFeature) Register(rs *RenderSystem) {
+	// init render
+	for _, r := range rs.RenderList {
+		if br, ok := r.(*BatchRender); ok {
+			f.R = br
+			break
+		}
+	}
+	// init table
+	for _, t := range rs.TableList {
+		switch table := t.(type) {
+		case *TrailTable:
+			f.tt = table
+		case *TransformTable:
+			f.xt = table
+		case *MaskTable:
+			f.mt = table
+		}
+	}
+	// add new feature, use the index as id
+	f.id = rs.Accept(f)
+}
+
+// Update records the world position of entities.
+func (f *TrailRenderFeature) Update(dt float32) {
+	for i := range f.tt.comps[:f.tt.index] {
+		tc := &f.tt.comps[i]
+		if xf := f.xt.Comp(tc.Entity); xf != nil {
+			tc.update(dt, xf.world.Position)
+		}
+	}
+}
+
+func (f *TrailRenderFeature) Extract(v *View) {
+	var (
+		camera = v.Camera
+		fi     = uint32(f.id) << 16
+	)
+	for i, tc := range f.tt.comps[:f.tt.index] {
+		if !tc.visible || tc.tex == nil || len(tc.points) < 2 {
+			continue
+		}
+		if a := tc.bound(); camera.overlap(&a) {
+			head := tc.points[len(tc.points)-1].pos
+			sid := tc.zOrder.sortId(head[1], tc.materialId.value, tc.batchId.value)
+			v.RenderNodes = append(v.RenderNodes, SortObject{sid, fi + uint32(i)})
+		}
+	}
+}
+
+func (f *TrailRenderFeature) Draw(nodes RenderNodes) {
+	var (
+		sortId  = uint64(0xFFFFFFFFFFFFFFFF)
+		stencil = uint32(0)
+		begin   = false
+		render  = f.R
+		obj     = &f.obj
+	)
+	for _, b := range nodes {
+		tc := &f.tt.comps[b.Value&0xFFFF]
+		// split batch on material, texture or mask changes
+		sten := f.mt.Stencil(tc.Entity)
+		if sid := b.SortId & 0xFFFFFFFF; sortId != sid || stencil != sten {
+			if begin {
+				render.End()
+			}
+			sortId, stencil = sid, sten
+			begin = true
+			depth, _ := UnpackSortId(b.SortId)
+			render.SetStencil(stencil)
+			render.BeginMaterial(UnpackMaterial(b.SortId), tc.tex.Tex(), depth)
+		}
+		if obj.build(tc); obj.Size() > 0 {
+			render.Draw(obj)
+		}
+	}
+	if begin {
+		render.End()
+	}
+	render.Flush()
+}
+
+func (f *TrailRenderFeature) Flush() {
+
+}
+
+// trailBatchObject fills the quads of a trail, a quad for each segment or
+// each tile of segment. The vertices are computed in build().
+type trailBatchObject struct {
+	vertex []PosTexColorVertex
+
+	// the edges at each point
+	edges []trailEdge
+}
+
+// an edge across the trail: left and right side, distance from the head,
+// texture coordinate along the trail and color
+type trailEdge struct {
+	l, r  f32.Vec2
+	d, s  float32
+	color Color
+}
+
+func (tbo *trailBatchObject) build(tc *TrailComp) {
+	tbo.vertex = tbo.vertex[:0]
+	pts := tc.points
+	n := len(pts)
+	if n < 2 {
+		return
+	}
+	if cap(tbo.edges) < n {
+		tbo.edges = make([]trailEdge, n)
+	}
+	edges := tbo.edges[:n]
+
+	// distance from the head
+	edges[n-1].d = 0
+	for i := n - 2; i >= 0; i-- {
+		edges[i].d = edges[i+1].d
+		if !pts[i+1].cut {
+			edges[i].d += pts[i+1].pos.Sub(pts[i].pos).Len()
+		}
+	}
+	total := edges[0].d
+	if total <= 0 {
+		return
+	}
+
+	// sides, the normal is the average of adjacent segments
+	for i := range pts {
+		var dir f32.Vec2
+		if i > 0 && !pts[i].cut {
+			dir = dir.Add(pts[i].pos.Sub(pts[i-1].pos).Norm())
+		}
+		if i+1 < n && !pts[i+1].cut {
+			dir = dir.Add(pts[i+1].pos.Sub(pts[i].pos).Norm())
+		}
+		if dir.IsZero() {
+			dir = f32.Vec2{1, 0}
+		}
+		dir = dir.Norm()
+
+		// position on the trail, by age or by length
+		var t float32
+		if tc.duration > 0 {
+			t = math.Clamp(pts[i].age/tc.duration, 0, 1)
+		} else {
+			t = edges[i].d / total
+		}
+		e := &edges[i]
+		w := tc.width.Value(t) / 2
+		normal := f32.Vec2{-dir[1] * w, dir[0] * w}
+		e.l, e.r = pts[i].pos.Add(normal), pts[i].pos.Sub(normal)
+		e.color = tc.gradient.Value(t)
+		e.s = e.d / total
+	}
+
+	tile := tc.uvMode == TrailTile && tc.tile > 0
+	if tile {
+		quads := 0
+		for i := 0; i < n-1; i++ {
+			if !pts[i+1].cut {
+				quads += int(math.Ceil(edges[i].d/tc.tile) - math.Floor(edges[i+1].d/tc.tile))
+			}
+		}
+		tile = quads <= maxTrailQuads
+	}
+
+	rg := tc.tex.Region()
+	for i := 0; i < n-1; i++ {
+		if pts[i+1].cut || edges[i].d == edges[i+1].d {
+			continue
+		}
+		head, tail := edges[i+1], edges[i]
+		if !tile {
+			tbo.quad(&rg, head, tail)
+			continue
+		}
+		// split the segment at the tiles
+		u0, u1 := head.d/tc.tile, tail.d/tc.tile
+		for u := u0; u < u1; {
+			k := math.Floor(u)
+			end := math.Min(k+1, u1)
+			a, b := lerpEdge(head, tail, (u-u0)/(u1-u0)), lerpEdge(head, tail, (end-u0)/(u1-u0))
+			a.s, b.s = u-k, end-k
+			tbo.quad(&rg, a, b)
+			u = end
+		}
+	}
+}
+
+func lerpEdge(a, b trailEdge, t float32) trailEdge {
+	return trailEdge{
+		l:     a.l.Add(b.l.Sub(a.l).Mul(t)),
+		r:     a.r.Add(b.r.Sub(a.r).Mul(t)),
+		d:     a.d + (b.d-a.d)*t,
+		color: lerpColor(a.color, b.color, t),
+	}
+}
+
+// quad appends a quad from edge a to edge b, the winding order is the same as
+// sprite: the right side is the bottom.
+func (tbo *trailBatchObject) quad(rg *Region, a, b trailEdge) {
+	// texture, (s, t) is the normalized coordinate from the bottom-left corner
+	uv := func(s, t float32) (u, v float32) {
+		if rg.Rotated {
+			return rg.X1 + t*(rg.X2-rg.X1), rg.Y1 + s*(rg.Y2-rg.Y1)
+		}
+		return rg.X1 + s*(rg.X2-rg.X1), rg.Y2 + t*(rg.Y1-rg.Y2)
+	}
+	var q [4]PosTexColorVertex
+	q[0].X, q[0].Y = a.r[0], a.r[1]
+	q[1].X, q[1].Y = b.r[0], b.r[1]
+	q[2].X, q[2].Y = b.l[0], b.l[1]
+	q[3].X, q[3].Y = a.l[0], a.l[1]
+	q[0].U, q[0].V = uv(a.s, 0)
+	q[1].U, q[1].V = uv(b.s, 0)
+	q[2].U, q[2].V = uv(b.s, 1)
+	q[3].U, q[3].V = uv(a.s, 1)
+	q[0].RGBA, q[3].RGBA = a.color.U32(), a.color.U32()
+	q[1].RGBA, q[2].RGBA = b.color.U32(), b.color.U32()
+	tbo.vertex = append(tbo.vertex, q[:]...)
+}
+
+func (tbo *trailBatchObject) Size() int {
+	return len(tbo.vertex)
+}
+
+func (tbo *trailBatchObject) Fill(buf []PosTexColorVertex) {
+	copy(buf, tbo.vertex)
+}
@@ -0,0 +1,105 @@
+package gfx
+
+import (
+	"testing"
+
+	"sckorok/engi"
+	"sckorok/math"
+	"sckorok/math/f32"
+)
+
+func TestTrailUpdate(t *testing.T) {
+	em := engi.NewEntityManager()
+	tt := NewTrailTable(4)
+	tc := tt.NewComp(em.New())
+	tc.SetTime(1)
+	tc.SetMinDistance(10)
+
+	// moves 5 pixels a frame, records every 2 frames
+	for i := 0; i <= 8; i++ {
+		tc.update(.125, f32.Vec2{float32(i * 5), 0})
+	}
+	if n := len(tc.points); n != 5 {
+		t.Fatal("points should be recorded by the min distance:", n, tc.points)
+	}
+	if head := tc.points[len(tc.points)-1]; head.pos[0] != 40 || head.age != 0 {
+		t.Error("head should follow the entity:", head)
+	}
+
+	// stops, the tail shrinks to the head
+	for _, dt := range []float32{.125, .125, .125, .0625} {
+		tc.update(dt, f32.Vec2{40, 0})
+	}
+	if tail := tc.points[0]; tail.age != 1 || tail.pos[0] != 17.5 {
+		t.Error("tail should be moved to the position at the time:", tail)
+	}
+	for i := 0; i < 8; i++ {
+		tc.update(.125, f32.Vec2{40, 0})
+	}
+	if len(tc.points) != 2 || tc.points[0].pos != tc.points[1].pos {
+		t.Error("trail should shrink to the head:", tc.points)
+	}
+
+	// paused trail fades out, resumed trail starts a new strip
+	tc.SetEmitting(false)
+	for i := 0; i < 9; i++ {
+		tc.update(.125, f32.Vec2{0, 0})
+	}
+	if len(tc.points) != 0 {
+		t.Error("paused trail should fade out:", tc.points)
+	}
+	tc.SetEmitting(true)
+	tc.update(.125, f32.Vec2{100, 0})
+	if len(tc.points) != 1 || !tc.points[0].cut {
+		t.Error("resumed trail should start a new strip:", tc.points)
+	}
+}
+
+func TestTrailLength(t *testing.T) {
+	tc := &TrailComp{emitting: true}
+	tc.SetLength(25)
+	for i := 0; i <= 10; i++ {
+		tc.update(.1, f32.Vec2{0, float32(i * 10)})
+	}
+	if tail := tc.points[0]; tail.pos[1] != 75 {
+		t.Error("tail should be cut at the length:", tail)
+	}
+}
+
+func TestTrailBuild(t *testing.T) {
+	tc := &TrailComp{tex: packedTex{}}
+	tc.width = TrailCurve{Head: 10, Tail: 10}
+	tc.gradient = TrailGradient{Head: White, Tail: Transparent}
+	tc.points = []trailPoint{
+		{pos: f32.Vec2{0, 0}, cut: true},
+		{pos: f32.Vec2{30, 0}},
+		{pos: f32.Vec2{50, 0}, cut: true},
+		{pos: f32.Vec2{70, 0}},
+	}
+
+	obj := &trailBatchObject{}
+	obj.build(tc)
+	if obj.Size() != 8 {
+		t.Fatal("a quad for each connected segment:", obj.Size())
+	}
+	q := obj.vertex[0:4]
+	if q[1].X != 0 || q[1].Y != -5 || q[2].Y != 5 {
+		t.Error("tail quad error:", q)
+	}
+	if q[1].U != 1 || q[1].RGBA != 0 {
+		t.Error("tail should be at the end of texture with the tail color:", q[1])
+	}
+
+	// tiles of 16 pixels: 30 pixels in 3 tiles and 20 pixels in 2 tiles
+	tc.SetUVMode(TrailTile, 16)
+	obj.build(tc)
+	if obj.Size() != 5*4 {
+		t.Fatal("segments should be split at the tiles:", obj.Size())
+	}
+	if q := obj.vertex[0:4]; q[0].X != 30 || q[0].U != .25 || q[1].X != 18 || q[1].U != 1 {
+		t.Error("tile error:", q)
+	}
+	if q := obj.vertex[16:20]; math.ABS(q[0].X-54) > 1e-3 || q[0].U != 0 || q[1].X != 50 || math.ABS(q[1].U-.25) > 1e-3 {
+		t.Error("tile error:", q)
+	}
+}
@@ -89,6 +89,9 @@ type DrawList struct {
 	Font            font.Font
 	FontSize        float32
 
+	// parses the text of AddText as markup if it's not nil, see font.ParseMarkup
+	Markup *font.MarkupStyle
+
 	Flags  DrawListFlags
 	ZOrder int16
 }
@@ -689,7 +692,9 @@ func (dl *DrawList) AddText(pos f32.Vec2, text string, font font.Font, fontSize
 		color:    color,
 	}
 
-	if wrapWidth > 0 {
+	if dl.Markup != nil {
+		size = fr.RenderMarkup(pos, text, dl.Markup, wrapWidth)
+	} else if wrapWidth > 0 {
 		size = fr.RenderWrapped(pos, text, wrapWidth)
 	} else {
 		size = fr.RenderText(pos, text)
@@ -697,6 +702,27 @@ func (dl *DrawList) AddText(pos f32.Vec2, text string, font font.Font, fontSize
 	return
 }
 
+// AddRichText draws the rich text, rt should be updated every frame to animate
+// the text, see font.RichText.
+func (dl *DrawList) AddRichText(pos f32.Vec2, rt *font.RichText, font font.Font, fontSize float32, color uint32, wrapWidth float32) (size f32.Vec2) {
+	if rt == nil || len(rt.Runs) == 0 {
+		return
+	}
+	if font == nil {
+		font = dl.Font
+	}
+	if fontSize == 0 {
+		fontSize = dl.FontSize
+	}
+	fr := &FontRender{
+		DrawList: dl,
+		fontSize: fontSize,
+		font:     font,
+		color:    color,
+	}
+	return fr.RenderRuns(pos, rt.Runs, rt, wrapWidth)
+}
+
 // 每次绘制都会产生一个 Command （可能会造成内存浪费! 1k cmd = 1000 * 6 * 4 = 24k）
 // 为了减少内存可以一边添加一边尝试向前合并
 func (dl *DrawList) AddCommand(elemCount int) {
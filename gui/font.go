@@ -4,72 +4,93 @@ import (
 	"sckorok/gfx/font"
 	"sckorok/math"
 	"sckorok/math/f32"
-	"unicode/utf8"
 )
 
 // 工具结构，负责把字符串转化为顶点..
 // 拥有所有需要的条件属性
 type FontRender struct {
 	*DrawList
-	fontSize  float32
-	font      font.Font
-	color     uint32
-	lineSpace float32
+	fontSize float32
+	font     font.Font
+	color    uint32
 }
 
+// RenderText draws the text with font.Layout, so the size is the same as
+// font.Measure and Context.CalcTextSize.
 // 当前的实现中，不考虑裁切优化，全部绘制所有字符
 func (fr *FontRender) RenderText(pos f32.Vec2, text string) (size f32.Vec2) {
-	dx, dy := pos[0], pos[1]
-	maxWidth := float32(0)
-
-	idxCount := len(text) * 6
-	vtxCount := len(text) * 4
-	fr.DrawList.PrimReserve(idxCount, vtxCount)
-
-	vtxWriter := fr.DrawList.VtxWriter
-	idxWriter := fr.DrawList.IdxWriter
-
-	color := fr.color
-	_, gh := fr.font.Bounds()
-	scale := fr.fontSize / gh
-	lineHeight := fr.fontSize
-
-	lineSpace := fr.lineSpace
-	if lineSpace == 0 {
-		lineSpace = 0.4 * lineHeight
-	}
+	return fr.RenderRuns(pos, []font.Run{{Text: text}}, nil, 0)
+}
 
-	bufferUsed := 0
+func (fr *FontRender) RenderWrapped(pos f32.Vec2, text string, wrapWidth float32) (size f32.Vec2) {
+	return fr.RenderRuns(pos, []font.Run{{Text: text}}, nil, wrapWidth)
+}
 
-	for i, w := 0, 0; i < len(text); i += w {
-		r, width := utf8.DecodeRuneInString(text[i:])
-		w = width
+// RenderMarkup parses the markup and draws it, the text isn't animated.
+func (fr *FontRender) RenderMarkup(pos f32.Vec2, markup string, ms *font.MarkupStyle, wrapWidth float32) (size f32.Vec2) {
+	return fr.RenderRuns(pos, font.ParseMarkup(markup, ms), nil, wrapWidth)
+}
 
-		if r == '\n' {
-			if dx > maxWidth {
-				maxWidth = dx
+// RenderRuns draws the styled runs, the runs are animated by rt if it's not nil.
+func (fr *FontRender) RenderRuns(pos f32.Vec2, runs []font.Run, rt *font.RichText, wrapWidth float32) (size f32.Vec2) {
+	lc := font.LayoutConfig{Size: fr.fontSize, WrapWidth: math.Max(wrapWidth, 0)}
+	glyphs, w, h := font.LayoutRuns(fr.font, runs, lc, runsBuffer[:0])
+	runsBuffer = glyphs
+
+	n := len(glyphs)
+	dl := fr.DrawList
+	dl.PrimReserve(n*6, n*4)
+	vtxWriter, idxWriter := dl.VtxWriter, dl.IdxWriter
+
+	// a command is added when the texture changes, the texture on the top
+	// of stack is replaced and restored at the end
+	dl.PushTextureId(dl.CurrentTextureId())
+	top, runStart := len(dl.TextureIdStack)-1, 0
+
+	for i := range glyphs {
+		g := &glyphs[i]
+		var (
+			tex            uint16
+			u1, v1, u2, v2 float32
+		)
+		if g.Font != nil {
+			tex, _ = g.Font.Tex2D()
+			if paged, ok := g.Font.(font.Paged); ok {
+				tex, _ = paged.Page(int(g.Page))
 			}
-
-			dy -= lineHeight + lineSpace
-			dx = pos[0]
-			continue
+			u1, v1, u2, v2 = g.Font.Frame(g.Rune)
+		} else {
+			icon := runs[g.Run].Icon
+			tex = icon.Tex
+			u1, v1, u2, v2 = icon.Region[0], icon.Region[1], icon.Region[2], icon.Region[3]
+		}
+		if dl.TextureIdStack[top] != tex {
+			if i > runStart {
+				dl.AddCommand((i - runStart) * 6)
+			}
+			dl.TextureIdStack[top] = tex
+			runStart = i
 		}
 
-		g, _ := fr.font.Glyph(r)
-
-		// Add kerning todo
-		// dx += getKerning(preglyph, g)
-		x1, y1 := dx, dy-(g.Height+g.YOffset)*scale
-		x2, y2 := x1+g.Width*scale, dy-(g.YOffset)*scale
-		u1, v1, u2, v2 := fr.font.Frame(r)
+		color := fr.color
+		if c := runs[g.Run].Color; c != 0 {
+			color = c
+		}
+		x1, y2 := pos[0]+g.X+g.XOffset*g.Scale, pos[1]-g.Y-g.YOffset*g.Scale
+		if rt != nil {
+			dx, dy := rt.Offset(g.Run, g.Index)
+			x1, y2 = x1+dx, y2+dy
+			color = rt.Fade(g.Index, color)
+		}
+		x2, y1 := x1+g.Width*g.Scale, y2-g.Height*g.Scale
 
-		vi := bufferUsed * 4
+		vi := i * 4
 		vtxWriter[vi+0] = DrawVert{f32.Vec2{x1, y1}, f32.Vec2{u1, v2}, color}
 		vtxWriter[vi+1] = DrawVert{f32.Vec2{x2, y1}, f32.Vec2{u2, v2}, color}
 		vtxWriter[vi+2] = DrawVert{f32.Vec2{x2, y2}, f32.Vec2{u2, v1}, color}
 		vtxWriter[vi+3] = DrawVert{f32.Vec2{x1, y2}, f32.Vec2{u1, v1}, color}
 
-		ii, offset := bufferUsed*6, fr.DrawList.vtxIndex
+		ii, offset := i*6, dl.vtxIndex
 		idxWriter[ii+0] = DrawIdx(offset + 0)
 		idxWriter[ii+1] = DrawIdx(offset + 1)
 		idxWriter[ii+2] = DrawIdx(offset + 2)
@@ -77,31 +98,15 @@ func (fr *FontRender) RenderText(pos f32.Vec2, text string) (size f32.Vec2) {
 		idxWriter[ii+4] = DrawIdx(offset + 2)
 		idxWriter[ii+5] = DrawIdx(offset + 3)
 
-		fr.DrawList.idxIndex += 6
-		fr.DrawList.vtxIndex += 4
-
-		dx += float32(g.Advance) * scale
-		bufferUsed++
-		// character space & line spacing todo
-		// 处理 < 32 的控制符号!!
+		dl.idxIndex += 6
+		dl.vtxIndex += 4
 	}
-
-	if dx > maxWidth {
-		maxWidth = dx
+	if n > runStart {
+		dl.AddCommand((n - runStart) * 6)
 	}
-	size[0] = maxWidth - pos[0]
-	size[1] = pos[1] - dy + fr.fontSize
-
-	fr.DrawList.AddCommand(bufferUsed * 6)
-	return
+	dl.PopTextureId()
+	return f32.Vec2{w, h}
 }
 
-func (fr *FontRender) RenderWrapped(pos f32.Vec2, text string, wrapWidth float32) (size f32.Vec2) {
-	wrap := math.Max(wrapWidth, 0)
-	// wrap text
-	_, lines := font.Wrap(fr.font, text, wrap, fr.fontSize)
-	//log.Println(lines)
-
-	size = fr.RenderText(pos, lines)
-	return
-}
+// shared by all the rich texts
+var runsBuffer []font.Positioned
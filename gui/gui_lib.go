@@ -475,7 +475,8 @@ func (ctx *Context) DrawText(bb *Rect, text string, style *TextStyle) (size f32.
 }
 
 func (ctx *Context) CalcTextSize(text string, wrapWidth float32, fnt font.Font, fontSize float32) f32.Vec2 {
-	return font.CalculateTextSize(text, fnt, fontSize)
+	w, h := font.Measure(fnt, text, font.LayoutConfig{Size: fontSize, WrapWidth: wrapWidth})
+	return f32.Vec2{w, h}
 }
 
 // 偷师 flat-ui 中的设计，把空间的前景和背景分离，背景单独根据事件来变化..
@@ -52,6 +52,30 @@ func ColorMask(r, g, b, a bool) {
 	gl.ColorMask(r, g, b, a)
 }
 
+func StencilFunc(fn uint32, ref int32, mask uint32) {
+	gl.StencilFunc(fn, ref, mask)
+}
+
+func StencilOp(fail, zfail, zpass uint32) {
+	gl.StencilOp(fail, zfail, zpass)
+}
+
+func StencilMask(mask uint32) {
+	gl.StencilMask(mask)
+}
+
+func ClearStencil(s int32) {
+	gl.ClearStencil(s)
+}
+
+func GetIntegerv(pname uint32, data *int32) {
+	gl.GetIntegerv(pname, data)
+}
+
+func ReadPixels(x, y, width, height int32, format, xtype uint32, pixels unsafe.Pointer) {
+	gl.ReadPixels(x, y, width, height, format, xtype, pixels)
+}
+
 func BlendFunc(src, dst uint32) {
 	gl.BlendFunc(src, dst)
 }
@@ -262,3 +286,29 @@ func GenTextures(n int32, textures *uint32) {
 func TexParameteri(texture uint32, pname uint32, params int32) {
 	gl.TexParameteri(texture, pname, params)
 }
+
+func GenerateMipmap(target uint32) {
+	gl.GenerateMipmap(target)
+}
+
+// framebuffer
+
+func GenFramebuffers(n int32, framebuffers *uint32) {
+	gl.GenFramebuffers(n, framebuffers)
+}
+
+func BindFramebuffer(target uint32, framebuffer uint32) {
+	gl.BindFramebuffer(target, framebuffer)
+}
+
+func FramebufferTexture2D(target, attachment, texTarget uint32, texture uint32, level int32) {
+	gl.FramebufferTexture2D(target, attachment, texTarget, texture, level)
+}
+
+func CheckFramebufferStatus(target uint32) uint32 {
+	return gl.CheckFramebufferStatus(target)
+}
+
+func DeleteFramebuffers(n int32, framebuffers *uint32) {
+	gl.DeleteFramebuffers(n, framebuffers)
+}
@@ -63,6 +63,39 @@ func ColorMask(r, g, b, a bool) {
 	glc.ColorMask(r, g, b, a)
 }
 
+func StencilFunc(fn uint32, ref int32, mask uint32) {
+	glc.StencilFunc(gl.Enum(fn), int(ref), mask)
+}
+
+func StencilOp(fail, zfail, zpass uint32) {
+	glc.StencilOp(gl.Enum(fail), gl.Enum(zfail), gl.Enum(zpass))
+}
+
+func StencilMask(mask uint32) {
+	glc.StencilMask(mask)
+}
+
+func ClearStencil(s int32) {
+	glc.ClearStencil(int(s))
+}
+
+// only VIEWPORT returns multiple values
+func integerCount(pname uint32) int {
+	if pname == VIEWPORT {
+		return 4
+	}
+	return 1
+}
+
+func GetIntegerv(pname uint32, data *int32) {
+	glc.GetIntegerv(((*[4]int32)(unsafe.Pointer(data)))[:integerCount(pname)], gl.Enum(pname))
+}
+
+// only RGBA/UNSIGNED_BYTE is used
+func ReadPixels(x, y, width, height int32, format, xtype uint32, pixels unsafe.Pointer) {
+	glc.ReadPixels(((*[1 << 28]byte)(pixels))[:width*height*4], int(x), int(y), int(width), int(height), gl.Enum(format), gl.Enum(xtype))
+}
+
 func BlendFunc(src, dst uint32) {
 	glc.BlendFunc(gl.Enum(src), gl.Enum(dst))
 }
@@ -270,3 +303,30 @@ func GenTextures(n int32, textures *uint32) {
 func TexParameteri(texture uint32, pname uint32, params int32) {
 	glc.TexParameteri(gl.Enum(texture), gl.Enum(pname), int(params))
 }
+
+func GenerateMipmap(target uint32) {
+	glc.GenerateMipmap(gl.Enum(target))
+}
+
+// framebuffer
+
+func GenFramebuffers(n int32, framebuffers *uint32) {
+	fb := glc.CreateFramebuffer()
+	*framebuffers = fb.Value
+}
+
+func BindFramebuffer(target uint32, framebuffer uint32) {
+	glc.BindFramebuffer(gl.Enum(target), gl.Framebuffer{framebuffer})
+}
+
+func FramebufferTexture2D(target, attachment, texTarget uint32, texture uint32, level int32) {
+	glc.FramebufferTexture2D(gl.Enum(target), gl.Enum(attachment), gl.Enum(texTarget), gl.Texture{texture}, int(level))
+}
+
+func CheckFramebufferStatus(target uint32) uint32 {
+	return uint32(glc.CheckFramebufferStatus(gl.Enum(target)))
+}
+
+func DeleteFramebuffers(n int32, framebuffers *uint32) {
+	glc.DeleteFramebuffer(gl.Framebuffer{*framebuffers})
+}
@@ -23,6 +23,9 @@ var (
 	locationCount int32
 	textureMap    = make(map[uint32]js.Value)
 	textureCount  uint32
+
+	framebufferMap   = make(map[uint32]js.Value)
+	framebufferCount uint32
 )
 
 type Slice struct {
@@ -94,6 +97,47 @@ func ColorMask(r, g, b, a bool) {
 	gl.ColorMask(r, g, b, a)
 }
 
+func StencilFunc(fn uint32, ref int32, mask uint32) {
+	gl.StencilFunc(int(fn), int(ref), int(mask))
+}
+
+func StencilOp(fail, zfail, zpass uint32) {
+	gl.StencilOp(int(fail), int(zfail), int(zpass))
+}
+
+func StencilMask(mask uint32) {
+	gl.StencilMask(int(mask))
+}
+
+func ClearStencil(s int32) {
+	gl.ClearStencil(int(s))
+}
+
+func GetIntegerv(pname uint32, data *int32) {
+	v := gl.GetParameter(int(pname))
+	if v.IsNull() {
+		// the default framebuffer
+		*data = 0
+		return
+	}
+	if v.Type() != js.TypeObject {
+		*data = int32(v.Int())
+		return
+	}
+	dst := (*[4]int32)(unsafe.Pointer(data))
+	for i := 0; i < v.Length() && i < 4; i++ {
+		dst[i] = int32(v.Index(i).Int())
+	}
+}
+
+// only RGBA/UNSIGNED_BYTE is used
+func ReadPixels(x, y, width, height int32, format, xtype uint32, pixels unsafe.Pointer) {
+	size := int(width * height * 4)
+	ta := js.Global().Get("Uint8Array").New(size)
+	gl.ReadPixels(int(x), int(y), int(width), int(height), int(format), int(xtype), ta)
+	js.CopyBytesToGo(((*[1 << 28]byte)(pixels))[:size], ta)
+}
+
 func BlendFunc(src, dst uint32) {
 	gl.BlendFunc(int(src), int(dst))
 }
@@ -423,3 +467,41 @@ func GenTextures(n int32, textures *uint32) {
 func TexParameteri(texture uint32, pname uint32, params int32) {
 	gl.TexParameteri(int(texture), int(pname), int(params))
 }
+
+func GenerateMipmap(target uint32) {
+	gl.GenerateMipmap(int(target))
+}
+
+// framebuffer, 0 is the default framebuffer(null in WebGL)
+
+func GenFramebuffers(n int32, framebuffers *uint32) {
+	x := gl.CreateFramebuffer()
+	if x == js.Null() {
+		*framebuffers = 0
+		return
+	}
+	framebufferCount++
+	framebufferMap[framebufferCount] = x
+	*framebuffers = framebufferCount
+}
+
+func BindFramebuffer(target uint32, framebuffer uint32) {
+	if framebuffer == 0 {
+		gl.BindFramebuffer(int(target), js.Null())
+		return
+	}
+	gl.BindFramebuffer(int(target), framebufferMap[framebuffer])
+}
+
+func FramebufferTexture2D(target, attachment, texTarget uint32, texture uint32, level int32) {
+	gl.FramebufferTexture2D(int(target), int(attachment), int(texTarget), textureMap[texture], int(level))
+}
+
+func CheckFramebufferStatus(target uint32) uint32 {
+	return uint32(gl.CheckFramebufferStatus(int(target)))
+}
+
+func DeleteFramebuffers(n int32, framebuffers *uint32) {
+	gl.DeleteFramebuffer(framebufferMap[*framebuffers])
+	delete(framebufferMap, *framebuffers)
+}
@@ -45,6 +45,8 @@ func CreateWindow(option *WindowOptions) {
 	glfw.WindowHint(glfw.ContextVersionMinor, 2)
 	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
 	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
+	// stencil-buffer for masks
+	glfw.WindowHint(glfw.StencilBits, 8)
 
 	// window resizable hint
 	if option.Resizable {
@@ -189,7 +191,7 @@ func CreateWindow(option *WindowOptions) {
 	// 如果窗口没有关闭，那么应该持续当前的循环
 	// main loop...
 	for !window.ShouldClose() {
-		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT | gl.STENCIL_BUFFER_BIT)
 
 		windowCallback.OnLoop()
 
@@ -127,7 +127,7 @@ func onResize(e size.Event) {
 }
 
 func onPaint(e paint.Event, sz size.Event) {
-	gl.Clear(gl.COLOR_BUFFER_BIT)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.STENCIL_BUFFER_BIT)
 	windowCallback.OnLoop()
 }
 
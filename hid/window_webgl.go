@@ -191,7 +191,7 @@ func CreateWindow(option *WindowOptions) {
 
 		go func() {
 			// runtime.GC()
-			gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+			gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT | gl.STENCIL_BUFFER_BIT)
 			windowCallback.OnLoop()
 			// window.SwapBuffers()
 			js.Global().Call("requestAnimationFrame", renderFrame)
@@ -40,6 +40,8 @@ func Run(options *Options, sc game.Scene) {
 			Transform = t
 		case *gfx.TextTable:
 			Text = t
+		case *gfx.TileMapTable:
+			TileMap = t
 		case *effect.ParticleSystemTable:
 			ParticleSystem = t
 		case *game.TagTable:
@@ -75,6 +77,7 @@ var Sprite *gfx.SpriteTable
 var Mesh *gfx.MeshTable
 var Transform *gfx.TransformTable
 var Text *gfx.TextTable
+var TileMap *gfx.TileMapTable
 
 // animation system
 var Flipbook *frame.FlipbookTable
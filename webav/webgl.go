@@ -49,7 +49,7 @@ func mb2mi(x map[string]bool) map[string]interface{} {
 
 // Returns a copy of the default WebGL context attributes.
 func DefaultAttributes() *ContextAttributes {
-	return &ContextAttributes{true, true, false, true, true, false}
+	return &ContextAttributes{true, true, true, true, true, false}
 }
 
 type Context struct {
@@ -492,6 +492,21 @@ func (c *Context) ClearStencil(s int) {
 	c.Call("clearStencil", s)
 }
 
+// Sets the function and reference value for stencil testing.
+func (c *Context) StencilFunc(fun, ref, mask int) {
+	c.Call("stencilFunc", fun, ref, mask)
+}
+
+// Sets the actions of the stencil test.
+func (c *Context) StencilOp(fail, zfail, zpass int) {
+	c.Call("stencilOp", fail, zfail, zpass)
+}
+
+// Controls enabling and disabling of front and back writing of individual bits in the stencil planes.
+func (c *Context) StencilMask(mask int) {
+	c.Call("stencilMask", mask)
+}
+
 // Lets you set whether individual colors can be written when
 // drawing or rendering to a framebuffer.
 func (c *Context) ColorMask(r, g, b, a bool) {